@@ -0,0 +1,290 @@
+package alert
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/ingest"
+	schema "GOLANG_SERVER/components/schema"
+
+	"github.com/google/uuid"
+)
+
+const (
+	reloadInterval = time.Minute      // Rules changed by another server are picked up this often
+	rateWindow     = time.Minute      // Samples a rate is measured over
+	rateMinSpan    = 10 * time.Second // Shortest span of samples a rate is computed from
+)
+
+// Event is a rule firing or resolving on a device, pushed to the WebSocket clients
+type Event struct {
+	Event string `json:"event"` // Always "alert"
+	schema.Alert
+}
+
+// sample is a value of the field of a rate rule
+type sample struct {
+	at    time.Time
+	value float64
+}
+
+// state is the evaluation of one rule on one device
+type state struct {
+	ruleID       string
+	deviceID     string
+	pendingSince time.Time     // First breach not yet long enough to fire
+	clearSince   time.Time     // First clear value while firing, not yet long enough to resolve
+	alert        *schema.Alert // Firing alert, nil when the rule is not firing
+	samples      []sample      // Recent values of a rate rule
+}
+
+var (
+	engine = struct {
+		sync.Mutex
+		rules  map[string][]schema.AlertRule // key: userID of the owner of the devices the rules watch
+		states map[string]*state             // key: ruleID + "/" + deviceID
+	}{rules: make(map[string][]schema.AlertRule), states: make(map[string]*state)}
+	listeners = struct {
+		sync.RWMutex
+		list []func(Event)
+	}{}
+)
+
+// OnChange registers fn to be called when an alert fires or resolves
+func OnChange(fn func(Event)) {
+	listeners.Lock()
+	listeners.list = append(listeners.list, fn)
+	listeners.Unlock()
+}
+
+func emit(alert schema.Alert) {
+	listeners.RLock()
+	defer listeners.RUnlock()
+	for _, fn := range listeners.list {
+		fn(Event{Event: "alert", Alert: alert})
+	}
+}
+
+// Start loads the firing alerts and the enabled rules and evaluates them on every ingested message
+func Start() {
+	if err := restore(time.Now()); err != nil {
+		log.Println("Error loading firing alerts:", err)
+	}
+	if err := Reload(); err != nil {
+		log.Println("Error loading alert rules:", err)
+	}
+	// Debounce and rates need every sample, so the engine does not drop
+	ingest.Subscribe("alerts", 1024, ingest.Block, func(msg ingest.Message) {
+		Evaluate(msg.Data, msg.Received)
+	})
+	go reloadLoop()
+}
+
+func reloadLoop() {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := Reload(); err != nil {
+			log.Println("Error loading alert rules:", err)
+		}
+	}
+}
+
+// restore takes over the alerts left firing by a previous run, so they resolve instead of firing again.
+// Only the newest alert of a rule on a device is kept, older copies are resolved at now.
+func restore(now time.Time) error {
+	alerts, err := db.FiringAlerts()
+	if err != nil {
+		return err
+	}
+
+	var resolved []schema.Alert
+	engine.Lock()
+	for i := range alerts {
+		key := alerts[i].RuleID + "/" + alerts[i].DeviceID
+		s, ok := engine.states[key]
+		if !ok {
+			s = &state{ruleID: alerts[i].RuleID, deviceID: alerts[i].DeviceID}
+			engine.states[key] = s
+		}
+		if s.alert != nil {
+			resolved = append(resolved, resolve(s, now))
+		}
+		s.alert = &alerts[i]
+	}
+	engine.Unlock()
+
+	for _, alert := range resolved {
+		saveResolved(alert)
+	}
+	return nil
+}
+
+// Reload reads the enabled rules again, alerts of rules deleted or disabled are resolved
+func Reload() error {
+	rules, err := db.EnabledAlertRules()
+	if err != nil {
+		return err
+	}
+	byUser := make(map[string][]schema.AlertRule)
+	byID := make(map[string]schema.AlertRule)
+	for _, rule := range rules {
+		owner := rule.UserID
+		if rule.DeviceID != "" {
+			// A rule on a device shared with the user watches the data of its owner, rules on devices
+			// the user lost access to are skipped
+			device, _, err := db.DeviceAccess(rule.UserID, rule.DeviceID)
+			if err == db.ErrNotDeviceOwner {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			owner = device.UserID
+		}
+		byUser[owner] = append(byUser[owner], rule)
+		byID[rule.ID] = rule
+	}
+
+	var resolved []schema.Alert
+	now := time.Now()
+
+	engine.Lock()
+	engine.rules = byUser
+	for key, s := range engine.states {
+		rule, ok := byID[s.ruleID]
+		if ok && (rule.DeviceID == "" || rule.DeviceID == s.deviceID) {
+			continue
+		}
+		if s.alert != nil {
+			resolved = append(resolved, resolve(s, now))
+		}
+		delete(engine.states, key)
+	}
+	engine.Unlock()
+
+	for _, alert := range resolved {
+		saveResolved(alert)
+	}
+	return nil
+}
+
+// Evaluate checks the rules on the device of the data against it, received at at
+func Evaluate(data schema.GyroData, at time.Time) {
+	var fired, resolved []schema.Alert
+
+	engine.Lock()
+	for _, rule := range engine.rules[data.UserID] {
+		if rule.DeviceID != "" && rule.DeviceID != data.DeviceID {
+			continue
+		}
+		key := rule.ID + "/" + data.DeviceID
+		s, ok := engine.states[key]
+		if !ok {
+			s = &state{ruleID: rule.ID, deviceID: data.DeviceID}
+			engine.states[key] = s
+		}
+
+		value, ok := measure(rule, s, data, at)
+		if !ok {
+			continue
+		}
+		hold := time.Duration(rule.For) * time.Second
+
+		if s.alert == nil {
+			if !breached(rule, value) {
+				s.pendingSince = time.Time{}
+				continue
+			}
+			if s.pendingSince.IsZero() {
+				s.pendingSince = at
+			}
+			if at.Sub(s.pendingSince) >= hold {
+				s.pendingSince = time.Time{}
+				s.alert = &schema.Alert{
+					ID:       uuid.New().String(),
+					RuleID:   rule.ID,
+					RuleName: rule.Name,
+					UserID:   rule.UserID,
+					DeviceID: data.DeviceID,
+					Field:    rule.Field,
+					Severity: rule.Severity,
+					Zone:     data.Zone,
+					Status:   db.AlertFiring,
+					Value:    value,
+					Message:  fmt.Sprintf("%s (%s)", Describe(rule), formatValue(value)),
+					FiredAt:  at,
+				}
+				fired = append(fired, *s.alert)
+			}
+			continue
+		}
+
+		if !cleared(rule, value) {
+			s.clearSince = time.Time{}
+			continue
+		}
+		if s.clearSince.IsZero() {
+			s.clearSince = at
+		}
+		if at.Sub(s.clearSince) >= hold {
+			resolved = append(resolved, resolve(s, at))
+		}
+	}
+	engine.Unlock()
+
+	for _, alert := range fired {
+		if err := db.InsertAlert(alert); err != nil {
+			log.Println("Error saving alert:", err)
+		}
+		emit(alert)
+	}
+	for _, alert := range resolved {
+		saveResolved(alert)
+	}
+}
+
+// measure returns the value the rule compares, the change per minute for a rate rule.
+// A rate rule has no value until its samples span rateMinSpan.
+func measure(rule schema.AlertRule, s *state, data schema.GyroData, at time.Time) (float64, bool) {
+	value := fields[rule.Field](data)
+	if rule.Kind != KindRate {
+		return value, true
+	}
+
+	s.samples = append(s.samples, sample{at: at, value: value})
+	cutoff := at.Add(-rateWindow)
+	first := 0
+	for first < len(s.samples)-1 && s.samples[first].at.Before(cutoff) {
+		first++
+	}
+	s.samples = s.samples[first:]
+
+	oldest := s.samples[0]
+	span := at.Sub(oldest.at)
+	if span < rateMinSpan {
+		return 0, false
+	}
+	return (value - oldest.value) / span.Minutes(), true
+}
+
+// resolve clears the firing alert of a state, the caller must hold the lock
+func resolve(s *state, at time.Time) schema.Alert {
+	alert := *s.alert
+	alert.Status = db.AlertResolved
+	alert.ResolvedAt = &at
+	s.alert = nil
+	s.clearSince = time.Time{}
+	return alert
+}
+
+func saveResolved(alert schema.Alert) {
+	if err := db.ResolveAlert(alert.ID, *alert.ResolvedAt); err != nil {
+		log.Println("Error resolving alert:", err)
+	}
+	emit(alert)
+}
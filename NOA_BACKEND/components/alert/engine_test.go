@@ -0,0 +1,215 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	schema "GOLANG_SERVER/components/schema"
+)
+
+var t0 = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+// newEngine empties the engine and the store, and loads the rule
+func newEngine(t *testing.T, rule schema.AlertRule) schema.AlertRule {
+	t.Helper()
+	db.UseStore(db.NewMemoryStore())
+	engine.Lock()
+	engine.rules = make(map[string][]schema.AlertRule)
+	engine.states = make(map[string]*state)
+	engine.Unlock()
+
+	rule.UserID = "userA"
+	rule.Enabled = true
+	if err := Validate(&rule); err != nil {
+		t.Fatal(err)
+	}
+	rule, err := db.CreateAlertRule(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	return rule
+}
+
+// velocity evaluates a message of deviceA with the velocity, seconds after t0
+func velocity(seconds int, value float64) {
+	Evaluate(schema.GyroData{UserID: "userA", DeviceID: "deviceA", Velocity: value}, t0.Add(time.Duration(seconds)*time.Second))
+}
+
+func alerts(t *testing.T) []schema.Alert {
+	t.Helper()
+	alerts, err := db.Alerts("userA", db.AlertQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alerts
+}
+
+// expect checks the status of every alert, newest first
+func expect(t *testing.T, statuses ...string) []schema.Alert {
+	t.Helper()
+	got := alerts(t)
+	if len(got) != len(statuses) {
+		t.Fatalf("%d alerts, want %d: %+v", len(got), len(statuses), got)
+	}
+	for i, status := range statuses {
+		if got[i].Status != status {
+			t.Fatalf("alert %d is %s, want %s: %+v", i, got[i].Status, status, got[i])
+		}
+	}
+	return got
+}
+
+func TestThresholdDebounce(t *testing.T) {
+	newEngine(t, schema.AlertRule{Field: "velocity", Operator: ">", Threshold: 5, For: 10})
+
+	// A breach shorter than For does not fire, and a clear value starts it over
+	velocity(0, 6)
+	velocity(9, 6)
+	velocity(10, 4)
+	velocity(15, 6)
+	velocity(24, 6)
+	expect(t)
+
+	velocity(25, 7)
+	fired := expect(t, db.AlertFiring)
+	if !fired[0].FiredAt.Equal(t0.Add(25*time.Second)) || fired[0].Value != 7 {
+		t.Fatalf("fired %+v", fired[0])
+	}
+
+	// Resolving needs For seconds of clear values too
+	velocity(30, 4)
+	velocity(39, 4)
+	expect(t, db.AlertFiring)
+	velocity(40, 4)
+	resolved := expect(t, db.AlertResolved)
+	if !resolved[0].ResolvedAt.Equal(t0.Add(40 * time.Second)) {
+		t.Fatalf("resolved at %v", resolved[0].ResolvedAt)
+	}
+}
+
+func TestThresholdHysteresis(t *testing.T) {
+	newEngine(t, schema.AlertRule{Field: "velocity", Operator: ">", Threshold: 5, Hysteresis: 1})
+
+	velocity(0, 5)
+	expect(t)
+	velocity(1, 5.5)
+	expect(t, db.AlertFiring)
+
+	// Below the threshold but within the hysteresis keeps firing
+	velocity(2, 4.5)
+	expect(t, db.AlertFiring)
+	velocity(3, 4)
+	expect(t, db.AlertResolved)
+
+	// Below threshold with operator <
+	newEngine(t, schema.AlertRule{Field: "velocity", Operator: "<", Threshold: 1, Hysteresis: 0.5})
+	velocity(0, 0.5)
+	velocity(1, 1.2)
+	expect(t, db.AlertFiring)
+	velocity(2, 1.5)
+	expect(t, db.AlertResolved)
+}
+
+func TestBand(t *testing.T) {
+	newEngine(t, schema.AlertRule{Field: "velocity", Kind: KindBand, Low: 10, High: 20, Hysteresis: 1})
+
+	velocity(0, 10)
+	velocity(1, 20)
+	expect(t)
+	velocity(2, 20.5)
+	expect(t, db.AlertFiring)
+	velocity(3, 19.5)
+	expect(t, db.AlertFiring)
+	velocity(4, 19)
+	expect(t, db.AlertResolved)
+
+	// The low side fires a new alert
+	velocity(5, 9.9)
+	expect(t, db.AlertFiring, db.AlertResolved)
+	velocity(6, 10.5)
+	expect(t, db.AlertFiring, db.AlertResolved)
+	velocity(7, 11)
+	expect(t, db.AlertResolved, db.AlertResolved)
+}
+
+func TestRate(t *testing.T) {
+	newEngine(t, schema.AlertRule{Field: "velocity", Kind: KindRate, Operator: ">", Threshold: 6})
+
+	// No rate until the samples span rateMinSpan, however steep
+	velocity(0, 0)
+	velocity(5, 10)
+	expect(t)
+
+	// 2 in 30s is 4 per minute
+	velocity(30, 2)
+	expect(t)
+	// 5 in 40s is 7.5 per minute
+	velocity(40, 5)
+	fired := expect(t, db.AlertFiring)
+	if fired[0].Value != 7.5 {
+		t.Fatalf("rate = %v, want 7.5", fired[0].Value)
+	}
+
+	// Samples older than rateWindow are dropped, the rate is from t0+40s to t0+100s
+	velocity(100, 8)
+	expect(t, db.AlertResolved)
+}
+
+func TestRuleDeletedOrDisabledResolves(t *testing.T) {
+	rule := newEngine(t, schema.AlertRule{Field: "velocity", Operator: ">", Threshold: 5})
+	velocity(0, 6)
+	expect(t, db.AlertFiring)
+
+	rule.Enabled = false
+	if _, err := db.UpdateAlertRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, db.AlertResolved)
+	velocity(1, 6)
+	expect(t, db.AlertResolved)
+
+	rule = newEngine(t, schema.AlertRule{Field: "velocity", Operator: ">", Threshold: 5})
+	velocity(0, 6)
+	if err := db.DeleteAlertRule("userA", rule.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, db.AlertResolved)
+}
+
+func TestRestartResumesFiringAlerts(t *testing.T) {
+	rule := newEngine(t, schema.AlertRule{Field: "velocity", Operator: ">", Threshold: 5})
+	velocity(0, 6)
+	expect(t, db.AlertFiring)
+	// A copy left firing by an older run
+	stale := schema.Alert{ID: "stale", RuleID: rule.ID, UserID: "userA", DeviceID: "deviceA", Status: db.AlertFiring, FiredAt: t0.Add(-time.Hour)}
+	if err := db.GetStore().InsertAlert(stale); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart: the states are gone, only the store remains
+	engine.Lock()
+	engine.states = make(map[string]*state)
+	engine.Unlock()
+	if err := restore(t0.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, db.AlertResolved, db.AlertFiring)
+
+	// Still breached: no second alert
+	velocity(2, 7)
+	expect(t, db.AlertResolved, db.AlertFiring)
+
+	// Cleared: the alert of the previous run resolves
+	velocity(3, 4)
+	expect(t, db.AlertResolved, db.AlertResolved)
+}
@@ -0,0 +1,152 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// Kinds of rule
+const (
+	KindThreshold = "threshold" // Field compared to Threshold with Operator
+	KindRate      = "rate"      // Change of the field per minute compared to Threshold with Operator
+	KindBand      = "band"      // Field outside Low..High
+)
+
+// Severities of a rule
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// MaxFor is the longest debounce of a rule in seconds
+const MaxFor = 3600
+
+// fields reads the measurements a rule can watch, keyed by the JSON path used in the telemetry
+var fields = buildFields()
+
+func buildFields() map[string]func(schema.GyroData) float64 {
+	fields := map[string]func(schema.GyroData) float64{
+		"Temperature": func(d schema.GyroData) float64 { return d.Data.Temperature },
+		"velocity":    func(d schema.GyroData) float64 { return d.Velocity },
+		"order":       func(d schema.GyroData) float64 { return d.Order },
+	}
+	axes := map[string]func(schema.GyroData) schema.AxisData{
+		"X": func(d schema.GyroData) schema.AxisData { return d.Data.X },
+		"Y": func(d schema.GyroData) schema.AxisData { return d.Data.Y },
+		"Z": func(d schema.GyroData) schema.AxisData { return d.Data.Z },
+	}
+	measures := map[string]func(schema.AxisData) float64{
+		"Acceleration":          func(a schema.AxisData) float64 { return a.Acceleration },
+		"VelocityAngular":       func(a schema.AxisData) float64 { return a.VelocityAngular },
+		"VibrationSpeed":        func(a schema.AxisData) float64 { return a.VibrationSpeed },
+		"VibrationAngle":        func(a schema.AxisData) float64 { return a.VibrationAngle },
+		"VibrationDisplacement": func(a schema.AxisData) float64 { return a.VibrationDisplacement },
+		"Frequency":             func(a schema.AxisData) float64 { return a.Frequency },
+	}
+	for axisName, axis := range axes {
+		for measureName, measure := range measures {
+			axis, measure := axis, measure
+			fields[axisName+"."+measureName] = func(d schema.GyroData) float64 { return measure(axis(d)) }
+		}
+	}
+	return fields
+}
+
+// Validate checks a rule and fills its defaults
+func Validate(rule *schema.AlertRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Field = strings.TrimPrefix(strings.TrimSpace(rule.Field), "data.")
+	if _, ok := fields[rule.Field]; !ok {
+		return fmt.Errorf("unknown field %q", rule.Field)
+	}
+	if rule.Kind == "" {
+		rule.Kind = KindThreshold
+	}
+	if rule.Severity == "" {
+		rule.Severity = SeverityWarning
+	}
+	if rule.Hysteresis < 0 {
+		return errors.New("hysteresis must not be negative")
+	}
+	if rule.For < 0 || rule.For > MaxFor {
+		return fmt.Errorf("for must be between 0 and %d seconds", MaxFor)
+	}
+
+	switch rule.Kind {
+	case KindThreshold, KindRate:
+		if rule.Operator != ">" && rule.Operator != "<" {
+			return errors.New("operator must be > or <")
+		}
+		rule.Low, rule.High = 0, 0
+	case KindBand:
+		if rule.High-rule.Low <= 2*rule.Hysteresis {
+			return errors.New("high must be above low by more than twice the hysteresis")
+		}
+		rule.Operator, rule.Threshold = "", 0
+	default:
+		return fmt.Errorf("unknown kind %q", rule.Kind)
+	}
+
+	switch rule.Severity {
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return fmt.Errorf("unknown severity %q", rule.Severity)
+	}
+
+	if rule.Name == "" {
+		rule.Name = Describe(*rule)
+	}
+	return nil
+}
+
+// Describe writes the condition of a rule, such as "Z.VibrationSpeed > 7.1 for 30s"
+func Describe(rule schema.AlertRule) string {
+	var condition string
+	switch rule.Kind {
+	case KindRate:
+		condition = fmt.Sprintf("%s rate %s %s/min", rule.Field, rule.Operator, formatValue(rule.Threshold))
+	case KindBand:
+		condition = fmt.Sprintf("%s outside %s..%s", rule.Field, formatValue(rule.Low), formatValue(rule.High))
+	default:
+		condition = fmt.Sprintf("%s %s %s", rule.Field, rule.Operator, formatValue(rule.Threshold))
+	}
+	if rule.For > 0 {
+		condition += fmt.Sprintf(" for %ds", rule.For)
+	}
+	return condition
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// breached reports whether value is past the limit of the rule
+func breached(rule schema.AlertRule, value float64) bool {
+	switch rule.Kind {
+	case KindBand:
+		return value < rule.Low || value > rule.High
+	default:
+		if rule.Operator == "<" {
+			return value < rule.Threshold
+		}
+		return value > rule.Threshold
+	}
+}
+
+// cleared reports whether value is back inside the limit of the rule by at least the hysteresis
+func cleared(rule schema.AlertRule, value float64) bool {
+	switch rule.Kind {
+	case KindBand:
+		return value >= rule.Low+rule.Hysteresis && value <= rule.High-rule.Hysteresis
+	default:
+		if rule.Operator == "<" {
+			return value >= rule.Threshold+rule.Hysteresis
+		}
+		return value <= rule.Threshold-rule.Hysteresis
+	}
+}
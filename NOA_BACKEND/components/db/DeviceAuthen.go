@@ -1,63 +1,30 @@
 package db
 
 import (
-	"context"
 	"errors"
-	"log"
-	"time"
 
-	env "GOLANG_SERVER/components/env"
-	schema "GOLANG_SERVER/components/schema"
+	"GOLANG_SERVER/components/schema"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// AuthenDevice function to authenticate device
-func AuthenDevice(email string, pass string, deviceID string) (bool, error) {
-	// Check if user exists
-	// log.Println("Device authentication started")
-	// log.Println("Email: ", email)
-	// log.Println("Pass: ", pass)
-	// log.Println("DeviceID: ", deviceID)
+// ErrDeviceCredentials is returned when a deviceID and password do not match a registered device
+var ErrDeviceCredentials = errors.New("invalid device credentials")
 
-	// Check Email, Password, and id in the database
-	collection := client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_DEVICECOLLECTION")) // Get collection user
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)                               // Create a context with timeout
-	defer cancel()                                                                                         // Defer cancel the context
-
-	if email == "" || pass == "" || deviceID == "" {
-		log.Println("Missing required fields")
-		return false, errors.New("missing required fields")
+// CheckDeviceCredentials returns the device when the password matches its bcrypt hash
+func CheckDeviceCredentials(deviceID, pass string) (*schema.Device, error) {
+	if deviceID == "" || pass == "" {
+		return nil, ErrDeviceCredentials
 	}
-
-	// find the user by email
-	user, err := FindUser(email)
-	if err != nil {
-		log.Println("Error finding user:", err)
-		return false, err
-	} else {
-		log.Println("User ID is OK")
+	device, err := store.FindDevice(deviceID)
+	if err == ErrNotFound {
+		return nil, ErrDeviceCredentials
 	}
-
-	log.Println("User ID:", user.ID)
-
-	filter := bson.M{"deviceID": deviceID, "userID": user.ID}
-	var device schema.Device
-	if err := collection.FindOne(ctx, filter).Decode(&device); err != nil {
-		return false, err
-	} else {
-		log.Println("Device ID is OK")
+	if err != nil {
+		return nil, err
 	}
-
-	// Check if the password is correct
 	if err := bcrypt.CompareHashAndPassword([]byte(device.Password), []byte(pass)); err != nil {
-		log.Println("Error comparing password:", err)
-		return false, err
-	} else {
-		log.Println("Password is OK")
+		return nil, ErrDeviceCredentials
 	}
-
-	log.Println("Device authentication successful")
-	return true, nil
+	return device, nil
 }
@@ -0,0 +1,232 @@
+package db
+
+import (
+	"context"
+	"math"
+	"time"
+
+	schema "GOLANG_SERVER/components/schema"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MaxTelemetryBuckets is the largest number of buckets returned by one aggregation
+const MaxTelemetryBuckets = 10000
+
+// TelemetryIntervals are the bucket sizes a client can aggregate by, buckets start on UTC boundaries
+var TelemetryIntervals = map[string]time.Duration{
+	"1s": time.Second,
+	"1m": time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+// TelemetryAggregateQuery selects the telemetry of one device, or of several devices together, to bucket
+type TelemetryAggregateQuery struct {
+	DeviceID  string        // Device to aggregate
+	DeviceIDs []string      // Devices to aggregate together when DeviceID is empty
+	From      int64         // Inclusive lower bound on TimeStamp in unix milliseconds, 0 for no bound
+	To        int64         // Inclusive upper bound on TimeStamp in unix milliseconds, 0 for no bound
+	Interval  time.Duration // Bucket size
+}
+
+// Stats summarises one measurement over a bucket
+type Stats struct {
+	Min  float64 `json:"min" bson:"min"`
+	Max  float64 `json:"max" bson:"max"`
+	Mean float64 `json:"mean" bson:"mean"`
+	RMS  float64 `json:"rms" bson:"rms"`
+}
+
+// AxisAggregate summarises the measurements of one axis over a bucket
+type AxisAggregate struct {
+	Acceleration          Stats `json:"Acceleration" bson:"acceleration"`
+	VibrationSpeed        Stats `json:"VibrationSpeed" bson:"vibrationspeed"`
+	VibrationDisplacement Stats `json:"VibrationDisplacement" bson:"vibrationdisplacement"`
+	Frequency             Stats `json:"Frequency" bson:"frequency"`
+}
+
+// TelemetryBucket is the telemetry of a device aggregated over one interval
+type TelemetryBucket struct {
+	Start       int64         `json:"start" bson:"start"` // Start of the bucket in unix milliseconds
+	Count       int64         `json:"count" bson:"count"` // Number of documents in the bucket
+	X           AxisAggregate `json:"X" bson:"x"`
+	Y           AxisAggregate `json:"Y" bson:"y"`
+	Z           AxisAggregate `json:"Z" bson:"z"`
+	Temperature Stats         `json:"Temperature" bson:"temperature"`
+}
+
+// aggregateAxes and aggregateAxisFields are the bson paths aggregated for every bucket
+var (
+	aggregateAxes       = []string{"x", "y", "z"}
+	aggregateAxisFields = []string{"acceleration", "vibrationspeed", "vibrationdisplacement", "frequency"}
+)
+
+// AggregateTelemetry buckets the telemetry of a device by interval
+func AggregateTelemetry(query TelemetryAggregateQuery) ([]TelemetryBucket, error) {
+	if query.DeviceID == "" && len(query.DeviceIDs) == 0 {
+		return nil, &QueryError{"deviceID is required"}
+	}
+	if query.Interval < time.Second {
+		return nil, &QueryError{"interval must be at least one second"}
+	}
+	if query.From > 0 && query.To > 0 && query.From > query.To {
+		return nil, &QueryError{"from must be before to"}
+	}
+
+	buckets, err := store.AggregateGyroData(query)
+	if err != nil {
+		return nil, err
+	}
+	if buckets == nil {
+		buckets = []TelemetryBucket{}
+	}
+	return buckets, nil
+}
+
+// AggregateGyroData buckets the telemetry of the device with an aggregation pipeline
+func (m *MongoStore) AggregateGyroData(query TelemetryAggregateQuery) ([]TelemetryBucket, error) {
+	collection := m.collection("MONGO_COLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	match := bson.M{"deviceid": query.DeviceID}
+	if query.DeviceID == "" {
+		match["deviceid"] = bson.M{"$in": query.DeviceIDs}
+	}
+	timeStamp := bson.M{}
+	if query.From > 0 {
+		timeStamp["$gte"] = query.From
+	}
+	if query.To > 0 {
+		timeStamp["$lte"] = query.To
+	}
+	if len(timeStamp) > 0 {
+		match["timestamp"] = timeStamp
+	}
+
+	// Group by the start of the interval, keep min/max/avg and the mean of squares for the RMS
+	interval := query.Interval.Milliseconds()
+	group := bson.M{
+		"_id":   bson.M{"$subtract": bson.A{"$timestamp", bson.M{"$mod": bson.A{"$timestamp", interval}}}},
+		"count": bson.M{"$sum": 1},
+	}
+	project := bson.M{"_id": 0, "start": "$_id", "count": 1}
+	addStats := func(name string, path string) {
+		group[name+"_min"] = bson.M{"$min": "$" + path}
+		group[name+"_max"] = bson.M{"$max": "$" + path}
+		group[name+"_mean"] = bson.M{"$avg": "$" + path}
+		group[name+"_sq"] = bson.M{"$avg": bson.M{"$multiply": bson.A{"$" + path, "$" + path}}}
+		project[path[len("data."):]] = bson.M{
+			"min":  "$" + name + "_min",
+			"max":  "$" + name + "_max",
+			"mean": "$" + name + "_mean",
+			"rms":  bson.M{"$sqrt": "$" + name + "_sq"},
+		}
+	}
+	for _, axis := range aggregateAxes {
+		for _, field := range aggregateAxisFields {
+			addStats(axis+"_"+field, "data."+axis+"."+field)
+		}
+	}
+	addStats("temperature", "data.temperature")
+
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$group": group},
+		bson.M{"$sort": bson.M{"_id": 1}},
+		bson.M{"$limit": MaxTelemetryBuckets},
+		bson.M{"$project": project},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var buckets []TelemetryBucket
+	if err = cursor.All(ctx, &buckets); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// statsAccumulator collects one measurement of a bucket in memory
+type statsAccumulator struct {
+	min, max, sum, sumSquares float64
+	count                     int64
+}
+
+func (a *statsAccumulator) add(value float64) {
+	if a.count == 0 || value < a.min {
+		a.min = value
+	}
+	if a.count == 0 || value > a.max {
+		a.max = value
+	}
+	a.sum += value
+	a.sumSquares += value * value
+	a.count++
+}
+
+func (a *statsAccumulator) stats() Stats {
+	if a.count == 0 {
+		return Stats{}
+	}
+	return Stats{
+		Min:  a.min,
+		Max:  a.max,
+		Mean: a.sum / float64(a.count),
+		RMS:  math.Sqrt(a.sumSquares / float64(a.count)),
+	}
+}
+
+// axisAccumulator collects the measurements of one axis of a bucket in memory
+type axisAccumulator struct {
+	acceleration, vibrationSpeed, vibrationDisplacement, frequency statsAccumulator
+}
+
+func (a *axisAccumulator) add(axis schema.AxisData) {
+	a.acceleration.add(axis.Acceleration)
+	a.vibrationSpeed.add(axis.VibrationSpeed)
+	a.vibrationDisplacement.add(axis.VibrationDisplacement)
+	a.frequency.add(axis.Frequency)
+}
+
+func (a *axisAccumulator) aggregate() AxisAggregate {
+	return AxisAggregate{
+		Acceleration:          a.acceleration.stats(),
+		VibrationSpeed:        a.vibrationSpeed.stats(),
+		VibrationDisplacement: a.vibrationDisplacement.stats(),
+		Frequency:             a.frequency.stats(),
+	}
+}
+
+// bucketAccumulator collects one bucket in memory, used by the MemoryStore
+type bucketAccumulator struct {
+	start       int64
+	count       int64
+	x, y, z     axisAccumulator
+	temperature statsAccumulator
+}
+
+func (b *bucketAccumulator) add(data schema.GyroData) {
+	b.count++
+	b.x.add(data.Data.X)
+	b.y.add(data.Data.Y)
+	b.z.add(data.Data.Z)
+	b.temperature.add(data.Data.Temperature)
+}
+
+func (b *bucketAccumulator) bucket() TelemetryBucket {
+	return TelemetryBucket{
+		Start:       b.start,
+		Count:       b.count,
+		X:           b.x.aggregate(),
+		Y:           b.y.aggregate(),
+		Z:           b.z.aggregate(),
+		Temperature: b.temperature.stats(),
+	}
+}
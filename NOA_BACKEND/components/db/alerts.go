@@ -0,0 +1,267 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	schema "GOLANG_SERVER/components/schema"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Status of an alert
+const (
+	AlertFiring   = "firing"   // The condition of the rule holds
+	AlertResolved = "resolved" // The condition cleared
+)
+
+// DefaultAlertLimit is the number of alerts listed per page
+const DefaultAlertLimit = 100
+
+// AlertQuery selects the alerts of a user
+type AlertQuery struct {
+	DeviceID  string   // Only this device when set
+	DeviceIDs []string // Only these devices when not nil
+	Status    string   // Only firing or resolved alerts when set
+	Limit     int64    // Maximum number of alerts
+}
+
+// CreateAlertRule stores a new rule, the rule must be validated by the caller
+func CreateAlertRule(rule schema.AlertRule) (schema.AlertRule, error) {
+	now := time.Now()
+	rule.ID = uuid.New().String()
+	rule.CreateAt = now
+	rule.UpdateAt = now
+	if err := store.InsertAlertRule(rule); err != nil {
+		return schema.AlertRule{}, err
+	}
+	return rule, nil
+}
+
+// UpdateAlertRule replaces a rule of the user, keeping its ID and creation date
+func UpdateAlertRule(rule schema.AlertRule) (schema.AlertRule, error) {
+	current, err := AlertRule(rule.UserID, rule.ID)
+	if err != nil {
+		return schema.AlertRule{}, err
+	}
+	rule.CreateAt = current.CreateAt
+	rule.UpdateAt = time.Now()
+	if err := store.UpdateAlertRule(rule); err != nil {
+		return schema.AlertRule{}, err
+	}
+	return rule, nil
+}
+
+// DeleteAlertRule deletes a rule of the user
+func DeleteAlertRule(userID, ruleID string) error {
+	return store.DeleteAlertRule(userID, ruleID)
+}
+
+// AlertRule finds a rule of the user
+func AlertRule(userID, ruleID string) (schema.AlertRule, error) {
+	rules, err := store.AlertRulesByUser(userID)
+	if err != nil {
+		return schema.AlertRule{}, err
+	}
+	for _, rule := range rules {
+		if rule.ID == ruleID {
+			return rule, nil
+		}
+	}
+	return schema.AlertRule{}, ErrNotFound
+}
+
+// AlertRules lists the rules of a user
+func AlertRules(userID string) ([]schema.AlertRule, error) {
+	rules, err := store.AlertRulesByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		rules = []schema.AlertRule{}
+	}
+	return rules, nil
+}
+
+// EnabledAlertRules lists the rules the alert engine evaluates
+func EnabledAlertRules() ([]schema.AlertRule, error) {
+	return store.EnabledAlertRules()
+}
+
+// InsertAlert stores a firing alert
+func InsertAlert(alert schema.Alert) error {
+	return store.InsertAlert(alert)
+}
+
+// ResolveAlert marks a firing alert resolved
+func ResolveAlert(alertID string, resolvedAt time.Time) error {
+	return store.ResolveAlert(alertID, resolvedAt)
+}
+
+// FiringAlerts lists the alerts not resolved yet, so the alert engine resumes them after a restart
+func FiringAlerts() ([]schema.Alert, error) {
+	return store.FiringAlerts()
+}
+
+// Alerts lists the alerts of a user, newest first
+func Alerts(userID string, query AlertQuery) ([]schema.Alert, error) {
+	if query.Limit <= 0 || query.Limit > DefaultAlertLimit {
+		query.Limit = DefaultAlertLimit
+	}
+	alerts, err := store.AlertsByUser(userID, query)
+	if err != nil {
+		return nil, err
+	}
+	if alerts == nil {
+		alerts = []schema.Alert{}
+	}
+	return alerts, nil
+}
+
+// InsertAlertRule inserts a rule in the alert rule collection
+func (m *MongoStore) InsertAlertRule(rule schema.AlertRule) error {
+	collection := m.collection("MONGO_ALERTRULECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.InsertOne(ctx, rule)
+	return err
+}
+
+// UpdateAlertRule replaces the rule with the same ID and user
+func (m *MongoStore) UpdateAlertRule(rule schema.AlertRule) error {
+	collection := m.collection("MONGO_ALERTRULECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := collection.ReplaceOne(ctx, bson.M{"ruleID": rule.ID, "userID": rule.UserID}, rule)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteAlertRule deletes the rule with the ID and user
+func (m *MongoStore) DeleteAlertRule(userID, ruleID string) error {
+	collection := m.collection("MONGO_ALERTRULECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := collection.DeleteOne(ctx, bson.M{"ruleID": ruleID, "userID": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AlertRulesByUser finds the rules of the user
+func (m *MongoStore) AlertRulesByUser(userID string) ([]schema.AlertRule, error) {
+	return m.findAlertRules(bson.M{"userID": userID})
+}
+
+// EnabledAlertRules finds the enabled rules of every user
+func (m *MongoStore) EnabledAlertRules() ([]schema.AlertRule, error) {
+	return m.findAlertRules(bson.M{"enabled": true})
+}
+
+func (m *MongoStore) findAlertRules(filter bson.M) ([]schema.AlertRule, error) {
+	collection := m.collection("MONGO_ALERTRULECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"createAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rules []schema.AlertRule
+	if err = cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// InsertAlert inserts an alert in the alert collection
+func (m *MongoStore) InsertAlert(alert schema.Alert) error {
+	collection := m.collection("MONGO_ALERTCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.InsertOne(ctx, alert)
+	return err
+}
+
+// ResolveAlert sets the resolve date of a firing alert
+func (m *MongoStore) ResolveAlert(alertID string, resolvedAt time.Time) error {
+	collection := m.collection("MONGO_ALERTCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"alertID": alertID, "status": AlertFiring}
+	update := bson.M{"$set": bson.M{"status": AlertResolved, "resolvedAt": resolvedAt}}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AlertsByUser finds the latest alerts of the user
+func (m *MongoStore) AlertsByUser(userID string, query AlertQuery) ([]schema.Alert, error) {
+	collection := m.collection("MONGO_ALERTCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"userID": userID}
+	if query.DeviceID != "" {
+		filter["deviceID"] = query.DeviceID
+	} else if query.DeviceIDs != nil {
+		filter["deviceID"] = bson.M{"$in": query.DeviceIDs}
+	}
+	if query.Status != "" {
+		filter["status"] = query.Status
+	}
+	findOptions := options.Find().SetSort(bson.M{"firedAt": -1}).SetLimit(query.Limit)
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var alerts []schema.Alert
+	if err = cursor.All(ctx, &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// FiringAlerts finds the firing alerts of every user, oldest first
+func (m *MongoStore) FiringAlerts() ([]schema.Alert, error) {
+	collection := m.collection("MONGO_ALERTCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{"status": AlertFiring}, options.Find().SetSort(bson.M{"firedAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var alerts []schema.Alert
+	if err = cursor.All(ctx, &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
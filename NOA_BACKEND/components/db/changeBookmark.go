@@ -1,7 +1,6 @@
 package db
 
 import (
-	env "GOLANG_SERVER/components/env"
 	"context"
 	"errors"
 	"time"
@@ -18,7 +17,19 @@ func UpdateBookmark(userID, deviceID string, bookmark bool) error {
 		return errors.New("deviceID is required")
 	}
 
-	collection := client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_DEVICECOLLECTION"))
+	if err := store.UpdateBookmark(userID, deviceID, bookmark); err != nil {
+		if err == ErrNotFound {
+			return errors.New("no device found with the given userID and deviceID")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// UpdateBookmark sets the bookmark field of the device
+func (m *MongoStore) UpdateBookmark(userID, deviceID string, bookmark bool) error {
+	collection := m.collection("MONGO_DEVICECOLLECTION")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -32,7 +43,7 @@ func UpdateBookmark(userID, deviceID string, bookmark bool) error {
 	}
 
 	if result.MatchedCount == 0 {
-		return errors.New("no device found with the given userID and deviceID")
+		return ErrNotFound
 	}
 
 	return nil
@@ -1,7 +1,6 @@
 package db
 
 import (
-	env "GOLANG_SERVER/components/env"
 	schema "GOLANG_SERVER/components/schema"
 	"context"
 	"errors"
@@ -14,10 +13,6 @@ import (
 
 // CheckDeviceID checks if a device ID is already registered in the database
 func HandlercheckDeviceID(deviceID string) (bool, error) {
-	collection := client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_DEVICECOLLECTION"))
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
 	log.Println("Checking device ID:", deviceID)
 
 	if deviceID == "" {
@@ -25,20 +20,35 @@ func HandlercheckDeviceID(deviceID string) (bool, error) {
 	}
 
 	// Check if the device ID exists in the database
-	filter := bson.M{"deviceID": deviceID}
-	var result schema.Device
-	err := collection.FindOne(ctx, filter).Decode(&result)
+	exists, err := store.DeviceExists(deviceID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			log.Println("Device ID " + deviceID + " does not exist:")
-			return false, nil // Device ID does not exist
-		}
 		log.Println("Error checking device ID:", err)
 		return false, err // Error occurred while checking device ID
+	}
 
+	if !exists {
+		log.Println("Device ID " + deviceID + " does not exist:")
+		return false, nil // Device ID does not exist
 	}
 
 	log.Println("Device ID " + deviceID + " exists.")
 	return true, nil // Device ID exists
+}
 
+// DeviceExists checks the device collection for the deviceID
+func (m *MongoStore) DeviceExists(deviceID string) (bool, error) {
+	collection := m.collection("MONGO_DEVICECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"deviceID": deviceID}
+	var result schema.Device
+	err := collection.FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
 }
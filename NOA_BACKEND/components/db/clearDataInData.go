@@ -8,11 +8,17 @@ import (
 )
 
 func CleanData() (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	_, err := collection.DeleteMany(ctx, bson.M{})
-	if err != nil {
+	if err := store.CleanGyroData(); err != nil {
 		return false, err
 	}
 	return true, nil
 }
+
+// CleanGyroData deletes every document of the data collection
+func (m *MongoStore) CleanGyroData() error {
+	collection := m.collection("MONGO_COLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := collection.DeleteMany(ctx, bson.M{})
+	return err
+}
@@ -0,0 +1,220 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	schema "GOLANG_SERVER/components/schema"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Status of a command
+const (
+	CommandPending = "pending" // Sent, waiting for the ack of the device
+	CommandAcked   = "acked"   // Applied by the device
+	CommandFailed  = "failed"  // Refused by the device
+	CommandExpired = "expired" // Not acknowledged before ExpireAt
+)
+
+const (
+	DefaultCommandTTL   = 5 * time.Minute // Time a device has to ack a command when the client does not set one
+	MaxCommandTTL       = 24 * time.Hour  // Longest time a command can stay pending
+	DefaultCommandLimit = 50              // Number of commands listed per device
+)
+
+// commandTypes checks the params of every command type a device understands
+var commandTypes = map[string]func(params map[string]interface{}) error{
+	// Change the sampling rate, {"hz": 1..1000}
+	"sampleRate": func(params map[string]interface{}) error {
+		hz, ok := params["hz"].(float64)
+		if !ok || hz != float64(int(hz)) || hz < 1 || hz > 1000 {
+			return errors.New("sampleRate needs an integer hz between 1 and 1000")
+		}
+		return nil
+	},
+	// Toggle the Modbus high speed mode of the sensor, {"enabled": true|false}
+	"modbusHighSpeed": func(params map[string]interface{}) error {
+		if _, ok := params["enabled"].(bool); !ok {
+			return errors.New("modbusHighSpeed needs a boolean enabled")
+		}
+		return nil
+	},
+	// Restart the device, no params
+	"reboot": func(params map[string]interface{}) error {
+		if len(params) > 0 {
+			return errors.New("reboot takes no params")
+		}
+		return nil
+	},
+	// Push keys of the device config file, {"MQTT_SERVER": "..."}, only the keys in configKeys
+	"config": func(params map[string]interface{}) error {
+		if len(params) == 0 {
+			return errors.New("config needs at least one key")
+		}
+		for key, value := range params {
+			if !configKeys[key] {
+				return fmt.Errorf("config key %q cannot be pushed", key)
+			}
+			if _, ok := value.(string); !ok {
+				return fmt.Errorf("config value of %q must be a string", key)
+			}
+		}
+		return nil
+	},
+}
+
+// configKeys are the keys of the config file the firmware reads, all of them connection settings
+var configKeys = map[string]bool{
+	"WIFI_SSID":     true,
+	"WIFI_PASSWORD": true,
+	"MQTT_SERVER":   true,
+	"MQTT_PORT":     true,
+	"MQTT_USER":     true,
+	"MQTT_PASS":     true,
+}
+
+// CommandRole is the lowest role allowed to send the command type. A config command can point the device
+// at another network or broker, so only the owner sends it.
+func CommandRole(commandType string) string {
+	if commandType == "config" {
+		return RoleOwner
+	}
+	return RoleOperator
+}
+
+// CreateCommand validates and stores a pending command for a device
+func CreateCommand(userID, deviceID, commandType string, params map[string]interface{}, ttl time.Duration) (schema.Command, error) {
+	validate, ok := commandTypes[commandType]
+	if !ok {
+		return schema.Command{}, fmt.Errorf("unknown command type %q", commandType)
+	}
+	if err := validate(params); err != nil {
+		return schema.Command{}, err
+	}
+	if ttl <= 0 {
+		ttl = DefaultCommandTTL
+	} else if ttl > MaxCommandTTL {
+		return schema.Command{}, errors.New("ttl must be at most 24 hours")
+	}
+
+	now := time.Now()
+	command := schema.Command{
+		ID:       uuid.New().String(),
+		DeviceID: deviceID,
+		UserID:   userID,
+		Type:     commandType,
+		Params:   params,
+		Status:   CommandPending,
+		CreateAt: now,
+		ExpireAt: now.Add(ttl),
+	}
+	if err := store.InsertCommand(command); err != nil {
+		return schema.Command{}, err
+	}
+	return command, nil
+}
+
+// AckCommand records the ack of a device, status is acked or failed
+func AckCommand(deviceID, commandID, status, reason string) error {
+	if status != CommandAcked && status != CommandFailed {
+		return fmt.Errorf("invalid ack status %q", status)
+	}
+	return store.AckCommand(deviceID, commandID, status, reason, time.Now())
+}
+
+// SetCommandFailed marks a pending command failed, used when it could not be published
+func SetCommandFailed(deviceID, commandID, reason string) error {
+	return store.AckCommand(deviceID, commandID, CommandFailed, reason, time.Now())
+}
+
+// DeviceCommands lists the latest commands of a device
+func DeviceCommands(deviceID string, limit int64) ([]schema.Command, error) {
+	if limit <= 0 || limit > DefaultCommandLimit {
+		limit = DefaultCommandLimit
+	}
+	commands, err := store.CommandsByDevice(deviceID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if commands == nil {
+		commands = []schema.Command{}
+	}
+	return commands, nil
+}
+
+// ExpireCommands marks the pending commands past their expiry as expired
+func ExpireCommands() (int64, error) {
+	return store.ExpireCommands(time.Now())
+}
+
+// InsertCommand inserts a command in the command collection
+func (m *MongoStore) InsertCommand(command schema.Command) error {
+	collection := m.collection("MONGO_COMMANDCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.InsertOne(ctx, command)
+	return err
+}
+
+// CommandsByDevice finds the latest commands of the device
+func (m *MongoStore) CommandsByDevice(deviceID string, limit int64) ([]schema.Command, error) {
+	collection := m.collection("MONGO_COMMANDCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.M{"createAt": -1}).SetLimit(limit)
+	cursor, err := collection.Find(ctx, bson.M{"deviceID": deviceID}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var commands []schema.Command
+	if err = cursor.All(ctx, &commands); err != nil {
+		return nil, err
+	}
+	return commands, nil
+}
+
+// AckCommand sets the result of a command still pending and not expired
+func (m *MongoStore) AckCommand(deviceID, commandID, status, reason string, ackAt time.Time) error {
+	collection := m.collection("MONGO_COMMANDCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"commandID": commandID,
+		"deviceID":  deviceID,
+		"status":    CommandPending,
+		"expireAt":  bson.M{"$gt": ackAt},
+	}
+	update := bson.M{"$set": bson.M{"status": status, "error": reason, "ackAt": ackAt}}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ExpireCommands marks pending commands past their expiry as expired
+func (m *MongoStore) ExpireCommands(now time.Time) (int64, error) {
+	collection := m.collection("MONGO_COMMANDCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"status": CommandPending, "expireAt": bson.M{"$lte": now}}
+	result, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"status": CommandExpired}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
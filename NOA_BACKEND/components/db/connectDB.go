@@ -7,14 +7,24 @@ import (
 
 	env "GOLANG_SERVER/components/env"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var client *mongo.Client
-var collection *mongo.Collection
 
-// * Connect to mongo db
+// MongoStore is the Store backed by MongoDB
+type MongoStore struct {
+	client *mongo.Client
+}
+
+// NewMongoStore creates a Store on top of a connected mongo client
+func NewMongoStore(client *mongo.Client) *MongoStore {
+	return &MongoStore{client: client}
+}
+
+// * Connect to mongo db and use it as the store
 func Connect() (bool, error) {
 	clientOptions := options.Client().ApplyURI(env.GetEnv("MONGO_URI"))
 	var err error
@@ -33,6 +43,195 @@ func Connect() (bool, error) {
 		return false, err
 	}
 
-	collection = client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_COLLECTION"))
+	mongoStore := NewMongoStore(client)
+	if err := mongoStore.ensureIndexes(); err != nil {
+		fmt.Println("Can't create mongo db indexes:", err)
+		return false, err
+	}
+
+	UseStore(mongoStore)
 	return true, nil
 }
+
+// ensureIndexes creates the indexes the queries of the store rely on
+func (m *MongoStore) ensureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Telemetry is always read per device in time order
+	_, err := m.collection("MONGO_COLLECTION").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "deviceid", Value: 1}, {Key: "timestamp", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Commands are listed per device newest first and swept by expiry
+	_, err = m.collection("MONGO_COMMANDCOLLECTION").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "deviceID", Value: 1}, {Key: "createAt", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expireAt", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// One usage document per device and day
+	_, err = m.collection("MONGO_USAGECOLLECTION").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "deviceID", Value: 1}, {Key: "day", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Rules and alerts are read per user
+	if _, err = m.collection("MONGO_ALERTRULECOLLECTION").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "userID", Value: 1}},
+	}); err != nil {
+		return err
+	}
+	if _, err = m.collection("MONGO_ALERTCOLLECTION").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "userID", Value: 1}, {Key: "firedAt", Value: -1}},
+	}); err != nil {
+		return err
+	}
+
+	// Notifications are listed per user newest first and deleted by MongoDB once expired
+	_, err = m.collection("MONGO_NOTIFICATIONCOLLECTION").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userID", Value: 1}, {Key: "createAt", Value: -1}, {Key: "notificationID", Value: -1}}},
+		{Keys: bson.D{{Key: "userID", Value: 1}, {Key: "read", Value: 1}}},
+		{Keys: bson.D{{Key: "expireAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Webhooks are read per user, deliveries by due date and per webhook
+	if _, err = m.collection("MONGO_WEBHOOKCOLLECTION").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "userID", Value: 1}},
+	}); err != nil {
+		return err
+	}
+	_, err = m.collection("MONGO_DELIVERYCOLLECTION").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttempt", Value: 1}}},
+		{Keys: bson.D{{Key: "webhookID", Value: 1}, {Key: "createAt", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// One OTP per subject and purpose, deleted by MongoDB once expired
+	_, err = m.collection("MONGO_OTPCOLLECTION").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "subject", Value: 1}, {Key: "purpose", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expireAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	// One send log per subject, deleted by MongoDB once its window ended
+	_, err = m.collection("MONGO_OTPSENDCOLLECTION").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "subject", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expireAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Sessions are found by ID and revoked per user, MongoDB deletes them once the refresh token expired
+	_, err = m.collection("MONGO_SESSIONCOLLECTION").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "sessionID", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "userID", Value: 1}}},
+		{Keys: bson.D{{Key: "expireAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	// One registration per email, deleted by MongoDB once expired
+	_, err = m.collection("MONGO_PENDINGUSERCOLLECTION").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expireAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Reset tokens are found by hash and deleted by MongoDB once expired
+	_, err = m.collection("MONGO_PASSWORDRESETCOLLECTION").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expireAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Pairing codes are claimed by hash, followed by ID and deleted by MongoDB once expired
+	_, err = m.collection("MONGO_PROVISIONINGCOLLECTION").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "codeHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "provisionID", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expireAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Shares are listed by device, user and invited email, MongoDB deletes invitations once expired
+	_, err = m.collection("MONGO_SHARECOLLECTION").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "shareID", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "deviceID", Value: 1}, {Key: "createAt", Value: 1}}},
+		{Keys: bson.D{{Key: "userID", Value: 1}, {Key: "deviceID", Value: 1}}},
+		{Keys: bson.D{{Key: "email", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "expireAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Organisations, sites and assets are listed per user and found by ID
+	for key, id := range map[string]string{
+		"MONGO_ORGANISATIONCOLLECTION": "orgID",
+		"MONGO_SITECOLLECTION":         "siteID",
+		"MONGO_ASSETCOLLECTION":        "assetID",
+	} {
+		_, err = m.collection(key).Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: id, Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "userID", Value: 1}, {Key: "name", Value: 1}}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectionDefaults are the collection names used when their environment variable is not set,
+// so collections added after a deployment do not need a new variable
+var collectionDefaults = map[string]string{
+	"MONGO_COMMANDCOLLECTION":       "commands",
+	"MONGO_USAGECOLLECTION":         "usage",
+	"MONGO_ALERTRULECOLLECTION":     "alertRules",
+	"MONGO_ALERTCOLLECTION":         "alerts",
+	"MONGO_NOTIFICATIONCOLLECTION":  "notifications",
+	"MONGO_WEBHOOKCOLLECTION":       "webhooks",
+	"MONGO_DELIVERYCOLLECTION":      "webhookDeliveries",
+	"MONGO_PASSWORDRESETCOLLECTION": "passwordResets",
+	"MONGO_OTPCOLLECTION":           "otps",
+	"MONGO_OTPSENDCOLLECTION":       "otpSends",
+	"MONGO_PENDINGUSERCOLLECTION":   "pendingUsers",
+	"MONGO_SESSIONCOLLECTION":       "sessions",
+	"MONGO_PROVISIONINGCOLLECTION":  "provisionings",
+	"MONGO_SHARECOLLECTION":         "deviceShares",
+	"MONGO_ORGANISATIONCOLLECTION":  "organisations",
+	"MONGO_SITECOLLECTION":          "sites",
+	"MONGO_ASSETCOLLECTION":         "assets",
+}
+
+// collection returns the collection named by the environment variable key
+func (m *MongoStore) collection(key string) *mongo.Collection {
+	name := env.GetEnv(key)
+	if name == "" {
+		name = collectionDefaults[key]
+	}
+	return m.client.Database(env.GetEnv("MONGO_DB")).Collection(name)
+}
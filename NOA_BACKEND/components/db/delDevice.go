@@ -1,7 +1,6 @@
 package db
 
 import (
-	env "GOLANG_SERVER/components/env"
 	"context"
 	"errors"
 	"time"
@@ -18,7 +17,20 @@ func DeleteDevice(userID, deviceID string) error {
 		return errors.New("deviceID is required")
 	}
 
-	collection := client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_DEVICECOLLECTION"))
+	if err := store.DeleteDevice(userID, deviceID); err != nil {
+		if err == ErrNotFound {
+			return errors.New("no device found with the given userID and deviceID")
+		}
+		return err
+	}
+
+	// Nobody keeps a role on a deleted device
+	return DeleteDeviceShares(deviceID)
+}
+
+// DeleteDevice deletes the device document owned by the user
+func (m *MongoStore) DeleteDevice(userID, deviceID string) error {
+	collection := m.collection("MONGO_DEVICECOLLECTION")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -30,7 +42,7 @@ func DeleteDevice(userID, deviceID string) error {
 	}
 
 	if result.DeletedCount == 0 {
-		return errors.New("no device found with the given userID and deviceID")
+		return ErrNotFound
 	}
 
 	return nil
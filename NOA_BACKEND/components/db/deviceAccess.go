@@ -0,0 +1,73 @@
+package db
+
+import (
+	"errors"
+
+	"GOLANG_SERVER/components/schema"
+)
+
+// Roles of a user on a device, each role can do everything the roles after it can
+const (
+	RoleOwner    = "owner"    // Registered the device, shares, transfers and deletes it
+	RoleOperator = "operator" // Sends commands and changes the machine class
+	RoleViewer   = "viewer"   // Reads the device, its telemetry, usage and predictions
+)
+
+var (
+	// ErrNotDeviceOwner is returned when a device does not exist or is not shared with the user, the two are not told apart
+	ErrNotDeviceOwner = errors.New("device not found")
+	// ErrDeviceRole is returned when the user has a role on the device below the one needed
+	ErrDeviceRole = errors.New("your role on this device does not allow this")
+)
+
+// roleRank orders the roles, unknown roles rank 0
+var roleRank = map[string]int{RoleViewer: 1, RoleOperator: 2, RoleOwner: 3}
+
+// ValidShareRole reports whether a device can be shared with the role
+func ValidShareRole(role string) bool {
+	return role == RoleOperator || role == RoleViewer
+}
+
+// RoleAllows reports whether the role can do what the needed role can
+func RoleAllows(role, needed string) bool {
+	return roleRank[role] > 0 && roleRank[role] >= roleRank[needed]
+}
+
+// DeviceAccess returns the device and the role of the user on it, ErrNotDeviceOwner when the user has none
+func DeviceAccess(userID, deviceID string) (*schema.Device, string, error) {
+	device, err := store.FindDevice(deviceID)
+	if err == ErrNotFound {
+		return nil, "", ErrNotDeviceOwner
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if device.UserID == userID {
+		return device, RoleOwner, nil
+	}
+
+	share, err := store.FindUserShare(userID, deviceID)
+	if err == ErrNotFound {
+		return nil, "", ErrNotDeviceOwner
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	// Only the device record makes an owner, an accepted transfer left as a share does not
+	if share.Role == RoleOwner {
+		return nil, "", ErrNotDeviceOwner
+	}
+	return device, share.Role, nil
+}
+
+// CheckDeviceAccess checks that the user has at least the role on the device
+func CheckDeviceAccess(userID, deviceID, role string) error {
+	_, have, err := DeviceAccess(userID, deviceID)
+	if err != nil {
+		return err
+	}
+	if !RoleAllows(have, role) {
+		return ErrDeviceRole
+	}
+	return nil
+}
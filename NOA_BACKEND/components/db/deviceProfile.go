@@ -0,0 +1,33 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	schema "GOLANG_SERVER/components/schema"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// UpdateDeviceProfile sets the machine profile of a device, the profile must be validated by the caller
+func UpdateDeviceProfile(userID, deviceID string, profile schema.DeviceProfile) error {
+	return store.UpdateDeviceProfile(userID, deviceID, profile)
+}
+
+// UpdateDeviceProfile sets the profile field of the device
+func (m *MongoStore) UpdateDeviceProfile(userID, deviceID string, profile schema.DeviceProfile) error {
+	collection := m.collection("MONGO_DEVICECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"userID": userID, "deviceID": deviceID}
+	update := bson.M{"$set": bson.M{"profile": profile, "currentDate": time.Now()}}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
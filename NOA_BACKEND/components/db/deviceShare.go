@@ -0,0 +1,331 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	schema "GOLANG_SERVER/components/schema"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Status of a device share
+const (
+	SharePending  = "pending"  // Invitation waiting for the invited email
+	ShareAccepted = "accepted" // Role granted to the user who accepted
+)
+
+// ShareInvitationTTL is how long an invitation can be accepted
+const ShareInvitationTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrShareExists is returned when the email already has a share or a pending invitation of the same kind on the device
+	ErrShareExists = errors.New("the device is already shared with this email")
+	// ErrShareRole is returned for a role a device cannot be shared with
+	ErrShareRole = errors.New("role must be operator or viewer")
+	// ErrShareEmail is returned when an invitation has no email
+	ErrShareEmail = errors.New("email is required")
+)
+
+// InviteShare invites the email to the device with the role, the owner role invites to take over the device
+func InviteShare(ownerID, deviceID, deviceName, email, role string) (schema.DeviceShare, error) {
+	if role != RoleOwner && !ValidShareRole(role) {
+		return schema.DeviceShare{}, ErrShareRole
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return schema.DeviceShare{}, ErrShareEmail
+	}
+
+	now := time.Now().Truncate(time.Millisecond)
+	shares, err := store.SharesByDevice(deviceID)
+	if err != nil {
+		return schema.DeviceShare{}, err
+	}
+	for _, share := range shares {
+		// A transfer can be offered to a user the device is already shared with
+		if share.Email != email || (share.Role == RoleOwner) != (role == RoleOwner) {
+			continue
+		}
+		if share.Status == ShareAccepted || share.ExpireAt.After(now) {
+			return schema.DeviceShare{}, ErrShareExists
+		}
+	}
+
+	expireAt := now.Add(ShareInvitationTTL)
+	share := schema.DeviceShare{
+		ID:         uuid.New().String(),
+		DeviceID:   deviceID,
+		DeviceName: deviceName,
+		OwnerID:    ownerID,
+		Email:      email,
+		Role:       role,
+		Status:     SharePending,
+		CreateAt:   now,
+		ExpireAt:   &expireAt,
+	}
+	if err := store.InsertShare(share); err != nil {
+		return schema.DeviceShare{}, err
+	}
+	return share, nil
+}
+
+// DeviceShares lists the shares and pending invitations of a device
+func DeviceShares(deviceID string) ([]schema.DeviceShare, error) {
+	shares, err := store.SharesByDevice(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if shares == nil {
+		shares = []schema.DeviceShare{}
+	}
+	return shares, nil
+}
+
+// FindShare finds a share by ID
+func FindShare(shareID string) (schema.DeviceShare, error) {
+	return store.FindShare(shareID)
+}
+
+// UpdateShareRole changes the role of a share of the device
+func UpdateShareRole(deviceID, shareID, role string) error {
+	if !ValidShareRole(role) {
+		return ErrShareRole
+	}
+	return store.UpdateShareRole(deviceID, shareID, role)
+}
+
+// DeleteShare revokes a share or withdraws an invitation
+func DeleteShare(shareID string) error {
+	return store.DeleteShare(shareID)
+}
+
+// DeleteDeviceShares deletes every share of a deleted device
+func DeleteDeviceShares(deviceID string) error {
+	return store.DeleteDeviceShares(deviceID)
+}
+
+// Invitations lists the pending invitations of an email
+func Invitations(email string) ([]schema.DeviceShare, error) {
+	invitations, err := store.InvitationsByEmail(strings.ToLower(email), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if invitations == nil {
+		invitations = []schema.DeviceShare{}
+	}
+	return invitations, nil
+}
+
+// AcceptShare accepts an invitation of the email for the user. Accepting a transfer makes the user the
+// owner of the device, the previous owner keeps no access and other pending transfers are dropped.
+func AcceptShare(shareID, email, userID string) (schema.DeviceShare, error) {
+	now := time.Now().Truncate(time.Millisecond)
+	share, err := store.AcceptShare(shareID, strings.ToLower(email), userID, now)
+	if err != nil || share.Role != RoleOwner {
+		return share, err
+	}
+
+	// The transfer is claimed, so it runs once, and done when the device record changes owner. Accepted
+	// transfers are not kept as shares, DeviceAccess ignores one left behind by a failed delete.
+	if transferErr := store.TransferDevice(share.DeviceID, share.OwnerID, userID); transferErr != nil {
+		// The device was deleted or transferred since the invitation, or the owner must send it again
+		if err := store.DeleteShare(share.ID); err != nil {
+			log.Println("Error deleting transfer", share.ID+":", err)
+		}
+		return schema.DeviceShare{}, transferErr
+	}
+
+	// Once transferred the accept succeeds. The shares left by a failed delete grant nothing: the other
+	// transfers fail as the device changed owner, and the new owner's role comes from the device record.
+	shares, err := store.SharesByDevice(share.DeviceID)
+	if err != nil {
+		log.Println("Error reading shares of transferred device", share.DeviceID+":", err)
+		return share, nil
+	}
+	for _, other := range shares {
+		if other.ID == share.ID || other.UserID == userID || other.Role == RoleOwner {
+			if err := store.DeleteShare(other.ID); err != nil {
+				log.Println("Error deleting share", other.ID+":", err)
+			}
+		}
+	}
+	return share, nil
+}
+
+// UpdateShareBookmark changes the bookmark of a device shared with the user
+func UpdateShareBookmark(userID, deviceID string, bookmark bool) error {
+	return store.UpdateShareBookmark(userID, deviceID, bookmark)
+}
+
+// InsertShare stores a new invitation
+func (m *MongoStore) InsertShare(share schema.DeviceShare) error {
+	collection := m.collection("MONGO_SHARECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	_, err := collection.InsertOne(ctx, share)
+	return err
+}
+
+// FindShare queries a share by its ID
+func (m *MongoStore) FindShare(shareID string) (schema.DeviceShare, error) {
+	return m.findShare(bson.M{"shareID": shareID})
+}
+
+// FindUserShare queries the accepted share of the device with the user
+func (m *MongoStore) FindUserShare(userID, deviceID string) (schema.DeviceShare, error) {
+	return m.findShare(bson.M{"userID": userID, "deviceID": deviceID, "status": ShareAccepted})
+}
+
+func (m *MongoStore) findShare(filter bson.M) (schema.DeviceShare, error) {
+	collection := m.collection("MONGO_SHARECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	var share schema.DeviceShare
+	err := collection.FindOne(ctx, filter).Decode(&share)
+	if err == mongo.ErrNoDocuments {
+		return schema.DeviceShare{}, ErrNotFound
+	}
+	return share, err
+}
+
+// SharesByDevice queries the shares of a device, oldest first
+func (m *MongoStore) SharesByDevice(deviceID string) ([]schema.DeviceShare, error) {
+	return m.findShares(bson.M{"deviceID": deviceID})
+}
+
+// SharesByUser queries the accepted shares of the user
+func (m *MongoStore) SharesByUser(userID string) ([]schema.DeviceShare, error) {
+	return m.findShares(bson.M{"userID": userID, "status": ShareAccepted})
+}
+
+// InvitationsByEmail queries the pending invitations of the email unexpired at now
+func (m *MongoStore) InvitationsByEmail(email string, now time.Time) ([]schema.DeviceShare, error) {
+	return m.findShares(bson.M{"email": email, "status": SharePending, "expireAt": bson.M{"$gt": now}})
+}
+
+func (m *MongoStore) findShares(filter bson.M) ([]schema.DeviceShare, error) {
+	collection := m.collection("MONGO_SHARECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var shares []schema.DeviceShare
+	if err := cursor.All(ctx, &shares); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// AcceptShare accepts a pending invitation in one update, so an invitation is only accepted once
+func (m *MongoStore) AcceptShare(shareID, email, userID string, now time.Time) (schema.DeviceShare, error) {
+	collection := m.collection("MONGO_SHARECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	filter := bson.M{
+		"shareID":  shareID,
+		"email":    email,
+		"status":   SharePending,
+		"expireAt": bson.M{"$gt": now},
+	}
+	// Without expireAt the TTL index keeps the accepted share
+	update := bson.M{
+		"$set":   bson.M{"status": ShareAccepted, "userID": userID, "acceptAt": now},
+		"$unset": bson.M{"expireAt": ""},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var share schema.DeviceShare
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&share)
+	if err == mongo.ErrNoDocuments {
+		return schema.DeviceShare{}, ErrNotFound
+	}
+	return share, err
+}
+
+// UpdateShareRole sets the role of a share of the device
+func (m *MongoStore) UpdateShareRole(deviceID, shareID, role string) error {
+	return m.updateShare(bson.M{"shareID": shareID, "deviceID": deviceID, "role": bson.M{"$ne": RoleOwner}}, bson.M{"role": role})
+}
+
+// UpdateShareBookmark sets the bookmark of the accepted share of the device with the user
+func (m *MongoStore) UpdateShareBookmark(userID, deviceID string, bookmark bool) error {
+	return m.updateShare(bson.M{"userID": userID, "deviceID": deviceID, "status": ShareAccepted}, bson.M{"bookmark": bookmark})
+}
+
+func (m *MongoStore) updateShare(filter, set bson.M) error {
+	collection := m.collection("MONGO_SHARECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteShare deletes a share by its ID
+func (m *MongoStore) DeleteShare(shareID string) error {
+	collection := m.collection("MONGO_SHARECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	result, err := collection.DeleteOne(ctx, bson.M{"shareID": shareID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteDeviceShares deletes every share of the device
+func (m *MongoStore) DeleteDeviceShares(deviceID string) error {
+	collection := m.collection("MONGO_SHARECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	_, err := collection.DeleteMany(ctx, bson.M{"deviceID": deviceID})
+	return err
+}
+
+// TransferDevice moves the device from the user to another, ErrNotFound when it is no longer the user's
+func (m *MongoStore) TransferDevice(deviceID, fromUserID, toUserID string) error {
+	collection := m.collection("MONGO_DEVICECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	filter := bson.M{"deviceID": deviceID, "userID": fromUserID}
+	// The asset of the device is in the hierarchy of the previous owner
+	update := bson.M{
+		"$set":   bson.M{"userID": toUserID, "bookmark": false, "currentDate": time.Now()},
+		"$unset": bson.M{"assetID": ""},
+	}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
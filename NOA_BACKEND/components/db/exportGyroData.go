@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+
+	schema "GOLANG_SERVER/components/schema"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// exportBatchSize is how many documents the Mongo cursor fetches per round trip while exporting
+const exportBatchSize = 1000
+
+// ExportTelemetry calls fn for every document of a device in the time range, oldest first.
+// Documents are streamed from the store so memory stays bounded whatever the range.
+func ExportTelemetry(ctx context.Context, query TelemetryQuery, fn func(schema.GyroData) error) error {
+	if query.DeviceID == "" {
+		return &QueryError{"deviceID is required"}
+	}
+	if query.From > 0 && query.To > 0 && query.From > query.To {
+		return &QueryError{"from must be before to"}
+	}
+	return store.EachGyroData(ctx, query, fn)
+}
+
+// EachGyroData iterates a cursor over the telemetry of the device sorted by timestamp
+func (m *MongoStore) EachGyroData(ctx context.Context, query TelemetryQuery, fn func(schema.GyroData) error) error {
+	collection := m.collection("MONGO_COLLECTION")
+
+	filter := bson.M{"deviceid": query.DeviceID}
+	timeStamp := bson.M{}
+	if query.From > 0 {
+		timeStamp["$gte"] = query.From
+	}
+	if query.To > 0 {
+		timeStamp["$lte"] = query.To
+	}
+	if len(timeStamp) > 0 {
+		filter["timestamp"] = timeStamp
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "_id", Value: 1}}).
+		SetBatchSize(exportBatchSize)
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(ctx) {
+		var data schema.GyroData
+		if err := cursor.Decode(&data); err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
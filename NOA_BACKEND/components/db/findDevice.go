@@ -6,15 +6,26 @@ import (
 	"log"
 	"time"
 
-	env "GOLANG_SERVER/components/env"
 	"GOLANG_SERVER/components/schema"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // FindDevice retrieves a device from the database by its DeviceID
 func FindDevice(deviceID string) (*schema.Device, error) {
-	collection := client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_DEVICECOLLECTION"))
+	device, err := store.FindDevice(deviceID)
+	if err != nil {
+		log.Println("Error finding device:", err)
+		return nil, errors.New("device not found")
+	}
+
+	return device, nil
+}
+
+// FindDevice queries the device collection by deviceID
+func (m *MongoStore) FindDevice(deviceID string) (*schema.Device, error) {
+	collection := m.collection("MONGO_DEVICECOLLECTION")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -23,8 +34,10 @@ func FindDevice(deviceID string) (*schema.Device, error) {
 	var device schema.Device
 	err := collection.FindOne(ctx, filter).Decode(&device)
 	if err != nil {
-		log.Println("Error finding device:", err)
-		return nil, errors.New("device not found")
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, err
 	}
 
 	return &device, nil
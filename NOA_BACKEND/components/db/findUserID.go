@@ -6,15 +6,26 @@ import (
 	"log"
 	"time"
 
-	env "GOLANG_SERVER/components/env"
 	"GOLANG_SERVER/components/schema"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // FindUserID retrieves a user from the database by their UserID
 func FindUserID(userID string) (*schema.User, error) {
-	collection := client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_USERCOLLECTION"))
+	user, err := store.FindUserID(userID)
+	if err != nil {
+		log.Println("Error finding user:", err)
+		return nil, errors.New("user not found")
+	}
+
+	return user, nil
+}
+
+// FindUserID queries the user collection by userID
+func (m *MongoStore) FindUserID(userID string) (*schema.User, error) {
+	collection := m.collection("MONGO_USERCOLLECTION")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -23,8 +34,10 @@ func FindUserID(userID string) (*schema.User, error) {
 	var user schema.User
 	err := collection.FindOne(ctx, filter).Decode(&user)
 	if err != nil {
-		log.Println("Error finding user:", err)
-		return nil, errors.New("user not found")
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, err
 	}
 
 	return &user, nil
@@ -4,18 +4,41 @@ import (
 	"context"
 	"time"
 
-	env "GOLANG_SERVER/components/env"
 	schema "GOLANG_SERVER/components/schema"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // find user by email
 func FindUser(email string) (schema.User, error) {
-	collection := client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_USERCOLLECTION")) // Get collection user
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)                             // Create a context with timeout
-	defer cancel()                                                                                       // Defer cancel the context
+	return store.FindUser(email)
+}
+
+// Users lists every user without the password
+func Users() ([]schema.User, error) {
+	return store.Users()
+}
+
+// Registered reports whether a user with the email finished the registration
+func Registered(email string) (bool, error) {
+	user, err := store.FindUserWithPassword(email)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// Users of the former registration only hold their email until verified
+	return user.Password != "", nil
+}
+
+// FindUser queries the user collection by email without the password field
+func (m *MongoStore) FindUser(email string) (schema.User, error) {
+	collection := m.collection("MONGO_USERCOLLECTION")                       // Get collection user
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Create a context with timeout
+	defer cancel()                                                           // Defer cancel the context
 
 	// Check if user exists
 	var result schema.User
@@ -25,14 +48,51 @@ func FindUser(email string) (schema.User, error) {
 	// Use options.FindOne() to set the projection
 	findOptions := options.FindOne().SetProjection(projection)
 
-<<<<<<< HEAD
-=======
 	// Find the user by email
->>>>>>> Final_BN
 	err := collection.FindOne(ctx, filter, findOptions).Decode(&result)
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return schema.User{}, ErrNotFound
+		}
 		return schema.User{}, err
 	}
 
 	return result, nil
 }
+
+// FindUserWithPassword queries the user collection by email including the password hash
+func (m *MongoStore) FindUserWithPassword(email string) (schema.User, error) {
+	collection := m.collection("MONGO_USERCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var result schema.User
+	err := collection.FindOne(ctx, bson.M{"email": email}).Decode(&result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return schema.User{}, ErrNotFound
+		}
+		return schema.User{}, err
+	}
+
+	return result, nil
+}
+
+// Users queries every document of the user collection without the password field
+func (m *MongoStore) Users() ([]schema.User, error) {
+	collection := m.collection("MONGO_USERCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"password": 0}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []schema.User
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
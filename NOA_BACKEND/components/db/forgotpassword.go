@@ -6,26 +6,18 @@ import (
 	"log"
 	"time"
 
-	env "GOLANG_SERVER/components/env"
 	schema "GOLANG_SERVER/components/schema"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // Login checks if the user exists and returns the user object and an error
 func ForgotpasswordCheck(email string) (schema.User, error) {
-	collection = client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_USERCOLLECTION")) // Get collection user
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)                            // Create a context with timeout
-	defer cancel()                                                                                      // Defer cancel the context
-
 	// Check if user exists
-	filter := bson.M{"email": email}
-	var result schema.User
-	err := collection.FindOne(ctx, filter).Decode(&result)
+	result, err := store.FindUserWithPassword(email)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == ErrNotFound {
 			return schema.User{}, errors.New("user not found")
 		}
 		return schema.User{}, err
@@ -37,15 +29,9 @@ func ForgotpasswordCheck(email string) (schema.User, error) {
 }
 
 func ForgotpasswordNewPassword(email string, password string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Create a context with timeout
-	defer cancel()                                                           // Defer cancel the context
-
 	// Check if user exists
-	filter := bson.M{"email": email}
-	var result schema.User
-	err := collection.FindOne(ctx, filter).Decode(&result)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
+	if _, err := store.FindUserWithPassword(email); err != nil {
+		if err == ErrNotFound {
 			return errors.New("user not found")
 		}
 		return err
@@ -61,11 +47,25 @@ func ForgotpasswordNewPassword(email string, password string) error {
 	}
 
 	// Update the user's password
-	update := bson.M{"$set": bson.M{"password": string(hashedPassword)}}
-	_, err = collection.UpdateOne(ctx, filter, update)
+	return store.UpdatePassword(email, string(hashedPassword))
+}
+
+// UpdatePassword replaces the password hash of the user with the email
+func (m *MongoStore) UpdatePassword(email string, hashedPassword string) error {
+	collection := m.collection("MONGO_USERCOLLECTION")                       // Get collection user
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Create a context with timeout
+	defer cancel()                                                           // Defer cancel the context
+
+	filter := bson.M{"email": email}
+	update := bson.M{"$set": bson.M{"password": hashedPassword}}
+	result, err := collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return err
 	}
 
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
 	return nil
 }
@@ -10,6 +10,12 @@ import (
 )
 
 func GetGyroData() ([]schema.GyroData, error) {
+	return store.GyroData()
+}
+
+// GyroData returns every document of the data collection
+func (m *MongoStore) GyroData() ([]schema.GyroData, error) {
+	collection := m.collection("MONGO_COLLECTION")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	cursor, err := collection.Find(ctx, bson.M{})
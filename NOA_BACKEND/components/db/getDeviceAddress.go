@@ -2,62 +2,66 @@ package db
 
 import (
 	"context"
-<<<<<<< HEAD
-	"time"
-
-	env "GOLANG_SERVER/components/env"
-=======
 	"errors"
 	"time"
 
-	env "GOLANG_SERVER/components/env"
 	"GOLANG_SERVER/components/schema"
->>>>>>> Final_BN
 
 	"go.mongodb.org/mongo-driver/bson"
 )
 
-<<<<<<< HEAD
-func GetDeviceAddress() ([]string, error) {
-	collection = client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_DEVICECOLLECTION"))
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Create a context with timeout
-	defer cancel()
-	cursor, err := collection.Find(ctx, bson.M{})
-=======
+// GetDeviceAddress lists the devices of the user followed by the devices shared with the user
 func GetDeviceAddress(userID string) ([]schema.GetDevice, error) {
 	if userID == "" {
 		return nil, errors.New("userID is required")
 	}
 
+	devices, err := store.DevicesByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range devices {
+		devices[i].Role = RoleOwner
+	}
+
+	shares, err := store.SharesByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, share := range shares {
+		if share.Role == RoleOwner {
+			continue
+		}
+		device, err := store.FindDevice(share.DeviceID)
+		if err == ErrNotFound || (err == nil && device.UserID == userID) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// The bookmark of a shared device is the user's own
+		shared := toGetDevice(*device)
+		shared.Role = share.Role
+		shared.Bookmark = share.Bookmark
+		devices = append(devices, shared)
+	}
+	return devices, nil
+}
+
+// DevicesByUser lists the devices of the user from the device collection
+func (m *MongoStore) DevicesByUser(userID string) ([]schema.GetDevice, error) {
 	// เชื่อมต่อกับ MongoDB
-	collection := client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_DEVICECOLLECTION"))
+	collection := m.collection("MONGO_DEVICECOLLECTION")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	// ค้นหาเอกสารทั้งหมดที่ตรงกับ userID
 	cursor, err := collection.Find(ctx, bson.M{"userID": userID})
->>>>>>> Final_BN
 	if err != nil {
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-<<<<<<< HEAD
-	var deviceAddresses []string
-	for cursor.Next(ctx) {
-		var result struct {
-			DeviceAddress string `bson:"deviceaddress"`
-		}
-		if err := cursor.Decode(&result); err != nil {
-			return nil, err
-		}
-		deviceAddresses = append(deviceAddresses, result.DeviceAddress)
-	}
-	if err := cursor.Err(); err != nil {
-		return nil, err
-	}
-	return deviceAddresses, nil
-=======
 	// สร้าง slice สำหรับเก็บผลลัพธ์
 	var devices []schema.GetDevice
 	for cursor.Next(ctx) {
@@ -76,5 +80,4 @@ func GetDeviceAddress(userID string) ([]schema.GetDevice, error) {
 	}
 
 	return devices, nil
->>>>>>> Final_BN
 }
@@ -1,40 +1,20 @@
 package db
 
 import (
-	"context"
 	"log"
-	"time"
-
-	env "GOLANG_SERVER/components/env"
-
-	"go.mongodb.org/mongo-driver/bson"
 )
 
+// GetDeviceAddressByDeviceAddress returns the device address if it is registered, the device address is the deviceID
 func GetDeviceAddressByDeviceAddress(deviceAddress string) ([]string, error) {
-	collection = client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_DEVICECOLLECTION"))
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Create a context with timeout
-	defer cancel()
-
-	filter := bson.M{"deviceaddress": deviceAddress}
-	log.Println("Querying database with filter:", filter)
-	cursor, err := collection.Find(ctx, filter)
+	log.Println("Querying database for device:", deviceAddress)
+	exists, err := store.DeviceExists(deviceAddress)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
 
 	var deviceAddresses []string
-	for cursor.Next(ctx) {
-		var result struct {
-			DeviceAddress string `bson:"deviceaddress"`
-		}
-		if err := cursor.Decode(&result); err != nil {
-			return nil, err
-		}
-		deviceAddresses = append(deviceAddresses, result.DeviceAddress)
-	}
-	if err := cursor.Err(); err != nil {
-		return nil, err
+	if exists {
+		deviceAddresses = append(deviceAddresses, deviceAddress)
 	}
 	log.Println("Found device addresses:", deviceAddresses)
 	return deviceAddresses, nil
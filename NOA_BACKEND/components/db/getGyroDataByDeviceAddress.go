@@ -3,7 +3,6 @@ package db
 import (
 	"context"
 	"errors"
-	"strings"
 	"time"
 
 	schema "GOLANG_SERVER/components/schema"
@@ -11,14 +10,21 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 )
 
+// GetGyroDataByDeviceAddress returns all data of a device, the device address is the deviceID
 func GetGyroDataByDeviceAddress(DeviceAddress string) ([]schema.GyroData, error) {
 	if len(DeviceAddress) == 0 {
 		return []schema.GyroData{}, errors.New("device address is empty")
 	}
 
+	return store.GyroDataByDevice(DeviceAddress)
+}
+
+// GyroDataByDevice returns every document of the data collection sent by the device
+func (m *MongoStore) GyroDataByDevice(deviceID string) ([]schema.GyroData, error) {
+	collection := m.collection("MONGO_COLLECTION")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	cursor, err := collection.Find(ctx, bson.M{strings.ToLower("DeviceAddress"): DeviceAddress})
+	cursor, err := collection.Find(ctx, bson.M{"deviceid": deviceID})
 	if err != nil {
 		return nil, err
 	}
@@ -3,26 +3,38 @@ package db
 import (
 	"context"
 	"errors"
-	"strings"
 	"time"
 
-	env "GOLANG_SERVER/components/env"
 	schema "GOLANG_SERVER/components/schema"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// GetGyroDataByDeviceAddressLatest returns the 50 newest data of a device, the device address is the deviceID
 func GetGyroDataByDeviceAddressLatest(DeviceAddress string) ([]schema.GyroData, error) {
 	if len(DeviceAddress) == 0 {
 		return nil, errors.New("device address is empty")
 	}
-	collection = client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_COLLECTION"))
 
+	gyroData, err := store.LatestGyroData(DeviceAddress, 50)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(gyroData) == 0 {
+		return nil, errors.New("no data found")
+	}
+	return gyroData, nil
+}
+
+// LatestGyroData returns the newest documents of the device sorted by timestamp descending
+func (m *MongoStore) LatestGyroData(deviceID string, limit int64) ([]schema.GyroData, error) {
+	collection := m.collection("MONGO_COLLECTION")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	var gyroData []schema.GyroData
-	cursor, err := collection.Find(ctx, bson.M{strings.ToLower("deviceaddress"): DeviceAddress}, options.Find().SetSort(bson.D{{Key: strings.ToLower("timestamp"), Value: -1}}).SetLimit(50))
+	cursor, err := collection.Find(ctx, bson.M{"deviceid": deviceID}, options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}}).SetLimit(limit))
 	if err != nil {
 		return nil, err
 	}
@@ -31,9 +43,5 @@ func GetGyroDataByDeviceAddressLatest(DeviceAddress string) ([]schema.GyroData,
 	if err = cursor.All(ctx, &gyroData); err != nil {
 		return nil, err
 	}
-
-	if len(gyroData) == 0 {
-		return nil, errors.New("no data found")
-	}
 	return gyroData, nil
 }
@@ -0,0 +1,604 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	schema "GOLANG_SERVER/components/schema"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrHierarchyInUse is returned when deleting an organisation, site or asset that still has something under it
+	ErrHierarchyInUse = errors.New("remove the sites, assets and devices under it first")
+	// ErrHierarchyParent is returned when the organisation, site or parent asset referenced does not exist
+	ErrHierarchyParent = errors.New("parent not found")
+	// ErrAssetCycle is returned when an asset would be moved under itself
+	ErrAssetCycle = errors.New("an asset cannot be under itself")
+	// ErrHierarchyName is returned when an organisation, site or asset has no name
+	ErrHierarchyName = errors.New("name is required")
+)
+
+// HierarchyFilter selects devices by where they are attached in the hierarchy, every field set must match
+type HierarchyFilter struct {
+	OrgID   string // Only devices under this organisation
+	SiteID  string // Only devices under this site
+	AssetID string // Only devices attached to this asset or an asset under it
+	Kind    string // Only devices attached to an asset of this kind or under one
+}
+
+// Empty reports whether the filter selects every device
+func (f HierarchyFilter) Empty() bool {
+	return f == HierarchyFilter{}
+}
+
+// Hierarchy is every organisation, site and asset of a user
+type Hierarchy struct {
+	Organisations []schema.Organisation
+	Sites         []schema.Site
+	Assets        []schema.Asset
+	sites         map[string]schema.Site  // key: siteID
+	assets        map[string]schema.Asset // key: assetID
+}
+
+// LoadHierarchy reads the hierarchy of the user
+func LoadHierarchy(userID string) (*Hierarchy, error) {
+	orgs, err := store.OrganisationsByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	sites, err := store.SitesByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	assets, err := store.AssetsByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Hierarchy{
+		Organisations: orgs,
+		Sites:         sites,
+		Assets:        assets,
+		sites:         make(map[string]schema.Site),
+		assets:        make(map[string]schema.Asset),
+	}
+	for _, site := range sites {
+		h.sites[site.ID] = site
+	}
+	for _, asset := range assets {
+		h.assets[asset.ID] = asset
+	}
+	return h, nil
+}
+
+// Organisation finds an organisation of the hierarchy
+func (h *Hierarchy) Organisation(orgID string) (schema.Organisation, bool) {
+	for _, org := range h.Organisations {
+		if org.ID == orgID {
+			return org, true
+		}
+	}
+	return schema.Organisation{}, false
+}
+
+// Site finds a site of the hierarchy
+func (h *Hierarchy) Site(siteID string) (schema.Site, bool) {
+	site, ok := h.sites[siteID]
+	return site, ok
+}
+
+// Asset finds an asset of the hierarchy
+func (h *Hierarchy) Asset(assetID string) (schema.Asset, bool) {
+	asset, ok := h.assets[assetID]
+	return asset, ok
+}
+
+// Path is the asset followed by its parents up to the top of its site, empty when the asset is not in the hierarchy
+func (h *Hierarchy) Path(assetID string) []schema.Asset {
+	var path []schema.Asset
+	for assetID != "" && len(path) <= len(h.assets) {
+		asset, ok := h.assets[assetID]
+		if !ok {
+			break
+		}
+		path = append(path, asset)
+		assetID = asset.ParentID
+	}
+	return path
+}
+
+// Matches reports whether a device attached to the asset is selected by the filter
+func (h *Hierarchy) Matches(assetID string, filter HierarchyFilter) bool {
+	path := h.Path(assetID)
+	if len(path) == 0 {
+		return false
+	}
+	siteID := path[0].SiteID
+	if filter.SiteID != "" && siteID != filter.SiteID {
+		return false
+	}
+	if filter.OrgID != "" && h.sites[siteID].OrgID != filter.OrgID {
+		return false
+	}
+
+	underAsset, ofKind := filter.AssetID == "", filter.Kind == ""
+	for _, asset := range path {
+		if asset.ID == filter.AssetID {
+			underAsset = true
+		}
+		if filter.Kind != "" && strings.EqualFold(asset.Kind, filter.Kind) {
+			ofKind = true
+		}
+	}
+	return underAsset && ofKind
+}
+
+// FilterDevices keeps the devices of the list attached under the filter in the hierarchy of the user.
+// A shared device is attached in the hierarchy of its owner, so it never matches a filter of the user.
+func FilterDevices(userID string, devices []schema.GetDevice, filter HierarchyFilter) ([]schema.GetDevice, error) {
+	if filter.Empty() {
+		return devices, nil
+	}
+	h, err := LoadHierarchy(userID)
+	if err != nil {
+		return nil, err
+	}
+	kept := []schema.GetDevice{}
+	for _, device := range devices {
+		if device.Role != RoleOwner && device.Role != "" {
+			continue
+		}
+		if device.AssetID != "" && h.Matches(device.AssetID, filter) {
+			kept = append(kept, device)
+		}
+	}
+	return kept, nil
+}
+
+// HierarchyDevices returns the IDs of the devices of the user attached under the filter, never nil
+func HierarchyDevices(userID string, filter HierarchyFilter) ([]string, error) {
+	devices, err := GetDeviceAddress(userID)
+	if err != nil {
+		return nil, err
+	}
+	if devices, err = FilterDevices(userID, devices, filter); err != nil {
+		return nil, err
+	}
+	deviceIDs := make([]string, 0, len(devices))
+	for _, device := range devices {
+		deviceIDs = append(deviceIDs, device.DeviceID)
+	}
+	return deviceIDs, nil
+}
+
+// OrganisationNode is an organisation with its sites
+type OrganisationNode struct {
+	schema.Organisation
+	Sites []SiteNode `json:"sites"`
+}
+
+// SiteNode is a site with the assets at its top
+type SiteNode struct {
+	schema.Site
+	Assets []AssetNode `json:"assets"`
+}
+
+// AssetNode is an asset with the devices attached to it and the assets under it
+type AssetNode struct {
+	schema.Asset
+	Devices []string    `json:"devices"`
+	Assets  []AssetNode `json:"assets"`
+}
+
+// HierarchyTree returns the hierarchy of the user as a tree with the devices of the user attached to each asset
+func HierarchyTree(userID string) ([]OrganisationNode, error) {
+	h, err := LoadHierarchy(userID)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := store.DevicesByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	attached := make(map[string][]string)
+	for _, device := range devices {
+		if device.AssetID != "" {
+			attached[device.AssetID] = append(attached[device.AssetID], device.DeviceID)
+		}
+	}
+	children := make(map[string][]schema.Asset) // key: parentID, siteID for the top assets
+	for _, asset := range h.Assets {
+		parent := asset.ParentID
+		if parent == "" {
+			parent = asset.SiteID
+		}
+		children[parent] = append(children[parent], asset)
+	}
+
+	var assetNodes func(parent string) []AssetNode
+	assetNodes = func(parent string) []AssetNode {
+		nodes := []AssetNode{}
+		for _, asset := range children[parent] {
+			devices := attached[asset.ID]
+			if devices == nil {
+				devices = []string{}
+			}
+			nodes = append(nodes, AssetNode{Asset: asset, Devices: devices, Assets: assetNodes(asset.ID)})
+		}
+		return nodes
+	}
+
+	tree := []OrganisationNode{}
+	for _, org := range h.Organisations {
+		node := OrganisationNode{Organisation: org, Sites: []SiteNode{}}
+		for _, site := range h.Sites {
+			if site.OrgID == org.ID {
+				node.Sites = append(node.Sites, SiteNode{Site: site, Assets: assetNodes(site.ID)})
+			}
+		}
+		tree = append(tree, node)
+	}
+	return tree, nil
+}
+
+// CreateOrganisation stores a new organisation of the user
+func CreateOrganisation(org schema.Organisation) (schema.Organisation, error) {
+	if strings.TrimSpace(org.Name) == "" {
+		return schema.Organisation{}, ErrHierarchyName
+	}
+	now := time.Now()
+	org.ID = uuid.New().String()
+	org.CreateAt = now
+	org.UpdateAt = now
+	if err := store.InsertOrganisation(org); err != nil {
+		return schema.Organisation{}, err
+	}
+	return org, nil
+}
+
+// UpdateOrganisation renames an organisation of the user
+func UpdateOrganisation(org schema.Organisation) (schema.Organisation, error) {
+	if strings.TrimSpace(org.Name) == "" {
+		return schema.Organisation{}, ErrHierarchyName
+	}
+	h, err := LoadHierarchy(org.UserID)
+	if err != nil {
+		return schema.Organisation{}, err
+	}
+	current, ok := h.Organisation(org.ID)
+	if !ok {
+		return schema.Organisation{}, ErrNotFound
+	}
+	org.CreateAt = current.CreateAt
+	org.UpdateAt = time.Now()
+	if err := store.UpdateOrganisation(org); err != nil {
+		return schema.Organisation{}, err
+	}
+	return org, nil
+}
+
+// DeleteOrganisation deletes an organisation of the user without sites
+func DeleteOrganisation(userID, orgID string) error {
+	sites, err := store.SitesByUser(userID)
+	if err != nil {
+		return err
+	}
+	for _, site := range sites {
+		if site.OrgID == orgID {
+			return ErrHierarchyInUse
+		}
+	}
+	return store.DeleteOrganisation(userID, orgID)
+}
+
+// CreateSite stores a new site in an organisation of the user
+func CreateSite(site schema.Site) (schema.Site, error) {
+	if err := checkSite(site); err != nil {
+		return schema.Site{}, err
+	}
+	now := time.Now()
+	site.ID = uuid.New().String()
+	site.CreateAt = now
+	site.UpdateAt = now
+	if err := store.InsertSite(site); err != nil {
+		return schema.Site{}, err
+	}
+	return site, nil
+}
+
+// UpdateSite changes a site of the user, it may move to another organisation of the user
+func UpdateSite(site schema.Site) (schema.Site, error) {
+	if err := checkSite(site); err != nil {
+		return schema.Site{}, err
+	}
+	h, err := LoadHierarchy(site.UserID)
+	if err != nil {
+		return schema.Site{}, err
+	}
+	current, ok := h.Site(site.ID)
+	if !ok {
+		return schema.Site{}, ErrNotFound
+	}
+	site.CreateAt = current.CreateAt
+	site.UpdateAt = time.Now()
+	if err := store.UpdateSite(site); err != nil {
+		return schema.Site{}, err
+	}
+	return site, nil
+}
+
+// checkSite checks the name of the site and that its organisation is the user's
+func checkSite(site schema.Site) error {
+	if strings.TrimSpace(site.Name) == "" {
+		return ErrHierarchyName
+	}
+	h, err := LoadHierarchy(site.UserID)
+	if err != nil {
+		return err
+	}
+	if _, ok := h.Organisation(site.OrgID); !ok {
+		return ErrHierarchyParent
+	}
+	return nil
+}
+
+// DeleteSite deletes a site of the user without assets
+func DeleteSite(userID, siteID string) error {
+	assets, err := store.AssetsByUser(userID)
+	if err != nil {
+		return err
+	}
+	for _, asset := range assets {
+		if asset.SiteID == siteID {
+			return ErrHierarchyInUse
+		}
+	}
+	return store.DeleteSite(userID, siteID)
+}
+
+// CreateAsset stores a new asset in a site of the user, under a parent asset of the same site when set
+func CreateAsset(asset schema.Asset) (schema.Asset, error) {
+	if strings.TrimSpace(asset.Name) == "" {
+		return schema.Asset{}, ErrHierarchyName
+	}
+	h, err := LoadHierarchy(asset.UserID)
+	if err != nil {
+		return schema.Asset{}, err
+	}
+	if asset.ParentID != "" {
+		parent, ok := h.Asset(asset.ParentID)
+		if !ok {
+			return schema.Asset{}, ErrHierarchyParent
+		}
+		// The site of an asset is the site of its parent
+		asset.SiteID = parent.SiteID
+	}
+	if _, ok := h.Site(asset.SiteID); !ok {
+		return schema.Asset{}, ErrHierarchyParent
+	}
+
+	now := time.Now()
+	asset.ID = uuid.New().String()
+	asset.CreateAt = now
+	asset.UpdateAt = now
+	if err := store.InsertAsset(asset); err != nil {
+		return schema.Asset{}, err
+	}
+	return asset, nil
+}
+
+// UpdateAsset changes the name, kind and parent of an asset of the user, an asset stays in its site
+func UpdateAsset(asset schema.Asset) (schema.Asset, error) {
+	if strings.TrimSpace(asset.Name) == "" {
+		return schema.Asset{}, ErrHierarchyName
+	}
+	h, err := LoadHierarchy(asset.UserID)
+	if err != nil {
+		return schema.Asset{}, err
+	}
+	current, ok := h.Asset(asset.ID)
+	if !ok {
+		return schema.Asset{}, ErrNotFound
+	}
+	if asset.ParentID != "" {
+		parent, ok := h.Asset(asset.ParentID)
+		if !ok || parent.SiteID != current.SiteID {
+			return schema.Asset{}, ErrHierarchyParent
+		}
+		for _, above := range h.Path(asset.ParentID) {
+			if above.ID == asset.ID {
+				return schema.Asset{}, ErrAssetCycle
+			}
+		}
+	}
+
+	asset.SiteID = current.SiteID
+	asset.CreateAt = current.CreateAt
+	asset.UpdateAt = time.Now()
+	if err := store.UpdateAsset(asset); err != nil {
+		return schema.Asset{}, err
+	}
+	return asset, nil
+}
+
+// DeleteAsset deletes an asset of the user without assets under it or devices attached
+func DeleteAsset(userID, assetID string) error {
+	assets, err := store.AssetsByUser(userID)
+	if err != nil {
+		return err
+	}
+	for _, asset := range assets {
+		if asset.ParentID == assetID {
+			return ErrHierarchyInUse
+		}
+	}
+	devices, err := store.DevicesByUser(userID)
+	if err != nil {
+		return err
+	}
+	for _, device := range devices {
+		if device.AssetID == assetID {
+			return ErrHierarchyInUse
+		}
+	}
+	return store.DeleteAsset(userID, assetID)
+}
+
+// AttachDevice attaches a device of the user to an asset of the user, or detaches it when assetID is empty
+func AttachDevice(userID, deviceID, assetID string) error {
+	if assetID != "" {
+		h, err := LoadHierarchy(userID)
+		if err != nil {
+			return err
+		}
+		if _, ok := h.Asset(assetID); !ok {
+			return ErrHierarchyParent
+		}
+	}
+	return store.SetDeviceAsset(userID, deviceID, assetID)
+}
+
+// InsertOrganisation stores a new organisation
+func (m *MongoStore) InsertOrganisation(org schema.Organisation) error {
+	return m.insertHierarchy("MONGO_ORGANISATIONCOLLECTION", org)
+}
+
+// UpdateOrganisation replaces the organisation with the same ID and user
+func (m *MongoStore) UpdateOrganisation(org schema.Organisation) error {
+	return m.replaceHierarchy("MONGO_ORGANISATIONCOLLECTION", bson.M{"orgID": org.ID, "userID": org.UserID}, org)
+}
+
+// DeleteOrganisation deletes an organisation of the user
+func (m *MongoStore) DeleteOrganisation(userID, orgID string) error {
+	return m.deleteHierarchy("MONGO_ORGANISATIONCOLLECTION", bson.M{"orgID": orgID, "userID": userID})
+}
+
+// OrganisationsByUser lists the organisations of the user by name
+func (m *MongoStore) OrganisationsByUser(userID string) ([]schema.Organisation, error) {
+	var orgs []schema.Organisation
+	err := m.findHierarchy("MONGO_ORGANISATIONCOLLECTION", userID, &orgs)
+	return orgs, err
+}
+
+// InsertSite stores a new site
+func (m *MongoStore) InsertSite(site schema.Site) error {
+	return m.insertHierarchy("MONGO_SITECOLLECTION", site)
+}
+
+// UpdateSite replaces the site with the same ID and user
+func (m *MongoStore) UpdateSite(site schema.Site) error {
+	return m.replaceHierarchy("MONGO_SITECOLLECTION", bson.M{"siteID": site.ID, "userID": site.UserID}, site)
+}
+
+// DeleteSite deletes a site of the user
+func (m *MongoStore) DeleteSite(userID, siteID string) error {
+	return m.deleteHierarchy("MONGO_SITECOLLECTION", bson.M{"siteID": siteID, "userID": userID})
+}
+
+// SitesByUser lists the sites of the user by name
+func (m *MongoStore) SitesByUser(userID string) ([]schema.Site, error) {
+	var sites []schema.Site
+	err := m.findHierarchy("MONGO_SITECOLLECTION", userID, &sites)
+	return sites, err
+}
+
+// InsertAsset stores a new asset
+func (m *MongoStore) InsertAsset(asset schema.Asset) error {
+	return m.insertHierarchy("MONGO_ASSETCOLLECTION", asset)
+}
+
+// UpdateAsset replaces the asset with the same ID and user
+func (m *MongoStore) UpdateAsset(asset schema.Asset) error {
+	return m.replaceHierarchy("MONGO_ASSETCOLLECTION", bson.M{"assetID": asset.ID, "userID": asset.UserID}, asset)
+}
+
+// DeleteAsset deletes an asset of the user
+func (m *MongoStore) DeleteAsset(userID, assetID string) error {
+	return m.deleteHierarchy("MONGO_ASSETCOLLECTION", bson.M{"assetID": assetID, "userID": userID})
+}
+
+// AssetsByUser lists the assets of the user by name
+func (m *MongoStore) AssetsByUser(userID string) ([]schema.Asset, error) {
+	var assets []schema.Asset
+	err := m.findHierarchy("MONGO_ASSETCOLLECTION", userID, &assets)
+	return assets, err
+}
+
+// SetDeviceAsset sets or, when empty, removes the assetID of a device of the user
+func (m *MongoStore) SetDeviceAsset(userID, deviceID, assetID string) error {
+	collection := m.collection("MONGO_DEVICECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"assetID": assetID, "currentDate": time.Now()}}
+	if assetID == "" {
+		update = bson.M{"$unset": bson.M{"assetID": ""}, "$set": bson.M{"currentDate": time.Now()}}
+	}
+	result, err := collection.UpdateOne(ctx, bson.M{"deviceID": deviceID, "userID": userID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *MongoStore) insertHierarchy(key string, doc interface{}) error {
+	collection := m.collection(key)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.InsertOne(ctx, doc)
+	return err
+}
+
+func (m *MongoStore) replaceHierarchy(key string, filter bson.M, doc interface{}) error {
+	collection := m.collection(key)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := collection.ReplaceOne(ctx, filter, doc)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *MongoStore) deleteHierarchy(key string, filter bson.M) error {
+	collection := m.collection(key)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// findHierarchy decodes every document of the user sorted by name into out, a pointer to a slice
+func (m *MongoStore) findHierarchy(key, userID string, out interface{}) error {
+	collection := m.collection(key)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{"userID": userID}, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	return cursor.All(ctx, out)
+}
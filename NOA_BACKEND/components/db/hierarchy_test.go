@@ -0,0 +1,191 @@
+package db
+
+import (
+	"slices"
+	"testing"
+
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// testHierarchy is org1/site1/line1/motor1 and org2/site2/line2/motor2 of a user
+type testHierarchy struct {
+	org1, org2, site1, site2     string
+	line1, motor1, line2, motor2 string
+}
+
+func newTestHierarchy(t *testing.T, userID string) testHierarchy {
+	t.Helper()
+	var h testHierarchy
+	must := func(id string, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	org := func(name string) string {
+		o, err := CreateOrganisation(schema.Organisation{UserID: userID, Name: name})
+		return must(o.ID, err)
+	}
+	site := func(orgID string) string {
+		s, err := CreateSite(schema.Site{UserID: userID, OrgID: orgID, Name: "plant"})
+		return must(s.ID, err)
+	}
+	asset := func(siteID, parentID, kind string) string {
+		a, err := CreateAsset(schema.Asset{UserID: userID, SiteID: siteID, ParentID: parentID, Name: kind, Kind: kind})
+		return must(a.ID, err)
+	}
+	h.org1, h.org2 = org("org1"), org("org2")
+	h.site1, h.site2 = site(h.org1), site(h.org2)
+	h.line1 = asset(h.site1, "", "line")
+	h.motor1 = asset("", h.line1, "Motor")
+	h.line2 = asset(h.site2, "", "line")
+	h.motor2 = asset("", h.line2, "motor")
+	return h
+}
+
+func TestHierarchyMatches(t *testing.T) {
+	UseStore(NewMemoryStore())
+	ids := newTestHierarchy(t, "userA")
+	h, err := LoadHierarchy("userA")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		assetID string
+		filter  HierarchyFilter
+		want    bool
+	}{
+		{"no filter", ids.motor1, HierarchyFilter{}, true},
+		{"organisation", ids.motor1, HierarchyFilter{OrgID: ids.org1}, true},
+		{"other organisation", ids.motor1, HierarchyFilter{OrgID: ids.org2}, false},
+		{"site", ids.motor1, HierarchyFilter{SiteID: ids.site1}, true},
+		{"other site", ids.motor1, HierarchyFilter{SiteID: ids.site2}, false},
+		{"asset itself", ids.motor1, HierarchyFilter{AssetID: ids.motor1}, true},
+		{"asset above", ids.motor1, HierarchyFilter{AssetID: ids.line1}, true},
+		{"asset below", ids.line1, HierarchyFilter{AssetID: ids.motor1}, false},
+		{"asset of another site", ids.motor1, HierarchyFilter{AssetID: ids.line2}, false},
+		{"kind ignores case", ids.motor1, HierarchyFilter{Kind: "motor"}, true},
+		{"kind above", ids.motor1, HierarchyFilter{Kind: "line"}, true},
+		{"kind below", ids.line1, HierarchyFilter{Kind: "motor"}, false},
+		{"other kind", ids.motor1, HierarchyFilter{Kind: "pump"}, false},
+		{"site and kind", ids.motor2, HierarchyFilter{SiteID: ids.site2, Kind: "motor"}, true},
+		{"every field must match", ids.motor2, HierarchyFilter{OrgID: ids.org1, Kind: "motor"}, false},
+		{"unknown asset", "unknown", HierarchyFilter{}, false},
+	}
+	for _, tt := range tests {
+		if got := h.Matches(tt.assetID, tt.filter); got != tt.want {
+			t.Errorf("%s: Matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHierarchyAssetCycle(t *testing.T) {
+	UseStore(NewMemoryStore())
+	ids := newTestHierarchy(t, "userA")
+
+	for _, parentID := range []string{ids.motor1, ids.line1} {
+		if _, err := UpdateAsset(schema.Asset{ID: ids.line1, UserID: "userA", ParentID: parentID, Name: "line"}); err != ErrAssetCycle {
+			t.Fatalf("line under %s: %v", parentID, err)
+		}
+	}
+	if _, err := UpdateAsset(schema.Asset{ID: ids.motor1, UserID: "userA", ParentID: ids.line2, Name: "motor"}); err != ErrHierarchyParent {
+		t.Fatalf("motor moved to another site: %v", err)
+	}
+	// Another user cannot build on the hierarchy
+	if _, err := CreateAsset(schema.Asset{UserID: "userB", ParentID: ids.line1, Name: "motor"}); err != ErrHierarchyParent {
+		t.Fatalf("asset under the line of another user: %v", err)
+	}
+
+	// Moving up is fine
+	moved, err := UpdateAsset(schema.Asset{ID: ids.motor1, UserID: "userA", Name: "motor"})
+	if err != nil || moved.ParentID != "" || moved.SiteID != ids.site1 {
+		t.Fatalf("motor moved to the top: %+v %v", moved, err)
+	}
+}
+
+func TestHierarchyDeleteInUse(t *testing.T) {
+	UseStore(NewMemoryStore())
+	ids := newTestHierarchy(t, "userA")
+	if err := SaveDevice("machine", "deviceA", "userA", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AttachDevice("userA", "deviceA", ids.motor1); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, err := range map[string]error{
+		"organisation with a site": DeleteOrganisation("userA", ids.org1),
+		"site with an asset":       DeleteSite("userA", ids.site1),
+		"asset with an asset":      DeleteAsset("userA", ids.line1),
+		"asset with a device":      DeleteAsset("userA", ids.motor1),
+	} {
+		if err != ErrHierarchyInUse {
+			t.Fatalf("delete %s: %v", name, err)
+		}
+	}
+
+	// Emptied from the bottom up, every level can go
+	if err := AttachDevice("userA", "deviceA", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, del := range []func() error{
+		func() error { return DeleteAsset("userA", ids.motor1) },
+		func() error { return DeleteAsset("userA", ids.line1) },
+		func() error { return DeleteSite("userA", ids.site1) },
+		func() error { return DeleteOrganisation("userA", ids.org1) },
+	} {
+		if err := del(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHierarchyDevicesLeavesSharedOut(t *testing.T) {
+	UseStore(NewMemoryStore())
+	a := newTestHierarchy(t, "userA")
+	b := newTestHierarchy(t, "userB")
+	for deviceID, owner := range map[string]string{"motorA1": "userA", "motorA2": "userA", "loose": "userA", "motorB": "userB"} {
+		if err := SaveDevice("machine", deviceID, owner, "hash"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, attach := range []struct{ userID, deviceID, assetID string }{
+		{"userA", "motorA1", a.motor1},
+		{"userA", "motorA2", a.motor2},
+		{"userB", "motorB", b.motor1},
+	} {
+		if err := AttachDevice(attach.userID, attach.deviceID, attach.assetID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	share := schema.DeviceShare{ID: "share", DeviceID: "motorB", OwnerID: "userB", UserID: "userA", Role: RoleViewer, Status: ShareAccepted}
+	if err := store.InsertShare(share); err != nil {
+		t.Fatal(err)
+	}
+
+	devices := func(filter HierarchyFilter) []string {
+		t.Helper()
+		ids, err := HierarchyDevices("userA", filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+	if got := devices(HierarchyFilter{}); !slices.Equal(got, []string{"loose", "motorA1", "motorA2", "motorB"}) {
+		t.Fatalf("no filter: %v", got)
+	}
+	// The shared motor is under a motor of user B, not of user A
+	if got := devices(HierarchyFilter{Kind: "motor"}); !slices.Equal(got, []string{"motorA1", "motorA2"}) {
+		t.Fatalf("kind motor: %v", got)
+	}
+	if got := devices(HierarchyFilter{SiteID: a.site2}); !slices.Equal(got, []string{"motorA2"}) {
+		t.Fatalf("site 2: %v", got)
+	}
+	if got := devices(HierarchyFilter{AssetID: b.motor1}); len(got) != 0 {
+		t.Fatalf("asset of user B: %v", got)
+	}
+}
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	schema "GOLANG_SERVER/components/schema"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// UpdateMachineClass sets the ISO 10816-3 class of a device, the class must be validated by the caller
+func UpdateMachineClass(userID, deviceID string, class schema.MachineClass) error {
+	return store.UpdateMachineClass(userID, deviceID, class)
+}
+
+// UpdateMachineClass sets the machineClass field of the device
+func (m *MongoStore) UpdateMachineClass(userID, deviceID string, class schema.MachineClass) error {
+	collection := m.collection("MONGO_DEVICECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"userID": userID, "deviceID": deviceID}
+	update := bson.M{"$set": bson.M{"machineClass": class, "currentDate": time.Now()}}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
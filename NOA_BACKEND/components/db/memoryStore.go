@@ -0,0 +1,1560 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// MemoryStore is a Store kept in process memory, used to run the server and its tests without MongoDB
+type MemoryStore struct {
+	mu        sync.RWMutex
+	users     map[string]schema.User        // key: userID
+	devices   map[string]schema.Device      // key: deviceID
+	otps      map[string]schema.OTP         // key: subject + "/" + purpose
+	otpSends  map[string]schema.OTPSends    // key: subject
+	telemetry []schema.GyroData             // in insertion order
+	commands  []schema.Command              // in insertion order
+	usage     map[string]schema.DeviceUsage // key: deviceID + "/" + day
+	batches   map[string]bool               // usage batch IDs already added
+	rules     []schema.AlertRule            // in insertion order
+	alerts    []schema.Alert                // in insertion order
+	notices   []schema.Notification         // in insertion order
+	webhooks  []schema.Webhook              // in insertion order
+	delivered []schema.WebhookDelivery      // webhook deliveries in insertion order
+	resets    []schema.PasswordReset        // in insertion order
+	pairings  []schema.Provisioning         // in insertion order
+	shares    []schema.DeviceShare          // in insertion order
+	orgs      []schema.Organisation         // in insertion order
+	sites     []schema.Site                 // in insertion order
+	assets    []schema.Asset                // in insertion order
+	pending   map[string]schema.PendingUser // key: email
+	sessions  map[string]schema.Session     // key: sessionID
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:    make(map[string]schema.User),
+		devices:  make(map[string]schema.Device),
+		otps:     make(map[string]schema.OTP),
+		otpSends: make(map[string]schema.OTPSends),
+		pending:  make(map[string]schema.PendingUser),
+		sessions: make(map[string]schema.Session),
+		usage:    make(map[string]schema.DeviceUsage),
+		batches:  make(map[string]bool),
+	}
+}
+
+// userByEmail returns the user with the email, the caller must hold the lock
+func (s *MemoryStore) userByEmail(email string) (schema.User, bool) {
+	for _, user := range s.users {
+		if user.Email == email {
+			return user, true
+		}
+	}
+	return schema.User{}, false
+}
+
+// InsertUser stores a new user if the email is not taken
+func (s *MemoryStore) InsertUser(user schema.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.userByEmail(user.Email); exists {
+		return ErrEmailExists
+	}
+	s.users[user.ID] = user
+	return nil
+}
+
+// UpdateUser sets the username and password of the user with the same email
+func (s *MemoryStore) UpdateUser(user schema.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.userByEmail(user.Email)
+	if !ok {
+		return ErrNotFound
+	}
+	existing.Username = user.Username
+	existing.Password = user.Password
+	s.users[existing.ID] = existing
+	return nil
+}
+
+// FindUser finds a user by email without the password
+func (s *MemoryStore) FindUser(email string) (schema.User, error) {
+	user, err := s.FindUserWithPassword(email)
+	user.Password = ""
+	return user, err
+}
+
+// FindUserWithPassword finds a user by email including the password hash
+func (s *MemoryStore) FindUserWithPassword(email string) (schema.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.userByEmail(email)
+	if !ok {
+		return schema.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+// FindUserID finds a user by userID
+func (s *MemoryStore) FindUserID(userID string) (*schema.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+// Users lists every user without the password, ordered by userID
+func (s *MemoryStore) Users() ([]schema.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]schema.User, 0, len(s.users))
+	for _, user := range s.users {
+		user.Password = ""
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+	return users, nil
+}
+
+// UpdatePassword replaces the password hash of the user with the email
+func (s *MemoryStore) UpdatePassword(email string, hashedPassword string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.userByEmail(email)
+	if !ok {
+		return ErrNotFound
+	}
+	user.Password = hashedPassword
+	s.users[user.ID] = user
+	return nil
+}
+
+// InsertDevice stores a new device
+func (s *MemoryStore) InsertDevice(device schema.Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.devices[device.ID] = device
+	return nil
+}
+
+// FindDevice finds a device by deviceID
+func (s *MemoryStore) FindDevice(deviceID string) (*schema.Device, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	device, ok := s.devices[deviceID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &device, nil
+}
+
+// DeviceExists checks if a deviceID is registered
+func (s *MemoryStore) DeviceExists(deviceID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.devices[deviceID]
+	return ok, nil
+}
+
+// DevicesByUser lists the devices of a user ordered by creation date
+func (s *MemoryStore) DevicesByUser(userID string) ([]schema.GetDevice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var devices []schema.GetDevice
+	for _, device := range s.devices {
+		if device.UserID == userID {
+			devices = append(devices, toGetDevice(device))
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].CreateDate.Before(devices[j].CreateDate)
+	})
+	return devices, nil
+}
+
+// DeleteDevice deletes a device owned by the user
+func (s *MemoryStore) DeleteDevice(userID, deviceID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	device, ok := s.devices[deviceID]
+	if !ok || device.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.devices, deviceID)
+	return nil
+}
+
+// UpdateBookmark changes the bookmark flag of a device owned by the user
+func (s *MemoryStore) UpdateBookmark(userID, deviceID string, bookmark bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	device, ok := s.devices[deviceID]
+	if !ok || device.UserID != userID {
+		return ErrNotFound
+	}
+	device.Bookmark = bookmark
+	s.devices[deviceID] = device
+	return nil
+}
+
+// UpdateMachineClass sets the machine class of a device owned by the user
+func (s *MemoryStore) UpdateMachineClass(userID, deviceID string, class schema.MachineClass) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	device, ok := s.devices[deviceID]
+	if !ok || device.UserID != userID {
+		return ErrNotFound
+	}
+	device.MachineClass = class
+	device.CurrentDate = time.Now()
+	s.devices[deviceID] = device
+	return nil
+}
+
+// UpdateDeviceProfile sets the machine profile of a device owned by the user
+func (s *MemoryStore) UpdateDeviceProfile(userID, deviceID string, profile schema.DeviceProfile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	device, ok := s.devices[deviceID]
+	if !ok || device.UserID != userID {
+		return ErrNotFound
+	}
+	device.Profile = profile
+	device.CurrentDate = time.Now()
+	s.devices[deviceID] = device
+	return nil
+}
+
+// UpdatePresence sets the online status of a device and its last seen date when not zero
+func (s *MemoryStore) UpdatePresence(deviceID string, online bool, lastSeen time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	device, ok := s.devices[deviceID]
+	if !ok {
+		return ErrNotFound
+	}
+	device.Status = online
+	if !lastSeen.IsZero() {
+		device.LastSeen = lastSeen
+	}
+	s.devices[deviceID] = device
+	return nil
+}
+
+// StaleOnlineDevices lists the devices still online that were last seen before cutoff
+func (s *MemoryStore) StaleOnlineDevices(cutoff time.Time) ([]schema.GetDevice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var devices []schema.GetDevice
+	for _, device := range s.devices {
+		if device.Status && device.LastSeen.Before(cutoff) {
+			devices = append(devices, toGetDevice(device))
+		}
+	}
+	return devices, nil
+}
+
+// TransferDevice moves a device of the user to another user
+func (s *MemoryStore) TransferDevice(deviceID, fromUserID, toUserID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	device, ok := s.devices[deviceID]
+	if !ok || device.UserID != fromUserID {
+		return ErrNotFound
+	}
+	device.UserID = toUserID
+	device.Bookmark = false
+	device.AssetID = ""
+	device.CurrentDate = time.Now()
+	s.devices[deviceID] = device
+	return nil
+}
+
+// SetDeviceAsset attaches a device of the user to an asset, or detaches it when assetID is empty
+func (s *MemoryStore) SetDeviceAsset(userID, deviceID, assetID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	device, ok := s.devices[deviceID]
+	if !ok || device.UserID != userID {
+		return ErrNotFound
+	}
+	device.AssetID = assetID
+	device.CurrentDate = time.Now()
+	s.devices[deviceID] = device
+	return nil
+}
+
+// SaveOTP inserts or replaces the OTP of the same subject and purpose
+func (s *MemoryStore) SaveOTP(otp schema.OTP) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.otps[otp.Subject+"/"+otp.Purpose] = otp
+	return nil
+}
+
+// FindOTP gets the OTP of the subject and purpose if it has not expired
+func (s *MemoryStore) FindOTP(subject, purpose string, now time.Time) (schema.OTP, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	otp, ok := s.otps[subject+"/"+purpose]
+	if !ok || !otp.ExpireAt.After(now) {
+		return schema.OTP{}, ErrNotFound
+	}
+	return otp, nil
+}
+
+// AttemptOTP increments the attempts of the unexpired OTP and returns it
+func (s *MemoryStore) AttemptOTP(subject, purpose string, now time.Time) (schema.OTP, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := subject + "/" + purpose
+	otp, ok := s.otps[key]
+	if !ok || !otp.ExpireAt.After(now) {
+		delete(s.otps, key)
+		return schema.OTP{}, ErrNotFound
+	}
+	otp.Attempts++
+	s.otps[key] = otp
+	return otp, nil
+}
+
+// LogOTPSend logs the send unless the subject had one within cooldown or max within window
+func (s *MemoryStore) LogOTPSend(subject string, now time.Time, cooldown, window time.Duration, max int) (schema.OTPSends, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sends := schema.OTPSends{Subject: subject}
+	for _, at := range s.otpSends[subject].Sends {
+		if at.After(now.Add(-window)) {
+			sends.Sends = append(sends.Sends, at)
+		}
+	}
+	sends.ExpireAt = s.otpSends[subject].ExpireAt
+	if n := len(sends.Sends); n >= max || (n > 0 && sends.Sends[n-1].After(now.Add(-cooldown))) {
+		s.otpSends[subject] = sends
+		return sends, false, nil
+	}
+	sends.Sends = append(sends.Sends, now)
+	sends.ExpireAt = now.Add(window)
+	s.otpSends[subject] = sends
+	return sends, true, nil
+}
+
+// DeleteOTP deletes the OTP of the subject and purpose
+func (s *MemoryStore) DeleteOTP(subject, purpose string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := subject + "/" + purpose
+	if _, ok := s.otps[key]; !ok {
+		return ErrNotFound
+	}
+	delete(s.otps, key)
+	return nil
+}
+
+// InsertSession stores a new session
+func (s *MemoryStore) InsertSession(session schema.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.ID] = session
+	return nil
+}
+
+// FindSession finds a session by ID
+func (s *MemoryStore) FindSession(sessionID string) (schema.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return schema.Session{}, ErrNotFound
+	}
+	return session, nil
+}
+
+// RotateSession replaces the refresh hash of an active session still at oldHash
+func (s *MemoryStore) RotateSession(sessionID, oldHash, newHash string, now, expireAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok || session.RevokedAt != nil || session.RefreshHash != oldHash {
+		return ErrNotFound
+	}
+	session.PreviousHash = oldHash
+	session.RefreshHash = newHash
+	session.RefreshAt = now
+	session.ExpireAt = expireAt
+	s.sessions[sessionID] = session
+	return nil
+}
+
+// RevokeSession revokes one session of the user
+func (s *MemoryStore) RevokeSession(userID, sessionID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok || session.UserID != userID {
+		return ErrNotFound
+	}
+	if session.RevokedAt == nil {
+		session.RevokedAt = &at
+		s.sessions[sessionID] = session
+	}
+	return nil
+}
+
+// RevokeSessions revokes every active session of the user
+func (s *MemoryStore) RevokeSessions(userID string, at time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for id, session := range s.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &at
+			s.sessions[id] = session
+			count++
+		}
+	}
+	return count, nil
+}
+
+// SavePendingUser inserts or replaces the registration of the same email
+func (s *MemoryStore) SavePendingUser(pending schema.PendingUser) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending[pending.Email] = pending
+	return nil
+}
+
+// InsertPendingUser inserts the registration unless the email has one unexpired at now
+func (s *MemoryStore) InsertPendingUser(pending schema.PendingUser, now time.Time) (schema.PendingUser, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if waiting, ok := s.pending[pending.Email]; ok && waiting.ExpireAt.After(now) {
+		return waiting, false, nil
+	}
+	s.pending[pending.Email] = pending
+	return pending, true, nil
+}
+
+// FindPendingUser gets the registration of the email if it has not expired
+func (s *MemoryStore) FindPendingUser(email string, now time.Time) (schema.PendingUser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pending, ok := s.pending[email]
+	if !ok || !pending.ExpireAt.After(now) {
+		return schema.PendingUser{}, ErrNotFound
+	}
+	return pending, nil
+}
+
+// DeletePendingUser deletes the registration of the email
+func (s *MemoryStore) DeletePendingUser(email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pending[email]; !ok {
+		return ErrNotFound
+	}
+	delete(s.pending, email)
+	return nil
+}
+
+// InsertGyroData appends a telemetry document
+func (s *MemoryStore) InsertGyroData(data schema.GyroData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.telemetry = append(s.telemetry, data)
+	return nil
+}
+
+// GyroData returns all telemetry documents
+func (s *MemoryStore) GyroData() ([]schema.GyroData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]schema.GyroData(nil), s.telemetry...), nil
+}
+
+// GyroDataByDevice returns all telemetry of a device in insertion order
+func (s *MemoryStore) GyroDataByDevice(deviceID string) ([]schema.GyroData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var gyroData []schema.GyroData
+	for _, data := range s.telemetry {
+		if data.DeviceID == deviceID {
+			gyroData = append(gyroData, data)
+		}
+	}
+	return gyroData, nil
+}
+
+// LatestGyroData returns the newest telemetry of a device sorted by timestamp descending
+func (s *MemoryStore) LatestGyroData(deviceID string, limit int64) ([]schema.GyroData, error) {
+	gyroData, _ := s.GyroDataByDevice(deviceID)
+	sort.SliceStable(gyroData, func(i, j int) bool {
+		return gyroData[i].TimeStamp > gyroData[j].TimeStamp
+	})
+	if limit > 0 && int64(len(gyroData)) > limit {
+		gyroData = gyroData[:limit]
+	}
+	return gyroData, nil
+}
+
+// QueryGyroData returns a page of telemetry of a device sorted by timestamp, ties keep insertion order
+func (s *MemoryStore) QueryGyroData(query TelemetryQuery) ([]schema.GyroData, error) {
+	gyroData, _ := s.GyroDataByDevice(query.DeviceID)
+	sort.SliceStable(gyroData, func(i, j int) bool {
+		return gyroData[i].TimeStamp < gyroData[j].TimeStamp
+	})
+	if query.Descending {
+		for i, j := 0, len(gyroData)-1; i < j; i, j = i+1, j-1 {
+			gyroData[i], gyroData[j] = gyroData[j], gyroData[i]
+		}
+	}
+
+	var page []schema.GyroData
+	skip := query.Cursor.Skip
+	for _, data := range gyroData {
+		if (query.From > 0 && data.TimeStamp < query.From) || (query.To > 0 && data.TimeStamp > query.To) {
+			continue
+		}
+		// Resume after the cursor
+		if query.Cursor.TimeStamp > 0 {
+			if (!query.Descending && data.TimeStamp < query.Cursor.TimeStamp) || (query.Descending && data.TimeStamp > query.Cursor.TimeStamp) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+		}
+		page = append(page, data)
+		if query.Limit > 0 && int64(len(page)) == query.Limit {
+			break
+		}
+	}
+	return page, nil
+}
+
+// EachGyroData calls fn for the telemetry of a device in the time range, oldest first
+func (s *MemoryStore) EachGyroData(ctx context.Context, query TelemetryQuery, fn func(schema.GyroData) error) error {
+	gyroData, _ := s.GyroDataByDevice(query.DeviceID)
+	sort.SliceStable(gyroData, func(i, j int) bool {
+		return gyroData[i].TimeStamp < gyroData[j].TimeStamp
+	})
+	for _, data := range gyroData {
+		if (query.From > 0 && data.TimeStamp < query.From) || (query.To > 0 && data.TimeStamp > query.To) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AggregateGyroData buckets the telemetry of a device by interval like the Mongo pipeline
+func (s *MemoryStore) AggregateGyroData(query TelemetryAggregateQuery) ([]TelemetryBucket, error) {
+	deviceIDs := query.DeviceIDs
+	if query.DeviceID != "" {
+		deviceIDs = []string{query.DeviceID}
+	}
+	var gyroData []schema.GyroData
+	for _, deviceID := range deviceIDs {
+		data, _ := s.GyroDataByDevice(deviceID)
+		gyroData = append(gyroData, data...)
+	}
+	interval := query.Interval.Milliseconds()
+
+	accumulators := make(map[int64]*bucketAccumulator)
+	for _, data := range gyroData {
+		if (query.From > 0 && data.TimeStamp < query.From) || (query.To > 0 && data.TimeStamp > query.To) {
+			continue
+		}
+		start := data.TimeStamp - data.TimeStamp%interval
+		accumulator, ok := accumulators[start]
+		if !ok {
+			accumulator = &bucketAccumulator{start: start}
+			accumulators[start] = accumulator
+		}
+		accumulator.add(data)
+	}
+
+	buckets := make([]TelemetryBucket, 0, len(accumulators))
+	for _, accumulator := range accumulators {
+		buckets = append(buckets, accumulator.bucket())
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Start < buckets[j].Start
+	})
+	if len(buckets) > MaxTelemetryBuckets {
+		buckets = buckets[:MaxTelemetryBuckets]
+	}
+	return buckets, nil
+}
+
+// CleanGyroData deletes all telemetry documents
+func (s *MemoryStore) CleanGyroData() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.telemetry = nil
+	return nil
+}
+
+// InsertCommand appends a command
+func (s *MemoryStore) InsertCommand(command schema.Command) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commands = append(s.commands, command)
+	return nil
+}
+
+// CommandsByDevice returns the latest commands of a device, newest first
+func (s *MemoryStore) CommandsByDevice(deviceID string, limit int64) ([]schema.Command, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var commands []schema.Command
+	for i := len(s.commands) - 1; i >= 0; i-- {
+		if s.commands[i].DeviceID == deviceID {
+			commands = append(commands, s.commands[i])
+			if limit > 0 && int64(len(commands)) == limit {
+				break
+			}
+		}
+	}
+	return commands, nil
+}
+
+// AckCommand sets the result of a command still pending and not expired
+func (s *MemoryStore) AckCommand(deviceID, commandID, status, reason string, ackAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.commands {
+		command := &s.commands[i]
+		if command.ID != commandID || command.DeviceID != deviceID {
+			continue
+		}
+		if command.Status != CommandPending || !ackAt.Before(command.ExpireAt) {
+			return ErrNotFound
+		}
+		command.Status = status
+		command.Error = reason
+		command.AckAt = &ackAt
+		return nil
+	}
+	return ErrNotFound
+}
+
+// ExpireCommands marks pending commands past their expiry as expired
+func (s *MemoryStore) ExpireCommands(now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired int64
+	for i := range s.commands {
+		if s.commands[i].Status == CommandPending && !now.Before(s.commands[i].ExpireAt) {
+			s.commands[i].Status = CommandExpired
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// AddUsage adds the counters to the day of the device and to the usage of the device, once per batchID
+func (s *MemoryStore) AddUsage(batchID string, usage schema.DeviceUsage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.batches[batchID] {
+		return nil
+	}
+	s.batches[batchID] = true
+
+	key := usage.DeviceID + "/" + usage.Day
+	day, ok := s.usage[key]
+	if !ok {
+		day = schema.DeviceUsage{DeviceID: usage.DeviceID, UserID: usage.UserID, Day: usage.Day}
+	}
+	day.Messages += usage.Messages
+	day.Bytes += usage.Bytes
+	day.Hours |= usage.Hours
+	day.Predictions += usage.Predictions
+	s.usage[key] = day
+
+	if device, ok := s.devices[usage.DeviceID]; ok {
+		device.Usage += int(usage.Messages)
+		s.devices[usage.DeviceID] = device
+	}
+	return nil
+}
+
+// UsageByDevice returns the days of a device in the inclusive range, oldest first
+func (s *MemoryStore) UsageByDevice(deviceID string, fromDay, toDay string) ([]schema.DeviceUsage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var days []schema.DeviceUsage
+	for _, day := range s.usage {
+		if day.DeviceID == deviceID && day.Day >= fromDay && day.Day <= toDay {
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Day < days[j].Day
+	})
+	return days, nil
+}
+
+// toGetDevice converts a device record to the device listing without the password
+func toGetDevice(device schema.Device) schema.GetDevice {
+	return schema.GetDevice{
+		UserID:       device.UserID,
+		DeviceName:   device.DeviceName,
+		DeviceID:     device.ID,
+		CreateDate:   device.CreateDate,
+		CurrentDate:  device.CurrentDate,
+		Bookmark:     device.Bookmark,
+		Usage:        device.Usage,
+		Status:       device.Status,
+		LastSeen:     device.LastSeen,
+		MachineClass: device.MachineClass,
+		AssetID:      device.AssetID,
+		Profile:      device.Profile,
+	}
+}
+
+// InsertAlertRule appends a rule
+func (s *MemoryStore) InsertAlertRule(rule schema.AlertRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rules = append(s.rules, rule)
+	return nil
+}
+
+// UpdateAlertRule replaces the rule with the same ID and user
+func (s *MemoryStore) UpdateAlertRule(rule schema.AlertRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.rules {
+		if s.rules[i].ID == rule.ID && s.rules[i].UserID == rule.UserID {
+			s.rules[i] = rule
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// DeleteAlertRule deletes the rule with the ID and user
+func (s *MemoryStore) DeleteAlertRule(userID, ruleID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.rules {
+		if s.rules[i].ID == ruleID && s.rules[i].UserID == userID {
+			s.rules = append(s.rules[:i], s.rules[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// AlertRulesByUser returns the rules of the user
+func (s *MemoryStore) AlertRulesByUser(userID string) ([]schema.AlertRule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rules []schema.AlertRule
+	for _, rule := range s.rules {
+		if rule.UserID == userID {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// EnabledAlertRules returns the enabled rules of every user
+func (s *MemoryStore) EnabledAlertRules() ([]schema.AlertRule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rules []schema.AlertRule
+	for _, rule := range s.rules {
+		if rule.Enabled {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// InsertAlert appends an alert
+func (s *MemoryStore) InsertAlert(alert schema.Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.alerts = append(s.alerts, alert)
+	return nil
+}
+
+// ResolveAlert sets the resolve date of a firing alert
+func (s *MemoryStore) ResolveAlert(alertID string, resolvedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.alerts {
+		alert := &s.alerts[i]
+		if alert.ID == alertID && alert.Status == AlertFiring {
+			alert.Status = AlertResolved
+			alert.ResolvedAt = &resolvedAt
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// AlertsByUser returns the latest alerts of the user, newest first
+func (s *MemoryStore) AlertsByUser(userID string, query AlertQuery) ([]schema.Alert, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var alerts []schema.Alert
+	for i := len(s.alerts) - 1; i >= 0; i-- {
+		alert := s.alerts[i]
+		if alert.UserID != userID ||
+			(query.DeviceID != "" && alert.DeviceID != query.DeviceID) ||
+			(query.DeviceIDs != nil && !slices.Contains(query.DeviceIDs, alert.DeviceID)) ||
+			(query.Status != "" && alert.Status != query.Status) {
+			continue
+		}
+		alerts = append(alerts, alert)
+		if query.Limit > 0 && int64(len(alerts)) == query.Limit {
+			break
+		}
+	}
+	return alerts, nil
+}
+
+// FiringAlerts returns the firing alerts of every user, oldest first
+func (s *MemoryStore) FiringAlerts() ([]schema.Alert, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var alerts []schema.Alert
+	for _, alert := range s.alerts {
+		if alert.Status == AlertFiring {
+			alerts = append(alerts, alert)
+		}
+	}
+	slices.SortStableFunc(alerts, func(a, b schema.Alert) int { return a.FiredAt.Compare(b.FiredAt) })
+	return alerts, nil
+}
+
+// purgeNotifications drops the expired notifications like the Mongo TTL index, the caller must hold the lock
+func (s *MemoryStore) purgeNotifications(now time.Time) {
+	kept := s.notices[:0]
+	for _, notification := range s.notices {
+		if notification.ExpireAt.After(now) {
+			kept = append(kept, notification)
+		}
+	}
+	s.notices = kept
+}
+
+// InsertNotification appends a notification
+func (s *MemoryStore) InsertNotification(notification schema.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeNotifications(time.Now())
+	s.notices = append(s.notices, notification)
+	return nil
+}
+
+// NotificationsByUser returns a page of the notifications of the user, newest first
+func (s *MemoryStore) NotificationsByUser(userID string, query NotificationQuery) ([]schema.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeNotifications(time.Now())
+	var notifications []schema.Notification
+	for _, notification := range s.notices {
+		if notification.UserID != userID || (query.Unread && notification.Read) {
+			continue
+		}
+		if !query.Cursor.CreateAt.IsZero() && !notification.CreateAt.Before(query.Cursor.CreateAt) &&
+			!(notification.CreateAt.Equal(query.Cursor.CreateAt) && notification.ID < query.Cursor.ID) {
+			continue
+		}
+		notifications = append(notifications, notification)
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		if !notifications[i].CreateAt.Equal(notifications[j].CreateAt) {
+			return notifications[i].CreateAt.After(notifications[j].CreateAt)
+		}
+		return notifications[i].ID > notifications[j].ID
+	})
+	if query.Limit > 0 && int64(len(notifications)) > query.Limit {
+		notifications = notifications[:query.Limit]
+	}
+	return notifications, nil
+}
+
+// MarkNotificationsRead sets the read flag of unread notifications of the user
+func (s *MemoryStore) MarkNotificationsRead(userID string, ids []string, readAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	selected := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		selected[id] = true
+	}
+	var updated int64
+	for i := range s.notices {
+		notification := &s.notices[i]
+		if notification.UserID != userID || notification.Read || (len(ids) > 0 && !selected[notification.ID]) {
+			continue
+		}
+		notification.Read = true
+		notification.ReadAt = &readAt
+		updated++
+	}
+	return updated, nil
+}
+
+// CountUnreadNotifications counts the unread notifications of the user
+func (s *MemoryStore) CountUnreadNotifications(userID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeNotifications(time.Now())
+	var unread int64
+	for _, notification := range s.notices {
+		if notification.UserID == userID && !notification.Read {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+// InsertWebhook appends a webhook
+func (s *MemoryStore) InsertWebhook(webhook schema.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.webhooks = append(s.webhooks, webhook)
+	return nil
+}
+
+// UpdateWebhook replaces the webhook with the same ID and user
+func (s *MemoryStore) UpdateWebhook(webhook schema.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.webhooks {
+		if s.webhooks[i].ID == webhook.ID && s.webhooks[i].UserID == webhook.UserID {
+			s.webhooks[i] = webhook
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// DeleteWebhook deletes the webhook with the ID and user
+func (s *MemoryStore) DeleteWebhook(userID, webhookID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.webhooks {
+		if s.webhooks[i].ID == webhookID && s.webhooks[i].UserID == userID {
+			s.webhooks = append(s.webhooks[:i], s.webhooks[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// WebhooksByUser returns the webhooks of the user
+func (s *MemoryStore) WebhooksByUser(userID string) ([]schema.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var webhooks []schema.Webhook
+	for _, webhook := range s.webhooks {
+		if webhook.UserID == userID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+// EnabledWebhooks lists the enabled webhooks subscribed to the event
+func (s *MemoryStore) EnabledWebhooks(event string) ([]schema.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var webhooks []schema.Webhook
+	for _, webhook := range s.webhooks {
+		if webhook.Enabled && slices.Contains(webhook.Events, event) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+// InsertDelivery appends a delivery
+func (s *MemoryStore) InsertDelivery(delivery schema.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.delivered = append(s.delivered, delivery)
+	return nil
+}
+
+// DueDeliveries returns the pending deliveries with a next attempt before now, oldest first
+func (s *MemoryStore) DueDeliveries(now time.Time, limit int64) ([]schema.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var deliveries []schema.WebhookDelivery
+	for _, delivery := range s.delivered {
+		if delivery.Status == DeliveryPending && !delivery.NextAttempt.After(now) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].NextAttempt.Before(deliveries[j].NextAttempt)
+	})
+	if limit > 0 && int64(len(deliveries)) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// ClaimDelivery moves the next attempt of a pending delivery still due at due
+func (s *MemoryStore) ClaimDelivery(deliveryID string, due, until time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.delivered {
+		delivery := &s.delivered[i]
+		if delivery.ID == deliveryID && delivery.Status == DeliveryPending && delivery.NextAttempt.Equal(due) {
+			delivery.NextAttempt = until
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RecordAttempt appends an attempt to a delivery and sets its status and next attempt
+func (s *MemoryStore) RecordAttempt(deliveryID string, attempt schema.WebhookAttempt, status string, next time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.delivered {
+		delivery := &s.delivered[i]
+		if delivery.ID != deliveryID {
+			continue
+		}
+		delivery.Attempts = append(delivery.Attempts, attempt)
+		delivery.Status = status
+		delivery.NextAttempt = next
+		if status == DeliveryDelivered {
+			at := attempt.At
+			delivery.DeliveredAt = &at
+		}
+		return nil
+	}
+	return ErrNotFound
+}
+
+// DeliveriesByWebhook returns the latest deliveries of the webhook, newest first
+func (s *MemoryStore) DeliveriesByWebhook(webhookID string, limit int64) ([]schema.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var deliveries []schema.WebhookDelivery
+	for i := len(s.delivered) - 1; i >= 0; i-- {
+		if s.delivered[i].WebhookID == webhookID {
+			delivery := s.delivered[i]
+			delivery.Attempts = append([]schema.WebhookAttempt(nil), delivery.Attempts...)
+			deliveries = append(deliveries, delivery)
+			if limit > 0 && int64(len(deliveries)) == limit {
+				break
+			}
+		}
+	}
+	return deliveries, nil
+}
+
+// InsertPasswordReset stores a new reset token
+func (s *MemoryStore) InsertPasswordReset(reset schema.PasswordReset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resets = append(s.resets, reset)
+	return nil
+}
+
+// UsePasswordReset marks an unused and unexpired reset token used
+func (s *MemoryStore) UsePasswordReset(tokenHash string, now time.Time) (schema.PasswordReset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.resets {
+		reset := &s.resets[i]
+		if reset.TokenHash != tokenHash || reset.UsedAt != nil || !reset.ExpireAt.After(now) {
+			continue
+		}
+		reset.UsedAt = &now
+		return *reset, nil
+	}
+	return schema.PasswordReset{}, ErrNotFound
+}
+
+// InsertProvisioning stores a new pairing code and drops the expired ones
+func (s *MemoryStore) InsertProvisioning(provisioning schema.Provisioning) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.pairings[:0]
+	for _, pairing := range s.pairings {
+		if pairing.ExpireAt.After(provisioning.CreateAt) {
+			kept = append(kept, pairing)
+		}
+	}
+	s.pairings = append(kept, provisioning)
+	return nil
+}
+
+// FindProvisioning returns a pairing of the user
+func (s *MemoryStore) FindProvisioning(userID, provisionID string) (schema.Provisioning, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, pairing := range s.pairings {
+		if pairing.ID == provisionID && pairing.UserID == userID {
+			return pairing, nil
+		}
+	}
+	return schema.Provisioning{}, ErrNotFound
+}
+
+// ClaimProvisioning claims the unclaimed code valid at now
+func (s *MemoryStore) ClaimProvisioning(codeHash string, now time.Time) (schema.Provisioning, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.pairings {
+		pairing := &s.pairings[i]
+		if pairing.CodeHash != codeHash || pairing.ClaimedAt != nil || !pairing.ExpireAt.After(now) {
+			continue
+		}
+		pairing.ClaimedAt = &now
+		return *pairing, nil
+	}
+	return schema.Provisioning{}, ErrNotFound
+}
+
+// SetProvisioningDevice sets the deviceID of a claimed pairing
+func (s *MemoryStore) SetProvisioningDevice(provisionID, deviceID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.pairings {
+		if s.pairings[i].ID == provisionID && s.pairings[i].ClaimedAt != nil {
+			s.pairings[i].DeviceID = deviceID
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// ReleaseProvisioning unsets the claim of a pairing without a device
+func (s *MemoryStore) ReleaseProvisioning(provisionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.pairings {
+		if s.pairings[i].ID == provisionID && s.pairings[i].DeviceID == "" {
+			s.pairings[i].ClaimedAt = nil
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// InsertShare appends an invitation and drops the expired ones
+func (s *MemoryStore) InsertShare(share schema.DeviceShare) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.shares[:0]
+	for _, existing := range s.shares {
+		if existing.ExpireAt == nil || existing.ExpireAt.After(share.CreateAt) {
+			kept = append(kept, existing)
+		}
+	}
+	s.shares = append(kept, share)
+	return nil
+}
+
+// FindShare returns the share with the ID
+func (s *MemoryStore) FindShare(shareID string) (schema.DeviceShare, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, share := range s.shares {
+		if share.ID == shareID {
+			return share, nil
+		}
+	}
+	return schema.DeviceShare{}, ErrNotFound
+}
+
+// FindUserShare returns the accepted share of the device with the user
+func (s *MemoryStore) FindUserShare(userID, deviceID string) (schema.DeviceShare, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, share := range s.shares {
+		if share.UserID == userID && share.DeviceID == deviceID && share.Status == ShareAccepted {
+			return share, nil
+		}
+	}
+	return schema.DeviceShare{}, ErrNotFound
+}
+
+// SharesByDevice lists the shares of the device in insertion order
+func (s *MemoryStore) SharesByDevice(deviceID string) ([]schema.DeviceShare, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var shares []schema.DeviceShare
+	for _, share := range s.shares {
+		if share.DeviceID == deviceID {
+			shares = append(shares, share)
+		}
+	}
+	return shares, nil
+}
+
+// SharesByUser lists the accepted shares of the user
+func (s *MemoryStore) SharesByUser(userID string) ([]schema.DeviceShare, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var shares []schema.DeviceShare
+	for _, share := range s.shares {
+		if share.UserID == userID && share.Status == ShareAccepted {
+			shares = append(shares, share)
+		}
+	}
+	return shares, nil
+}
+
+// InvitationsByEmail lists the pending invitations of the email unexpired at now
+func (s *MemoryStore) InvitationsByEmail(email string, now time.Time) ([]schema.DeviceShare, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var shares []schema.DeviceShare
+	for _, share := range s.shares {
+		if share.Email == email && share.Status == SharePending && share.ExpireAt.After(now) {
+			shares = append(shares, share)
+		}
+	}
+	return shares, nil
+}
+
+// AcceptShare accepts a pending invitation of the email valid at now for the user
+func (s *MemoryStore) AcceptShare(shareID, email, userID string, now time.Time) (schema.DeviceShare, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.shares {
+		share := &s.shares[i]
+		if share.ID != shareID || share.Email != email || share.Status != SharePending || !share.ExpireAt.After(now) {
+			continue
+		}
+		share.Status = ShareAccepted
+		share.UserID = userID
+		share.AcceptAt = &now
+		share.ExpireAt = nil
+		return *share, nil
+	}
+	return schema.DeviceShare{}, ErrNotFound
+}
+
+// UpdateShareRole changes the role of a share of the device, a transfer keeps its role
+func (s *MemoryStore) UpdateShareRole(deviceID, shareID, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.shares {
+		share := &s.shares[i]
+		if share.ID == shareID && share.DeviceID == deviceID && share.Role != RoleOwner {
+			share.Role = role
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// UpdateShareBookmark changes the bookmark of the accepted share of the device with the user
+func (s *MemoryStore) UpdateShareBookmark(userID, deviceID string, bookmark bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.shares {
+		share := &s.shares[i]
+		if share.UserID == userID && share.DeviceID == deviceID && share.Status == ShareAccepted {
+			share.Bookmark = bookmark
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// DeleteShare removes the share with the ID
+func (s *MemoryStore) DeleteShare(shareID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, share := range s.shares {
+		if share.ID == shareID {
+			s.shares = append(s.shares[:i], s.shares[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// DeleteDeviceShares removes every share of the device
+func (s *MemoryStore) DeleteDeviceShares(deviceID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.shares[:0]
+	for _, share := range s.shares {
+		if share.DeviceID != deviceID {
+			kept = append(kept, share)
+		}
+	}
+	s.shares = kept
+	return nil
+}
+
+// InsertOrganisation appends an organisation
+func (s *MemoryStore) InsertOrganisation(org schema.Organisation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orgs = append(s.orgs, org)
+	return nil
+}
+
+// UpdateOrganisation replaces the organisation with the same ID and user
+func (s *MemoryStore) UpdateOrganisation(org schema.Organisation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.orgs {
+		if s.orgs[i].ID == org.ID && s.orgs[i].UserID == org.UserID {
+			s.orgs[i] = org
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// DeleteOrganisation removes an organisation of the user
+func (s *MemoryStore) DeleteOrganisation(userID, orgID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, org := range s.orgs {
+		if org.ID == orgID && org.UserID == userID {
+			s.orgs = append(s.orgs[:i], s.orgs[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// OrganisationsByUser lists the organisations of the user by name
+func (s *MemoryStore) OrganisationsByUser(userID string) ([]schema.Organisation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var orgs []schema.Organisation
+	for _, org := range s.orgs {
+		if org.UserID == userID {
+			orgs = append(orgs, org)
+		}
+	}
+	sort.SliceStable(orgs, func(i, j int) bool {
+		return orgs[i].Name < orgs[j].Name
+	})
+	return orgs, nil
+}
+
+// InsertSite appends a site
+func (s *MemoryStore) InsertSite(site schema.Site) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sites = append(s.sites, site)
+	return nil
+}
+
+// UpdateSite replaces the site with the same ID and user
+func (s *MemoryStore) UpdateSite(site schema.Site) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.sites {
+		if s.sites[i].ID == site.ID && s.sites[i].UserID == site.UserID {
+			s.sites[i] = site
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// DeleteSite removes a site of the user
+func (s *MemoryStore) DeleteSite(userID, siteID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, site := range s.sites {
+		if site.ID == siteID && site.UserID == userID {
+			s.sites = append(s.sites[:i], s.sites[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// SitesByUser lists the sites of the user by name
+func (s *MemoryStore) SitesByUser(userID string) ([]schema.Site, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sites []schema.Site
+	for _, site := range s.sites {
+		if site.UserID == userID {
+			sites = append(sites, site)
+		}
+	}
+	sort.SliceStable(sites, func(i, j int) bool {
+		return sites[i].Name < sites[j].Name
+	})
+	return sites, nil
+}
+
+// InsertAsset appends an asset
+func (s *MemoryStore) InsertAsset(asset schema.Asset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.assets = append(s.assets, asset)
+	return nil
+}
+
+// UpdateAsset replaces the asset with the same ID and user
+func (s *MemoryStore) UpdateAsset(asset schema.Asset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.assets {
+		if s.assets[i].ID == asset.ID && s.assets[i].UserID == asset.UserID {
+			s.assets[i] = asset
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// DeleteAsset removes an asset of the user
+func (s *MemoryStore) DeleteAsset(userID, assetID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, asset := range s.assets {
+		if asset.ID == assetID && asset.UserID == userID {
+			s.assets = append(s.assets[:i], s.assets[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// AssetsByUser lists the assets of the user by name
+func (s *MemoryStore) AssetsByUser(userID string) ([]schema.Asset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var assets []schema.Asset
+	for _, asset := range s.assets {
+		if asset.UserID == userID {
+			assets = append(assets, asset)
+		}
+	}
+	sort.SliceStable(assets, func(i, j int) bool {
+		return assets[i].Name < assets[j].Name
+	})
+	return assets, nil
+}
@@ -0,0 +1,286 @@
+package db
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	schema "GOLANG_SERVER/components/schema"
+)
+
+func TestMemoryUsers(t *testing.T) {
+	s := NewMemoryStore()
+	if err := s.InsertUser(schema.User{ID: "userA", Username: "a", Email: "a@example.com", Password: "hashA"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertUser(schema.User{ID: "userB", Username: "b", Email: "a@example.com"}); err != ErrEmailExists {
+		t.Fatalf("duplicate email: %v", err)
+	}
+
+	// FindUser leaves the password out like the Mongo projection
+	user, err := s.FindUser("a@example.com")
+	if err != nil || user.ID != "userA" || user.Password != "" {
+		t.Fatalf("FindUser = %+v, %v", user, err)
+	}
+	if user, err := s.FindUserWithPassword("a@example.com"); err != nil || user.Password != "hashA" {
+		t.Fatalf("FindUserWithPassword = %+v, %v", user, err)
+	}
+	if found, err := s.FindUserID("userA"); err != nil || found.Email != "a@example.com" {
+		t.Fatalf("FindUserID = %+v, %v", found, err)
+	}
+
+	if err := s.UpdateUser(schema.User{Email: "a@example.com", Username: "renamed", Password: "hash2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdatePassword("a@example.com", "hash3"); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := s.FindUserWithPassword("a@example.com"); user.ID != "userA" || user.Username != "renamed" || user.Password != "hash3" {
+		t.Fatalf("after the updates: %+v", user)
+	}
+
+	for name, err := range map[string]error{
+		"FindUser":             second(s.FindUser("nobody@example.com")),
+		"FindUserWithPassword": second(s.FindUserWithPassword("nobody@example.com")),
+		"FindUserID":           second(s.FindUserID("nobody")),
+		"UpdateUser":           s.UpdateUser(schema.User{Email: "nobody@example.com"}),
+		"UpdatePassword":       s.UpdatePassword("nobody@example.com", "hash"),
+	} {
+		if err != ErrNotFound {
+			t.Errorf("%s of an unknown user = %v, want ErrNotFound", name, err)
+		}
+	}
+}
+
+func second[T any](_ T, err error) error {
+	return err
+}
+
+func TestMemoryDevices(t *testing.T) {
+	s := NewMemoryStore()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, device := range []schema.Device{
+		{ID: "device2", UserID: "userA", DeviceName: "second", CreateDate: start.Add(time.Hour)},
+		{ID: "device1", UserID: "userA", DeviceName: "first", CreateDate: start},
+		{ID: "device3", UserID: "userB", DeviceName: "other", CreateDate: start},
+		{ID: "device0", UserID: "userA", DeviceName: "third", CreateDate: start.Add(2 * time.Hour)},
+	} {
+		if err := s.InsertDevice(device); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Listed oldest first, only the devices of the user
+	devices, err := s.DevicesByUser("userA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, device := range devices {
+		ids = append(ids, device.DeviceID)
+	}
+	if !slices.Equal(ids, []string{"device1", "device2", "device0"}) {
+		t.Fatalf("devices of user A = %v", ids)
+	}
+	if devices, _ := s.DevicesByUser("nobody"); len(devices) != 0 {
+		t.Fatalf("devices of an unknown user = %v", devices)
+	}
+
+	if exists, _ := s.DeviceExists("device3"); !exists {
+		t.Fatal("device3 does not exist")
+	}
+	if _, err := s.FindDevice("nope"); err != ErrNotFound {
+		t.Fatalf("FindDevice of an unknown device = %v", err)
+	}
+
+	// Changes only apply to the devices of the user
+	if err := s.UpdateBookmark("userB", "device1", true); err != ErrNotFound {
+		t.Fatalf("bookmark by another user = %v", err)
+	}
+	if err := s.UpdateBookmark("userA", "device1", true); err != nil {
+		t.Fatal(err)
+	}
+	if device, _ := s.FindDevice("device1"); !device.Bookmark {
+		t.Fatal("bookmark not saved")
+	}
+	if err := s.TransferDevice("device1", "userB", "userA"); err != ErrNotFound {
+		t.Fatalf("transfer from a user not owning the device = %v", err)
+	}
+	if err := s.TransferDevice("device1", "userA", "userB"); err != nil {
+		t.Fatal(err)
+	}
+	if device, _ := s.FindDevice("device1"); device.UserID != "userB" {
+		t.Fatalf("transferred device owned by %s", device.UserID)
+	}
+	if err := s.DeleteDevice("userA", "device3"); err != ErrNotFound {
+		t.Fatalf("delete by another user = %v", err)
+	}
+	if err := s.DeleteDevice("userB", "device3"); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := s.DeviceExists("device3"); exists {
+		t.Fatal("deleted device still exists")
+	}
+}
+
+func TestMemoryOTP(t *testing.T) {
+	s := NewMemoryStore()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	otp := schema.OTP{Subject: "a@example.com", Purpose: "register", CodeHash: "hash1", ExpireAt: now.Add(time.Minute)}
+	if err := s.SaveOTP(otp); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.FindOTP("a@example.com", "reset", now); err != ErrNotFound {
+		t.Fatalf("OTP of another purpose = %v", err)
+	}
+	for want := 1; want <= 2; want++ {
+		if otp, err := s.AttemptOTP("a@example.com", "register", now); err != nil || otp.Attempts != want {
+			t.Fatalf("attempt %d = %+v, %v", want, otp, err)
+		}
+	}
+
+	// Saving again replaces the code and its attempts
+	otp.CodeHash = "hash2"
+	if err := s.SaveOTP(otp); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := s.FindOTP("a@example.com", "register", now); err != nil || found.CodeHash != "hash2" || found.Attempts != 0 {
+		t.Fatalf("replaced OTP = %+v, %v", found, err)
+	}
+
+	// Expired codes are gone even before the TTL index removes them
+	if _, err := s.FindOTP("a@example.com", "register", otp.ExpireAt); err != ErrNotFound {
+		t.Fatalf("FindOTP at expiry = %v", err)
+	}
+	if _, err := s.AttemptOTP("a@example.com", "register", otp.ExpireAt); err != ErrNotFound {
+		t.Fatalf("AttemptOTP at expiry = %v", err)
+	}
+
+	otp.ExpireAt = now.Add(time.Hour)
+	s.SaveOTP(otp)
+	if err := s.DeleteOTP("a@example.com", "register"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteOTP("a@example.com", "register"); err != ErrNotFound {
+		t.Fatalf("second delete = %v", err)
+	}
+}
+
+// insertTelemetry stores one document per timestamp, the label is kept in Zone
+func insertTelemetry(t *testing.T, deviceID string, labels string, timeStamps ...int64) {
+	t.Helper()
+	for i, ts := range timeStamps {
+		data := schema.GyroData{DeviceID: deviceID, TimeStamp: ts, Zone: string(labels[i])}
+		data.Data.X.Acceleration = float64(ts) / 100
+		if err := store.InsertGyroData(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// allPages follows the page tokens of the query and returns the labels of every page
+func allPages(t *testing.T, query TelemetryQuery) []string {
+	t.Helper()
+	var pages []string
+	for {
+		page, err := QueryTelemetry(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var labels strings.Builder
+		for _, document := range page.Data {
+			labels.WriteString(document["zone"].(string))
+		}
+		pages = append(pages, labels.String())
+		if page.NextPageToken == "" {
+			return pages
+		}
+		if query.Cursor, err = DecodePageToken(page.NextPageToken); err != nil {
+			t.Fatal(err)
+		}
+		if len(pages) > 10 {
+			t.Fatalf("pages do not end: %v", pages)
+		}
+	}
+}
+
+func TestMemoryTelemetryPages(t *testing.T) {
+	UseStore(NewMemoryStore())
+	defer UseStore(nil)
+	insertTelemetry(t, "deviceA", "abcdefg", 300, 100, 200, 200, 200, 100, 400)
+	insertTelemetry(t, "deviceB", "vwxyz", 500, 500, 500, 500, 500)
+
+	tests := []struct {
+		name  string
+		query TelemetryQuery
+		want  []string
+	}{
+		// Ties keep insertion order like the _id sort of Mongo, a cursor can fall inside a tie
+		{"ascending", TelemetryQuery{DeviceID: "deviceA", Limit: 2}, []string{"bf", "cd", "ea", "g"}},
+		{"descending", TelemetryQuery{DeviceID: "deviceA", Limit: 2, Descending: true}, []string{"ga", "ed", "cf", "b"}},
+		{"range", TelemetryQuery{DeviceID: "deviceA", Limit: 3, From: 150, To: 350}, []string{"cde", "a"}},
+		{"range descending", TelemetryQuery{DeviceID: "deviceA", Limit: 3, From: 150, To: 350, Descending: true}, []string{"aed", "c"}},
+		{"exact last page", TelemetryQuery{DeviceID: "deviceA", Limit: 7}, []string{"bfcdeag"}},
+		// Whole pages share the timestamp of the cursor
+		{"one timestamp", TelemetryQuery{DeviceID: "deviceB", Limit: 2}, []string{"vw", "xy", "z"}},
+		{"one timestamp descending", TelemetryQuery{DeviceID: "deviceB", Limit: 2, Descending: true}, []string{"zy", "xw", "v"}},
+		{"no data", TelemetryQuery{DeviceID: "deviceC"}, []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allPages(t, tt.query); !slices.Equal(got, tt.want) {
+				t.Fatalf("pages = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	latest, _ := store.LatestGyroData("deviceA", 3)
+	var labels string
+	for _, data := range latest {
+		labels += data.Zone
+	}
+	if labels != "gac" {
+		t.Fatalf("latest = %q, want gac", labels)
+	}
+}
+
+func TestMemoryTelemetryAggregate(t *testing.T) {
+	UseStore(NewMemoryStore())
+	defer UseStore(nil)
+	minute := time.Minute.Milliseconds()
+	// X.Acceleration is the timestamp / 100
+	insertTelemetry(t, "deviceA", "abcde", 2*minute+100, 100, 2*minute, 300, 5*minute)
+	insertTelemetry(t, "deviceB", "f", 200)
+
+	buckets, err := AggregateTelemetry(TelemetryAggregateQuery{DeviceID: "deviceA", Interval: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var starts, counts []int64
+	for _, bucket := range buckets {
+		starts = append(starts, bucket.Start)
+		counts = append(counts, bucket.Count)
+	}
+	if !slices.Equal(starts, []int64{0, 2 * minute, 5 * minute}) || !slices.Equal(counts, []int64{2, 2, 1}) {
+		t.Fatalf("buckets start at %v with %v documents", starts, counts)
+	}
+	if got, want := buckets[0].X.Acceleration, (Stats{Min: 1, Max: 3, Mean: 2, RMS: math.Sqrt(5)}); got != want {
+		t.Fatalf("first bucket X.Acceleration = %+v, want %+v", got, want)
+	}
+
+	// Several devices share buckets, the range bounds are inclusive
+	buckets, err = AggregateTelemetry(TelemetryAggregateQuery{DeviceIDs: []string{"deviceA", "deviceB"}, From: 200, To: 2 * minute, Interval: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 2 || buckets[0].Count != 2 || buckets[0].X.Acceleration.Min != 2 || buckets[1].Count != 1 {
+		t.Fatalf("buckets of both devices = %+v", buckets)
+	}
+
+	if buckets, err := AggregateTelemetry(TelemetryAggregateQuery{DeviceID: "deviceC", Interval: time.Hour}); err != nil || buckets == nil || len(buckets) != 0 {
+		t.Fatalf("buckets without data = %v, %v", buckets, err)
+	}
+}
@@ -0,0 +1,201 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"GOLANG_SERVER/components/env"
+	schema "GOLANG_SERVER/components/schema"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultNotificationTTL   = 30 * 24 * time.Hour // Retention when NOTIFICATION_TTL is not set
+	DefaultNotificationLimit = 50                  // Number of notifications listed per page
+)
+
+// NotificationQuery selects a page of the notifications of a user
+type NotificationQuery struct {
+	Unread bool               // Only unread notifications
+	Cursor NotificationCursor // Position after the previous page
+	Limit  int64              // Maximum number of notifications
+}
+
+// NotificationCursor is the last notification of the previous page, zero for the first page
+type NotificationCursor struct {
+	CreateAt time.Time
+	ID       string
+}
+
+// NotificationPage is one page of notifications with the unread count of the user
+type NotificationPage struct {
+	Notifications []schema.Notification `json:"notifications"`
+	Unread        int64                 `json:"unread"`
+	NextPageToken string                `json:"nextPageToken,omitempty"`
+}
+
+// notificationTTL reads NOTIFICATION_TTL, a Go duration such as "720h"
+func notificationTTL() time.Duration {
+	value := env.GetEnv("NOTIFICATION_TTL")
+	if value == "" {
+		return DefaultNotificationTTL
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		log.Println("Invalid NOTIFICATION_TTL, using", DefaultNotificationTTL)
+		return DefaultNotificationTTL
+	}
+	return ttl
+}
+
+// CreateNotification stores a new unread notification and returns it with its ID and dates
+func CreateNotification(notification schema.Notification) (schema.Notification, error) {
+	if notification.UserID == "" {
+		return schema.Notification{}, errors.New("userID is required")
+	}
+	now := time.Now()
+	notification.ID = uuid.New().String()
+	notification.Read = false
+	notification.ReadAt = nil
+	notification.CreateAt = now
+	notification.ExpireAt = now.Add(notificationTTL())
+	if err := store.InsertNotification(notification); err != nil {
+		return schema.Notification{}, err
+	}
+	return notification, nil
+}
+
+// Notifications returns one page of the notifications of a user, newest first
+func Notifications(userID string, query NotificationQuery) (NotificationPage, error) {
+	if query.Limit <= 0 || query.Limit > DefaultNotificationLimit {
+		query.Limit = DefaultNotificationLimit
+	}
+	notifications, err := store.NotificationsByUser(userID, query)
+	if err != nil {
+		return NotificationPage{}, err
+	}
+	unread, err := store.CountUnreadNotifications(userID)
+	if err != nil {
+		return NotificationPage{}, err
+	}
+
+	page := NotificationPage{Notifications: notifications, Unread: unread}
+	if page.Notifications == nil {
+		page.Notifications = []schema.Notification{}
+	}
+	if int64(len(notifications)) == query.Limit {
+		last := notifications[len(notifications)-1]
+		page.NextPageToken = EncodeNotificationToken(NotificationCursor{CreateAt: last.CreateAt, ID: last.ID})
+	}
+	return page, nil
+}
+
+// MarkNotificationsRead marks notifications of the user read, every unread one when ids is empty
+func MarkNotificationsRead(userID string, ids []string) (int64, error) {
+	return store.MarkNotificationsRead(userID, ids, time.Now())
+}
+
+// CountUnreadNotifications counts the unread notifications of a user
+func CountUnreadNotifications(userID string) (int64, error) {
+	return store.CountUnreadNotifications(userID)
+}
+
+// EncodeNotificationToken turns a cursor into the opaque token handed to the client
+func EncodeNotificationToken(cursor NotificationCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%s", cursor.CreateAt.UnixNano(), cursor.ID)))
+}
+
+// DecodeNotificationToken reads a token produced by EncodeNotificationToken
+func DecodeNotificationToken(token string) (NotificationCursor, error) {
+	var cursor NotificationCursor
+	if token == "" {
+		return cursor, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, errors.New("invalid page token")
+	}
+	nanos, id, ok := strings.Cut(string(raw), ".")
+	var unixNano int64
+	if _, err := fmt.Sscanf(nanos, "%d", &unixNano); !ok || err != nil || id == "" {
+		return cursor, errors.New("invalid page token")
+	}
+	return NotificationCursor{CreateAt: time.Unix(0, unixNano), ID: id}, nil
+}
+
+// InsertNotification inserts a notification in the notification collection
+func (m *MongoStore) InsertNotification(notification schema.Notification) error {
+	collection := m.collection("MONGO_NOTIFICATIONCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.InsertOne(ctx, notification)
+	return err
+}
+
+// NotificationsByUser finds a page of the notifications of the user, newest first
+func (m *MongoStore) NotificationsByUser(userID string, query NotificationQuery) ([]schema.Notification, error) {
+	collection := m.collection("MONGO_NOTIFICATIONCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Expired documents may outlive their date until the TTL monitor runs
+	filter := bson.M{"userID": userID, "expireAt": bson.M{"$gt": time.Now()}}
+	if query.Unread {
+		filter["read"] = false
+	}
+	if !query.Cursor.CreateAt.IsZero() {
+		filter["$or"] = bson.A{
+			bson.M{"createAt": bson.M{"$lt": query.Cursor.CreateAt}},
+			bson.M{"createAt": query.Cursor.CreateAt, "notificationID": bson.M{"$lt": query.Cursor.ID}},
+		}
+	}
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "createAt", Value: -1}, {Key: "notificationID", Value: -1}}).
+		SetLimit(query.Limit)
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var notifications []schema.Notification
+	if err = cursor.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// MarkNotificationsRead sets the read flag of unread notifications of the user
+func (m *MongoStore) MarkNotificationsRead(userID string, ids []string, readAt time.Time) (int64, error) {
+	collection := m.collection("MONGO_NOTIFICATIONCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"userID": userID, "read": false}
+	if len(ids) > 0 {
+		filter["notificationID"] = bson.M{"$in": ids}
+	}
+	result, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"read": true, "readAt": readAt}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// CountUnreadNotifications counts the unread notifications of the user
+func (m *MongoStore) CountUnreadNotifications(userID string) (int64, error) {
+	collection := m.collection("MONGO_NOTIFICATIONCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return collection.CountDocuments(ctx, bson.M{"userID": userID, "read": false, "expireAt": bson.M{"$gt": time.Now()}})
+}
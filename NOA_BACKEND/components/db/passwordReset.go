@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	schema "GOLANG_SERVER/components/schema"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PasswordResetTTL is how long a reset token can be used after the OTP is verified
+const PasswordResetTTL = 15 * time.Minute
+
+// ErrInvalidResetToken is returned for a reset token that is unknown, expired or already used
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// hashResetToken is the hex SHA-256 of a reset token, the token itself is never stored
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreatePasswordReset issues a reset token for the user and returns it
+func CreatePasswordReset(userID string) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	now := time.Now().Truncate(time.Millisecond)
+	reset := schema.PasswordReset{
+		TokenHash: hashResetToken(token),
+		UserID:    userID,
+		CreateAt:  now,
+		ExpireAt:  now.Add(PasswordResetTTL),
+	}
+	if err := store.InsertPasswordReset(reset); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// UsePasswordReset marks the reset token used and returns its user, a token only works once
+func UsePasswordReset(token string) (string, error) {
+	if token == "" {
+		return "", ErrInvalidResetToken
+	}
+	reset, err := store.UsePasswordReset(hashResetToken(token), time.Now())
+	if err == ErrNotFound {
+		return "", ErrInvalidResetToken
+	}
+	if err != nil {
+		return "", err
+	}
+	return reset.UserID, nil
+}
+
+// InsertPasswordReset stores a new reset token
+func (m *MongoStore) InsertPasswordReset(reset schema.PasswordReset) error {
+	collection := m.collection("MONGO_PASSWORDRESETCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	_, err := collection.InsertOne(ctx, reset)
+	return err
+}
+
+// UsePasswordReset marks an unused and unexpired reset token used in one update, so two requests cannot both use it
+func (m *MongoStore) UsePasswordReset(tokenHash string, now time.Time) (schema.PasswordReset, error) {
+	collection := m.collection("MONGO_PASSWORDRESETCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	filter := bson.M{
+		"tokenHash": tokenHash,
+		"usedAt":    bson.M{"$exists": false},
+		"expireAt":  bson.M{"$gt": now},
+	}
+	var reset schema.PasswordReset
+	err := collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"usedAt": now}}).Decode(&reset)
+	if err == mongo.ErrNoDocuments {
+		return schema.PasswordReset{}, ErrNotFound
+	}
+	if err != nil {
+		return schema.PasswordReset{}, err
+	}
+	reset.UsedAt = &now
+	return reset, nil
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"log"
+	"time"
+
+	schema "GOLANG_SERVER/components/schema"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PendingUserTTL is how long a registration waits for its email to be verified
+const PendingUserTTL = 24 * time.Hour
+
+// SavePendingUser stores the registration, replacing the previous one of the same email, and starts its expiry
+func SavePendingUser(pending schema.PendingUser) (schema.PendingUser, error) {
+	now := time.Now().Truncate(time.Millisecond)
+	pending.CreateAt = now
+	pending.ExpireAt = now.Add(PendingUserTTL)
+	if err := store.SavePendingUser(pending); err != nil {
+		return schema.PendingUser{}, err
+	}
+	return pending, nil
+}
+
+// CreatePendingUser stores the registration and starts its expiry, unless the email has one waiting. That
+// one is kept with its password, so registering again cannot change the password the code activates. It
+// returns the registration waiting for the email and whether it is the new one.
+func CreatePendingUser(pending schema.PendingUser) (schema.PendingUser, bool, error) {
+	now := time.Now().Truncate(time.Millisecond)
+	pending.CreateAt = now
+	pending.ExpireAt = now.Add(PendingUserTTL)
+	return store.InsertPendingUser(pending, now)
+}
+
+// FindPendingUser gets the unexpired registration of the email, ErrNotFound if none
+func FindPendingUser(email string) (schema.PendingUser, error) {
+	return store.FindPendingUser(email, time.Now())
+}
+
+// ActivatePendingUser turns the registration of the email into a user and returns it without the password
+func ActivatePendingUser(email string) (schema.User, error) {
+	pending, err := store.FindPendingUser(email, time.Now())
+	if err != nil {
+		return schema.User{}, err
+	}
+
+	user := schema.User{
+		ID:       generateUserID(),
+		Username: pending.Username,
+		Email:    pending.Email,
+		Password: pending.Password,
+	}
+	err = store.InsertUser(user)
+	if err == ErrEmailExists {
+		// Users of the former registration only hold their email until verified
+		existing, findErr := store.FindUserWithPassword(email)
+		if findErr != nil {
+			return schema.User{}, findErr
+		}
+		if existing.Password != "" {
+			return schema.User{}, ErrEmailExists
+		}
+		user.ID = existing.ID
+		err = store.UpdateUser(user)
+	}
+	if err != nil {
+		return schema.User{}, err
+	}
+
+	if err := store.DeletePendingUser(email); err != nil {
+		log.Println("Error deleting pending user:", err)
+	}
+	user.Password = ""
+	return user, nil
+}
+
+// SavePendingUser inserts or replaces the registration of the email in the pending user collection
+func (m *MongoStore) SavePendingUser(pending schema.PendingUser) error {
+	collection := m.collection("MONGO_PENDINGUSERCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	_, err := collection.ReplaceOne(ctx, bson.M{"email": pending.Email}, pending, options.Replace().SetUpsert(true))
+	return err
+}
+
+// InsertPendingUser upserts the registration without changing one already stored, after deleting an expired
+// one the TTL index has not removed yet
+func (m *MongoStore) InsertPendingUser(pending schema.PendingUser, now time.Time) (schema.PendingUser, bool, error) {
+	collection := m.collection("MONGO_PENDINGUSERCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	if _, err := collection.DeleteOne(ctx, bson.M{"email": pending.Email, "expireAt": bson.M{"$lte": now}}); err != nil {
+		return schema.PendingUser{}, false, err
+	}
+	filter := bson.M{"email": pending.Email}
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$setOnInsert": pending}, options.Update().SetUpsert(true))
+	if err != nil {
+		return schema.PendingUser{}, false, err
+	}
+	if result.UpsertedCount == 1 {
+		return pending, true, nil
+	}
+
+	var waiting schema.PendingUser
+	if err := collection.FindOne(ctx, filter).Decode(&waiting); err != nil {
+		return schema.PendingUser{}, false, err
+	}
+	return waiting, false, nil
+}
+
+// FindPendingUser gets the registration of the email if it has not expired
+func (m *MongoStore) FindPendingUser(email string, now time.Time) (schema.PendingUser, error) {
+	collection := m.collection("MONGO_PENDINGUSERCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	var pending schema.PendingUser
+	err := collection.FindOne(ctx, bson.M{"email": email, "expireAt": bson.M{"$gt": now}}).Decode(&pending)
+	if err == mongo.ErrNoDocuments {
+		return schema.PendingUser{}, ErrNotFound
+	}
+	if err != nil {
+		return schema.PendingUser{}, err
+	}
+	return pending, nil
+}
+
+// DeletePendingUser deletes the registration of the email
+func (m *MongoStore) DeletePendingUser(email string) error {
+	collection := m.collection("MONGO_PENDINGUSERCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	result, err := collection.DeleteOne(ctx, bson.M{"email": email})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"GOLANG_SERVER/components/schema"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// UpdatePresence saves the online status of a device, lastSeen is kept when zero
+func UpdatePresence(deviceID string, online bool, lastSeen time.Time) error {
+	return store.UpdatePresence(deviceID, online, lastSeen)
+}
+
+// StaleOnlineDevices lists the devices marked online that have not been seen since cutoff
+func StaleOnlineDevices(cutoff time.Time) ([]schema.GetDevice, error) {
+	return store.StaleOnlineDevices(cutoff)
+}
+
+// UpdatePresence sets the status and last seen date of the device in the device collection
+func (m *MongoStore) UpdatePresence(deviceID string, online bool, lastSeen time.Time) error {
+	collection := m.collection("MONGO_DEVICECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	set := bson.M{"status": online}
+	if !lastSeen.IsZero() {
+		set["lastSeen"] = lastSeen
+	}
+	result, err := collection.UpdateOne(ctx, bson.M{"deviceID": deviceID}, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// StaleOnlineDevices finds the online devices without a message since cutoff
+func (m *MongoStore) StaleOnlineDevices(cutoff time.Time) ([]schema.GetDevice, error) {
+	collection := m.collection("MONGO_DEVICECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"status": true,
+		"$or": bson.A{
+			bson.M{"lastSeen": bson.M{"$lt": cutoff}},
+			bson.M{"lastSeen": bson.M{"$exists": false}},
+		},
+	}
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var devices []schema.GetDevice
+	if err = cursor.All(ctx, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
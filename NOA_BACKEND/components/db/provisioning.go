@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	schema "GOLANG_SERVER/components/schema"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrInvalidPairingCode is returned for a pairing code that is unknown, expired or already claimed
+var ErrInvalidPairingCode = errors.New("invalid or expired pairing code")
+
+// CreateProvisioning stores a pairing code, its ID and dates must be set
+func CreateProvisioning(provisioning schema.Provisioning) error {
+	if provisioning.UserID == "" || provisioning.CodeHash == "" || provisioning.ID == "" {
+		return errors.New("userID, provisionID and code are required")
+	}
+	return store.InsertProvisioning(provisioning)
+}
+
+// FindProvisioning returns a pairing of the user
+func FindProvisioning(userID, provisionID string) (schema.Provisioning, error) {
+	return store.FindProvisioning(userID, provisionID)
+}
+
+// ClaimProvisioning marks the pairing code claimed and returns it, a code only works once
+func ClaimProvisioning(codeHash string) (schema.Provisioning, error) {
+	provisioning, err := store.ClaimProvisioning(codeHash, time.Now().Truncate(time.Millisecond))
+	if err == ErrNotFound {
+		return schema.Provisioning{}, ErrInvalidPairingCode
+	}
+	return provisioning, err
+}
+
+// SetProvisioningDevice records the device paired by a claimed code
+func SetProvisioningDevice(provisionID, deviceID string) error {
+	return store.SetProvisioningDevice(provisionID, deviceID)
+}
+
+// ReleaseProvisioning makes a claimed code usable again when its device could not be paired
+func ReleaseProvisioning(provisionID string) error {
+	return store.ReleaseProvisioning(provisionID)
+}
+
+// InsertProvisioning stores a new pairing code
+func (m *MongoStore) InsertProvisioning(provisioning schema.Provisioning) error {
+	collection := m.collection("MONGO_PROVISIONINGCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	_, err := collection.InsertOne(ctx, provisioning)
+	return err
+}
+
+// FindProvisioning queries a pairing of the user by its ID
+func (m *MongoStore) FindProvisioning(userID, provisionID string) (schema.Provisioning, error) {
+	collection := m.collection("MONGO_PROVISIONINGCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	var provisioning schema.Provisioning
+	err := collection.FindOne(ctx, bson.M{"provisionID": provisionID, "userID": userID}).Decode(&provisioning)
+	if err == mongo.ErrNoDocuments {
+		return schema.Provisioning{}, ErrNotFound
+	}
+	return provisioning, err
+}
+
+// ClaimProvisioning claims an unclaimed and unexpired code in one update, so two devices cannot both claim it
+func (m *MongoStore) ClaimProvisioning(codeHash string, now time.Time) (schema.Provisioning, error) {
+	collection := m.collection("MONGO_PROVISIONINGCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	filter := bson.M{
+		"codeHash":  codeHash,
+		"claimedAt": bson.M{"$exists": false},
+		"expireAt":  bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"claimedAt": now}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var provisioning schema.Provisioning
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&provisioning)
+	if err == mongo.ErrNoDocuments {
+		return schema.Provisioning{}, ErrNotFound
+	}
+	return provisioning, err
+}
+
+// SetProvisioningDevice sets the deviceID of a claimed pairing
+func (m *MongoStore) SetProvisioningDevice(provisionID, deviceID string) error {
+	return m.updateProvisioning(bson.M{"provisionID": provisionID, "claimedAt": bson.M{"$exists": true}},
+		bson.M{"$set": bson.M{"deviceID": deviceID}})
+}
+
+// ReleaseProvisioning unsets the claim of a pairing without a device
+func (m *MongoStore) ReleaseProvisioning(provisionID string) error {
+	return m.updateProvisioning(bson.M{"provisionID": provisionID, "deviceID": bson.M{"$in": bson.A{nil, ""}}},
+		bson.M{"$unset": bson.M{"claimedAt": ""}})
+}
+
+func (m *MongoStore) updateProvisioning(filter, update bson.M) error {
+	collection := m.collection("MONGO_PROVISIONINGCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
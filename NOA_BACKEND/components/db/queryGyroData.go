@@ -0,0 +1,282 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	schema "GOLANG_SERVER/components/schema"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultTelemetryLimit = 500  // Page size when the client does not ask for one
+	MaxTelemetryLimit     = 5000 // Largest page a client can ask for
+)
+
+// QueryError is a telemetry query the caller got wrong, such as a range ending before it starts
+type QueryError struct {
+	Reason string
+}
+
+func (e *QueryError) Error() string {
+	return e.Reason
+}
+
+// TelemetryQuery selects a page of telemetry of one device
+type TelemetryQuery struct {
+	DeviceID   string   // Device to query
+	From       int64    // Inclusive lower bound on TimeStamp in unix milliseconds, 0 for no bound
+	To         int64    // Inclusive upper bound on TimeStamp in unix milliseconds, 0 for no bound
+	Fields     []string // JSON paths to return such as "X.Acceleration", empty for every field
+	Descending bool     // Newest first when true
+	Limit      int64    // Number of documents to return
+	Cursor     TelemetryCursor
+}
+
+// TelemetryCursor is the position after the last document of the previous page
+type TelemetryCursor struct {
+	TimeStamp int64 // TimeStamp of the last document returned, 0 for the first page
+	Skip      int64 // Number of documents with that TimeStamp already returned
+}
+
+// TelemetryPage is one page of telemetry
+type TelemetryPage struct {
+	Data          []map[string]interface{} `json:"data"`
+	NextPageToken string                   `json:"nextPageToken,omitempty"`
+}
+
+// telemetryFields maps the JSON paths a client can project to the bson paths stored by StoreGyroData
+var telemetryFields = buildTelemetryFields()
+
+func buildTelemetryFields() map[string]string {
+	fields := map[string]string{
+		"deviceID":      "deviceid",
+		"userID":        "userid",
+		"Datetime":      "datetime",
+		"TimeStamp":     "timestamp",
+		"DeviceAddress": "data.deviceaddress",
+		"Temperature":   "data.temperature",
+		"velocity":      "velocity",
+		"zone":          "zone",
+		"order":         "order",
+	}
+	axisFields := []string{"Acceleration", "VelocityAngular", "VibrationSpeed", "VibrationAngle", "VibrationDisplacement", "Frequency"}
+	for _, axis := range []string{"X", "Y", "Z"} {
+		fields[axis] = "data." + strings.ToLower(axis)
+		for _, field := range axisFields {
+			fields[axis+"."+field] = "data." + strings.ToLower(axis) + "." + strings.ToLower(field)
+		}
+	}
+	return fields
+}
+
+// ParseTelemetryFields validates a comma separated projection such as "X.Acceleration,Temperature"
+func ParseTelemetryFields(value string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimPrefix(strings.TrimSpace(field), "data.")
+		if field == "" {
+			continue
+		}
+		if _, ok := telemetryFields[field]; !ok {
+			return nil, &QueryError{fmt.Sprintf("unknown field %q", field)}
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// EncodePageToken turns a cursor into the opaque token handed to the client
+func EncodePageToken(cursor TelemetryCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%d", cursor.TimeStamp, cursor.Skip)))
+}
+
+// DecodePageToken reads a token produced by EncodePageToken
+func DecodePageToken(token string) (TelemetryCursor, error) {
+	var cursor TelemetryCursor
+	if token == "" {
+		return cursor, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, &QueryError{"invalid page token"}
+	}
+	if _, err := fmt.Sscanf(string(raw), "%d.%d", &cursor.TimeStamp, &cursor.Skip); err != nil || cursor.Skip < 0 {
+		return cursor, &QueryError{"invalid page token"}
+	}
+	return cursor, nil
+}
+
+// QueryTelemetry returns one page of telemetry of a device with the token of the next page
+func QueryTelemetry(query TelemetryQuery) (TelemetryPage, error) {
+	if query.DeviceID == "" {
+		return TelemetryPage{}, &QueryError{"deviceID is required"}
+	}
+	if query.From > 0 && query.To > 0 && query.From > query.To {
+		return TelemetryPage{}, &QueryError{"from must be before to"}
+	}
+	if query.Limit <= 0 {
+		query.Limit = DefaultTelemetryLimit
+	} else if query.Limit > MaxTelemetryLimit {
+		query.Limit = MaxTelemetryLimit
+	}
+
+	// Ask for one more document to know if there is a next page
+	limit := query.Limit
+	query.Limit++
+	gyroData, err := store.QueryGyroData(query)
+	if err != nil {
+		return TelemetryPage{}, err
+	}
+
+	page := TelemetryPage{Data: make([]map[string]interface{}, 0, len(gyroData))}
+	hasMore := int64(len(gyroData)) > limit
+	if hasMore {
+		gyroData = gyroData[:limit]
+	}
+
+	for _, data := range gyroData {
+		document, err := projectGyroData(data, query.Fields)
+		if err != nil {
+			return TelemetryPage{}, err
+		}
+		page.Data = append(page.Data, document)
+	}
+
+	if hasMore {
+		page.NextPageToken = EncodePageToken(nextCursor(query.Cursor, gyroData))
+	}
+	return page, nil
+}
+
+// nextCursor moves the cursor past the documents of the page
+func nextCursor(cursor TelemetryCursor, page []schema.GyroData) TelemetryCursor {
+	last := page[len(page)-1].TimeStamp
+	var skip int64
+	for i := len(page) - 1; i >= 0 && page[i].TimeStamp == last; i-- {
+		skip++
+	}
+	// The whole page shares the TimeStamp of the previous cursor
+	if int64(len(page)) == skip && last == cursor.TimeStamp {
+		skip += cursor.Skip
+	}
+	return TelemetryCursor{TimeStamp: last, Skip: skip}
+}
+
+// projectGyroData keeps only the requested fields of a document, in the same JSON shape as schema.GyroData
+func projectGyroData(data schema.GyroData, fields []string) (map[string]interface{}, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return document, nil
+	}
+
+	// Always keep the identity of the document
+	projected := map[string]interface{}{
+		"deviceID":  document["deviceID"],
+		"Datetime":  document["Datetime"],
+		"TimeStamp": document["TimeStamp"],
+	}
+	detail, _ := document["data"].(map[string]interface{})
+	projectedDetail := map[string]interface{}{}
+	for _, field := range fields {
+		path := strings.Split(field, ".")
+		if !strings.HasPrefix(telemetryFields[field], "data.") {
+			projected[field] = document[field]
+			continue
+		}
+		if len(path) == 1 {
+			projectedDetail[field] = detail[field]
+			continue
+		}
+		axis, _ := detail[path[0]].(map[string]interface{})
+		projectedAxis, ok := projectedDetail[path[0]].(map[string]interface{})
+		if !ok {
+			projectedAxis = map[string]interface{}{}
+			projectedDetail[path[0]] = projectedAxis
+		}
+		projectedAxis[path[1]] = axis[path[1]]
+	}
+	if len(projectedDetail) > 0 {
+		projected["data"] = projectedDetail
+	}
+	return projected, nil
+}
+
+// QueryGyroData finds a page of telemetry of the device sorted by timestamp
+func (m *MongoStore) QueryGyroData(query TelemetryQuery) ([]schema.GyroData, error) {
+	collection := m.collection("MONGO_COLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"deviceid": query.DeviceID}
+	timeStamp := bson.M{}
+	if query.From > 0 {
+		timeStamp["$gte"] = query.From
+	}
+	if query.To > 0 {
+		timeStamp["$lte"] = query.To
+	}
+
+	// Resume from the cursor, documents sharing its TimeStamp are skipped
+	order := 1
+	if query.Descending {
+		order = -1
+	}
+	var skip int64
+	if query.Cursor.TimeStamp > 0 {
+		if query.Descending {
+			timeStamp["$lte"] = minInt64(query.Cursor.TimeStamp, query.To)
+		} else {
+			timeStamp["$gte"] = query.Cursor.TimeStamp
+		}
+		skip = query.Cursor.Skip
+	}
+	if len(timeStamp) > 0 {
+		filter["timestamp"] = timeStamp
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: order}, {Key: "_id", Value: order}}).
+		SetSkip(skip).
+		SetLimit(query.Limit)
+	if len(query.Fields) > 0 {
+		projection := bson.M{"deviceid": 1, "datetime": 1, "timestamp": 1}
+		for _, field := range query.Fields {
+			projection[telemetryFields[field]] = 1
+		}
+		findOptions.SetProjection(projection)
+	}
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var gyroData []schema.GyroData
+	if err = cursor.All(ctx, &gyroData); err != nil {
+		return nil, err
+	}
+	return gyroData, nil
+}
+
+// minInt64 returns the smaller bound, 0 meaning no bound
+func minInt64(a, b int64) int64 {
+	if b <= 0 || a < b {
+		return a
+	}
+	return b
+}
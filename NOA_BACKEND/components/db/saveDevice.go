@@ -1,19 +1,16 @@
 package db
 
 import (
-	env "GOLANG_SERVER/components/env"
 	"context"
 	"errors"
 	"log"
 	"time"
+
+	schema "GOLANG_SERVER/components/schema"
 )
 
 // SaveDevice saves a new device to the database
 func SaveDevice(deviceName, deviceID, userID, devicePassword string) error {
-	collection := client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_DEVICECOLLECTION"))
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
 	if len(deviceName) == 0 {
 		return errors.New("device name is empty")
 
@@ -31,33 +28,20 @@ func SaveDevice(deviceName, deviceID, userID, devicePassword string) error {
 	var createDate = time.Now()
 
 	// Insert the new device into the database
-	type Device struct {
-		UserID      string    `bson:"userID"`
-		Password    string    `bson:"password"`
-		DeviceName  string    `bson:"deviceName"`
-		DeviceID    string    `bson:"deviceID"`
-		CreateDate  time.Time `bson:"createDate"`
-		CurrentDate time.Time `bson:"currentDate"`
-		Bookmark    bool      `bson:"bookmark"`
-		Usage       int       `bson:"usage"`
-		Status      bool      `bson:"status"`
-	}
-
-	// Insert the new device into the database
-	device := Device{
+	device := schema.Device{
 		UserID:      userID,
 		Password:    devicePassword,
 		DeviceName:  deviceName,
-		DeviceID:    deviceID,
+		ID:          deviceID,
 		CreateDate:  createDate,
 		CurrentDate: time.Now(),
 		Bookmark:    false,
 		Usage:       0,
-		Status:      true,
+		Status:      false,                                  // Offline until the first message, see presence
+		Profile:     schema.DeviceProfile{Tags: []string{}}, // Set with PUT /device/{deviceID}/profile
 	}
 
-	_, err := collection.InsertOne(ctx, device)
-	if err != nil {
+	if err := store.InsertDevice(device); err != nil {
 		log.Println("Error saving device:", err)
 		return err
 	}
@@ -65,3 +49,13 @@ func SaveDevice(deviceName, deviceID, userID, devicePassword string) error {
 	log.Println("Device saved successfully:", deviceID)
 	return nil
 }
+
+// InsertDevice inserts a device into the device collection
+func (m *MongoStore) InsertDevice(device schema.Device) error {
+	collection := m.collection("MONGO_DEVICECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.InsertOne(ctx, device)
+	return err
+}
@@ -2,101 +2,112 @@ package db
 
 import (
 	"context"
-	"log"
+	"strconv"
 	"time"
 
-	env "GOLANG_SERVER/components/env"
+	schema "GOLANG_SERVER/components/schema"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// SaveOTP saves the OTP in the database
-func SaveOTP(userID string, otp string) {
-	collection = client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_AUTHCOLLECTION"))
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel() // Defer cancel the context
+// SaveOTP stores the OTP, replacing the previous one of the same subject and purpose
+func SaveOTP(otp schema.OTP) error {
+	return store.SaveOTP(otp)
+}
 
-	// Check if user already exists
-<<<<<<< HEAD
-	filter := bson.M{"userid": userID}
+// FindOTP gets the unexpired OTP of the subject for the purpose, ErrNotFound if none
+func FindOTP(subject, purpose string) (schema.OTP, error) {
+	return store.FindOTP(subject, purpose, time.Now())
+}
 
-	log.Println("Querying database with filter:", filter)
+// DeleteOTP deletes the OTP, so it cannot be used again
+func DeleteOTP(subject, purpose string) error {
+	return store.DeleteOTP(subject, purpose)
+}
 
-	// Check if OTP already exists
-	var result struct {
-		UserID string `bson:"userid"`
-=======
-	filter := bson.M{"userID": userID}
+// LogOTPSend logs a code sent to the subject now, unless one was sent within cooldown or max within window.
+// It returns the sends of the subject within window and whether this one was logged.
+func LogOTPSend(subject string, cooldown, window time.Duration, max int) (schema.OTPSends, bool, error) {
+	return store.LogOTPSend(subject, time.Now().Truncate(time.Millisecond), cooldown, window, max)
+}
 
-	//log.Println("Querying database with filter:", filter)
+// SaveOTP inserts or replaces the OTP of the subject and purpose in the OTP collection
+func (m *MongoStore) SaveOTP(otp schema.OTP) error {
+	collection := m.collection("MONGO_OTPCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
 
-	// Check if OTP already exists
-	var result struct {
-		UserID string `bson:"userID"`
->>>>>>> Final_BN
-		OTP    string `bson:"otp"`
-	}
-	if err := collection.FindOne(ctx, filter).Decode(&result); err == nil {
-		// If no error, the user exists, so update the OTP
-		collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"otp": otp, "expireAt": time.Now().Add(time.Minute)}})
-		log.Println("OTP updated:", userID+" "+otp)
-
-		// Schedule OTP deletion after 1 minute
-		time.AfterFunc(1*time.Minute, func() {
-			deleteOTP(userID)
-		})
-		return
-	}
+	filter := bson.M{"subject": otp.Subject, "purpose": otp.Purpose}
+	_, err := collection.ReplaceOne(ctx, filter, otp, options.Replace().SetUpsert(true))
+	return err
+}
 
-	// Save the OTP in the database
-<<<<<<< HEAD
-	_, err := collection.InsertOne(ctx, bson.M{"userid": userID, "otp": otp, "expireAt": time.Now().Add(time.Minute)})
-=======
-	_, err := collection.InsertOne(ctx, bson.M{"userID": userID, "otp": otp, "expireAt": time.Now().Add(time.Minute)})
->>>>>>> Final_BN
-	if err != nil {
-		log.Println("Error saving OTP:", err)
-		return
+// FindOTP gets the OTP of the subject and purpose if it has not expired
+func (m *MongoStore) FindOTP(subject, purpose string, now time.Time) (schema.OTP, error) {
+	collection := m.collection("MONGO_OTPCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	// The TTL index removes expired documents only about once a minute
+	filter := bson.M{"subject": subject, "purpose": purpose, "expireAt": bson.M{"$gt": now}}
+	var otp schema.OTP
+	if err := collection.FindOne(ctx, filter).Decode(&otp); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return schema.OTP{}, ErrNotFound
+		}
+		return schema.OTP{}, err
 	}
+	return otp, nil
+}
 
-	// Schedule OTP deletion after 1 minute
-	time.AfterFunc(1*time.Minute, func() {
-		deleteOTP(userID)
-	})
+// DeleteOTP deletes the OTP of the subject and purpose
+func (m *MongoStore) DeleteOTP(subject, purpose string) error {
+	collection := m.collection("MONGO_OTPCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
 
-	log.Println("OTP saved:", userID+" "+otp)
+	result, err := collection.DeleteOne(ctx, bson.M{"subject": subject, "purpose": purpose})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
 
-// deleteOTP deletes the OTP from the database
-func deleteOTP(userID string) {
-	collection = client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_AUTHCOLLECTION"))
+// LogOTPSend pushes the send in one update whose filter holds the limits, so concurrent requests cannot
+// send more than max codes
+func (m *MongoStore) LogOTPSend(subject string, now time.Time, cooldown, window time.Duration, max int) (schema.OTPSends, bool, error) {
+	collection := m.collection("MONGO_OTPSENDCOLLECTION")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel() // Defer cancel the context
 
-	// Check if user already exists
-<<<<<<< HEAD
-	filter := bson.M{"userid": userID}
-=======
-	filter := bson.M{"userID": userID}
->>>>>>> Final_BN
-
-	log.Println("Querying database with filter:", filter)
-
-	// Check if OTP already exists
-	var result struct {
-<<<<<<< HEAD
-		UserID string `bson:"userid"`
-=======
-		UserID string `bson:"userID"`
->>>>>>> Final_BN
-		OTP    string `bson:"otp"`
-	}
-	if collection.FindOne(ctx, filter).Decode(&result) == nil {
-		// DELETE OTP only
-		collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"otp": "", "expireAt": time.Now()}})
-		log.Println("OTP deleted:", userID)
-		return
+	// Forget the sends out of the window, creating the log of a new subject
+	filter := bson.M{"subject": subject}
+	pull := bson.M{"$pull": bson.M{"sends": bson.M{"$lte": now.Add(-window)}}}
+	if _, err := collection.UpdateOne(ctx, filter, pull, options.Update().SetUpsert(true)); err != nil {
+		return schema.OTPSends{}, false, err
 	}
 
-	log.Println("OTP not found:", userID)
+	limits := bson.M{
+		"subject":                      subject,
+		"sends":                        bson.M{"$not": bson.M{"$gt": now.Add(-cooldown)}},
+		"sends." + strconv.Itoa(max-1): bson.M{"$exists": false},
+	}
+	update := bson.M{"$push": bson.M{"sends": now}, "$set": bson.M{"expireAt": now.Add(window)}}
+	var sends schema.OTPSends
+	err := collection.FindOneAndUpdate(ctx, limits, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&sends)
+	if err == nil {
+		return sends, true, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return schema.OTPSends{}, false, err
+	}
+	if err := collection.FindOne(ctx, filter).Decode(&sends); err != nil {
+		return schema.OTPSends{}, false, err
+	}
+	return sends, false, nil
 }
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	schema "GOLANG_SERVER/components/schema"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CreateSession stores a new session
+func CreateSession(session schema.Session) error {
+	return store.InsertSession(session)
+}
+
+// FindSession finds a session by ID, ErrNotFound if none
+func FindSession(sessionID string) (schema.Session, error) {
+	return store.FindSession(sessionID)
+}
+
+// RotateSession replaces the refresh hash of an active session still at oldHash and extends it to expireAt,
+// ErrNotFound when the session was revoked or already rotated
+func RotateSession(sessionID, oldHash, newHash string, expireAt time.Time) error {
+	return store.RotateSession(sessionID, oldHash, newHash, time.Now(), expireAt)
+}
+
+// RevokeSession logs out one session of the user
+func RevokeSession(userID, sessionID string) error {
+	return store.RevokeSession(userID, sessionID, time.Now())
+}
+
+// RevokeSessions logs out every session of the user and returns how many were active
+func RevokeSessions(userID string) (int64, error) {
+	return store.RevokeSessions(userID, time.Now())
+}
+
+// InsertSession inserts a session into the session collection
+func (m *MongoStore) InsertSession(session schema.Session) error {
+	collection := m.collection("MONGO_SESSIONCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	_, err := collection.InsertOne(ctx, session)
+	return err
+}
+
+// FindSession finds a session by ID in the session collection
+func (m *MongoStore) FindSession(sessionID string) (schema.Session, error) {
+	collection := m.collection("MONGO_SESSIONCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	var session schema.Session
+	err := collection.FindOne(ctx, bson.M{"sessionID": sessionID}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return schema.Session{}, ErrNotFound
+	}
+	if err != nil {
+		return schema.Session{}, err
+	}
+	return session, nil
+}
+
+// RotateSession replaces the refresh hash in one conditional update, so a refresh token can only be rotated once
+func (m *MongoStore) RotateSession(sessionID, oldHash, newHash string, now, expireAt time.Time) error {
+	collection := m.collection("MONGO_SESSIONCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	filter := bson.M{"sessionID": sessionID, "refreshHash": oldHash, "revokedAt": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{
+		"refreshHash":  newHash,
+		"previousHash": oldHash,
+		"refreshAt":    now,
+		"expireAt":     expireAt,
+	}}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RevokeSession sets the revoke date of one session of the user
+func (m *MongoStore) RevokeSession(userID, sessionID string, at time.Time) error {
+	collection := m.collection("MONGO_SESSIONCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	// A session already revoked keeps its first revoke date
+	result, err := collection.UpdateOne(ctx, bson.M{"sessionID": sessionID, "userID": userID}, []bson.M{
+		{"$set": bson.M{"revokedAt": bson.M{"$ifNull": bson.A{"$revokedAt", at}}}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RevokeSessions sets the revoke date of every active session of the user
+func (m *MongoStore) RevokeSessions(userID string, at time.Time) (int64, error) {
+	collection := m.collection("MONGO_SESSIONCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel() // Defer cancel the context
+
+	filter := bson.M{"userID": userID, "revokedAt": bson.M{"$exists": false}}
+	result, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revokedAt": at}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
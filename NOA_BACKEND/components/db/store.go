@@ -0,0 +1,219 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	env "GOLANG_SERVER/components/env"
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// ErrNotFound is returned by a Store when the requested record does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrEmailExists is returned by a Store when a user with the same email already exists
+var ErrEmailExists = errors.New("email already exists")
+
+// UserStore stores user accounts
+type UserStore interface {
+	InsertUser(user schema.User) error                        // Insert a new user, ErrEmailExists if the email is taken
+	UpdateUser(user schema.User) error                        // Update username and password of the user with the same email
+	FindUser(email string) (schema.User, error)               // Find a user by email without the password
+	FindUserWithPassword(email string) (schema.User, error)   // Find a user by email including the password hash
+	FindUserID(userID string) (*schema.User, error)           // Find a user by userID
+	UpdatePassword(email string, hashedPassword string) error // Replace the password hash of a user
+	Users() ([]schema.User, error)                            // List every user without the password
+}
+
+// DeviceStore stores devices registered by users
+type DeviceStore interface {
+	InsertDevice(device schema.Device) error                                         // Insert a new device
+	FindDevice(deviceID string) (*schema.Device, error)                              // Find a device by deviceID
+	DeviceExists(deviceID string) (bool, error)                                      // Check if a deviceID is already registered
+	DevicesByUser(userID string) ([]schema.GetDevice, error)                         // List the devices of a user
+	DeleteDevice(userID, deviceID string) error                                      // Delete a device owned by a user
+	UpdateBookmark(userID, deviceID string, bookmark bool) error                     // Change the bookmark flag of a device
+	UpdateMachineClass(userID, deviceID string, class schema.MachineClass) error     // Change the ISO 10816-3 class of a device
+	UpdateDeviceProfile(userID, deviceID string, profile schema.DeviceProfile) error // Replace the machine profile of a device
+	UpdatePresence(deviceID string, online bool, lastSeen time.Time) error           // Set the online status and, when not zero, the last seen date
+	StaleOnlineDevices(cutoff time.Time) ([]schema.GetDevice, error)                 // List the online devices last seen before cutoff
+	TransferDevice(deviceID, fromUserID, toUserID string) error                      // Move a device of fromUserID to toUserID, ErrNotFound if not fromUserID's
+	SetDeviceAsset(userID, deviceID, assetID string) error                           // Attach a device of the user to an asset, detach it when assetID is empty
+}
+
+// HierarchyStore stores the organisations, sites and assets the devices of a user are grouped by
+type HierarchyStore interface {
+	InsertOrganisation(org schema.Organisation) error                 // Insert a new organisation
+	UpdateOrganisation(org schema.Organisation) error                 // Replace an organisation of the same user, ErrNotFound if none
+	DeleteOrganisation(userID, orgID string) error                    // Delete an organisation of the user, ErrNotFound if none
+	OrganisationsByUser(userID string) ([]schema.Organisation, error) // List the organisations of a user by name
+	InsertSite(site schema.Site) error                                // Insert a new site
+	UpdateSite(site schema.Site) error                                // Replace a site of the same user, ErrNotFound if none
+	DeleteSite(userID, siteID string) error                           // Delete a site of the user, ErrNotFound if none
+	SitesByUser(userID string) ([]schema.Site, error)                 // List the sites of a user by name
+	InsertAsset(asset schema.Asset) error                             // Insert a new asset
+	UpdateAsset(asset schema.Asset) error                             // Replace an asset of the same user, ErrNotFound if none
+	DeleteAsset(userID, assetID string) error                         // Delete an asset of the user, ErrNotFound if none
+	AssetsByUser(userID string) ([]schema.Asset, error)               // List the assets of a user by name
+}
+
+// ShareStore stores the devices shared between users and the invitations to them
+type ShareStore interface {
+	InsertShare(share schema.DeviceShare) error                                           // Insert a new invitation
+	FindShare(shareID string) (schema.DeviceShare, error)                                 // Find a share by ID, ErrNotFound if none
+	FindUserShare(userID, deviceID string) (schema.DeviceShare, error)                    // Find the accepted share of the device with the user, ErrNotFound if none
+	SharesByDevice(deviceID string) ([]schema.DeviceShare, error)                         // List the shares and invitations of a device, oldest first
+	SharesByUser(userID string) ([]schema.DeviceShare, error)                             // List the accepted shares of a user
+	InvitationsByEmail(email string, now time.Time) ([]schema.DeviceShare, error)         // List the pending invitations of an email unexpired at now
+	AcceptShare(shareID, email, userID string, now time.Time) (schema.DeviceShare, error) // Accept a pending invitation of the email valid at now, ErrNotFound if none
+	UpdateShareRole(deviceID, shareID, role string) error                                 // Change the role of a share of the device, ErrNotFound if none
+	UpdateShareBookmark(userID, deviceID string, bookmark bool) error                     // Change the bookmark of the accepted share of the device with the user
+	DeleteShare(shareID string) error                                                     // Delete a share, ErrNotFound if none
+	DeleteDeviceShares(deviceID string) error                                             // Delete every share of a device
+}
+
+// OTPStore stores hashed one-time passwords keyed by subject and purpose
+type OTPStore interface {
+	SaveOTP(otp schema.OTP) error                                                                                     // Insert or replace the OTP of the same subject and purpose
+	FindOTP(subject, purpose string, now time.Time) (schema.OTP, error)                                               // Get the OTP unexpired at now, ErrNotFound if none
+	AttemptOTP(subject, purpose string, now time.Time) (schema.OTP, error)                                            // Increment the attempts of the OTP unexpired at now and return it
+	DeleteOTP(subject, purpose string) error                                                                          // Delete the OTP
+	LogOTPSend(subject string, now time.Time, cooldown, window time.Duration, max int) (schema.OTPSends, bool, error) // Log a send unless one was within cooldown or max within window, return the log and whether it was logged
+}
+
+// SessionStore stores the login sessions of the users
+type SessionStore interface {
+	InsertSession(session schema.Session) error                                      // Insert a new session
+	FindSession(sessionID string) (schema.Session, error)                            // Find a session by ID, ErrNotFound if none
+	RotateSession(sessionID, oldHash, newHash string, now, expireAt time.Time) error // Replace the refresh hash of an active session still at oldHash, ErrNotFound otherwise
+	RevokeSession(userID, sessionID string, at time.Time) error                      // Revoke one session of the user
+	RevokeSessions(userID string, at time.Time) (int64, error)                       // Revoke every active session of the user
+}
+
+// PendingUserStore stores registrations waiting for their email to be verified
+type PendingUserStore interface {
+	SavePendingUser(pending schema.PendingUser) error                                              // Insert or replace the registration of the same email
+	InsertPendingUser(pending schema.PendingUser, now time.Time) (schema.PendingUser, bool, error) // Insert the registration unless one of the email is unexpired at now, return the one kept and whether it is new
+	FindPendingUser(email string, now time.Time) (schema.PendingUser, error)                       // Get the registration unexpired at now, ErrNotFound if none
+	DeletePendingUser(email string) error                                                          // Delete the registration of the email
+}
+
+// PasswordResetStore stores the hashed password reset tokens
+type PasswordResetStore interface {
+	InsertPasswordReset(reset schema.PasswordReset) error                           // Insert a new reset token
+	UsePasswordReset(tokenHash string, now time.Time) (schema.PasswordReset, error) // Mark an unused token valid at now used, ErrNotFound if none
+}
+
+// ProvisioningStore stores the pairing codes of new devices
+type ProvisioningStore interface {
+	InsertProvisioning(provisioning schema.Provisioning) error                     // Insert a new pairing code
+	FindProvisioning(userID, provisionID string) (schema.Provisioning, error)      // Find a pairing of the user, ErrNotFound if none
+	ClaimProvisioning(codeHash string, now time.Time) (schema.Provisioning, error) // Claim an unclaimed code valid at now, ErrNotFound if none
+	SetProvisioningDevice(provisionID, deviceID string) error                      // Record the device paired by a claimed code
+	ReleaseProvisioning(provisionID string) error                                  // Unclaim a code whose device could not be paired
+}
+
+// TelemetryStore stores the gyro data sent by the devices
+type TelemetryStore interface {
+	InsertGyroData(data schema.GyroData) error                                                    // Insert a telemetry document
+	GyroData() ([]schema.GyroData, error)                                                         // Get all telemetry documents
+	GyroDataByDevice(deviceID string) ([]schema.GyroData, error)                                  // Get all telemetry of a device
+	LatestGyroData(deviceID string, limit int64) ([]schema.GyroData, error)                       // Get the newest telemetry of a device
+	QueryGyroData(query TelemetryQuery) ([]schema.GyroData, error)                                // Get a page of telemetry of a device in a time range
+	AggregateGyroData(query TelemetryAggregateQuery) ([]TelemetryBucket, error)                   // Bucket the telemetry of a device by interval
+	EachGyroData(ctx context.Context, query TelemetryQuery, fn func(schema.GyroData) error) error // Stream the telemetry of a device in a time range
+	CleanGyroData() error                                                                         // Delete all telemetry documents
+}
+
+// CommandStore stores the commands sent to devices
+type CommandStore interface {
+	InsertCommand(command schema.Command) error                                   // Insert a pending command
+	CommandsByDevice(deviceID string, limit int64) ([]schema.Command, error)      // List the commands of a device newest first
+	AckCommand(deviceID, commandID, status, reason string, ackAt time.Time) error // Set the result of a pending command, ErrNotFound if none
+	ExpireCommands(now time.Time) (int64, error)                                  // Mark pending commands past their expiry as expired
+}
+
+// UsageStore stores the daily usage of the devices
+type UsageStore interface {
+	AddUsage(batchID string, usage schema.DeviceUsage) error                            // Add the counters to the day of the device and the usage of the device record, once per batchID
+	UsageByDevice(deviceID string, fromDay, toDay string) ([]schema.DeviceUsage, error) // List the days of a device in the inclusive range, oldest first
+}
+
+// AlertStore stores alert rules and the alerts they raise
+type AlertStore interface {
+	InsertAlertRule(rule schema.AlertRule) error                          // Insert a new rule
+	UpdateAlertRule(rule schema.AlertRule) error                          // Replace a rule of the same user, ErrNotFound if none
+	DeleteAlertRule(userID, ruleID string) error                          // Delete a rule of the user
+	AlertRulesByUser(userID string) ([]schema.AlertRule, error)           // List the rules of a user
+	EnabledAlertRules() ([]schema.AlertRule, error)                       // List the enabled rules of every user
+	InsertAlert(alert schema.Alert) error                                 // Insert a firing alert
+	ResolveAlert(alertID string, resolvedAt time.Time) error              // Mark a firing alert resolved
+	AlertsByUser(userID string, query AlertQuery) ([]schema.Alert, error) // List the alerts of a user newest first
+	FiringAlerts() ([]schema.Alert, error)                                // List the firing alerts of every user oldest first
+}
+
+// NotificationStore stores the notifications of the users
+type NotificationStore interface {
+	InsertNotification(notification schema.Notification) error                                 // Insert a notification
+	NotificationsByUser(userID string, query NotificationQuery) ([]schema.Notification, error) // List the notifications of a user, newest first
+	MarkNotificationsRead(userID string, ids []string, readAt time.Time) (int64, error)        // Mark notifications of the user read, all when ids is empty
+	CountUnreadNotifications(userID string) (int64, error)                                     // Count the unread notifications of a user
+}
+
+// WebhookStore stores the webhooks of the users and their delivery queue
+type WebhookStore interface {
+	InsertWebhook(webhook schema.Webhook) error                                                          // Insert a new webhook
+	UpdateWebhook(webhook schema.Webhook) error                                                          // Replace a webhook of the same user, ErrNotFound if none
+	DeleteWebhook(userID, webhookID string) error                                                        // Delete a webhook of the user
+	WebhooksByUser(userID string) ([]schema.Webhook, error)                                              // List the webhooks of a user
+	EnabledWebhooks(event string) ([]schema.Webhook, error)                                              // List the enabled webhooks of every user subscribed to the event
+	InsertDelivery(delivery schema.WebhookDelivery) error                                                // Queue a delivery
+	DueDeliveries(now time.Time, limit int64) ([]schema.WebhookDelivery, error)                          // List pending deliveries due at now, oldest first
+	ClaimDelivery(deliveryID string, due, until time.Time) (bool, error)                                 // Move the next attempt of a delivery still due at due to until, false if another worker did
+	RecordAttempt(deliveryID string, attempt schema.WebhookAttempt, status string, next time.Time) error // Log an attempt and set the status and next attempt
+	DeliveriesByWebhook(webhookID string, limit int64) ([]schema.WebhookDelivery, error)                 // List the latest deliveries of a webhook
+}
+
+// Store is the storage backend used by the db package
+type Store interface {
+	UserStore
+	DeviceStore
+	OTPStore
+	SessionStore
+	PendingUserStore
+	PasswordResetStore
+	ProvisioningStore
+	ShareStore
+	HierarchyStore
+	TelemetryStore
+	CommandStore
+	UsageStore
+	AlertStore
+	NotificationStore
+	WebhookStore
+}
+
+// store is the backend every package level function of db goes through
+var store Store
+
+// UseStore sets the storage backend used by the db package
+func UseStore(s Store) {
+	store = s
+}
+
+// GetStore returns the storage backend used by the db package
+func GetStore() Store {
+	return store
+}
+
+// Open selects the store from DB_STORE, "memory" keeps everything in process and anything else connects to MongoDB
+func Open() (bool, error) {
+	if env.GetEnv("DB_STORE") == "memory" {
+		log.Println("Using in-memory store, data is lost on restart")
+		UseStore(NewMemoryStore())
+		return true, nil
+	}
+	return Connect()
+}
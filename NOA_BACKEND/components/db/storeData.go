@@ -4,16 +4,11 @@ import (
 	"context"
 	"time"
 
-	env "GOLANG_SERVER/components/env"
 	schema "GOLANG_SERVER/components/schema"
 )
 
 // * store data to mongo db and use upper camel case for function name
 func StoreGyroData(data schema.GyroData) (bool, error) {
-	collection = client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_COLLECTION")) // Get collection data
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)                        // Create a context with timeout
-	defer cancel()                                                                                  // Defer cancel the context
-
 	// load Bangkok timezone
 	loc, err := time.LoadLocation("Asia/Bangkok")
 	if err != nil {
@@ -24,9 +19,18 @@ func StoreGyroData(data schema.GyroData) (bool, error) {
 
 	data.DateTime = currentTime.Format(time.RFC3339) // Set timestamp to current time
 	data.TimeStamp = currentTime.UnixMilli()         // Set timestamp to current time
-	_, err = collection.InsertOne(ctx, data)
-	if err != nil {
+	if err := store.InsertGyroData(data); err != nil {
 		return false, err
 	}
 	return true, nil
 }
+
+// InsertGyroData inserts a document into the data collection
+func (m *MongoStore) InsertGyroData(data schema.GyroData) error {
+	collection := m.collection("MONGO_COLLECTION")                           // Get collection data
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Create a context with timeout
+	defer cancel()                                                           // Defer cancel the context
+
+	_, err := collection.InsertOne(ctx, data)
+	return err
+}
@@ -1,41 +1,40 @@
 package db
 
 import (
-	env "GOLANG_SERVER/components/env"
+	schema "GOLANG_SERVER/components/schema"
 	"context"
-	"errors"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 )
 
-// StoreEmail checks if an email exists in the database
-func StoreEmail(email string) (bool, error) {
-	collection := client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_USERCOLLECTION"))
+// InsertUser inserts a user into the user collection if the email is not taken
+func (m *MongoStore) InsertUser(user schema.User) error {
+	collection := m.collection("MONGO_USERCOLLECTION")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	// Use a BSON document for the filter
-	filter := bson.M{"email": email}
+	filter := bson.M{"email": user.Email}
 
 	// Check if the email already exists
 	count, err := collection.CountDocuments(ctx, filter)
 	if err != nil {
-		return false, err
+		return err
 	}
 
 	if count > 0 {
-		return false, errors.New("email already exists")
+		return ErrEmailExists
 	}
 
-	// Generate user ID
-	var ID = generateUserID()
-
-	// Insert the email into the database (if needed)
-	_, err = collection.InsertOne(ctx, bson.M{"userID": ID, "email": email})
-	if err != nil {
-		return false, err
+	document := bson.M{"userID": user.ID, "email": user.Email}
+	if user.Username != "" {
+		document["username"] = user.Username
+	}
+	if user.Password != "" {
+		document["password"] = user.Password
 	}
 
-	return true, nil
+	_, err = collection.InsertOne(ctx, document)
+	return err
 }
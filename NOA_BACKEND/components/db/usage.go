@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"math/bits"
+	"time"
+
+	schema "GOLANG_SERVER/components/schema"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UsageDayLayout is the format of schema.DeviceUsage.Day
+const UsageDayLayout = "2006-01-02"
+
+// UsagePeriod is the usage of a device over one day or one month
+type UsagePeriod struct {
+	Period      string `json:"period"`      // 2006-01-02 for a day, 2006-01 for a month, empty for the total
+	Messages    int64  `json:"messages"`    // Telemetry messages ingested
+	Bytes       int64  `json:"bytes"`       // Payload bytes ingested
+	ActiveHours int64  `json:"activeHours"` // Hours with at least one message
+	Predictions int64  `json:"predictions"` // Prediction calls
+}
+
+func (p *UsagePeriod) add(day schema.DeviceUsage) {
+	p.Messages += day.Messages
+	p.Bytes += day.Bytes
+	p.ActiveHours += int64(bits.OnesCount64(uint64(day.Hours)))
+	p.Predictions += day.Predictions
+}
+
+// UsageReport is the usage of a device between two days broken down by day or month
+type UsageReport struct {
+	DeviceID  string        `json:"deviceID"`
+	From      string        `json:"from"`
+	To        string        `json:"to"`
+	Breakdown string        `json:"breakdown"` // daily or monthly
+	Usage     []UsagePeriod `json:"usage"`
+	Total     UsagePeriod   `json:"total"`
+}
+
+// AddUsage adds metered counters to the usage of a device, once per batchID so a retried flush is not counted twice
+func AddUsage(batchID string, usage schema.DeviceUsage) error {
+	return store.AddUsage(batchID, usage)
+}
+
+// DeviceUsageReport rolls the daily usage of a device between from and to (2006-01-02, inclusive) up by day or month
+func DeviceUsageReport(deviceID, from, to string, monthly bool) (UsageReport, error) {
+	fromDay, err := time.Parse(UsageDayLayout, from)
+	if err != nil {
+		return UsageReport{}, errors.New("from must be a date as 2006-01-02")
+	}
+	toDay, err := time.Parse(UsageDayLayout, to)
+	if err != nil {
+		return UsageReport{}, errors.New("to must be a date as 2006-01-02")
+	}
+	if toDay.Before(fromDay) {
+		return UsageReport{}, errors.New("from must be before to")
+	}
+
+	days, err := store.UsageByDevice(deviceID, from, to)
+	if err != nil {
+		return UsageReport{}, err
+	}
+
+	report := UsageReport{DeviceID: deviceID, From: from, To: to, Breakdown: "daily", Usage: []UsagePeriod{}}
+	if monthly {
+		report.Breakdown = "monthly"
+	}
+	for _, day := range days {
+		period := day.Day
+		if monthly {
+			period = day.Day[:len("2006-01")]
+		}
+		if n := len(report.Usage); n == 0 || report.Usage[n-1].Period != period {
+			report.Usage = append(report.Usage, UsagePeriod{Period: period})
+		}
+		report.Usage[len(report.Usage)-1].add(day)
+		report.Total.add(day)
+	}
+	return report, nil
+}
+
+// usageBatchHistory is how many batch IDs a usage or device document remembers to skip a retried batch
+const usageBatchHistory = 100
+
+// AddUsage increments the usage document of the day and the usage counter of the device.
+// Each write records batchID and skips a batch it already has, so retrying after a failure between them is safe.
+func (m *MongoStore) AddUsage(batchID string, usage schema.DeviceUsage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"deviceID": usage.DeviceID, "day": usage.Day, "batches": bson.M{"$ne": batchID}}
+	update := bson.M{
+		"$inc":         bson.M{"messages": usage.Messages, "bytes": usage.Bytes, "predictions": usage.Predictions},
+		"$bit":         bson.M{"hours": bson.M{"or": usage.Hours}},
+		"$push":        bson.M{"batches": bson.M{"$each": []string{batchID}, "$slice": -usageBatchHistory}},
+		"$setOnInsert": bson.M{"userID": usage.UserID},
+	}
+	_, err := m.collection("MONGO_USAGECOLLECTION").UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	// The day already has the batch, the upsert then collides with the unique index
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+
+	if usage.Messages == 0 {
+		return nil
+	}
+	_, err = m.collection("MONGO_DEVICECOLLECTION").UpdateOne(ctx,
+		bson.M{"deviceID": usage.DeviceID, "usageBatches": bson.M{"$ne": batchID}},
+		bson.M{
+			"$inc":  bson.M{"usage": usage.Messages},
+			"$push": bson.M{"usageBatches": bson.M{"$each": []string{batchID}, "$slice": -usageBatchHistory}},
+		})
+	return err
+}
+
+// UsageByDevice finds the usage documents of the device in the range of days
+func (m *MongoStore) UsageByDevice(deviceID string, fromDay, toDay string) ([]schema.DeviceUsage, error) {
+	collection := m.collection("MONGO_USAGECOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"deviceID": deviceID, "day": bson.M{"$gte": fromDay, "$lte": toDay}}
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"day": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var days []schema.DeviceUsage
+	if err = cursor.All(ctx, &days); err != nil {
+		return nil, err
+	}
+	return days, nil
+}
@@ -2,45 +2,35 @@ package db
 
 import (
 	"context"
-	"log"
-	"strings"
 	"time"
 
-	env "GOLANG_SERVER/components/env"
+	schema "GOLANG_SERVER/components/schema"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// VerifyOTP verifies the OTP of the user with token in 1 minute
-func VerifyOTP(userID string, otp string) string {
-	collection = client.Database(env.GetEnv("MONGO_DB")).Collection(env.GetEnv("MONGO_AUTHCOLLECTION"))
+// AttemptOTP counts one verification of the unexpired OTP and returns it with the new count, ErrNotFound if none
+func AttemptOTP(subject, purpose string) (schema.OTP, error) {
+	return store.AttemptOTP(subject, purpose, time.Now())
+}
+
+// AttemptOTP increments the attempts of the OTP in one update, so concurrent guesses are all counted
+func (m *MongoStore) AttemptOTP(subject, purpose string, now time.Time) (schema.OTP, error) {
+	collection := m.collection("MONGO_OTPCOLLECTION")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel() // Defer cancel the context
 
-	// Check if user already exists
-<<<<<<< HEAD
-	filter := bson.M{"userid": userID}
-
-	// Check if OTP already exists
-	var result struct {
-		UserID string `bson:"userid"`
-=======
-	filter := bson.M{"userID": userID}
-
-	// Check if OTP already exists
-	var result struct {
-		UserID string `bson:"userID"`
->>>>>>> Final_BN
-		OTP    string `bson:"otp"`
+	filter := bson.M{"subject": subject, "purpose": purpose, "expireAt": bson.M{"$gt": now}}
+	update := bson.M{"$inc": bson.M{"attempts": 1}}
+	var otp schema.OTP
+	err := collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&otp)
+	if err == mongo.ErrNoDocuments {
+		return schema.OTP{}, ErrNotFound
 	}
-	// Verify the OTP
-	err := collection.FindOne(ctx, filter).Decode(&result)
 	if err != nil {
-		log.Println("Error:", err)
-		return ""
+		return schema.OTP{}, err
 	}
-
-	// Trim and return the OTP
-	result.OTP = strings.TrimSpace(result.OTP)
-	return result.OTP
+	return otp, nil
 }
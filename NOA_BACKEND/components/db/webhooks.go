@@ -0,0 +1,305 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	schema "GOLANG_SERVER/components/schema"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Status of a webhook delivery
+const (
+	DeliveryPending   = "pending"   // Waiting for its next attempt
+	DeliveryDelivered = "delivered" // Answered with a 2xx status
+	DeliveryFailed    = "failed"    // Gave up after the last attempt
+)
+
+// DefaultDeliveryLimit is the number of deliveries listed per webhook
+const DefaultDeliveryLimit = 50
+
+// CreateWebhook stores a new webhook, the webhook must be validated by the caller
+func CreateWebhook(webhook schema.Webhook) (schema.Webhook, error) {
+	now := time.Now()
+	webhook.ID = uuid.New().String()
+	webhook.CreateAt = now
+	webhook.UpdateAt = now
+	if err := store.InsertWebhook(webhook); err != nil {
+		return schema.Webhook{}, err
+	}
+	return webhook, nil
+}
+
+// UpdateWebhook replaces a webhook of the user, keeping its creation date and, when none is given, its secret
+func UpdateWebhook(webhook schema.Webhook) (schema.Webhook, error) {
+	current, err := Webhook(webhook.UserID, webhook.ID)
+	if err != nil {
+		return schema.Webhook{}, err
+	}
+	if webhook.Secret == "" {
+		webhook.Secret = current.Secret
+	}
+	webhook.CreateAt = current.CreateAt
+	webhook.UpdateAt = time.Now()
+	if err := store.UpdateWebhook(webhook); err != nil {
+		return schema.Webhook{}, err
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook deletes a webhook of the user
+func DeleteWebhook(userID, webhookID string) error {
+	return store.DeleteWebhook(userID, webhookID)
+}
+
+// Webhook finds a webhook of the user
+func Webhook(userID, webhookID string) (schema.Webhook, error) {
+	webhooks, err := store.WebhooksByUser(userID)
+	if err != nil {
+		return schema.Webhook{}, err
+	}
+	for _, webhook := range webhooks {
+		if webhook.ID == webhookID {
+			return webhook, nil
+		}
+	}
+	return schema.Webhook{}, ErrNotFound
+}
+
+// Webhooks lists the webhooks of a user
+func Webhooks(userID string) ([]schema.Webhook, error) {
+	webhooks, err := store.WebhooksByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if webhooks == nil {
+		webhooks = []schema.Webhook{}
+	}
+	return webhooks, nil
+}
+
+// EnabledWebhooks lists the enabled webhooks of every user subscribed to the event
+func EnabledWebhooks(event string) ([]schema.Webhook, error) {
+	return store.EnabledWebhooks(event)
+}
+
+// QueueDelivery stores a pending delivery of the payload, due now
+func QueueDelivery(webhook schema.Webhook, event string, payload []byte) (schema.WebhookDelivery, error) {
+	// MongoDB keeps milliseconds, ClaimDelivery matches the date exactly
+	now := time.Now().Truncate(time.Millisecond)
+	delivery := schema.WebhookDelivery{
+		ID:          uuid.New().String(),
+		WebhookID:   webhook.ID,
+		UserID:      webhook.UserID,
+		Event:       event,
+		Payload:     string(payload),
+		Status:      DeliveryPending,
+		Attempts:    []schema.WebhookAttempt{},
+		NextAttempt: now,
+		CreateAt:    now,
+	}
+	if err := store.InsertDelivery(delivery); err != nil {
+		return schema.WebhookDelivery{}, err
+	}
+	return delivery, nil
+}
+
+// DueDeliveries lists the pending deliveries due now, oldest first
+func DueDeliveries(limit int64) ([]schema.WebhookDelivery, error) {
+	return store.DueDeliveries(time.Now(), limit)
+}
+
+// ClaimDelivery reserves a due delivery until the given date so a single worker attempts it
+func ClaimDelivery(delivery schema.WebhookDelivery, until time.Time) (bool, error) {
+	return store.ClaimDelivery(delivery.ID, delivery.NextAttempt, until)
+}
+
+// RecordAttempt logs an attempt of a delivery with its new status and next attempt
+func RecordAttempt(deliveryID string, attempt schema.WebhookAttempt, status string, next time.Time) error {
+	return store.RecordAttempt(deliveryID, attempt, status, next)
+}
+
+// WebhookDeliveries lists the latest deliveries of a webhook
+func WebhookDeliveries(webhookID string, limit int64) ([]schema.WebhookDelivery, error) {
+	if limit <= 0 || limit > DefaultDeliveryLimit {
+		limit = DefaultDeliveryLimit
+	}
+	deliveries, err := store.DeliveriesByWebhook(webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if deliveries == nil {
+		deliveries = []schema.WebhookDelivery{}
+	}
+	return deliveries, nil
+}
+
+// InsertWebhook inserts a webhook in the webhook collection
+func (m *MongoStore) InsertWebhook(webhook schema.Webhook) error {
+	collection := m.collection("MONGO_WEBHOOKCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.InsertOne(ctx, webhook)
+	return err
+}
+
+// UpdateWebhook replaces the webhook with the same ID and user
+func (m *MongoStore) UpdateWebhook(webhook schema.Webhook) error {
+	collection := m.collection("MONGO_WEBHOOKCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := collection.ReplaceOne(ctx, bson.M{"webhookID": webhook.ID, "userID": webhook.UserID}, webhook)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteWebhook deletes the webhook with the ID and user
+func (m *MongoStore) DeleteWebhook(userID, webhookID string) error {
+	collection := m.collection("MONGO_WEBHOOKCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := collection.DeleteOne(ctx, bson.M{"webhookID": webhookID, "userID": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// WebhooksByUser finds the webhooks of the user
+func (m *MongoStore) WebhooksByUser(userID string) ([]schema.Webhook, error) {
+	collection := m.collection("MONGO_WEBHOOKCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{"userID": userID}, options.Find().SetSort(bson.M{"createAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var webhooks []schema.Webhook
+	if err = cursor.All(ctx, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// EnabledWebhooks queries the enabled webhooks subscribed to the event
+func (m *MongoStore) EnabledWebhooks(event string) ([]schema.Webhook, error) {
+	collection := m.collection("MONGO_WEBHOOKCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{"enabled": true, "events": event})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var webhooks []schema.Webhook
+	if err = cursor.All(ctx, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// InsertDelivery inserts a delivery in the delivery collection
+func (m *MongoStore) InsertDelivery(delivery schema.WebhookDelivery) error {
+	collection := m.collection("MONGO_DELIVERYCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.InsertOne(ctx, delivery)
+	return err
+}
+
+// DueDeliveries finds the pending deliveries with a next attempt before now, oldest first
+func (m *MongoStore) DueDeliveries(now time.Time, limit int64) ([]schema.WebhookDelivery, error) {
+	collection := m.collection("MONGO_DELIVERYCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"status": DeliveryPending, "nextAttempt": bson.M{"$lte": now}}
+	findOptions := options.Find().SetSort(bson.M{"nextAttempt": 1}).SetLimit(limit)
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var deliveries []schema.WebhookDelivery
+	if err = cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ClaimDelivery moves the next attempt of a pending delivery still due at due, the update fails
+// when another server claimed it first
+func (m *MongoStore) ClaimDelivery(deliveryID string, due, until time.Time) (bool, error) {
+	collection := m.collection("MONGO_DELIVERYCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"deliveryID": deliveryID, "status": DeliveryPending, "nextAttempt": due}
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"nextAttempt": until}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// RecordAttempt appends an attempt to a delivery and sets its status and next attempt
+func (m *MongoStore) RecordAttempt(deliveryID string, attempt schema.WebhookAttempt, status string, next time.Time) error {
+	collection := m.collection("MONGO_DELIVERYCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	set := bson.M{"status": status, "nextAttempt": next}
+	if status == DeliveryDelivered {
+		set["deliveredAt"] = attempt.At
+	}
+	update := bson.M{"$set": set, "$push": bson.M{"attempts": attempt}}
+	result, err := collection.UpdateOne(ctx, bson.M{"deliveryID": deliveryID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeliveriesByWebhook finds the latest deliveries of the webhook
+func (m *MongoStore) DeliveriesByWebhook(webhookID string, limit int64) ([]schema.WebhookDelivery, error) {
+	collection := m.collection("MONGO_DELIVERYCOLLECTION")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.M{"createAt": -1}).SetLimit(limit)
+	cursor, err := collection.Find(ctx, bson.M{"webhookID": webhookID}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var deliveries []schema.WebhookDelivery
+	if err = cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,71 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// csvFlushRows is how many rows are buffered before flushing to the output
+const csvFlushRows = 1000
+
+type csvEncoder struct {
+	writer  *csv.Writer
+	record  []string
+	rows    int
+	started bool
+}
+
+// NewCSVEncoder writes one header row and one row per document with flattened columns
+func NewCSVEncoder(w io.Writer) Encoder {
+	return &csvEncoder{writer: csv.NewWriter(w), record: make([]string, len(columns))}
+}
+
+func (e *csvEncoder) Write(data schema.GyroData) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	for i, column := range columns {
+		switch value := column.Value(&data).(type) {
+		case string:
+			e.record[i] = value
+		case int64:
+			e.record[i] = strconv.FormatInt(value, 10)
+		case float64:
+			e.record[i] = strconv.FormatFloat(value, 'g', -1, 64)
+		}
+	}
+	if err := e.writer.Write(e.record); err != nil {
+		return err
+	}
+
+	e.rows++
+	if e.rows%csvFlushRows == 0 {
+		e.writer.Flush()
+		return e.writer.Error()
+	}
+	return nil
+}
+
+// writeHeader writes the column names once before the first row
+func (e *csvEncoder) writeHeader() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	for i, column := range columns {
+		e.record[i] = column.Name
+	}
+	return e.writer.Write(e.record)
+}
+
+func (e *csvEncoder) Close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
@@ -0,0 +1,93 @@
+package export
+
+import (
+	"errors"
+	"io"
+
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// Encoder writes telemetry documents one at a time to an output stream
+type Encoder interface {
+	Write(data schema.GyroData) error // Encode one document
+	Close() error                     // Flush buffered documents and write any trailer
+}
+
+// Format describes an export format
+type Format struct {
+	ContentType string
+	Extension   string
+	New         func(w io.Writer) Encoder
+}
+
+// Formats are the export formats by name
+var Formats = map[string]Format{
+	"csv":     {ContentType: "text/csv", Extension: "csv", New: NewCSVEncoder},
+	"jsonl":   {ContentType: "application/x-ndjson", Extension: "jsonl", New: NewJSONLinesEncoder},
+	"parquet": {ContentType: "application/vnd.apache.parquet", Extension: "parquet", New: NewParquetEncoder},
+}
+
+// ErrUnknownFormat is returned for a format missing from Formats
+var ErrUnknownFormat = errors.New("format must be one of csv, jsonl or parquet")
+
+// column is one flattened field of schema.GyroData
+type column struct {
+	Name  string
+	Kind  columnKind
+	Value func(data *schema.GyroData) interface{}
+}
+
+type columnKind int
+
+const (
+	kindString columnKind = iota
+	kindInt64
+	kindFloat64
+)
+
+// columns are the flattened fields in output order, axis fields are named like X.Acceleration
+var columns = buildColumns()
+
+func buildColumns() []column {
+	columns := []column{
+		{"deviceID", kindString, func(d *schema.GyroData) interface{} { return d.DeviceID }},
+		{"userID", kindString, func(d *schema.GyroData) interface{} { return d.UserID }},
+		{"Datetime", kindString, func(d *schema.GyroData) interface{} { return d.DateTime }},
+		{"TimeStamp", kindInt64, func(d *schema.GyroData) interface{} { return d.TimeStamp }},
+		{"DeviceAddress", kindString, func(d *schema.GyroData) interface{} { return d.Data.DeviceAddress }},
+	}
+	axes := []struct {
+		Name string
+		Axis func(d *schema.GyroData) *schema.AxisData
+	}{
+		{"X", func(d *schema.GyroData) *schema.AxisData { return &d.Data.X }},
+		{"Y", func(d *schema.GyroData) *schema.AxisData { return &d.Data.Y }},
+		{"Z", func(d *schema.GyroData) *schema.AxisData { return &d.Data.Z }},
+	}
+	fields := []struct {
+		Name  string
+		Field func(a *schema.AxisData) float64
+	}{
+		{"Acceleration", func(a *schema.AxisData) float64 { return a.Acceleration }},
+		{"VelocityAngular", func(a *schema.AxisData) float64 { return a.VelocityAngular }},
+		{"VibrationSpeed", func(a *schema.AxisData) float64 { return a.VibrationSpeed }},
+		{"VibrationAngle", func(a *schema.AxisData) float64 { return a.VibrationAngle }},
+		{"VibrationDisplacement", func(a *schema.AxisData) float64 { return a.VibrationDisplacement }},
+		{"Frequency", func(a *schema.AxisData) float64 { return a.Frequency }},
+	}
+	for _, axis := range axes {
+		for _, field := range fields {
+			axis, field := axis, field
+			columns = append(columns, column{axis.Name + "." + field.Name, kindFloat64, func(d *schema.GyroData) interface{} {
+				return field.Field(axis.Axis(d))
+			}})
+		}
+	}
+	columns = append(columns,
+		column{"Temperature", kindFloat64, func(d *schema.GyroData) interface{} { return d.Data.Temperature }},
+		column{"velocity", kindFloat64, func(d *schema.GyroData) interface{} { return d.Velocity }},
+		column{"zone", kindString, func(d *schema.GyroData) interface{} { return d.Zone }},
+		column{"order", kindFloat64, func(d *schema.GyroData) interface{} { return d.Order }},
+	)
+	return columns
+}
@@ -0,0 +1,28 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	schema "GOLANG_SERVER/components/schema"
+)
+
+type jsonLinesEncoder struct {
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+// NewJSONLinesEncoder writes one JSON document per line in the same shape as the MQTT payload
+func NewJSONLinesEncoder(w io.Writer) Encoder {
+	buffer := bufio.NewWriterSize(w, 64*1024)
+	return &jsonLinesEncoder{buffer: buffer, encoder: json.NewEncoder(buffer)}
+}
+
+func (e *jsonLinesEncoder) Write(data schema.GyroData) error {
+	return e.encoder.Encode(data) // Encode appends the newline
+}
+
+func (e *jsonLinesEncoder) Close() error {
+	return e.buffer.Flush()
+}
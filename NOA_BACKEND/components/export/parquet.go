@@ -0,0 +1,213 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// parquetRowGroupRows is how many rows are buffered in memory before a row group is written
+const parquetRowGroupRows = 8192
+
+// Parquet enum values used by the writer
+const (
+	parquetTypeInt64     = 2
+	parquetTypeDouble    = 5
+	parquetTypeByteArray = 6
+
+	parquetRequired        = 0
+	parquetConvertedUTF8   = 0
+	parquetTimestampMillis = 9
+	parquetPlain           = 0
+	parquetRLE             = 3
+	parquetUncompressed    = 0
+	parquetDataPage        = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+// parquetColumnChunk is the footer entry of a column chunk already written
+type parquetColumnChunk struct {
+	offset int64
+	size   int64
+}
+
+// parquetRowGroup is the footer entry of a row group already written
+type parquetRowGroup struct {
+	rows    int64
+	size    int64
+	columns []parquetColumnChunk
+}
+
+// parquetEncoder writes a flat Parquet file with one required column per flattened field,
+// PLAIN encoded and uncompressed. Rows are buffered per column and flushed every
+// parquetRowGroupRows rows so memory stays bounded whatever the export size.
+type parquetEncoder struct {
+	w         io.Writer
+	offset    int64
+	columns   []bytes.Buffer
+	rows      int64
+	totalRows int64
+	groups    []parquetRowGroup
+	err       error
+}
+
+// NewParquetEncoder writes a Parquet file with the same columns as the CSV export
+func NewParquetEncoder(w io.Writer) Encoder {
+	return &parquetEncoder{w: w, columns: make([]bytes.Buffer, len(columns))}
+}
+
+func (e *parquetEncoder) write(b []byte) {
+	if e.err != nil {
+		return
+	}
+	n, err := e.w.Write(b)
+	e.offset += int64(n)
+	e.err = err
+}
+
+func (e *parquetEncoder) Write(data schema.GyroData) error {
+	if e.offset == 0 {
+		e.write(parquetMagic)
+	}
+
+	var b [8]byte
+	for i, column := range columns {
+		buf := &e.columns[i]
+		switch value := column.Value(&data).(type) {
+		case string:
+			binary.LittleEndian.PutUint32(b[:4], uint32(len(value)))
+			buf.Write(b[:4])
+			buf.WriteString(value)
+		case int64:
+			binary.LittleEndian.PutUint64(b[:], uint64(value))
+			buf.Write(b[:])
+		case float64:
+			binary.LittleEndian.PutUint64(b[:], math.Float64bits(value))
+			buf.Write(b[:])
+		}
+	}
+
+	e.rows++
+	if e.rows == parquetRowGroupRows {
+		e.flushRowGroup()
+	}
+	return e.err
+}
+
+// flushRowGroup writes one data page per column for the buffered rows
+func (e *parquetEncoder) flushRowGroup() {
+	if e.rows == 0 {
+		return
+	}
+	group := parquetRowGroup{rows: e.rows, columns: make([]parquetColumnChunk, len(columns))}
+	for i := range columns {
+		values := e.columns[i].Bytes()
+
+		var header thriftWriter
+		header.beginStruct(0)
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(len(values)))
+		header.i32(3, int32(len(values)))
+		header.beginStruct(5)
+		header.i32(1, int32(e.rows))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.endStruct()
+		header.endStruct()
+
+		chunk := parquetColumnChunk{offset: e.offset}
+		e.write(header.bytes())
+		e.write(values)
+		chunk.size = e.offset - chunk.offset
+		group.columns[i] = chunk
+		group.size += chunk.size
+
+		e.columns[i].Reset()
+	}
+	e.groups = append(e.groups, group)
+	e.totalRows += e.rows
+	e.rows = 0
+}
+
+func (e *parquetEncoder) Close() error {
+	if e.offset == 0 {
+		e.write(parquetMagic)
+	}
+	e.flushRowGroup()
+
+	// FileMetaData
+	var footer thriftWriter
+	footer.beginStruct(0)
+	footer.i32(1, 1)
+
+	footer.listHeader(2, thriftStruct, len(columns)+1)
+	footer.beginStruct(0)
+	footer.str(4, "schema")
+	footer.i32(5, int32(len(columns)))
+	footer.endStruct()
+	for _, column := range columns {
+		footer.beginStruct(0)
+		footer.i32(1, parquetType(column.Kind))
+		footer.i32(3, parquetRequired)
+		footer.str(4, column.Name)
+		switch column.Kind {
+		case kindString:
+			footer.i32(6, parquetConvertedUTF8)
+		case kindInt64:
+			footer.i32(6, parquetTimestampMillis)
+		}
+		footer.endStruct()
+	}
+
+	footer.i64(3, e.totalRows)
+
+	footer.listHeader(4, thriftStruct, len(e.groups))
+	for _, group := range e.groups {
+		footer.beginStruct(0)
+		footer.listHeader(1, thriftStruct, len(group.columns))
+		for i, chunk := range group.columns {
+			footer.beginStruct(0)
+			footer.i64(2, chunk.offset)
+			footer.beginStruct(3)
+			footer.i32(1, parquetType(columns[i].Kind))
+			footer.i32List(2, parquetPlain, parquetRLE)
+			footer.strList(3, columns[i].Name)
+			footer.i32(4, parquetUncompressed)
+			footer.i64(5, group.rows)
+			footer.i64(6, chunk.size)
+			footer.i64(7, chunk.size)
+			footer.i64(9, chunk.offset)
+			footer.endStruct()
+			footer.endStruct()
+		}
+		footer.i64(2, group.size)
+		footer.i64(3, group.rows)
+		footer.endStruct()
+	}
+
+	footer.str(6, "NOA_Backend export")
+	footer.endStruct()
+
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer.bytes())))
+	e.write(footer.bytes())
+	e.write(length[:])
+	e.write(parquetMagic)
+	return e.err
+}
+
+func parquetType(kind columnKind) int32 {
+	switch kind {
+	case kindString:
+		return parquetTypeByteArray
+	case kindInt64:
+		return parquetTypeInt64
+	default:
+		return parquetTypeDouble
+	}
+}
@@ -0,0 +1,260 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// thriftReader decodes Thrift compact structs into maps of field id to value, independently of thriftWriter
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) byte() byte {
+	c := r.b[r.pos]
+	r.pos++
+	return c
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		panic("bad varint")
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(kind byte) interface{} {
+	switch kind {
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n := int(r.varint())
+		s := string(r.b[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case thriftList:
+		header := r.byte()
+		size, elem := int(header>>4), header&0x0f
+		if size == 15 {
+			size = int(r.varint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(elem)
+		}
+		return list
+	case thriftStruct:
+		return r.structure()
+	}
+	panic(fmt.Sprintf("unexpected thrift type %d", kind))
+}
+
+func (r *thriftReader) structure() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	var id int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		kind := header & 0x0f
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(kind)
+	}
+}
+
+func field[T any](t *testing.T, s map[int16]interface{}, id int16) T {
+	t.Helper()
+	v, ok := s[id].(T)
+	if !ok {
+		t.Fatalf("field %d = %#v", id, s[id])
+	}
+	return v
+}
+
+// readParquet checks the layout of a file written by the encoder and returns its rows column by column
+func readParquet(t *testing.T, file []byte) (int64, [][]interface{}) {
+	t.Helper()
+	if !bytes.HasPrefix(file, parquetMagic) || !bytes.HasSuffix(file, parquetMagic) {
+		t.Fatal("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footerStart := len(file) - 8 - footerLen
+	footer := &thriftReader{b: file[footerStart : len(file)-8]}
+	meta := footer.structure()
+	if footer.pos != footerLen {
+		t.Fatalf("footer decoded %d of %d bytes", footer.pos, footerLen)
+	}
+
+	if field[int64](t, meta, 1) != 1 {
+		t.Fatalf("version = %v", meta[1])
+	}
+	elements := field[[]interface{}](t, meta, 2)
+	if len(elements) != len(columns)+1 {
+		t.Fatalf("%d schema elements, want %d", len(elements), len(columns)+1)
+	}
+	root := elements[0].(map[int16]interface{})
+	if field[int64](t, root, 5) != int64(len(columns)) {
+		t.Fatalf("root has %v children", root[5])
+	}
+	for i, column := range columns {
+		element := elements[i+1].(map[int16]interface{})
+		if field[string](t, element, 4) != column.Name || field[int64](t, element, 1) != int64(parquetType(column.Kind)) {
+			t.Fatalf("schema element %d = %v, want %s", i, element, column.Name)
+		}
+	}
+
+	values := make([][]interface{}, len(columns))
+	var rows int64
+	offset := int64(len(parquetMagic))
+	for _, g := range field[[]interface{}](t, meta, 4) {
+		group := g.(map[int16]interface{})
+		groupRows := field[int64](t, group, 3)
+		var groupSize int64
+		for i, c := range field[[]interface{}](t, group, 1) {
+			chunk := c.(map[int16]interface{})
+			chunkMeta := field[map[int16]interface{}](t, chunk, 3)
+			start := field[int64](t, chunkMeta, 9)
+			size := field[int64](t, chunkMeta, 7)
+			if start != offset || field[int64](t, chunk, 2) != start || field[int64](t, chunkMeta, 6) != size {
+				t.Fatalf("column %d chunk at %d, want %d: %v", i, start, offset, chunkMeta)
+			}
+			if path := field[[]interface{}](t, chunkMeta, 3); len(path) != 1 || path[0] != columns[i].Name {
+				t.Fatalf("column %d path = %v", i, path)
+			}
+			if field[int64](t, chunkMeta, 5) != groupRows {
+				t.Fatalf("column %d has %v values, want %d", i, chunkMeta[5], groupRows)
+			}
+
+			page := &thriftReader{b: file[start : start+size]}
+			header := page.structure()
+			pageSize := field[int64](t, header, 3)
+			dataPage := field[map[int16]interface{}](t, header, 5)
+			if field[int64](t, header, 1) != parquetDataPage || field[int64](t, header, 2) != pageSize || field[int64](t, dataPage, 1) != groupRows {
+				t.Fatalf("column %d page header = %v", i, header)
+			}
+			if int64(page.pos)+pageSize != size {
+				t.Fatalf("column %d page of %d bytes in a chunk of %d", i, int64(page.pos)+pageSize, size)
+			}
+
+			// PLAIN values
+			data := page.b[page.pos:]
+			for n := int64(0); n < groupRows; n++ {
+				switch columns[i].Kind {
+				case kindString:
+					l := binary.LittleEndian.Uint32(data)
+					values[i] = append(values[i], string(data[4:4+l]))
+					data = data[4+l:]
+				case kindInt64:
+					values[i] = append(values[i], int64(binary.LittleEndian.Uint64(data)))
+					data = data[8:]
+				case kindFloat64:
+					values[i] = append(values[i], math.Float64frombits(binary.LittleEndian.Uint64(data)))
+					data = data[8:]
+				}
+			}
+			if len(data) != 0 {
+				t.Fatalf("column %d has %d bytes left", i, len(data))
+			}
+			offset += size
+			groupSize += size
+		}
+		if field[int64](t, group, 2) != groupSize {
+			t.Fatalf("row group size = %v, want %d", group[2], groupSize)
+		}
+		rows += groupRows
+	}
+	if offset != int64(footerStart) {
+		t.Fatalf("footer at %d, pages end at %d", footerStart, offset)
+	}
+	if field[int64](t, meta, 3) != rows {
+		t.Fatalf("num_rows = %v, row groups have %d", meta[3], rows)
+	}
+	return rows, values
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	// More rows than a row group, so the file has two
+	var rows []schema.GyroData
+	for i := 0; i < parquetRowGroupRows+3; i++ {
+		data := schema.GyroData{
+			DeviceID:  "deviceA",
+			UserID:    "userA",
+			DateTime:  fmt.Sprintf("2026-01-01 00:00:%02d", i%60),
+			TimeStamp: 1767225600000 + int64(i),
+			Zone:      []string{"A", "", "zone C"}[i%3],
+			Velocity:  float64(i) / 3,
+			Order:     -float64(i),
+		}
+		data.Data.DeviceAddress = "addr"
+		data.Data.X.Acceleration = float64(i) * 0.5
+		data.Data.Z.Frequency = math.Pi
+		data.Data.Temperature = 21.5
+		rows = append(rows, data)
+	}
+
+	var buf bytes.Buffer
+	encoder := NewParquetEncoder(&buf)
+	for _, data := range rows {
+		if err := encoder.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	n, values := readParquet(t, buf.Bytes())
+	if n != int64(len(rows)) {
+		t.Fatalf("%d rows read, want %d", n, len(rows))
+	}
+	for i, column := range columns {
+		for r := range rows {
+			if want := column.Value(&rows[r]); values[i][r] != want {
+				t.Fatalf("row %d %s = %v, want %v", r, column.Name, values[i][r], want)
+			}
+		}
+	}
+}
+
+func TestParquetEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewParquetEncoder(&buf).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := readParquet(t, buf.Bytes()); n != 0 {
+		t.Fatalf("%d rows in an empty export", n)
+	}
+}
+
+func TestThriftLongFieldDelta(t *testing.T) {
+	var w thriftWriter
+	w.beginStruct(0)
+	w.i32(1, -3)
+	w.str(20, "far")
+	w.i64(21, 1<<40)
+	w.endStruct()
+
+	r := &thriftReader{b: w.bytes()}
+	got := r.structure()
+	if got[1] != int64(-3) || got[20] != "far" || got[21] != int64(1<<40) || r.pos != len(w.bytes()) {
+		t.Fatalf("decoded %v", got)
+	}
+}
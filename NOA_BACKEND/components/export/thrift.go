@@ -0,0 +1,103 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Thrift compact protocol types used by the Parquet metadata
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs with the Thrift compact protocol, just enough for the Parquet footer and page headers
+type thriftWriter struct {
+	buf     bytes.Buffer
+	lastIDs []int16 // last field id of every open struct
+	lastID  int16
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	t.buf.Write(b[:n])
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) fieldHeader(id int16, kind byte) {
+	if delta := id - t.lastID; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		t.buf.WriteByte(kind)
+		t.zigzag(int64(id))
+	}
+	t.lastID = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) str(id int16, v string) {
+	t.fieldHeader(id, thriftBinary)
+	t.varint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+// listHeader starts a list field of size elements of kind
+func (t *thriftWriter) listHeader(id int16, kind byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | kind)
+	} else {
+		t.buf.WriteByte(0xf0 | kind)
+		t.varint(uint64(size))
+	}
+}
+
+func (t *thriftWriter) i32List(id int16, values ...int32) {
+	t.listHeader(id, thriftI32, len(values))
+	for _, v := range values {
+		t.zigzag(int64(v))
+	}
+}
+
+func (t *thriftWriter) strList(id int16, values ...string) {
+	t.listHeader(id, thriftBinary, len(values))
+	for _, v := range values {
+		t.varint(uint64(len(v)))
+		t.buf.WriteString(v)
+	}
+}
+
+// beginStruct starts a struct, either as field id or as a list element when id is 0
+func (t *thriftWriter) beginStruct(id int16) {
+	if id != 0 {
+		t.fieldHeader(id, thriftStruct)
+	}
+	t.lastIDs = append(t.lastIDs, t.lastID)
+	t.lastID = 0
+}
+
+func (t *thriftWriter) endStruct() {
+	t.buf.WriteByte(0) // stop field
+	t.lastID = t.lastIDs[len(t.lastIDs)-1]
+	t.lastIDs = t.lastIDs[:len(t.lastIDs)-1]
+}
+
+func (t *thriftWriter) bytes() []byte {
+	return t.buf.Bytes()
+}
@@ -0,0 +1,239 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// Message is one telemetry payload decoded once and handed to every subscriber
+type Message struct {
+	Topic    string                // MQTT topic the payload arrived on
+	Payload  []byte                // Raw JSON as published by the device
+	Data     schema.GyroData       // Decoded payload
+	Received time.Time             // When the bus received the payload
+	Profile  *schema.DeviceProfile // Machine profile of the device, set by the authorizer
+}
+
+// Policy is what Publish does when the queue of a subscriber is full
+type Policy int
+
+const (
+	Block Policy = iota // Wait for room, which slows the MQTT connection down
+	Drop                // Drop the message for this subscriber and count it
+)
+
+func (p Policy) String() string {
+	if p == Drop {
+		return "drop"
+	}
+	return "block"
+}
+
+// Subscriber receives the messages of the bus on its own goroutine through a bounded queue
+type Subscriber struct {
+	name    string
+	policy  Policy
+	queue   chan Message
+	done    chan struct{} // Closed by Unsubscribe
+	handler func(Message)
+
+	mu sync.RWMutex // Held for reading by send, run takes it once after done so no message is queued after its last drain
+
+	delivered atomic.Uint64 // Messages handled
+	dropped   atomic.Uint64 // Messages dropped because the queue was full
+	blocked   atomic.Uint64 // Times Publish waited for room in the queue
+}
+
+// SubscriberStats are the counters of one subscriber
+type SubscriberStats struct {
+	Name      string `json:"name"`
+	Policy    string `json:"policy"`
+	Queued    int    `json:"queued"`
+	Capacity  int    `json:"capacity"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"`
+	Blocked   uint64 `json:"blocked"`
+}
+
+// Stats are the counters of the bus
+type Stats struct {
+	Received    uint64            `json:"received"` // Payloads published to the bus
+	Invalid     uint64            `json:"invalid"`  // Payloads that could not be decoded
+	Denied      uint64            `json:"denied"`   // Payloads refused by the authorizer
+	Subscribers []SubscriberStats `json:"subscribers"`
+}
+
+var (
+	subscribers = struct {
+		sync.RWMutex
+		list []*Subscriber
+	}{}
+	authorizer func(*Message) error // Checks the topic of a message before fan out, may fill in its IDs
+	received   atomic.Uint64
+	invalid    atomic.Uint64
+	denied     atomic.Uint64
+)
+
+// SetAuthorizer sets the check every decoded message must pass before it reaches the subscribers
+func SetAuthorizer(fn func(*Message) error) {
+	authorizer = fn
+}
+
+// Subscribe registers handler under name. Messages are queued up to capacity and
+// handled in order; policy decides what happens once the queue is full.
+func Subscribe(name string, capacity int, policy Policy, handler func(Message)) *Subscriber {
+	if capacity < 1 {
+		capacity = 1
+	}
+	s := &Subscriber{name: name, policy: policy, queue: make(chan Message, capacity), done: make(chan struct{}), handler: handler}
+	go s.run()
+
+	subscribers.Lock()
+	subscribers.list = append(subscribers.list, s)
+	subscribers.Unlock()
+	return s
+}
+
+func (s *Subscriber) run() {
+	for {
+		select {
+		case msg := <-s.queue:
+			s.handle(msg)
+		case <-s.done:
+			// Wait for the sends in progress, which give up or queue now that done is closed, then handle
+			// what was queued before Unsubscribe
+			s.mu.Lock()
+			s.mu.Unlock()
+			for {
+				select {
+				case msg := <-s.queue:
+					s.handle(msg)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Subscriber) handle(msg Message) {
+	s.handler(msg)
+	s.delivered.Add(1)
+}
+
+// send queues the message, or applies the policy when the queue is full. It reports whether the message
+// will be handled, false once the subscriber is unsubscribed.
+func (s *Subscriber) send(msg Message) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// A select picks at random among ready cases, so done is checked alone first
+	select {
+	case <-s.done:
+		return false
+	default:
+	}
+	select {
+	case <-s.done:
+		return false
+	case s.queue <- msg:
+		return true
+	default:
+	}
+	if s.policy == Drop {
+		s.dropped.Add(1)
+		return false
+	}
+	s.blocked.Add(1)
+	select {
+	case s.queue <- msg:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// Unsubscribe stops delivery, messages already queued are still handled.
+// A Publish waiting for room in the queue gives up.
+func (s *Subscriber) Unsubscribe() {
+	subscribers.Lock()
+	defer subscribers.Unlock()
+
+	for i, sub := range subscribers.list {
+		if sub == s {
+			subscribers.list = append(subscribers.list[:i], subscribers.list[i+1:]...)
+			close(s.done)
+			return
+		}
+	}
+}
+
+// Publish decodes a telemetry payload and fans it out to every subscriber
+func Publish(topic string, payload []byte) error {
+	received.Add(1)
+
+	if len(payload) == 0 {
+		invalid.Add(1)
+		return errors.New("empty message")
+	}
+	msg := Message{Topic: topic, Payload: payload, Received: time.Now()}
+	if err := json.Unmarshal(payload, &msg.Data); err != nil {
+		invalid.Add(1)
+		return err
+	}
+	if authorizer != nil {
+		if err := authorizer(&msg); err != nil {
+			denied.Add(1)
+			return err
+		}
+	}
+	if msg.Data.UserID == "" || msg.Data.DeviceID == "" {
+		invalid.Add(1)
+		return errors.New("userID and deviceID are required")
+	}
+
+	PublishMessage(msg)
+	return nil
+}
+
+// PublishMessage fans an already decoded message out to every subscriber. The list is copied
+// so a Block subscriber with a full queue does not hold the lock Subscribe and GetStats need.
+func PublishMessage(msg Message) {
+	subscribers.RLock()
+	list := append([]*Subscriber(nil), subscribers.list...)
+	subscribers.RUnlock()
+
+	for _, s := range list {
+		s.send(msg)
+	}
+}
+
+// GetStats returns the counters of the bus and of every subscriber
+func GetStats() Stats {
+	subscribers.RLock()
+	defer subscribers.RUnlock()
+
+	stats := Stats{
+		Received:    received.Load(),
+		Invalid:     invalid.Load(),
+		Denied:      denied.Load(),
+		Subscribers: make([]SubscriberStats, 0, len(subscribers.list)),
+	}
+	for _, s := range subscribers.list {
+		stats.Subscribers = append(stats.Subscribers, SubscriberStats{
+			Name:      s.name,
+			Policy:    s.policy.String(),
+			Queued:    len(s.queue),
+			Capacity:  cap(s.queue),
+			Delivered: s.delivered.Load(),
+			Dropped:   s.dropped.Load(),
+			Blocked:   s.blocked.Load(),
+		})
+	}
+	return stats
+}
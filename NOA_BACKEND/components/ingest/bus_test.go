@@ -0,0 +1,104 @@
+package ingest
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// within fails the test when fn does not return in time
+func within(t *testing.T, what string, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("%s blocked", what)
+	}
+}
+
+func TestBlockedSubscriberDoesNotHoldTheBus(t *testing.T) {
+	release := make(chan struct{})
+	handled := make(chan string, 10)
+	slow := Subscribe("slow", 1, Block, func(msg Message) {
+		<-release
+		handled <- msg.Topic
+	})
+
+	// The handler waits on the first message and the second fills the queue, so the third blocks
+	PublishMessage(Message{Topic: "1"})
+	PublishMessage(Message{Topic: "2"})
+	published := make(chan struct{})
+	go func() {
+		PublishMessage(Message{Topic: "3"})
+		close(published)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	var fast *Subscriber
+	within(t, "Subscribe", func() { fast = Subscribe("fast", 10, Drop, func(Message) {}) })
+	within(t, "GetStats", func() { GetStats() })
+	within(t, "Unsubscribe", fast.Unsubscribe)
+
+	// Unsubscribing the blocked subscriber releases the publisher, the queued message is still handled
+	within(t, "Unsubscribe of the blocked subscriber", slow.Unsubscribe)
+	within(t, "blocked Publish", func() { <-published })
+	close(release)
+	for _, want := range []string{"1", "2"} {
+		select {
+		case got := <-handled:
+			if got != want {
+				t.Fatalf("handled %s, want %s", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("message %s not handled", want)
+		}
+	}
+	PublishMessage(Message{Topic: "4"})
+	select {
+	case got := <-handled:
+		t.Fatalf("message %s handled after Unsubscribe", got)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestNothingQueuedAfterUnsubscribe(t *testing.T) {
+	for round := 0; round < 50; round++ {
+		var handled atomic.Int64
+		sub := Subscribe("racy", 2, Block, func(Message) { handled.Add(1) })
+
+		// Every message a send accepts is handled, even when Unsubscribe runs in the middle of the sends
+		var accepted atomic.Int64
+		var senders sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			senders.Add(1)
+			go func() {
+				defer senders.Done()
+				for j := 0; j < 50; j++ {
+					if sub.send(Message{}) {
+						accepted.Add(1)
+					}
+				}
+			}()
+		}
+		time.Sleep(time.Duration(round%5) * 100 * time.Microsecond)
+		sub.Unsubscribe()
+		senders.Wait()
+
+		deadline := time.Now().Add(time.Second)
+		for handled.Load() != accepted.Load() {
+			if time.Now().After(deadline) {
+				t.Fatalf("round %d: %d messages accepted, %d handled", round, accepted.Load(), handled.Load())
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if sub.send(Message{}) {
+			t.Fatal("message accepted after Unsubscribe")
+		}
+	}
+}
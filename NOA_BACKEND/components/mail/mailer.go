@@ -0,0 +1,174 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	env "GOLANG_SERVER/components/env"
+)
+
+// Message is one email
+type Message struct {
+	To      []string
+	Subject string
+	HTML    string // HTML body
+	Text    string // Plain text body, optional
+}
+
+// Mailer delivers emails
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+const (
+	maxAttempts = 4                // Attempts of a queued email before it is dropped
+	retryDelay  = 5 * time.Second  // Wait after the first failure, doubled after each one
+	sendTimeout = 30 * time.Second // Time one attempt can take
+	queueSize   = 256              // Emails waiting to be sent
+)
+
+// ErrNoMailer is returned before a mailer is set
+var ErrNoMailer = errors.New("no mailer configured")
+
+// ErrQueueFull is returned by Send when the queue cannot take more emails
+var ErrQueueFull = errors.New("mail queue is full")
+
+var (
+	mailer    Mailer
+	from      string
+	queue     chan Message
+	startOnce sync.Once
+)
+
+// Use sets the Mailer and sender address used by Send and SendNow
+func Use(m Mailer, sender string) {
+	mailer = m
+	from = sender
+}
+
+// Open selects the mailer from MAIL_DRIVER: "smtp" (default), "file" or "memory",
+// then starts the queue worker
+func Open() error {
+	kind := env.GetEnv("MAIL_DRIVER")
+	var m Mailer
+	switch kind {
+	case "", "smtp":
+		smtp, err := NewSMTPFromEnv()
+		if err != nil {
+			return err
+		}
+		m = smtp
+	case "file":
+		m = &Outbox{Dir: envOr("MAIL_OUTBOX_DIR", "outbox")}
+	case "memory":
+		m = &Memory{}
+	default:
+		return fmt.Errorf("unknown mail driver %q", kind)
+	}
+	log.Println("Using mail driver:", kind)
+	Use(m, env.GetEnv("MAIL_FROM"))
+	Start()
+	return nil
+}
+
+// Start runs the worker sending the queued emails once, Open and Send call it
+func Start() {
+	startOnce.Do(func() {
+		queue = make(chan Message, queueSize)
+		go worker()
+	})
+}
+
+// Send queues an email, it is sent in the background and retried when the server refuses it
+func Send(msg Message) error {
+	if mailer == nil {
+		return ErrNoMailer
+	}
+	Start()
+	select {
+	case queue <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// SendNow sends an email and waits for the result
+func SendNow(ctx context.Context, msg Message) error {
+	if mailer == nil {
+		return ErrNoMailer
+	}
+	return mailer.Send(ctx, msg)
+}
+
+func worker() {
+	for msg := range queue {
+		delay := retryDelay
+		for attempt := 1; ; attempt++ {
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			err := SendNow(ctx, msg)
+			cancel()
+			if err == nil {
+				break
+			}
+			if attempt == maxAttempts {
+				log.Printf("Error sending email to %s, giving up: %v\n", strings.Join(msg.To, ", "), err)
+				break
+			}
+			log.Printf("Error sending email to %s, retrying in %s: %v\n", strings.Join(msg.To, ", "), delay, err)
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+}
+
+// build writes the message as a MIME email with a text and an HTML part
+func build(sender string, msg Message, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	header := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\n"+
+		"Content-Type: multipart/alternative; boundary=%q\r\n\r\n",
+		sender, strings.Join(msg.To, ", "), mime.QEncoding.Encode("utf-8", msg.Subject),
+		date.Format(time.RFC1123Z), writer.Boundary())
+
+	parts := []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	}
+	for _, part := range parts {
+		if part.body == "" {
+			continue
+		}
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return append([]byte(header), buf.Bytes()...), nil
+}
+
+func envOr(key string, fallback string) string {
+	if value := env.GetEnv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,37 @@
+package mail
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSendStartsOnce(t *testing.T) {
+	outbox := &Memory{}
+	Use(outbox, "noa@example.com")
+
+	// Send starts the worker itself, concurrently with Open
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			Start()
+		}()
+		go func() {
+			defer wg.Done()
+			if err := Send(Message{To: []string{"a@example.com"}, Subject: "hello"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(outbox.Messages()) < 20 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := len(outbox.Messages()); n != 20 {
+		t.Fatalf("%d emails sent, want 20", n)
+	}
+}
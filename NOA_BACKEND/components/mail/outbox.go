@@ -0,0 +1,52 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Outbox writes every email as an .eml file in Dir instead of sending it, for local runs
+type Outbox struct {
+	Dir string
+}
+
+// Send writes the message to a new file named after the time it was sent
+func (o *Outbox) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data, err := build(from, msg, now)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(o.Dir, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%d.eml", now.Format("20060102-150405"), now.UnixNano()%1e9)
+	return os.WriteFile(filepath.Join(o.Dir, name), data, 0644)
+}
+
+// Memory keeps the emails in memory, for tests
+type Memory struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// Send records the message
+func (m *Memory) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns the emails sent so far, oldest first
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Message(nil), m.messages...)
+}
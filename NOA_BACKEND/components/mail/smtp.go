@@ -0,0 +1,120 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+
+	env "GOLANG_SERVER/components/env"
+)
+
+// Security of the SMTP connection
+const (
+	SecurityStartTLS = "starttls" // Plain connection upgraded with STARTTLS, usually port 587
+	SecurityTLS      = "tls"      // Implicit TLS, usually port 465
+	SecurityNone     = "none"     // No encryption, for a local relay only
+)
+
+// SMTP sends emails through an SMTP server
+type SMTP struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	Security string // starttls, tls or none
+}
+
+// NewSMTPFromEnv reads SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD and SMTP_SECURITY
+func NewSMTPFromEnv() (*SMTP, error) {
+	s := &SMTP{
+		Host:     env.GetEnv("SMTP_HOST"),
+		Port:     env.GetEnv("SMTP_PORT"),
+		Username: env.GetEnv("SMTP_USERNAME"),
+		Password: env.GetEnv("SMTP_PASSWORD"),
+		Security: envOr("SMTP_SECURITY", SecurityStartTLS),
+	}
+	if s.Host == "" {
+		return nil, errors.New("SMTP_HOST is required")
+	}
+	switch s.Security {
+	case SecurityStartTLS:
+		if s.Port == "" {
+			s.Port = "587"
+		}
+	case SecurityTLS:
+		if s.Port == "" {
+			s.Port = "465"
+		}
+	case SecurityNone:
+		if s.Port == "" {
+			s.Port = "25"
+		}
+	default:
+		return nil, fmt.Errorf("unknown SMTP_SECURITY %q", s.Security)
+	}
+	return s, nil
+}
+
+// Send delivers the message, the context bounds the whole SMTP session
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	data, err := build(from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	address := net.JoinHostPort(s.Host, s.Port)
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	if s.Security == SecurityTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: s.Host}}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if s.Security == SecurityStartTLS {
+		if err := client.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,122 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	env "GOLANG_SERVER/components/env"
+)
+
+// Templates of the emails the server sends, each is a file in templates/{language}/{name}.html
+const (
+	TemplateOTP           = "otp"            // Data: Name, OTP, ExpireMinutes
+	TemplatePasswordReset = "password_reset" // Data: Name, Code, Link, ExpireMinutes
+	TemplateAlert         = "alert"          // Data: Name, DeviceID, RuleName, Severity, Status, Value, Zone, Message, FiredAt
+	TemplateWeeklyReport  = "weekly_report"  // Data: Name, From, To, Devices with DeviceID, DeviceName, Messages, ActiveHours, Alerts, Zone
+	TemplateDeviceInvite  = "device_invite"  // Data: Name, Inviter, DeviceID, DeviceName, Role, Transfer, Link, ExpireDays
+)
+
+// DefaultLanguage is used when MAIL_LANG is not set and for templates missing in a language
+const DefaultLanguage = "en"
+
+//go:embed templates
+var embedded embed.FS
+
+// parsed caches the templates by language + "/" + name
+var parsed sync.Map
+
+// template is one email template, subject and text are plain text, html is escaped
+type template struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// templates returns the template files, MAIL_TEMPLATE_DIR replaces the templates built in
+func templates() fs.FS {
+	if dir := env.GetEnv("MAIL_TEMPLATE_DIR"); dir != "" {
+		return os.DirFS(dir)
+	}
+	sub, _ := fs.Sub(embedded, "templates")
+	return sub
+}
+
+// Language picks th or en from an Accept-Language header or a language code, MAIL_LANG when neither matches
+func Language(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch {
+	case strings.HasPrefix(value, "th"):
+		return "th"
+	case strings.HasPrefix(value, "en"):
+		return "en"
+	}
+	return envOr("MAIL_LANG", DefaultLanguage)
+}
+
+// load parses the template of the language, falling back to DefaultLanguage
+func load(name, lang string) (*template, error) {
+	key := lang + "/" + name
+	if t, ok := parsed.Load(key); ok {
+		return t.(*template), nil
+	}
+
+	source, err := fs.ReadFile(templates(), lang+"/"+name+".html")
+	if err != nil && lang != DefaultLanguage {
+		return load(name, DefaultLanguage)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t := &template{}
+	if t.text, err = texttemplate.New(name).Parse(string(source)); err != nil {
+		return nil, err
+	}
+	if t.html, err = htmltemplate.New(name).Parse(string(source)); err != nil {
+		return nil, err
+	}
+	parsed.Store(key, t)
+	return t, nil
+}
+
+// Render builds the email of a template for one recipient. The template file defines
+// "subject", "html" and optionally "text".
+func Render(to, name, lang string, data interface{}) (Message, error) {
+	t, err := load(name, lang)
+	if err != nil {
+		return Message{}, err
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if t.text.Lookup("text") != nil {
+		if err := t.text.ExecuteTemplate(&text, "text", data); err != nil {
+			return Message{}, err
+		}
+	}
+	if err := t.html.ExecuteTemplate(&html, "html", data); err != nil {
+		return Message{}, err
+	}
+	return Message{
+		To:      []string{to},
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()),
+		HTML:    html.String(),
+	}, nil
+}
+
+// SendTemplate renders a template and queues the email
+func SendTemplate(to, name, lang string, data interface{}) error {
+	msg, err := Render(to, name, lang, data)
+	if err != nil {
+		return err
+	}
+	return Send(msg)
+}
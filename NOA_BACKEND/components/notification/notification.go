@@ -0,0 +1,111 @@
+package notification
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"GOLANG_SERVER/components/alert"
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/mail"
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// Types of notification
+const (
+	TypePrediction = "prediction" // The predicted class of a device changed
+	TypeAlert      = "alert"      // An alert rule fired or resolved
+)
+
+// Event is a new notification pushed to the WebSocket clients of its user
+type Event struct {
+	Event string `json:"event"` // Always "notification"
+	schema.Notification
+}
+
+var listeners = struct {
+	sync.RWMutex
+	list []func(Event)
+}{}
+
+// OnCreate registers fn to be called for every notification stored
+func OnCreate(fn func(Event)) {
+	listeners.Lock()
+	listeners.list = append(listeners.list, fn)
+	listeners.Unlock()
+}
+
+// EmailSeverities are the severities of the alerts also emailed to the owner of the device
+var EmailSeverities = map[string]bool{"warning": true, "critical": true}
+
+// Start turns firing and resolved alerts into notifications, and emails the severe ones
+func Start() {
+	alert.OnChange(notifyAlert)
+	alert.OnChange(emailAlert)
+}
+
+// Notify stores a notification and pushes it to the listeners
+func Notify(notification schema.Notification) (schema.Notification, error) {
+	notification, err := db.CreateNotification(notification)
+	if err != nil {
+		return schema.Notification{}, err
+	}
+
+	listeners.RLock()
+	defer listeners.RUnlock()
+	for _, fn := range listeners.list {
+		fn(Event{Event: "notification", Notification: notification})
+	}
+	return notification, nil
+}
+
+func notifyAlert(event alert.Event) {
+	title := fmt.Sprintf("%s alert: %s", event.Severity, event.RuleName)
+	if event.Status == db.AlertResolved {
+		title = "Resolved: " + event.RuleName
+	}
+	_, err := Notify(schema.Notification{
+		UserID:   event.UserID,
+		DeviceID: event.DeviceID,
+		Type:     TypeAlert,
+		Title:    title,
+		Message:  event.Message,
+		Data: map[string]interface{}{
+			"alertID":  event.ID,
+			"ruleID":   event.RuleID,
+			"status":   event.Status,
+			"severity": event.Severity,
+			"zone":     event.Zone,
+			"value":    event.Value,
+		},
+	})
+	if err != nil {
+		log.Println("Error saving notification:", err)
+	}
+}
+
+// emailAlert queues the alert email to the owner of the device
+func emailAlert(event alert.Event) {
+	if !EmailSeverities[event.Severity] {
+		return
+	}
+	user, err := db.FindUserID(event.UserID)
+	if err != nil {
+		log.Println("Error finding the user of alert", event.ID+":", err)
+		return
+	}
+	err = mail.SendTemplate(user.Email, mail.TemplateAlert, mail.Language(""), map[string]interface{}{
+		"Name":     user.Username,
+		"DeviceID": event.DeviceID,
+		"RuleName": event.RuleName,
+		"Severity": event.Severity,
+		"Status":   event.Status,
+		"Value":    event.Value,
+		"Zone":     event.Zone,
+		"Message":  event.Message,
+		"FiredAt":  event.FiredAt.Format("2006-01-02 15:04:05 MST"),
+	})
+	if err != nil {
+		log.Println("Error sending alert email:", err)
+	}
+}
@@ -0,0 +1,52 @@
+package notification
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"GOLANG_SERVER/components/alert"
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/mail"
+	schema "GOLANG_SERVER/components/schema"
+)
+
+func TestEmailAlert(t *testing.T) {
+	os.Setenv("DB_STORE", "memory")
+	defer os.Unsetenv("DB_STORE")
+	if _, err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.GetStore().InsertUser(schema.User{ID: "userA", Username: "alice", Email: "a@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	outbox := &mail.Memory{}
+	mail.Use(outbox, "noa@example.com")
+
+	fired := schema.Alert{ID: "alert1", UserID: "userA", DeviceID: "deviceA", RuleName: "Hot bearing", Severity: "critical", Status: db.AlertFiring, Value: 81.5, FiredAt: time.Now()}
+	emailAlert(alert.Event{Event: "alert", Alert: fired})
+	info := fired
+	info.Severity = "info"
+	emailAlert(alert.Event{Event: "alert", Alert: info})
+	resolved := fired
+	resolved.Status = db.AlertResolved
+	emailAlert(alert.Event{Event: "alert", Alert: resolved})
+
+	// Emails are queued, the worker sends them in the background
+	deadline := time.Now().Add(2 * time.Second)
+	for len(outbox.Messages()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	messages := outbox.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("%d alert emails, want the critical alert firing and resolving", len(messages))
+	}
+	if messages[0].To[0] != "a@example.com" || !strings.Contains(messages[0].Subject, "Hot bearing on deviceA") || !strings.Contains(messages[0].Text, "81.5") {
+		t.Fatalf("firing email = %+v", messages[0])
+	}
+	if !strings.Contains(messages[1].Subject, "Resolved: Hot bearing") {
+		t.Fatalf("resolved email subject = %q", messages[1].Subject)
+	}
+}
@@ -0,0 +1,174 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/env"
+	"GOLANG_SERVER/components/schema"
+)
+
+// Purposes of an OTP, a code issued for one purpose cannot be used for another
+const (
+	PurposeRegister   = "register"
+	PurposeReset      = "reset"
+	PurposeDevicePair = "device-pair" // Subject is the device ID
+)
+
+const (
+	Digits      = 6                // Length of a code
+	TTL         = 10 * time.Minute // Time a code can be used
+	MaxAttempts = 5                // Verifications before the code is deleted
+	Cooldown    = time.Minute      // Time before another code can be sent to the same subject
+	MaxSends    = 5                // Codes sent to a subject within SendWindow, for every purpose
+	SendWindow  = time.Hour        // Rolling window of MaxSends
+)
+
+var (
+	ErrInvalid = errors.New("invalid or expired OTP")               // Wrong code, or none for the subject
+	ErrTooMany = errors.New("too many attempts, request a new OTP") // MaxAttempts reached, the code is deleted
+	ErrPurpose = errors.New("invalid OTP purpose")                  // Purpose is not one of the Purpose constants
+)
+
+// CooldownError is returned by Issue when the previous code of the subject was sent less than Cooldown ago,
+// or MaxSends codes were sent within SendWindow
+type CooldownError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CooldownError) Error() string {
+	return fmt.Sprintf("an OTP was sent recently, retry in %d seconds", int(e.RetryAfter.Seconds()+0.5))
+}
+
+var (
+	keyOnce sync.Once
+	key     []byte
+)
+
+// secret is the HMAC key from OTP_SECRET, a random key when not set
+func secret() []byte {
+	keyOnce.Do(func() {
+		if value := env.GetEnv("OTP_SECRET"); value != "" {
+			key = []byte(value)
+			return
+		}
+		log.Println("[OTP] OTP_SECRET is not set, codes are lost on restart and not shared between servers")
+		key = make([]byte, 32)
+		rand.Read(key)
+	})
+	return key
+}
+
+// hash is the hex HMAC-SHA256 of the code, bound to its subject and purpose
+func hash(subject, purpose, code string) string {
+	mac := hmac.New(sha256.New, secret())
+	mac.Write([]byte(purpose + "\x00" + subject + "\x00" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generate returns a random code of Digits digits
+func generate() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < Digits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", Digits, n), nil
+}
+
+// validPurpose reports whether the purpose is one of the Purpose constants
+func validPurpose(purpose string) bool {
+	return purpose == PurposeRegister || purpose == PurposeReset || purpose == PurposeDevicePair
+}
+
+// Issue creates a code for the subject and purpose, replacing the previous one. It returns a *CooldownError
+// when the previous code of the subject was sent less than Cooldown ago or MaxSends codes within SendWindow.
+// As every code is deleted after MaxAttempts, a subject gets at most MaxSends * MaxAttempts guesses per window.
+func Issue(subject, purpose string) (string, error) {
+	if !validPurpose(purpose) {
+		return "", ErrPurpose
+	}
+
+	// The send is logged before the code exists, a failure below still counts
+	sends, logged, err := db.LogOTPSend(subject, Cooldown, SendWindow, MaxSends)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().Truncate(time.Millisecond)
+	if !logged {
+		return "", &CooldownError{RetryAfter: retryAfter(sends.Sends, now)}
+	}
+
+	code, err := generate()
+	if err != nil {
+		return "", err
+	}
+	err = db.SaveOTP(schema.OTP{
+		Subject:  subject,
+		Purpose:  purpose,
+		CodeHash: hash(subject, purpose, code),
+		CreateAt: now,
+		ExpireAt: now.Add(TTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// retryAfter is the wait before the next send allowed by the sends within SendWindow, oldest first
+func retryAfter(sends []time.Time, now time.Time) time.Duration {
+	if len(sends) == 0 {
+		return 0
+	}
+	next := sends[len(sends)-1].Add(Cooldown)
+	if len(sends) >= MaxSends {
+		if end := sends[len(sends)-MaxSends].Add(SendWindow); end.After(next) {
+			next = end
+		}
+	}
+	return next.Sub(now)
+}
+
+// Verify checks the code of the subject and purpose and deletes it once matched, so it works once.
+// Every call counts as an attempt, the code is deleted after MaxAttempts.
+func Verify(subject, purpose, code string) error {
+	if !validPurpose(purpose) {
+		return ErrPurpose
+	}
+
+	stored, err := db.AttemptOTP(subject, purpose)
+	if err == db.ErrNotFound {
+		return ErrInvalid
+	}
+	if err != nil {
+		return err
+	}
+	if stored.Attempts > MaxAttempts {
+		db.DeleteOTP(subject, purpose)
+		return ErrTooMany
+	}
+
+	// Compare the hashes in constant time
+	if !hmac.Equal([]byte(hash(subject, purpose, code)), []byte(stored.CodeHash)) {
+		return ErrInvalid
+	}
+	// Deleting claims the code, a concurrent verification of the same code finds it gone
+	err = db.DeleteOTP(subject, purpose)
+	if err == db.ErrNotFound {
+		return ErrInvalid
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package otp
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+)
+
+func openMemory(t *testing.T) {
+	t.Helper()
+	os.Setenv("DB_STORE", "memory")
+	t.Cleanup(func() { os.Unsetenv("DB_STORE") })
+	if _, err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	openMemory(t)
+	code, err := Issue("a@example.com", PurposeRegister)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify("a@example.com", PurposeReset, code); err != ErrInvalid {
+		t.Fatalf("code verified for another purpose: %v", err)
+	}
+	if err := Verify("a@example.com", PurposeRegister, code); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify("a@example.com", PurposeRegister, code); err != ErrInvalid {
+		t.Fatalf("code verified twice: %v", err)
+	}
+}
+
+func TestDevicePairCode(t *testing.T) {
+	openMemory(t)
+	code, err := Issue("deviceA", PurposeDevicePair)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify("deviceA", PurposeRegister, code); err != ErrInvalid {
+		t.Fatalf("pairing code verified for registration: %v", err)
+	}
+	if err := Verify("deviceA", PurposeDevicePair, code); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Issue("deviceA", "pair"); err != ErrPurpose {
+		t.Fatalf("unknown purpose: %v", err)
+	}
+}
+
+func TestCooldownOutlivesDeletedCode(t *testing.T) {
+	openMemory(t)
+	if _, err := Issue("a@example.com", PurposeRegister); err != nil {
+		t.Fatal(err)
+	}
+
+	// Too many wrong guesses delete the code
+	var err error
+	for i := 0; i <= MaxAttempts && err != ErrTooMany; i++ {
+		err = Verify("a@example.com", PurposeRegister, "not a code")
+	}
+	if err != ErrTooMany {
+		t.Fatalf("after %d wrong guesses: %v", MaxAttempts+1, err)
+	}
+
+	// but no new code can be sent before the cooldown, for any purpose
+	for _, purpose := range []string{PurposeRegister, PurposeReset, PurposeDevicePair} {
+		var cooldown *CooldownError
+		if _, err := Issue("a@example.com", purpose); !errors.As(err, &cooldown) || cooldown.RetryAfter <= 0 || cooldown.RetryAfter > Cooldown {
+			t.Fatalf("%s code issued after the deleted one: %v", purpose, err)
+		}
+	}
+	if _, err := Issue("b@example.com", PurposeRegister); err != nil {
+		t.Fatalf("other subject: %v", err)
+	}
+}
+
+func TestSendWindow(t *testing.T) {
+	store := db.NewMemoryStore()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// One send per cooldown is allowed until MaxSends within the window
+	at := start
+	for i := 0; i < MaxSends; i++ {
+		if _, logged, _ := store.LogOTPSend("a@example.com", at, Cooldown, SendWindow, MaxSends); !logged {
+			t.Fatalf("send %d refused", i+1)
+		}
+		if _, logged, _ := store.LogOTPSend("a@example.com", at.Add(Cooldown/2), Cooldown, SendWindow, MaxSends); logged {
+			t.Fatalf("send %d allowed within the cooldown", i+1)
+		}
+		at = at.Add(Cooldown)
+	}
+	sends, logged, _ := store.LogOTPSend("a@example.com", at, Cooldown, SendWindow, MaxSends)
+	if logged {
+		t.Fatalf("send %d allowed within the window", MaxSends+1)
+	}
+	if wait := retryAfter(sends.Sends, at); wait != start.Add(SendWindow).Sub(at) {
+		t.Fatalf("retry after %v, want the end of the window of the first send", wait)
+	}
+
+	// The window rolls: once the first send is an hour old, one more is allowed
+	if _, logged, _ := store.LogOTPSend("a@example.com", start.Add(SendWindow), Cooldown, SendWindow, MaxSends); !logged {
+		t.Fatal("send refused once the first one left the window")
+	}
+	if _, logged, _ := store.LogOTPSend("a@example.com", start.Add(SendWindow+Cooldown), Cooldown, SendWindow, MaxSends); !logged {
+		t.Fatal("send refused once the second one left the window")
+	}
+	if _, logged, _ := store.LogOTPSend("a@example.com", start.Add(SendWindow+2*Cooldown-time.Second), Cooldown, SendWindow, MaxSends); logged {
+		t.Fatal("send allowed within the cooldown of the last one")
+	}
+}
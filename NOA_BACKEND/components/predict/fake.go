@@ -0,0 +1,58 @@
+package predict
+
+import (
+	"context"
+	"sync"
+)
+
+// Fake is a deterministic Predictor for tests. It returns Class, or the result of
+// ClassFunc when set, as a one-hot prediction over Classes classes and counts its calls.
+type Fake struct {
+	Classes   int             // Number of classes, 3 when zero
+	Class     int             // Class returned when ClassFunc is nil
+	ClassFunc func(Input) int // Optional class per input
+	Err       error           // Returned instead of a result when set
+
+	mu    sync.Mutex
+	calls []Input
+}
+
+func (f *Fake) Predict(ctx context.Context, input Input) (*Result, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, input)
+	f.mu.Unlock()
+
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	class := f.Class
+	if f.ClassFunc != nil {
+		class = f.ClassFunc(input)
+	}
+	classes := f.Classes
+	if classes == 0 {
+		classes = 3
+	}
+
+	prediction := make([]float32, classes)
+	if class >= 0 && class < classes {
+		prediction[class] = 1
+	}
+	return &Result{
+		Prediction:     [][]float32{prediction},
+		PredictedClass: []int{class},
+		Timestamp:      input.Timestamp,
+	}, nil
+}
+
+// Calls returns the inputs received so far
+func (f *Fake) Calls() []Input {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Input(nil), f.calls...)
+}
+
+func (f *Fake) Close() error {
+	return nil
+}
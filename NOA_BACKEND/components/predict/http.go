@@ -0,0 +1,59 @@
+package predict
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// HTTP posts windows as JSON to a prediction service, the body and response match the WebSocket service
+type HTTP struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTP creates a predictor posting to url
+func NewHTTP(url string) *HTTP {
+	return &HTTP{url: url, client: &http.Client{Timeout: defaultRemoteTimeout}}
+}
+
+func (p *HTTP) Predict(ctx context.Context, input Input) (*Result, error) {
+	payload, err := json.Marshal(requestBody(input))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("prediction service returned %s", resp.Status)
+	}
+
+	var result Result
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if len(result.PredictedClass) == 0 {
+		return nil, errors.New("prediction service returned no class")
+	}
+	return &result, nil
+}
+
+// Close releases idle keep-alive connections
+func (p *HTTP) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,319 @@
+package predict
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+// Model runs a small exported network in process so classification works without the Python service.
+//
+// The weights file is JSON:
+//
+//	{
+//	  "inputLength": 200,
+//	  "mean": [0, 0, 0], "std": [1, 1, 1],
+//	  "layers": [
+//	    {"type": "conv1d", "kernel": [[[...]]], "bias": [...], "stride": 1, "activation": "relu"},
+//	    {"type": "maxpool1d", "size": 2},
+//	    {"type": "flatten"},
+//	    {"type": "dense", "kernel": [[...]], "bias": [...], "activation": "softmax"}
+//	  ]
+//	}
+//
+// The input is 3 channels (X, Y, Z accelerations) of inputLength samples, normalised by the
+// optional per channel mean and std. Kernels are laid out as Keras exports them: conv1d
+// [size][inChannels][filters] and dense [inputs][units]. flatten and the implicit flatten
+// before a dense layer are time major like Keras Flatten after Conv1D.
+type Model struct {
+	InputLength int          `json:"inputLength"`
+	Mean        []float64    `json:"mean"`
+	Std         []float64    `json:"std"`
+	Layers      []ModelLayer `json:"layers"`
+}
+
+// ModelLayer is one layer of a Model, the fields used depend on Type
+type ModelLayer struct {
+	Type       string          `json:"type"`       // conv1d, maxpool1d, globalavgpool1d, flatten or dense
+	Kernel     json.RawMessage `json:"kernel"`     // conv1d [size][in][filters], dense [inputs][units]
+	Bias       []float64       `json:"bias"`       // One per filter or unit
+	Stride     int             `json:"stride"`     // conv1d stride, 1 when zero
+	Size       int             `json:"size"`       // maxpool1d window, 2 when zero
+	Activation string          `json:"activation"` // relu, tanh, sigmoid, softmax or linear
+
+	conv  [][][]float64
+	dense [][]float64
+}
+
+// modelChannels is the number of input channels, the X, Y and Z accelerations
+const modelChannels = 3
+
+// tensor is a time major activation [step][channel], a flat vector has one step
+type tensor [][]float64
+
+// LoadModel reads and checks a weights file
+func LoadModel(path string) (*Model, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var model Model
+	if err := json.Unmarshal(raw, &model); err != nil {
+		return nil, fmt.Errorf("invalid model %s: %v", path, err)
+	}
+	if err := model.compile(); err != nil {
+		return nil, fmt.Errorf("invalid model %s: %v", path, err)
+	}
+	return &model, nil
+}
+
+// compile decodes the kernels and checks every layer against the shape of its input
+func (m *Model) compile() error {
+	if m.InputLength <= 0 {
+		return fmt.Errorf("inputLength must be positive")
+	}
+	if (m.Mean != nil && len(m.Mean) != modelChannels) || (m.Std != nil && len(m.Std) != modelChannels) {
+		return fmt.Errorf("mean and std need %d values", modelChannels)
+	}
+	if len(m.Layers) == 0 {
+		return fmt.Errorf("model has no layers")
+	}
+
+	steps, channels := m.InputLength, modelChannels
+	for i := range m.Layers {
+		layer := &m.Layers[i]
+		if !validActivation(layer.Activation) {
+			return fmt.Errorf("layer %d: unknown activation %q", i, layer.Activation)
+		}
+		switch layer.Type {
+		case "conv1d":
+			if err := json.Unmarshal(layer.Kernel, &layer.conv); err != nil {
+				return fmt.Errorf("layer %d: %v", i, err)
+			}
+			if layer.Stride <= 0 {
+				layer.Stride = 1
+			}
+			size := len(layer.conv)
+			if size == 0 || size > steps || len(layer.conv[0]) != channels || len(layer.conv[0][0]) == 0 {
+				return fmt.Errorf("layer %d: kernel does not match input of %d steps and %d channels", i, steps, channels)
+			}
+			filters := len(layer.conv[0][0])
+			for _, k := range layer.conv {
+				if len(k) != channels {
+					return fmt.Errorf("layer %d: ragged kernel", i)
+				}
+				for _, c := range k {
+					if len(c) != filters {
+						return fmt.Errorf("layer %d: ragged kernel", i)
+					}
+				}
+			}
+			if layer.Bias != nil && len(layer.Bias) != filters {
+				return fmt.Errorf("layer %d: bias needs %d values", i, filters)
+			}
+			steps, channels = (steps-size)/layer.Stride+1, filters
+		case "maxpool1d":
+			if layer.Size <= 0 {
+				layer.Size = 2
+			}
+			if layer.Size > steps {
+				return fmt.Errorf("layer %d: pool larger than input", i)
+			}
+			steps /= layer.Size
+		case "globalavgpool1d":
+			steps = 1
+		case "flatten":
+			steps, channels = 1, steps*channels
+		case "dense":
+			if err := json.Unmarshal(layer.Kernel, &layer.dense); err != nil {
+				return fmt.Errorf("layer %d: %v", i, err)
+			}
+			inputs := steps * channels
+			if len(layer.dense) != inputs || len(layer.dense[0]) == 0 {
+				return fmt.Errorf("layer %d: kernel needs %d rows", i, inputs)
+			}
+			units := len(layer.dense[0])
+			for _, row := range layer.dense {
+				if len(row) != units {
+					return fmt.Errorf("layer %d: ragged kernel", i)
+				}
+			}
+			if layer.Bias != nil && len(layer.Bias) != units {
+				return fmt.Errorf("layer %d: bias needs %d values", i, units)
+			}
+			steps, channels = 1, units
+		default:
+			return fmt.Errorf("layer %d: unknown type %q", i, layer.Type)
+		}
+		layer.Kernel = nil
+	}
+	if steps != 1 {
+		return fmt.Errorf("model output must be a vector")
+	}
+	return nil
+}
+
+// Predict runs the network on the last InputLength samples of the window
+func (m *Model) Predict(ctx context.Context, input Input) (*Result, error) {
+	if len(input.X) < m.InputLength || len(input.Y) < m.InputLength || len(input.Z) < m.InputLength {
+		return nil, fmt.Errorf("model needs %d samples per axis", m.InputLength)
+	}
+
+	x := make(tensor, m.InputLength)
+	for t := range x {
+		x[t] = make([]float64, modelChannels)
+		for c, axis := range [][]float32{input.X, input.Y, input.Z} {
+			value := float64(axis[len(axis)-m.InputLength+t])
+			if m.Mean != nil {
+				value -= m.Mean[c]
+			}
+			if m.Std != nil && m.Std[c] != 0 {
+				value /= m.Std[c]
+			}
+			x[t][c] = value
+		}
+	}
+
+	for i := range m.Layers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		x = m.Layers[i].forward(x)
+	}
+
+	output := x[0]
+	prediction := make([]float32, len(output))
+	class := 0
+	for i, p := range output {
+		prediction[i] = float32(p)
+		if p > output[class] {
+			class = i
+		}
+	}
+	return &Result{
+		Prediction:     [][]float32{prediction},
+		PredictedClass: []int{class},
+		Timestamp:      input.Timestamp,
+	}, nil
+}
+
+func (m *Model) Close() error {
+	return nil
+}
+
+// forward applies the layer to a compiled input shape
+func (l *ModelLayer) forward(x tensor) tensor {
+	var y tensor
+	switch l.Type {
+	case "conv1d":
+		size, filters := len(l.conv), len(l.conv[0][0])
+		y = make(tensor, (len(x)-size)/l.Stride+1)
+		for t := range y {
+			out := make([]float64, filters)
+			for f := range out {
+				if l.Bias != nil {
+					out[f] = l.Bias[f]
+				}
+			}
+			for k := 0; k < size; k++ {
+				step := x[t*l.Stride+k]
+				for c, value := range step {
+					for f, w := range l.conv[k][c] {
+						out[f] += value * w
+					}
+				}
+			}
+			y[t] = out
+		}
+	case "maxpool1d":
+		y = make(tensor, len(x)/l.Size)
+		for t := range y {
+			out := append([]float64(nil), x[t*l.Size]...)
+			for k := 1; k < l.Size; k++ {
+				for c, value := range x[t*l.Size+k] {
+					out[c] = math.Max(out[c], value)
+				}
+			}
+			y[t] = out
+		}
+	case "globalavgpool1d":
+		out := make([]float64, len(x[0]))
+		for _, step := range x {
+			for c, value := range step {
+				out[c] += value / float64(len(x))
+			}
+		}
+		y = tensor{out}
+	case "flatten":
+		y = tensor{flattenTensor(x)}
+	case "dense":
+		in := flattenTensor(x)
+		out := make([]float64, len(l.dense[0]))
+		if l.Bias != nil {
+			copy(out, l.Bias)
+		}
+		for i, value := range in {
+			for u, w := range l.dense[i] {
+				out[u] += value * w
+			}
+		}
+		y = tensor{out}
+	}
+	for _, step := range y {
+		activate(l.Activation, step)
+	}
+	return y
+}
+
+// flattenTensor joins the steps of x, time major
+func flattenTensor(x tensor) []float64 {
+	if len(x) == 1 {
+		return x[0]
+	}
+	flat := make([]float64, 0, len(x)*len(x[0]))
+	for _, step := range x {
+		flat = append(flat, step...)
+	}
+	return flat
+}
+
+func validActivation(name string) bool {
+	switch name {
+	case "", "linear", "relu", "tanh", "sigmoid", "softmax":
+		return true
+	}
+	return false
+}
+
+// activate applies the activation in place
+func activate(name string, values []float64) {
+	switch name {
+	case "relu":
+		for i, v := range values {
+			values[i] = math.Max(v, 0)
+		}
+	case "tanh":
+		for i, v := range values {
+			values[i] = math.Tanh(v)
+		}
+	case "sigmoid":
+		for i, v := range values {
+			values[i] = 1 / (1 + math.Exp(-v))
+		}
+	case "softmax":
+		max := math.Inf(-1)
+		for _, v := range values {
+			max = math.Max(max, v)
+		}
+		var sum float64
+		for i, v := range values {
+			values[i] = math.Exp(v - max)
+			sum += values[i]
+		}
+		for i := range values {
+			values[i] /= sum
+		}
+	}
+}
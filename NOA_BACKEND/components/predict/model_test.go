@@ -0,0 +1,128 @@
+package predict
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testModel normalises X by 2 and Z by its mean, then
+//
+//	conv1d: filter 0 is x[t]+x[t+1] of X, filter 1 is 1-x[t] of X, relu
+//	maxpool1d of 2, flatten
+//	dense:  logits [f0, 2*f1, 1-f0]
+const testModel = `{
+  "inputLength": 4,
+  "mean": [0, 0, 1], "std": [2, 1, 1],
+  "layers": [
+    {"type": "conv1d", "activation": "relu", "bias": [0, 1], "kernel": [
+      [[1, -1], [0, 0], [5, 5]],
+      [[1, 0], [0, 0], [0, 0]]
+    ]},
+    {"type": "maxpool1d", "size": 2},
+    {"type": "flatten"},
+    {"type": "dense", "activation": "ACTIVATION", "bias": [0, 0, 1], "kernel": [
+      [1, 0, -1],
+      [0, 2, 0]
+    ]}
+  ]
+}`
+
+func loadTestModel(t *testing.T, activation string) *Model {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := os.WriteFile(path, []byte(strings.Replace(testModel, "ACTIVATION", activation, 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	model, err := LoadModel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model
+}
+
+func TestModelLogits(t *testing.T) {
+	model := loadTestModel(t, "linear")
+
+	tests := []struct {
+		name   string
+		x      []float32
+		logits []float32
+		class  int
+	}{
+		// X/2 = 0.5 1 1.5 2, conv f0 = 1.5 2.5 3.5 and f1 = 0.5 0 0, pooled 2.5 0.5
+		{"rising", []float32{1, 2, 3, 4}, []float32{2.5, 1, -1.5}, 0},
+		// conv f0 = 0 and f1 = 1, pooled 0 1
+		{"still", []float32{0, 0, 0, 0}, []float32{0, 2, 1}, 1},
+		// Only the last inputLength samples are used
+		{"longer window", []float32{100, -100, 1, 2, 3, 4}, []float32{2.5, 1, -1.5}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := len(tt.x)
+			result, err := model.Predict(context.Background(), Input{
+				Timestamp: 42,
+				X:         tt.x,
+				Y:         make([]float32, n),
+				Z:         []float32{1, 1, 1, 1, 1, 1}[:n],
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, want := range tt.logits {
+				if got := result.Prediction[0][i]; math.Abs(float64(got-want)) > 1e-6 {
+					t.Fatalf("logits = %v, want %v", result.Prediction[0], tt.logits)
+				}
+			}
+			if result.PredictedClass[0] != tt.class || result.Timestamp != 42 {
+				t.Fatalf("class %v at %d, want %d", result.PredictedClass, result.Timestamp, tt.class)
+			}
+		})
+	}
+}
+
+func TestModelSoftmax(t *testing.T) {
+	model := loadTestModel(t, "softmax")
+	result, err := model.Predict(context.Background(), Input{X: []float32{1, 2, 3, 4}, Y: make([]float32, 4), Z: []float32{1, 1, 1, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logits := []float64{2.5, 1, -1.5}
+	var sum float64
+	for _, l := range logits {
+		sum += math.Exp(l)
+	}
+	for i, l := range logits {
+		if got := float64(result.Prediction[0][i]); math.Abs(got-math.Exp(l)/sum) > 1e-6 {
+			t.Fatalf("probabilities = %v", result.Prediction[0])
+		}
+	}
+	if result.PredictedClass[0] != 0 {
+		t.Fatalf("class = %v", result.PredictedClass)
+	}
+
+	if _, err := model.Predict(context.Background(), Input{X: []float32{1}, Y: []float32{1}, Z: []float32{1}}); err == nil {
+		t.Fatal("window shorter than inputLength accepted")
+	}
+}
+
+func TestModelCompileErrors(t *testing.T) {
+	tests := map[string]string{
+		"dense rows":   `{"inputLength": 4, "layers": [{"type": "dense", "kernel": [[1]]}]}`,
+		"conv channel": `{"inputLength": 4, "layers": [{"type": "conv1d", "kernel": [[[1], [1]]]}, {"type": "flatten"}]}`,
+		"not a vector": `{"inputLength": 4, "layers": [{"type": "maxpool1d"}]}`,
+		"activation":   `{"inputLength": 4, "layers": [{"type": "flatten", "activation": "gelu"}]}`,
+	}
+	for name, weights := range tests {
+		path := filepath.Join(t.TempDir(), "model.json")
+		if err := os.WriteFile(path, []byte(weights), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadModel(path); err == nil {
+			t.Errorf("%s: invalid model loaded", name)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package predict
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	env "GOLANG_SERVER/components/env"
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// Input is one sliding window of accelerations sent to the model
+type Input struct {
+	Timestamp int64     // Time of the window in unix milliseconds
+	X         []float32 // X acceleration samples, oldest first
+	Y         []float32 // Y acceleration samples, oldest first
+	Z         []float32 // Z acceleration samples, oldest first
+	// Machine the window was measured on, sent to the remote predictors so the service can
+	// account for the machine type, running speed and axis mapping. nil when unknown.
+	Profile *schema.DeviceProfile
+}
+
+// Result is the class probabilities of one window, same shape as the Python service response
+type Result struct {
+	Prediction     [][]float32 `json:"prediction"`
+	PredictedClass []int       `json:"predicted_class"`
+	Timestamp      int64       `json:"timestamp"`
+}
+
+// Predictor classifies sliding windows of vibration data
+type Predictor interface {
+	Predict(ctx context.Context, input Input) (*Result, error) // Classify one window
+	Close() error                                              // Release connections held by the predictor
+}
+
+// ErrNoPredictor is returned by Predict before a predictor is set
+var ErrNoPredictor = errors.New("no predictor configured")
+
+// predictor is the Predictor every package level function goes through
+var predictor Predictor
+
+// Use sets the Predictor used by Predict
+func Use(p Predictor) {
+	predictor = p
+}
+
+// Predict classifies a window with the configured Predictor
+func Predict(ctx context.Context, input Input) (*Result, error) {
+	if predictor == nil {
+		return nil, ErrNoPredictor
+	}
+	return predictor.Predict(ctx, input)
+}
+
+// Open selects the predictor from PREDICTOR: "remote" (default), "http", "model" or "fake"
+func Open() error {
+	kind := env.GetEnv("PREDICTOR")
+	var p Predictor
+	switch kind {
+	case "", "remote":
+		p = NewRemote(envOr("PREDICT_WS_URL", "ws://localhost:8080/ws/predict"), 4)
+	case "http":
+		p = NewHTTP(envOr("PREDICT_HTTP_URL", "http://localhost:8080/predict"))
+	case "model":
+		model, err := LoadModel(env.GetEnv("PREDICT_MODEL_PATH"))
+		if err != nil {
+			return err
+		}
+		p = model
+	case "fake":
+		p = &Fake{}
+	default:
+		return fmt.Errorf("unknown predictor %q", kind)
+	}
+	log.Println("Using predictor:", kind)
+	Use(p)
+	return nil
+}
+
+// flatten builds the model input row [timestamp, X..., Y..., Z...]
+func flatten(input Input) []float64 {
+	row := make([]float64, 0, 1+len(input.X)+len(input.Y)+len(input.Z))
+	row = append(row, float64(input.Timestamp))
+	for _, axis := range [][]float32{input.X, input.Y, input.Z} {
+		for _, v := range axis {
+			row = append(row, float64(v))
+		}
+	}
+	return row
+}
+
+// requestBody is the JSON sent to the remote predictors
+func requestBody(input Input) map[string]interface{} {
+	body := map[string]interface{}{"inputs": [][]float64{flatten(input)}}
+	if input.Profile != nil {
+		body["profile"] = input.Profile
+	}
+	return body
+}
+
+func envOr(key string, fallback string) string {
+	if value := env.GetEnv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,116 @@
+package predict
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// defaultRemoteTimeout bounds a round trip when the context has no deadline
+const defaultRemoteTimeout = 10 * time.Second
+
+// Remote sends windows to the Python WebSocket service over a pool of persistent connections.
+// A connection carries one request at a time, so the pool size is the number of predictions in flight.
+type Remote struct {
+	url   string
+	slots chan struct{}        // One per prediction in flight, further calls wait for a slot
+	pool  chan *websocket.Conn // Idle connections
+}
+
+// NewRemote creates a pool of at most size connections to url, connections are dialled on first use
+func NewRemote(url string, size int) *Remote {
+	if size < 1 {
+		size = 1
+	}
+	return &Remote{url: url, slots: make(chan struct{}, size), pool: make(chan *websocket.Conn, size)}
+}
+
+// acquire waits for a free slot until ctx is done, then takes an idle connection or dials a new one
+func (p *Remote) acquire(ctx context.Context) (*websocket.Conn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case conn := <-p.pool:
+		return conn, nil
+	default:
+	}
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, p.url, nil)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return conn, nil
+}
+
+// release puts a healthy connection back, or closes it when the pool is full, and frees its slot
+func (p *Remote) release(conn *websocket.Conn) {
+	select {
+	case p.pool <- conn:
+	default:
+		conn.Close()
+	}
+	<-p.slots
+}
+
+// discard closes a broken connection and frees its slot, the next call dials again
+func (p *Remote) discard(conn *websocket.Conn) {
+	conn.Close()
+	<-p.slots
+}
+
+func (p *Remote) Predict(ctx context.Context, input Input) (*Result, error) {
+	payload, err := json.Marshal(requestBody(input))
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultRemoteTimeout)
+	}
+	conn.SetWriteDeadline(deadline)
+	conn.SetReadDeadline(deadline)
+
+	if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+		p.discard(conn)
+		return nil, err
+	}
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		p.discard(conn)
+		return nil, err
+	}
+	p.release(conn)
+
+	var result Result
+	if err := json.Unmarshal(message, &result); err != nil {
+		return nil, err
+	}
+	if len(result.PredictedClass) == 0 {
+		return nil, errors.New("prediction service returned no class")
+	}
+	return &result, nil
+}
+
+// Close closes the idle connections of the pool
+func (p *Remote) Close() error {
+	for {
+		select {
+		case conn := <-p.pool:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package predict
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRemoteWaitsForSlot(t *testing.T) {
+	p := NewRemote("ws://127.0.0.1:1", 2)
+
+	// Both slots busy: the call waits and gives up with its context
+	p.slots <- struct{}{}
+	p.slots <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Predict(ctx, Input{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("predict with no free slot: %v", err)
+	}
+
+	// A freed slot lets the next call through, and failed calls give their slot back
+	<-p.slots
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := p.Predict(ctx, Input{})
+		cancel()
+		if err == nil {
+			t.Fatal("predict without a service succeeded")
+		}
+		if len(p.slots) != 1 {
+			t.Fatalf("call %d left %d slots taken, want 1", i, len(p.slots))
+		}
+	}
+}
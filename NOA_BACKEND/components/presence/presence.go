@@ -0,0 +1,223 @@
+package presence
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/env"
+	"GOLANG_SERVER/components/ingest"
+	"GOLANG_SERVER/components/schema"
+)
+
+const (
+	DefaultOfflineAfter = 2 * time.Minute  // Silence after which a device is offline when PRESENCE_OFFLINE_AFTER is not set
+	persistInterval     = 30 * time.Second // Last seen is written to the database at most this often per device
+)
+
+// Reasons of a presence change
+const (
+	ReasonTelemetry = "telemetry" // A message was ingested from an offline device
+	ReasonStatus    = "status"    // The device published on its status topic, or its last will did
+	ReasonTimeout   = "timeout"   // No message for the offline window
+)
+
+// Event is a change of the online status of a device, pushed to the WebSocket clients
+type Event struct {
+	Event    string    `json:"event"` // Always "presence"
+	UserID   string    `json:"userID"`
+	DeviceID string    `json:"deviceID"`
+	Online   bool      `json:"online"`
+	LastSeen time.Time `json:"lastSeen"`
+	Reason   string    `json:"reason"`
+}
+
+// state is the presence of one device kept in memory
+type state struct {
+	userID    string
+	online    bool
+	lastSeen  time.Time // Last message, updated on every message
+	persisted time.Time // Last seen date last written to the database
+}
+
+var (
+	devices = struct {
+		sync.Mutex
+		states map[string]*state // key: deviceID
+	}{states: make(map[string]*state)}
+	listeners = struct {
+		sync.RWMutex
+		list []func(Event)
+	}{}
+	offlineAfter = DefaultOfflineAfter
+)
+
+// OnChange registers fn to be called on every presence change
+func OnChange(fn func(Event)) {
+	listeners.Lock()
+	listeners.list = append(listeners.list, fn)
+	listeners.Unlock()
+}
+
+func emit(event Event) {
+	event.Event = "presence"
+	listeners.RLock()
+	defer listeners.RUnlock()
+	for _, fn := range listeners.list {
+		fn(event)
+	}
+}
+
+// Start tracks the last seen date of every ingested message and marks silent devices
+// offline after PRESENCE_OFFLINE_AFTER, a Go duration such as "2m"
+func Start() {
+	if value := env.GetEnv("PRESENCE_OFFLINE_AFTER"); value != "" {
+		window, err := time.ParseDuration(value)
+		if err != nil || window <= 0 {
+			log.Println("Invalid PRESENCE_OFFLINE_AFTER, using", DefaultOfflineAfter)
+		} else {
+			offlineAfter = window
+		}
+	}
+
+	// Any message keeps the device online, dropping some under load is harmless
+	ingest.Subscribe("presence", 1024, ingest.Drop, func(msg ingest.Message) {
+		Seen(msg.Data.UserID, msg.Data.DeviceID, msg.Received)
+	})
+	go sweep()
+}
+
+// getState returns the state of a device, the caller must hold the lock
+func getState(userID, deviceID string) *state {
+	s, ok := devices.states[deviceID]
+	if !ok {
+		s = &state{}
+		devices.states[deviceID] = s
+	}
+	s.userID = userID
+	return s
+}
+
+// Seen records a message from a device
+func Seen(userID, deviceID string, at time.Time) {
+	devices.Lock()
+	s := getState(userID, deviceID)
+	wasOnline := s.online
+	s.online = true
+	s.lastSeen = at
+	persist := !wasOnline || at.Sub(s.persisted) >= persistInterval
+	if persist {
+		s.persisted = at
+	}
+	devices.Unlock()
+
+	if persist {
+		if err := db.UpdatePresence(deviceID, true, at); err != nil {
+			log.Println("Error saving presence:", err)
+		}
+	}
+	if !wasOnline {
+		emit(Event{UserID: userID, DeviceID: deviceID, Online: true, LastSeen: at, Reason: ReasonTelemetry})
+	}
+}
+
+// SetStatus records an online or offline message of a device, including its last will
+func SetStatus(userID, deviceID string, online bool) {
+	now := time.Now()
+
+	devices.Lock()
+	s := getState(userID, deviceID)
+	changed := s.online != online
+	s.online = online
+	if online {
+		s.lastSeen = now
+		s.persisted = now
+	}
+	lastSeen := s.lastSeen
+	devices.Unlock()
+
+	if err := db.UpdatePresence(deviceID, online, lastSeen); err != nil {
+		log.Println("Error saving presence:", err)
+	}
+	if changed {
+		emit(Event{UserID: userID, DeviceID: deviceID, Online: online, LastSeen: lastSeen, Reason: ReasonStatus})
+	}
+}
+
+// sweep marks the devices silent for longer than the offline window offline
+func sweep() {
+	interval := offlineAfter / 4
+	if interval < 5*time.Second {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		expire(time.Now())
+	}
+}
+
+// expire marks offline every device not seen since now minus the offline window
+func expire(now time.Time) {
+	cutoff := now.Add(-offlineAfter)
+	var events []Event
+
+	devices.Lock()
+	for deviceID, s := range devices.states {
+		if s.online && s.lastSeen.Before(cutoff) {
+			s.online = false
+			events = append(events, Event{UserID: s.userID, DeviceID: deviceID, LastSeen: s.lastSeen, Reason: ReasonTimeout})
+		}
+	}
+	devices.Unlock()
+
+	// Devices left online in the database, for example by a restart of the server
+	stale, err := db.StaleOnlineDevices(cutoff)
+	if err != nil {
+		log.Println("Error finding stale devices:", err)
+	}
+	for _, device := range stale {
+		devices.Lock()
+		s, known := devices.states[device.DeviceID]
+		recent := known && s.online
+		devices.Unlock()
+		if recent || containsDevice(events, device.DeviceID) {
+			continue
+		}
+		events = append(events, Event{UserID: device.UserID, DeviceID: device.DeviceID, LastSeen: device.LastSeen, Reason: ReasonTimeout})
+	}
+
+	for _, event := range events {
+		if err := db.UpdatePresence(event.DeviceID, false, event.LastSeen); err != nil {
+			log.Println("Error saving presence:", err)
+		}
+		emit(event)
+	}
+}
+
+func containsDevice(events []Event, deviceID string) bool {
+	for _, event := range events {
+		if event.DeviceID == deviceID {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply overwrites the status and last seen date of the devices with what is known in memory,
+// which is more recent than the database between two writes
+func Apply(list []schema.GetDevice) {
+	devices.Lock()
+	defer devices.Unlock()
+
+	for i := range list {
+		if s, ok := devices.states[list[i].DeviceID]; ok {
+			list[i].Status = s.online
+			if s.lastSeen.After(list[i].LastSeen) {
+				list[i].LastSeen = s.lastSeen
+			}
+		}
+	}
+}
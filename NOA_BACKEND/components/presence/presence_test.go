@@ -0,0 +1,159 @@
+package presence
+
+import (
+	"testing"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/schema"
+)
+
+var t0 = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+// reset empties the presence and the store with deviceA of userA, and returns the events emitted from now on
+func reset(t *testing.T) *[]Event {
+	t.Helper()
+	db.UseStore(db.NewMemoryStore())
+	if err := db.SaveDevice("machine", "deviceA", "userA", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	devices.Lock()
+	devices.states = make(map[string]*state)
+	devices.Unlock()
+
+	events := &[]Event{}
+	listeners.Lock()
+	listeners.list = []func(Event){func(event Event) { *events = append(*events, event) }}
+	listeners.Unlock()
+	return events
+}
+
+// expect checks the emitted events by online status and reason, and clears them
+func expect(t *testing.T, events *[]Event, want ...Event) {
+	t.Helper()
+	if len(*events) != len(want) {
+		t.Fatalf("%d events, want %d: %+v", len(*events), len(want), *events)
+	}
+	for i, event := range *events {
+		if event.Event != "presence" || event.DeviceID != want[i].DeviceID || event.Online != want[i].Online || event.Reason != want[i].Reason {
+			t.Fatalf("event %d is %+v, want %+v", i, event, want[i])
+		}
+	}
+	*events = (*events)[:0]
+}
+
+// stored returns the presence of deviceA saved in the store
+func stored(t *testing.T) (bool, time.Time) {
+	t.Helper()
+	device, err := db.FindDevice("deviceA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return device.Status, device.LastSeen
+}
+
+func TestTelemetryOnlineAndTimeout(t *testing.T) {
+	events := reset(t)
+
+	// The first message brings the device online, the next ones change nothing
+	Seen("userA", "deviceA", t0)
+	expect(t, events, Event{DeviceID: "deviceA", Online: true, Reason: ReasonTelemetry})
+	Seen("userA", "deviceA", t0.Add(10*time.Second))
+	expect(t, events)
+
+	// Last seen is written at most every persistInterval
+	if online, lastSeen := stored(t); !online || !lastSeen.Equal(t0) {
+		t.Fatalf("stored after 10s: %v %v", online, lastSeen)
+	}
+	Seen("userA", "deviceA", t0.Add(persistInterval))
+	if _, lastSeen := stored(t); !lastSeen.Equal(t0.Add(persistInterval)) {
+		t.Fatalf("stored after %v: %v", persistInterval, lastSeen)
+	}
+
+	// Silent for the whole window is offline, with the last message as last seen
+	last := t0.Add(40 * time.Second)
+	Seen("userA", "deviceA", last)
+	expire(last.Add(offlineAfter))
+	expect(t, events)
+	expire(last.Add(offlineAfter + time.Second))
+	expect(t, events, Event{DeviceID: "deviceA", Online: false, Reason: ReasonTimeout})
+	if online, lastSeen := stored(t); online || !lastSeen.Equal(last) {
+		t.Fatalf("stored after the timeout: %v %v", online, lastSeen)
+	}
+	expire(last.Add(2 * offlineAfter))
+	expect(t, events)
+
+	// A message after the timeout brings it back
+	Seen("userA", "deviceA", last.Add(time.Hour))
+	expect(t, events, Event{DeviceID: "deviceA", Online: true, Reason: ReasonTelemetry})
+}
+
+func TestStatusAndLastWill(t *testing.T) {
+	events := reset(t)
+
+	SetStatus("userA", "deviceA", true)
+	expect(t, events, Event{DeviceID: "deviceA", Online: true, Reason: ReasonStatus})
+	online, seen := stored(t)
+	if !online || seen.IsZero() {
+		t.Fatalf("stored after online: %v %v", online, seen)
+	}
+	SetStatus("userA", "deviceA", true)
+	expect(t, events)
+
+	// The last will is an offline status, it keeps the last seen date of the device
+	SetStatus("userA", "deviceA", false)
+	expect(t, events, Event{DeviceID: "deviceA", Online: false, Reason: ReasonStatus})
+	if online, lastSeen := stored(t); online || lastSeen.Before(seen) {
+		t.Fatalf("stored after the last will: %v %v", online, lastSeen)
+	}
+	SetStatus("userA", "deviceA", false)
+	expect(t, events)
+
+	// Telemetry after the last will is online again, and the timeout skips a device already offline
+	Seen("userA", "deviceA", time.Now())
+	expect(t, events, Event{DeviceID: "deviceA", Online: true, Reason: ReasonTelemetry})
+	SetStatus("userA", "deviceA", false)
+	expect(t, events, Event{DeviceID: "deviceA", Online: false, Reason: ReasonStatus})
+	expire(time.Now().Add(2 * offlineAfter))
+	expect(t, events)
+}
+
+func TestTimeoutOfDeviceLeftOnline(t *testing.T) {
+	events := reset(t)
+
+	// Online in the database from before a restart, unknown in memory
+	if err := db.UpdatePresence("deviceA", true, t0); err != nil {
+		t.Fatal(err)
+	}
+	expire(t0.Add(offlineAfter / 2))
+	expect(t, events)
+	expire(t0.Add(offlineAfter + time.Second))
+	expect(t, events, Event{DeviceID: "deviceA", Online: false, Reason: ReasonTimeout})
+	if online, _ := stored(t); online {
+		t.Fatal("device left online after the timeout")
+	}
+}
+
+func TestApply(t *testing.T) {
+	reset(t)
+	Seen("userA", "deviceA", t0)
+
+	list := []schema.GetDevice{
+		{DeviceID: "deviceA", LastSeen: t0.Add(-time.Minute)},
+		{DeviceID: "deviceB", Status: true, LastSeen: t0.Add(-time.Hour)},
+	}
+	Apply(list)
+	if !list[0].Status || !list[0].LastSeen.Equal(t0) {
+		t.Fatalf("known device: %+v", list[0])
+	}
+	if !list[1].Status || !list[1].LastSeen.Equal(t0.Add(-time.Hour)) {
+		t.Fatalf("unknown device changed: %+v", list[1])
+	}
+
+	// A later date from the database is kept
+	list[0].LastSeen = t0.Add(time.Minute)
+	Apply(list)
+	if !list[0].LastSeen.Equal(t0.Add(time.Minute)) {
+		t.Fatalf("later database date replaced: %v", list[0].LastSeen)
+	}
+}
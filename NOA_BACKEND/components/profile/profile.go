@@ -0,0 +1,110 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// Mounting orientations of a device
+const (
+	Horizontal = "horizontal"
+	Vertical   = "vertical"
+	Inclined   = "inclined"
+)
+
+// Axial is the direction of the shaft a sensor axis can be mapped to, with the radial Horizontal and Vertical
+const Axial = "axial"
+
+// MaxTags is the number of tags a device can have
+const MaxTags = 20
+
+// Validate checks a profile and normalises it: the mounting, the axis mapping and the tags are lowercase,
+// the tags are trimmed, sorted and without duplicates
+func Validate(p *schema.DeviceProfile) error {
+	p.MachineType = strings.ToLower(strings.TrimSpace(p.MachineType))
+	p.BearingModel = strings.TrimSpace(p.BearingModel)
+	p.Location = strings.TrimSpace(p.Location)
+
+	if p.RatedRPM < 0 {
+		return errors.New("ratedRPM cannot be negative")
+	}
+	if p.SampleRate < 0 {
+		return errors.New("sampleRate cannot be negative")
+	}
+
+	p.Mounting = strings.ToLower(strings.TrimSpace(p.Mounting))
+	switch p.Mounting {
+	case "", Horizontal, Vertical, Inclined:
+	default:
+		return errors.New("mounting must be horizontal, vertical or inclined")
+	}
+
+	axes := []*string{&p.AxisMapping.X, &p.AxisMapping.Y, &p.AxisMapping.Z}
+	seen := make(map[string]bool)
+	for _, axis := range axes {
+		*axis = strings.ToLower(strings.TrimSpace(*axis))
+		switch *axis {
+		case "":
+			continue
+		case Axial, Horizontal, Vertical:
+		default:
+			return errors.New("axisMapping must map X, Y and Z to axial, horizontal or vertical")
+		}
+		if seen[*axis] {
+			return errors.New("axisMapping cannot map two axes to the same direction")
+		}
+		seen[*axis] = true
+	}
+
+	p.Tags = ParseTags(p.Tags...)
+	if len(p.Tags) > MaxTags {
+		return fmt.Errorf("a device can have at most %d tags", MaxTags)
+	}
+	return nil
+}
+
+// ParseTags normalises tags, each value may hold several tags separated by commas
+func ParseTags(values ...string) []string {
+	tags := []string{}
+	seen := make(map[string]bool)
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag != "" && !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// HasTags reports whether the profile has every tag, the tags must be normalised by ParseTags
+func HasTags(p schema.DeviceProfile, tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(p.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterTags keeps the devices having every tag
+func FilterTags(devices []schema.GetDevice, tags []string) []schema.GetDevice {
+	if len(tags) == 0 {
+		return devices
+	}
+	kept := []schema.GetDevice{}
+	for _, device := range devices {
+		if HasTags(device.Profile, tags) {
+			kept = append(kept, device)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,117 @@
+package profile
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	schema "GOLANG_SERVER/components/schema"
+)
+
+func TestValidateNormalises(t *testing.T) {
+	p := schema.DeviceProfile{
+		MachineType:  " Motor ",
+		BearingModel: " SKF 6205 ",
+		Location:     " hall 2 ",
+		Mounting:     " Vertical",
+		AxisMapping:  schema.AxisMapping{X: "Axial ", Y: "", Z: " VERTICAL"},
+		Tags:         []string{"Line 1, critical", "critical", " ", "pump"},
+	}
+	if err := Validate(&p); err != nil {
+		t.Fatal(err)
+	}
+	want := schema.DeviceProfile{
+		MachineType:  "motor",
+		BearingModel: "SKF 6205",
+		Location:     "hall 2",
+		Mounting:     Vertical,
+		AxisMapping:  schema.AxisMapping{X: Axial, Z: Vertical},
+		Tags:         []string{"critical", "line 1", "pump"},
+	}
+	if p.MachineType != want.MachineType || p.BearingModel != want.BearingModel || p.Location != want.Location ||
+		p.Mounting != want.Mounting || p.AxisMapping != want.AxisMapping || !slices.Equal(p.Tags, want.Tags) {
+		t.Fatalf("normalised to %+v, want %+v", p, want)
+	}
+
+	// An empty profile is valid and has no tags rather than nil ones
+	empty := schema.DeviceProfile{}
+	if err := Validate(&empty); err != nil || empty.Tags == nil || len(empty.Tags) != 0 {
+		t.Fatalf("empty profile: %+v %v", empty, err)
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	tooMany := make([]string, MaxTags+1)
+	for i := range tooMany {
+		tooMany[i] = strings.Repeat("t", i+1)
+	}
+
+	tests := []struct {
+		name    string
+		profile schema.DeviceProfile
+		want    string
+	}{
+		{"negative rpm", schema.DeviceProfile{RatedRPM: -1}, "ratedRPM"},
+		{"negative sample rate", schema.DeviceProfile{SampleRate: -1}, "sampleRate"},
+		{"unknown mounting", schema.DeviceProfile{Mounting: "upside down"}, "mounting"},
+		{"unknown direction", schema.DeviceProfile{AxisMapping: schema.AxisMapping{Y: "radial"}}, "axisMapping must map"},
+		{"same direction twice", schema.DeviceProfile{AxisMapping: schema.AxisMapping{X: "axial", Z: " Axial"}}, "same direction"},
+		{"too many tags", schema.DeviceProfile{Tags: tooMany}, "at most"},
+	}
+	for _, tt := range tests {
+		err := Validate(&tt.profile)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: %v, want an error about %s", tt.name, err, tt.want)
+		}
+	}
+
+	// Duplicates count once towards MaxTags
+	same := schema.DeviceProfile{Tags: slices.Repeat([]string{"Pump, pump"}, MaxTags+1)}
+	if err := Validate(&same); err != nil || !slices.Equal(same.Tags, []string{"pump"}) {
+		t.Fatalf("repeated tag: %v %v", same.Tags, err)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   []string
+	}{
+		{nil, []string{}},
+		{[]string{"", " , ,"}, []string{}},
+		{[]string{"pump"}, []string{"pump"}},
+		{[]string{"Pump,line 1", "critical"}, []string{"critical", "line 1", "pump"}},
+		{[]string{" PUMP ", "pump,Pump"}, []string{"pump"}},
+	}
+	for _, tt := range tests {
+		if got := ParseTags(tt.values...); !slices.Equal(got, tt.want) || got == nil {
+			t.Errorf("ParseTags(%q) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestFilterTags(t *testing.T) {
+	devices := []schema.GetDevice{
+		{DeviceID: "pump", Profile: schema.DeviceProfile{Tags: []string{"critical", "pump"}}},
+		{DeviceID: "fan", Profile: schema.DeviceProfile{Tags: []string{"fan"}}},
+		{DeviceID: "untagged"},
+	}
+	ids := func(tags ...string) []string {
+		var ids []string
+		for _, device := range FilterTags(devices, ParseTags(tags...)) {
+			ids = append(ids, device.DeviceID)
+		}
+		return ids
+	}
+
+	if got := ids(); !slices.Equal(got, []string{"pump", "fan", "untagged"}) {
+		t.Fatalf("no tags: %v", got)
+	}
+	if got := ids("Critical"); !slices.Equal(got, []string{"pump"}) {
+		t.Fatalf("critical: %v", got)
+	}
+	// Every tag must match
+	if got := ids("critical,fan"); len(got) != 0 {
+		t.Fatalf("critical and fan: %v", got)
+	}
+}
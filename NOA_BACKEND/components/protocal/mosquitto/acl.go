@@ -0,0 +1,111 @@
+package mosquitto
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/ingest"
+	schema "GOLANG_SERVER/components/schema"
+	"GOLANG_SERVER/components/severity"
+)
+
+// aclTTL is how long the owner, machine class and profile of a device are cached, a deleted device can
+// publish for at most this long
+const aclTTL = 30 * time.Second
+
+var errTopicDenied = errors.New("topic does not match the device record")
+
+// aclEntry is the cached owner, machine class and profile of a device
+type aclEntry struct {
+	userID  string
+	class   schema.MachineClass
+	profile schema.DeviceProfile
+	expires time.Time
+}
+
+var aclCache = struct {
+	sync.Mutex
+	owners map[string]aclEntry // key: deviceID
+}{owners: make(map[string]aclEntry)}
+
+// deviceEntry returns the cached record of a device, its userID is "" when the device is not registered
+func deviceEntry(deviceID string) (aclEntry, error) {
+	aclCache.Lock()
+	entry, ok := aclCache.owners[deviceID]
+	aclCache.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry, nil
+	}
+
+	device, err := db.FindDevice(deviceID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return aclEntry{}, err
+	}
+	entry = aclEntry{expires: time.Now().Add(aclTTL)}
+	if device != nil {
+		entry.userID = device.UserID
+		entry.class = device.MachineClass
+		entry.profile = device.Profile
+	}
+
+	aclCache.Lock()
+	aclCache.owners[deviceID] = entry
+	aclCache.Unlock()
+	return entry, nil
+}
+
+// CanPublish checks that the device is registered to the user
+func CanPublish(userID, deviceID string) (bool, error) {
+	entry, err := deviceEntry(deviceID)
+	if err != nil {
+		return false, err
+	}
+	return entry.userID != "" && entry.userID == userID, nil
+}
+
+// ForgetDevice drops the cached record of a device so a change to it applies to the next message
+func ForgetDevice(deviceID string) {
+	aclCache.Lock()
+	delete(aclCache.owners, deviceID)
+	aclCache.Unlock()
+}
+
+// authorizeTelemetry is the ingest bus authorizer. A message on noa/{userID}/{deviceID}/telemetry
+// is accepted when the device record belongs to userID and the payload does not claim another
+// device. Legacy messages on vibration are bridged to the device topic named by their payload.
+// Accepted messages get their ISO 10816-3 zone and the profile of the device here, once for every subscriber.
+func authorizeTelemetry(msg *ingest.Message) error {
+	data := &msg.Data
+
+	if msg.Topic == LegacyTelemetryTopic {
+		if data.UserID == "" || data.DeviceID == "" {
+			return errors.New("userID and deviceID are required")
+		}
+		msg.Topic = TelemetryTopic(data.UserID, data.DeviceID)
+	}
+
+	userID, deviceID, kind, ok := ParseTopic(msg.Topic)
+	if !ok || kind != KindTelemetry {
+		return errTopicDenied
+	}
+	if (data.UserID != "" && data.UserID != userID) || (data.DeviceID != "" && data.DeviceID != deviceID) {
+		return errTopicDenied
+	}
+
+	entry, err := deviceEntry(deviceID)
+	if err != nil {
+		return err
+	}
+	if entry.userID == "" || entry.userID != userID {
+		return errTopicDenied
+	}
+
+	// The topic is the identity of the message
+	data.UserID = userID
+	data.DeviceID = deviceID
+	severity.Classify(data, entry.class, entry.profile)
+	msg.Profile = &entry.profile
+	return nil
+}
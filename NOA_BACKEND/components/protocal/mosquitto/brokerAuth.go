@@ -0,0 +1,68 @@
+package mosquitto
+
+import (
+	"crypto/subtle"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/env"
+)
+
+// Access asked by an ACL check, the values mosquitto-go-auth sends as acc
+const (
+	AccessRead      = 1
+	AccessWrite     = 2
+	AccessReadWrite = 3
+	AccessSubscribe = 4
+)
+
+// IsSuperuser reports whether the username is the account of this server, MQTT_USERNAME
+func IsSuperuser(username string) bool {
+	server := env.GetEnv("MQTT_USERNAME")
+	return server != "" && username == server
+}
+
+// AuthenticateClient checks the credentials a client connects to the broker with. Devices connect
+// with their deviceID and device password, this server with MQTT_USERNAME and MQTT_PASSWORD.
+func AuthenticateClient(username, password string) (bool, error) {
+	if username == "" || password == "" {
+		return false, nil
+	}
+	if IsSuperuser(username) {
+		return subtle.ConstantTimeCompare([]byte(password), []byte(env.GetEnv("MQTT_PASSWORD"))) == 1, nil
+	}
+
+	_, err := db.CheckDeviceCredentials(username, password)
+	if err == db.ErrDeviceCredentials {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CanAccess reports whether the client may use the topic. A device may publish its telemetry,
+// status and acks and read its commands, and nothing of another device.
+func CanAccess(username, topic string, access int) (bool, error) {
+	if IsSuperuser(username) {
+		return true, nil
+	}
+
+	userID, deviceID, kind, ok := ParseTopic(topic)
+	if !ok || deviceID != username {
+		return false, nil
+	}
+	entry, err := deviceEntry(deviceID)
+	if err != nil {
+		return false, err
+	}
+	if entry.userID == "" || entry.userID != userID {
+		return false, nil
+	}
+
+	switch access {
+	case AccessWrite:
+		return kind == KindTelemetry || kind == KindStatus || kind == KindAck, nil
+	case AccessRead, AccessSubscribe:
+		return kind == KindCommand, nil
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,90 @@
+package mosquitto
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	schema "GOLANG_SERVER/components/schema"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// commandExpiryInterval is how often pending commands past their expiry are marked expired
+const commandExpiryInterval = 30 * time.Second
+
+// CommandPayload is the message published on noa/{userID}/{deviceID}/cmd
+type CommandPayload struct {
+	CommandID string                 `json:"commandID"`
+	Type      string                 `json:"type"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+	ExpireAt  int64                  `json:"expireAt"` // Unix milliseconds, the device ignores the command after it
+}
+
+// AckPayload is the message a device publishes on noa/{userID}/{deviceID}/ack
+type AckPayload struct {
+	CommandID string `json:"commandID"`
+	Status    string `json:"status"`          // acked or failed
+	Error     string `json:"error,omitempty"` // Reason when failed
+}
+
+// SendCommand publishes a stored command on the cmd topic of its device
+func SendCommand(command schema.Command) error {
+	if client == nil || !client.IsConnected() {
+		return errors.New("MQTT client is not connected")
+	}
+
+	payload, err := json.Marshal(CommandPayload{
+		CommandID: command.ID,
+		Type:      command.Type,
+		Params:    command.Params,
+		ExpireAt:  command.ExpireAt.UnixMilli(),
+	})
+	if err != nil {
+		return err
+	}
+
+	token := client.Publish(CommandTopic(command.UserID, command.DeviceID), 1, false, payload)
+	if !token.WaitTimeout(10 * time.Second) {
+		return errors.New("timeout publishing command")
+	}
+	return token.Error()
+}
+
+// handleAck correlates the ack of a device with its pending command
+func handleAck(client mqtt.Client, msg mqtt.Message) {
+	userID, deviceID, kind, ok := ParseTopic(msg.Topic())
+	if !ok || kind != KindAck {
+		return
+	}
+	if allowed, err := CanPublish(userID, deviceID); err != nil || !allowed {
+		log.Println("Rejected ack on", msg.Topic())
+		return
+	}
+
+	var ack AckPayload
+	if err := json.Unmarshal(msg.Payload(), &ack); err != nil || ack.CommandID == "" {
+		log.Println("Invalid ack on", msg.Topic())
+		return
+	}
+
+	if err := db.AckCommand(deviceID, ack.CommandID, ack.Status, ack.Error); err != nil {
+		log.Printf("Ack of command %s not recorded: %v\n", ack.CommandID, err)
+	}
+}
+
+// expireCommands marks unacknowledged commands expired until the server stops
+func expireCommands() {
+	ticker := time.NewTicker(commandExpiryInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if n, err := db.ExpireCommands(); err != nil {
+			log.Println("Error expiring commands:", err)
+		} else if n > 0 {
+			log.Printf("Expired %d commands\n", n)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package mosquitto
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"GOLANG_SERVER/components/presence"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// handleStatus records the online or offline message a device publishes on its status topic,
+// offline is also published by the broker as the last will of the device
+func handleStatus(client mqtt.Client, msg mqtt.Message) {
+	userID, deviceID, kind, ok := ParseTopic(msg.Topic())
+	if !ok || kind != KindStatus {
+		return
+	}
+	if allowed, err := CanPublish(userID, deviceID); err != nil || !allowed {
+		log.Println("Rejected status on", msg.Topic())
+		return
+	}
+
+	// Either "online" / "offline" or {"status": "online"}
+	status := strings.TrimSpace(string(msg.Payload()))
+	var payload struct {
+		Status string `json:"status"`
+	}
+	if json.Unmarshal(msg.Payload(), &payload) == nil && payload.Status != "" {
+		status = payload.Status
+	}
+
+	switch status {
+	case "online":
+		presence.SetStatus(userID, deviceID, true)
+	case "offline":
+		presence.SetStatus(userID, deviceID, false)
+	default:
+		log.Printf("Invalid status %q on %s\n", status, msg.Topic())
+	}
+}
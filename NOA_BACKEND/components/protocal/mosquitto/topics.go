@@ -0,0 +1,65 @@
+package mosquitto
+
+import "strings"
+
+// Devices publish and subscribe under noa/{userID}/{deviceID}/{kind}
+const (
+	TopicPrefix = "noa"
+
+	KindTelemetry = "telemetry" // Device -> server, schema.GyroData
+	KindStatus    = "status"    // Device -> server, online/offline, retained
+	KindCommand   = "cmd"       // Server -> device, schema.Command
+	KindAck       = "ack"       // Device -> server, result of a command
+
+	// LegacyTelemetryTopic is the flat topic used before per-device topics, bridged while devices migrate
+	LegacyTelemetryTopic = "vibration"
+)
+
+// DeviceTopic returns the topic of one kind of message of a device
+func DeviceTopic(userID, deviceID, kind string) string {
+	return TopicPrefix + "/" + userID + "/" + deviceID + "/" + kind
+}
+
+// TelemetryTopic returns the topic a device publishes its vibration data to
+func TelemetryTopic(userID, deviceID string) string {
+	return DeviceTopic(userID, deviceID, KindTelemetry)
+}
+
+// StatusTopic returns the topic a device publishes its online status to
+func StatusTopic(userID, deviceID string) string {
+	return DeviceTopic(userID, deviceID, KindStatus)
+}
+
+// CommandTopic returns the topic a device receives commands on
+func CommandTopic(userID, deviceID string) string {
+	return DeviceTopic(userID, deviceID, KindCommand)
+}
+
+// AckTopic returns the topic a device acknowledges commands on
+func AckTopic(userID, deviceID string) string {
+	return DeviceTopic(userID, deviceID, KindAck)
+}
+
+// Topics returns the topics of a device by kind, as given to the device when it pairs or authenticates
+func Topics(userID, deviceID string) map[string]string {
+	return map[string]string{
+		KindTelemetry: TelemetryTopic(userID, deviceID),
+		KindStatus:    StatusTopic(userID, deviceID),
+		KindCommand:   CommandTopic(userID, deviceID),
+		KindAck:       AckTopic(userID, deviceID),
+	}
+}
+
+// subscription returns the wildcard topic matching one kind of message of every device
+func subscription(kind string) string {
+	return TopicPrefix + "/+/+/" + kind
+}
+
+// ParseTopic splits a device topic into its parts, ok is false for any other topic
+func ParseTopic(topic string) (userID string, deviceID string, kind string, ok bool) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 4 || parts[0] != TopicPrefix || parts[1] == "" || parts[2] == "" || parts[3] == "" {
+		return "", "", "", false
+	}
+	return parts[1], parts[2], parts[3], true
+}
@@ -0,0 +1,214 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	schema "GOLANG_SERVER/components/schema"
+	"GOLANG_SERVER/components/sensitive"
+	"GOLANG_SERVER/components/user"
+)
+
+// tenants is user A with a device and telemetry, and user B who has no role on it
+type tenants struct {
+	tokenA, tokenB string
+	deviceID       string
+	mux            *http.ServeMux
+}
+
+// newTenants opens a memory store like DB_STORE=memory and routes the handlers like main.go
+func newTenants(t *testing.T) *tenants {
+	t.Helper()
+	os.Setenv("DB_STORE", "memory")
+	t.Cleanup(func() { os.Unsetenv("DB_STORE") })
+	if _, err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	store := db.GetStore()
+
+	for _, u := range []schema.User{
+		{ID: "userA", Username: "a", Email: "a@example.com"},
+		{ID: "userB", Username: "b", Email: "b@example.com"},
+	} {
+		if err := store.InsertUser(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.SaveDevice("machine", "deviceA", "userA", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UnixMilli()
+	for i := int64(0); i < 3; i++ {
+		if err := store.InsertGyroData(schema.GyroData{DeviceID: "deviceA", UserID: "userA", TimeStamp: now - i*1000}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts := &tenants{deviceID: "deviceA", mux: http.NewServeMux()}
+	for _, u := range []struct {
+		user  schema.User
+		token *string
+	}{
+		{schema.User{ID: "userA", Username: "a"}, &ts.tokenA},
+		{schema.User{ID: "userB", Username: "b"}, &ts.tokenB},
+	} {
+		tokens, err := sensitive.StartSession(u.user, "test", "127.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		*u.token = tokens.AccessToken
+	}
+
+	protect := func(h http.HandlerFunc) http.Handler { return sensitive.AuthMiddleware(h) }
+	ts.mux.Handle("/device/", protect(HandleDeviceRoute))
+	ts.mux.Handle("/device/deleteDevice", protect(HandleDeleteDevice))
+	ts.mux.Handle("/device/changeBookmark", protect(ChangeBookmark))
+	ts.mux.Handle("/device/checkdeviceaddresses/", protect(HandleGetDeviceAddressByDeviceAddress))
+	ts.mux.Handle("/downloaddata", protect(HandleDownloadData))
+	ts.mux.Handle("/userID", protect(user.GetUserByUserID))
+	return ts
+}
+
+func (ts *tenants) do(t *testing.T, token, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	ts.mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCrossTenantAccessDenied(t *testing.T) {
+	ts := newTenants(t)
+
+	tests := []struct {
+		name, method, path, body string
+		want                     int
+	}{
+		{"telemetry", http.MethodGet, "/device/deviceA/telemetry", "", http.StatusNotFound},
+		{"aggregate", http.MethodGet, "/device/deviceA/telemetry/aggregate?interval=1m", "", http.StatusNotFound},
+		{"export", http.MethodGet, "/device/deviceA/telemetry/export", "", http.StatusNotFound},
+		{"list commands", http.MethodGet, "/device/deviceA/commands", "", http.StatusNotFound},
+		{"send command", http.MethodPost, "/device/deviceA/commands", `{"type":"reboot"}`, http.StatusNotFound},
+		{"list shares", http.MethodGet, "/device/deviceA/shares", "", http.StatusNotFound},
+		{"invite", http.MethodPost, "/device/deviceA/shares", `{"email":"b@example.com","role":"operator"}`, http.StatusNotFound},
+		{"transfer", http.MethodPost, "/device/deviceA/transfer", `{"email":"b@example.com"}`, http.StatusNotFound},
+		{"read profile", http.MethodGet, "/device/deviceA/profile", "", http.StatusNotFound},
+		{"patch profile", http.MethodPatch, "/device/deviceA/profile", `{"machineType":"pump"}`, http.StatusNotFound},
+		{"delete", http.MethodDelete, "/device/deleteDevice", `{"deviceID":"deviceA"}`, http.StatusNotFound},
+		{"delete as A", http.MethodDelete, "/device/deleteDevice", `{"deviceID":"deviceA","userID":"userA"}`, http.StatusForbidden},
+		{"check address", http.MethodGet, "/device/checkdeviceaddresses/deviceA", "", http.StatusNotFound},
+		{"bookmark", http.MethodPut, "/device/changeBookmark", `{"deviceID":"deviceA","bookmark":true}`, http.StatusNotFound},
+		{"download", http.MethodGet, "/downloaddata?deviceID=deviceA", "", http.StatusNotFound},
+		{"download as A", http.MethodGet, "/downloaddata?deviceID=deviceA&userID=userA", "", http.StatusForbidden},
+		{"user", http.MethodPost, "/userID", `{"userID":"userA"}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ts.do(t, ts.tokenB, tt.method, tt.path, tt.body)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			if strings.Contains(rec.Body.String(), "a@example.com") {
+				t.Fatalf("response leaks user A: %s", rec.Body.String())
+			}
+		})
+	}
+
+	// Nothing of user A changed
+	device, err := db.FindDevice("deviceA")
+	if err != nil {
+		t.Fatal("device of user A deleted:", err)
+	}
+	if device.UserID != "userA" || device.Bookmark || device.Profile.MachineType != "" {
+		t.Fatalf("device of user A changed: %+v", device)
+	}
+	data, _ := db.GetStore().GyroDataByDevice("deviceA")
+	if len(data) != 3 {
+		t.Fatalf("telemetry of user A has %d rows, want 3", len(data))
+	}
+	commands, _ := db.GetStore().CommandsByDevice("deviceA", 0)
+	shares, _ := db.DeviceShares("deviceA")
+	if len(commands) != 0 || len(shares) != 0 {
+		t.Fatalf("commands %v or shares %v added to the device of user A", commands, shares)
+	}
+}
+
+func TestOwnerAccessAllowed(t *testing.T) {
+	ts := newTenants(t)
+
+	// The same routes answer user A, so the denials above are about the tenant
+	for _, path := range []string{"/device/deviceA/telemetry", "/device/deviceA/profile", "/downloaddata?deviceID=deviceA"} {
+		if rec := ts.do(t, ts.tokenA, http.MethodGet, path, ""); rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200: %s", path, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := ts.do(t, ts.tokenA, http.MethodGet, "/device/checkdeviceaddresses/deviceA", ""); rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `["deviceA"]` {
+		t.Fatalf("check address: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	// An unknown device answers like the device of another tenant
+	if rec := ts.do(t, ts.tokenA, http.MethodGet, "/device/checkdeviceaddresses/unknown", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown address: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := ts.do(t, ts.tokenA, http.MethodPost, "/userID", `{}`); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "a@example.com") {
+		t.Fatalf("/userID: status = %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestOnlyOwnerSendsConfig(t *testing.T) {
+	ts := newTenants(t)
+	share := schema.DeviceShare{ID: "share", DeviceID: "deviceA", OwnerID: "userA", UserID: "userB", Role: db.RoleOperator, Status: db.ShareAccepted}
+	if err := db.GetStore().InsertShare(share); err != nil {
+		t.Fatal(err)
+	}
+
+	// An operator cannot point the device at another broker
+	if rec := ts.do(t, ts.tokenB, http.MethodPost, "/device/deviceA/commands", `{"type":"config","params":{"MQTT_SERVER":"evil.example.com"}}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("config as operator: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	// The owner can only push the keys of the config file
+	if rec := ts.do(t, ts.tokenA, http.MethodPost, "/device/deviceA/commands", `{"type":"config","params":{"DEVICE_ID":"deviceB"}}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown config key: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if commands, _ := db.GetStore().CommandsByDevice("deviceA", 0); len(commands) != 0 {
+		t.Fatalf("refused config stored: %v", commands)
+	}
+
+	// Both reach the device otherwise, it fails with no broker in the tests
+	for _, tt := range []struct{ token, body string }{
+		{ts.tokenB, `{"type":"reboot"}`},
+		{ts.tokenA, `{"type":"config","params":{"MQTT_SERVER":"broker.example.com"}}`},
+	} {
+		if rec := ts.do(t, tt.token, http.MethodPost, "/device/deviceA/commands", tt.body); rec.Code == http.StatusForbidden || rec.Code == http.StatusBadRequest {
+			t.Fatalf("%s: status = %d: %s", tt.body, rec.Code, rec.Body.String())
+		}
+	}
+	if commands, _ := db.GetStore().CommandsByDevice("deviceA", 0); len(commands) != 2 {
+		t.Fatalf("%d commands stored, want 2", len(commands))
+	}
+}
+
+func TestIngestStatsAdminOnly(t *testing.T) {
+	ts := newTenants(t)
+	ts.mux.Handle("/ingest/stats", sensitive.AuthMiddleware(http.HandlerFunc(HandleIngestStats)))
+
+	t.Setenv("ADMIN_USER_IDS", "")
+	if rec := ts.do(t, ts.tokenA, http.MethodGet, "/ingest/stats", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("no admins: status = %d", rec.Code)
+	}
+	t.Setenv("ADMIN_USER_IDS", "admin, userB")
+	if rec := ts.do(t, ts.tokenA, http.MethodGet, "/ingest/stats", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("tenant: status = %d", rec.Code)
+	}
+	if rec := ts.do(t, ts.tokenB, http.MethodGet, "/ingest/stats", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"subscribers"`) {
+		t.Fatalf("admin: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := ts.do(t, "", http.MethodGet, "/ingest/stats", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("no token: status = %d", rec.Code)
+	}
+}
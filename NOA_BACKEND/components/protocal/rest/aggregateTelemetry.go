@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"GOLANG_SERVER/components/db"
+)
+
+// HandleAggregateTelemetry returns min/max/mean/RMS of a device's telemetry bucketed by interval
+//
+//	GET /device/{deviceID}/telemetry/aggregate?interval=1s|1m|1h|1d&from=&to=
+func HandleAggregateTelemetry(w http.ResponseWriter, r *http.Request, deviceID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	query, ok := aggregateQuery(w, params)
+	if !ok {
+		return
+	}
+	query.DeviceID = deviceID
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Aggregate the data in the database
+	buckets, err := db.AggregateTelemetry(query)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	// Encode the buckets into JSON
+	response := map[string]interface{}{
+		"deviceID": deviceID,
+		"interval": params.Get("interval"),
+		"buckets":  buckets,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// aggregateQuery reads the interval and time range of an aggregation
+func aggregateQuery(w http.ResponseWriter, params url.Values) (db.TelemetryAggregateQuery, bool) {
+	var query db.TelemetryAggregateQuery
+
+	interval, ok := db.TelemetryIntervals[params.Get("interval")]
+	if !ok {
+		http.Error(w, "Interval must be one of 1s, 1m, 1h or 1d", http.StatusBadRequest)
+		return query, false
+	}
+	query.Interval = interval
+
+	var err error
+	if query.From, err = parseTimeParam(params.Get("from")); err != nil {
+		http.Error(w, errInvalidParam("from").Error(), http.StatusBadRequest)
+		return query, false
+	}
+	if query.To, err = parseTimeParam(params.Get("to")); err != nil {
+		http.Error(w, errInvalidParam("to").Error(), http.StatusBadRequest)
+		return query, false
+	}
+	return query, true
+}
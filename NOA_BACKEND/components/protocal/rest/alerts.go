@@ -0,0 +1,212 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"GOLANG_SERVER/components/alert"
+	"GOLANG_SERVER/components/db"
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// HandleAlertRoute dispatches the alert rule CRUD and the alert history
+//
+//	GET    /alerts?deviceID=&orgID=&siteID=&assetID=&kind=&status=firing|resolved&limit=
+//	GET    /alerts/rules
+//	POST   /alerts/rules
+//	GET    /alerts/rules/{ruleID}
+//	PUT    /alerts/rules/{ruleID}
+//	DELETE /alerts/rules/{ruleID}
+func HandleAlertRoute(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/alerts"), "/")
+	switch {
+	case path == "":
+		handleListAlerts(w, r)
+	case path == "rules":
+		switch r.Method {
+		case http.MethodGet:
+			handleListAlertRules(w, r)
+		case http.MethodPost:
+			handleSaveAlertRule(w, r, "")
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case strings.HasPrefix(path, "rules/") && !strings.Contains(path[len("rules/"):], "/"):
+		ruleID := path[len("rules/"):]
+		switch r.Method {
+		case http.MethodGet:
+			handleGetAlertRule(w, r, ruleID)
+		case http.MethodPut:
+			handleSaveAlertRule(w, r, ruleID)
+		case http.MethodDelete:
+			handleDeleteAlertRule(w, r, ruleID)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func handleListAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	userID, ok := tokenUser(w, r, params.Get("userID"))
+	if !ok {
+		return
+	}
+
+	query := db.AlertQuery{DeviceID: params.Get("deviceID"), Status: params.Get("status")}
+	if query.Status != "" && query.Status != db.AlertFiring && query.Status != db.AlertResolved {
+		http.Error(w, errInvalidParam("status").Error(), http.StatusBadRequest)
+		return
+	}
+	if value := params.Get("limit"); value != "" {
+		var err error
+		if query.Limit, err = strconv.ParseInt(value, 10, 64); err != nil || query.Limit <= 0 {
+			http.Error(w, errInvalidParam("limit").Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	// Only the devices under an organisation, site or asset of the user when set
+	if filter := hierarchyFilter(params.Get); !filter.Empty() {
+		var err error
+		if query.DeviceIDs, err = db.HierarchyDevices(userID, filter); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	alerts, err := db.Alerts(userID, query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"alerts": alerts}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleListAlertRules(w http.ResponseWriter, r *http.Request) {
+	userID, ok := tokenUser(w, r, r.URL.Query().Get("userID"))
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	rules, err := db.AlertRules(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"rules": rules}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetAlertRule(w http.ResponseWriter, r *http.Request, ruleID string) {
+	userID, ok := tokenUser(w, r, r.URL.Query().Get("userID"))
+	if !ok {
+		return
+	}
+
+	rule, err := db.AlertRule(userID, ruleID)
+	if err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rule); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSaveAlertRule creates a rule when ruleID is empty, or replaces the rule
+func handleSaveAlertRule(w http.ResponseWriter, r *http.Request, ruleID string) {
+	var rule schema.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID, ok := tokenUser(w, r, rule.UserID)
+	if !ok {
+		return
+	}
+	rule.UserID = userID
+	if rule.DeviceID != "" {
+		if err := checkDeviceAccess(rule.UserID, rule.DeviceID, db.RoleViewer); err != nil {
+			writeAccessError(w, err)
+			return
+		}
+	}
+	if err := alert.Validate(&rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	status := http.StatusCreated
+	if ruleID == "" {
+		rule, err = db.CreateAlertRule(rule)
+	} else {
+		rule.ID = ruleID
+		rule, err = db.UpdateAlertRule(rule)
+		status = http.StatusOK
+	}
+	if err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	reloadAlertRules()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(rule); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteAlertRule(w http.ResponseWriter, r *http.Request, ruleID string) {
+	userID, ok := tokenUser(w, r, r.URL.Query().Get("userID"))
+	if !ok {
+		return
+	}
+
+	if err := db.DeleteAlertRule(userID, ruleID); err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	reloadAlertRules()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Rule deleted"})
+}
+
+// reloadAlertRules applies a rule change to the engine of this server right away
+func reloadAlertRules() {
+	if err := alert.Reload(); err != nil {
+		log.Println("Error loading alert rules:", err)
+	}
+}
+
+func writeRuleError(w http.ResponseWriter, err error) {
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "Rule not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
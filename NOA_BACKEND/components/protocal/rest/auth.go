@@ -0,0 +1,63 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/env"
+	"GOLANG_SERVER/components/sensitive"
+)
+
+// errUserMismatch is returned when a request names another user than the one of its token
+var errUserMismatch = errors.New("userID does not match the token")
+
+// tokenUser returns the user of the access token. Requests may still send a userID,
+// it is only accepted when it is the same user.
+func tokenUser(w http.ResponseWriter, r *http.Request, claimed string) (string, bool) {
+	userID := sensitive.UserID(r)
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", false
+	}
+	if claimed != "" && claimed != userID {
+		http.Error(w, errUserMismatch.Error(), http.StatusForbidden)
+		return "", false
+	}
+	return userID, true
+}
+
+var errUserIDRequired = errors.New("User ID is required")
+
+// checkDeviceAccess checks that the user has at least the role on the device
+func checkDeviceAccess(userID, deviceID, role string) error {
+	if userID == "" {
+		return errUserIDRequired
+	}
+	return db.CheckDeviceAccess(userID, deviceID, role)
+}
+
+// writeAccessError writes the status matching an error of checkDeviceAccess
+func writeAccessError(w http.ResponseWriter, err error) {
+	switch err {
+	case errUserIDRequired:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case db.ErrNotDeviceOwner:
+		http.Error(w, "Device not found", http.StatusNotFound)
+	case db.ErrDeviceRole:
+		http.Error(w, "Insufficient role on the device", http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// isAdmin reports whether the user is one of the operators of the server listed in ADMIN_USER_IDS, comma separated
+func isAdmin(userID string) bool {
+	for _, admin := range strings.Split(env.GetEnv("ADMIN_USER_IDS"), ",") {
+		if admin = strings.TrimSpace(admin); admin != "" && admin == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -23,9 +23,9 @@ func ChangeBookmark(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
-	userID, ok := requestBody["userID"].(string)
-	if !ok || userID == "" {
-		http.Error(w, "User ID is required", http.StatusBadRequest)
+	claimed, _ := requestBody["userID"].(string)
+	userID, ok := tokenUser(w, r, claimed)
+	if !ok {
 		return
 	}
 
@@ -34,6 +34,11 @@ func ChangeBookmark(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Device ID is required", http.StatusBadRequest)
 		return
 	}
+	_, role, err := db.DeviceAccess(userID, deviceID)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
 
 	bookmark, ok := requestBody["bookmark"].(bool)
 	if !ok {
@@ -41,8 +46,12 @@ func ChangeBookmark(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Call the database function to update the bookmark status
-	if err := db.UpdateBookmark(userID, deviceID, bookmark); err != nil {
+	// The owner bookmarks the device, the users it is shared with their share
+	update := db.UpdateBookmark
+	if role != db.RoleOwner {
+		update = db.UpdateShareBookmark
+	}
+	if err := update(userID, deviceID, bookmark); err != nil {
 		http.Error(w, "Failed to update bookmark: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
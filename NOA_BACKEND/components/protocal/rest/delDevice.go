@@ -21,9 +21,8 @@ func HandleDeleteDevice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID := requestBody["userID"]
-	if userID == "" {
-		http.Error(w, "User ID is required", http.StatusBadRequest)
+	userID, ok := tokenUser(w, r, requestBody["userID"])
+	if !ok {
 		return
 	}
 
@@ -32,12 +31,27 @@ func HandleDeleteDevice(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Device ID is required", http.StatusBadRequest)
 		return
 	}
+	if err := checkDeviceAccess(userID, deviceID, db.RoleOwner); err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
+	shares, err := db.DeviceShares(deviceID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	// Call the database function to delete the device
+	// Call the database function to delete the device, its shares go with it
 	if err := db.DeleteDevice(userID, deviceID); err != nil {
 		http.Error(w, "Failed to delete device: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	for _, share := range shares {
+		if share.UserID != "" {
+			revokeAccess(share.UserID, deviceID)
+		}
+	}
 
 	// Respond with success
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,57 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"GOLANG_SERVER/components/db"
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// HandleDeviceAsset reads or sets the asset of the owner's hierarchy the device is attached to,
+// an empty assetID detaches it
+//
+//	GET /device/{deviceID}/asset
+//	PUT /device/{deviceID}/asset {"assetID"}
+func HandleDeviceAsset(w http.ResponseWriter, r *http.Request, device *schema.Device) {
+	assetID := device.AssetID
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		// Handle both lowercase and uppercase keys
+		assetID = body["assetID"]
+		if assetID == "" {
+			assetID = body["AssetID"]
+		}
+		if err := db.AttachDevice(device.UserID, device.ID, assetID); err != nil {
+			writeHierarchyError(w, err)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h, err := db.LoadHierarchy(device.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The asset first, then the assets above it
+	path := h.Path(assetID)
+	if path == nil {
+		path = []schema.Asset{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deviceID": device.ID,
+		"assetID":  assetID,
+		"path":     path,
+	})
+}
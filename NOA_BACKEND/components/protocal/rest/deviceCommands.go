@@ -0,0 +1,108 @@
+package rest
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/protocal/mosquitto"
+)
+
+// CommandRequest is the body of POST /device/{deviceID}/commands
+type CommandRequest struct {
+	Type   string                 `json:"type"`   // sampleRate, modbusHighSpeed, reboot or config
+	Params map[string]interface{} `json:"params"` // {"hz": 100}, {"enabled": true}, none, {"MQTT_SERVER": "..."}
+	TTL    int64                  `json:"ttl"`    // Seconds the device has to ack, 300 when zero
+}
+
+// HandleDeviceCommands sends a command to a device or lists its latest commands, role is the role of the caller
+// and ownerID the owner of the device whose topics the command goes to
+//
+//	POST /device/{deviceID}/commands
+//	GET  /device/{deviceID}/commands?limit=
+func HandleDeviceCommands(w http.ResponseWriter, r *http.Request, role, ownerID, deviceID string) {
+	switch r.Method {
+	case http.MethodPost:
+		handleSendCommand(w, r, role, ownerID, deviceID)
+	case http.MethodGet:
+		handleListCommands(w, r, deviceID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleSendCommand(w http.ResponseWriter, r *http.Request, role, ownerID, deviceID string) {
+	var req CommandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !db.RoleAllows(role, db.CommandRole(req.Type)) {
+		writeAccessError(w, db.ErrDeviceRole)
+		return
+	}
+	if req.TTL < 0 {
+		http.Error(w, errInvalidParam("ttl").Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Store the command as pending before it reaches the device so its ack always finds it
+	command, err := db.CreateCommand(ownerID, deviceID, req.Type, req.Params, time.Duration(req.TTL)*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := mosquitto.SendCommand(command); err != nil {
+		log.Println("Error sending command:", err)
+		if err := db.SetCommandFailed(deviceID, command.ID, err.Error()); err != nil {
+			log.Println("Error updating command:", err)
+		}
+		command.Status = db.CommandFailed
+		command.Error = err.Error()
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(command)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(command); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleListCommands(w http.ResponseWriter, r *http.Request, deviceID string) {
+	params := r.URL.Query()
+
+	var limit int64
+	if value := params.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.ParseInt(value, 10, 64); err != nil || limit <= 0 {
+			http.Error(w, errInvalidParam("limit").Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	commands, err := db.DeviceCommands(deviceID, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"deviceID": deviceID,
+		"commands": commands,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
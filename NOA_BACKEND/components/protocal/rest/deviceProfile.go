@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/profile"
+	"GOLANG_SERVER/components/protocal/mosquitto"
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// HandleDeviceProfile reads, replaces or patches the machine profile of a device of ownerID.
+// PUT replaces the whole profile, PATCH only changes the fields in the body.
+//
+//	GET   /device/{deviceID}/profile
+//	PUT   /device/{deviceID}/profile
+//	PATCH /device/{deviceID}/profile
+func HandleDeviceProfile(w http.ResponseWriter, r *http.Request, ownerID string, device *schema.Device) {
+	p := device.Profile
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPatch:
+		if r.Method == http.MethodPut {
+			p = schema.DeviceProfile{}
+		}
+		// Tags are decoded into the slice they replace, a copy leaves the device untouched when the body is rejected
+		p.Tags = slices.Clone(p.Tags)
+		// Fields missing from the body of a PATCH keep their current value
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := profile.Validate(&p); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := db.UpdateDeviceProfile(ownerID, device.ID, p); err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeAccessError(w, db.ErrNotDeviceOwner)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Classify and predict the next message with the new profile
+		mosquitto.ForgetDevice(device.ID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if p.Tags == nil {
+		p.Tags = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"deviceID": device.ID,
+		"profile":  p,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
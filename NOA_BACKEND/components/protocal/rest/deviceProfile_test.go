@@ -0,0 +1,99 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"GOLANG_SERVER/components/db"
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// profileOf calls the profile route and decodes the profile it answers
+func (ts *tenants) profileOf(t *testing.T, method, body string, want int) schema.DeviceProfile {
+	t.Helper()
+	rec := ts.do(t, ts.tokenA, method, "/device/deviceA/profile", body)
+	if rec.Code != want {
+		t.Fatalf("%s %s: %d %s", method, body, rec.Code, rec.Body.String())
+	}
+	var response struct {
+		DeviceID string               `json:"deviceID"`
+		Profile  schema.DeviceProfile `json:"profile"`
+	}
+	if want == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil || response.DeviceID != "deviceA" {
+			t.Fatalf("%s %s: %+v %v", method, body, response, err)
+		}
+	}
+	return response.Profile
+}
+
+func TestProfilePatchMerges(t *testing.T) {
+	ts := newTenants(t)
+
+	// A new device has an empty profile with no tags
+	p := ts.profileOf(t, http.MethodGet, "", http.StatusOK)
+	if p.MachineType != "" || p.Tags == nil || len(p.Tags) != 0 {
+		t.Fatalf("new device: %+v", p)
+	}
+
+	ts.profileOf(t, http.MethodPut, `{"machineType":"Pump","ratedRPM":1480,"mounting":"horizontal","axisMapping":{"X":"axial","Y":"horizontal"},"tags":["Line 1","critical"]}`, http.StatusOK)
+
+	// Fields missing from a PATCH keep their value, including a single axis of the mapping
+	p = ts.profileOf(t, http.MethodPatch, `{"location":"hall 2","axisMapping":{"Z":"vertical"}}`, http.StatusOK)
+	if p.MachineType != "pump" || p.RatedRPM != 1480 || p.Mounting != "horizontal" || p.Location != "hall 2" {
+		t.Fatalf("after PATCH: %+v", p)
+	}
+	if p.AxisMapping != (schema.AxisMapping{X: "axial", Y: "horizontal", Z: "vertical"}) {
+		t.Fatalf("axis mapping after PATCH: %+v", p.AxisMapping)
+	}
+	if !slices.Equal(p.Tags, []string{"critical", "line 1"}) {
+		t.Fatalf("tags after PATCH without tags: %v", p.Tags)
+	}
+
+	// Tags are an array: a PATCH with tags replaces all of them, it does not add to them
+	p = ts.profileOf(t, http.MethodPatch, `{"tags":["pump"]}`, http.StatusOK)
+	if !slices.Equal(p.Tags, []string{"pump"}) || p.MachineType != "pump" {
+		t.Fatalf("after PATCH of the tags: %+v", p)
+	}
+	p = ts.profileOf(t, http.MethodPatch, `{"tags":[]}`, http.StatusOK)
+	if len(p.Tags) != 0 {
+		t.Fatalf("after PATCH with no tags: %v", p.Tags)
+	}
+
+	// PUT replaces the whole profile
+	p = ts.profileOf(t, http.MethodPut, `{"tags":["fan"]}`, http.StatusOK)
+	if p.MachineType != "" || p.RatedRPM != 0 || p.AxisMapping != (schema.AxisMapping{}) || !slices.Equal(p.Tags, []string{"fan"}) {
+		t.Fatalf("after PUT: %+v", p)
+	}
+
+	// What was answered is what was saved
+	device, err := db.FindDevice("deviceA")
+	if err != nil || !slices.Equal(device.Profile.Tags, []string{"fan"}) {
+		t.Fatalf("saved profile: %+v %v", device, err)
+	}
+}
+
+func TestProfileRejectedPatchChangesNothing(t *testing.T) {
+	ts := newTenants(t)
+	ts.profileOf(t, http.MethodPut, `{"machineType":"pump","tags":["critical","line 1"]}`, http.StatusOK)
+
+	for _, body := range []string{
+		`{"tags":["fan"],"ratedRPM":-1}`,
+		`{"tags":["fan"],"axisMapping":{"X":"axial","Y":"axial"}}`,
+		`{"tags":["fan"],"mounting":"upside down"}`,
+		`{"tags":"fan"}`,
+		`not json`,
+	} {
+		ts.profileOf(t, http.MethodPatch, body, http.StatusBadRequest)
+	}
+	p := ts.profileOf(t, http.MethodGet, "", http.StatusOK)
+	if p.MachineType != "pump" || !slices.Equal(p.Tags, []string{"critical", "line 1"}) {
+		t.Fatalf("after rejected patches: %+v", p)
+	}
+
+	if rec := ts.do(t, ts.tokenA, http.MethodDelete, "/device/deviceA/profile", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("DELETE: %d", rec.Code)
+	}
+}
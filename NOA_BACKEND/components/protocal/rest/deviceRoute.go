@@ -0,0 +1,83 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+
+	"GOLANG_SERVER/components/db"
+)
+
+// HandleDeviceRoute dispatches /device/{deviceID}/{resource} requests of the users with a role on the device
+func HandleDeviceRoute(w http.ResponseWriter, r *http.Request) {
+	// Get the device ID and resource from the URL
+	parts := strings.Split(strings.Trim(r.URL.Path[len("/device/"):], "/"), "/")
+	if len(parts) < 2 || parts[0] == "" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	deviceID, resource := parts[0], strings.Join(parts[1:], "/")
+
+	// Every resource belongs to the device, the caller needs a role on it
+	userID, ok := tokenUser(w, r, r.URL.Query().Get("userID"))
+	if !ok {
+		return
+	}
+	device, role, err := db.DeviceAccess(userID, deviceID)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+	if !db.RoleAllows(role, deviceRouteRole(parts[1], r.Method)) {
+		writeAccessError(w, db.ErrDeviceRole)
+		return
+	}
+
+	switch {
+	case resource == "telemetry":
+		HandleGetTelemetry(w, r, deviceID)
+	case resource == "telemetry/aggregate":
+		HandleAggregateTelemetry(w, r, deviceID)
+	case resource == "telemetry/export":
+		HandleExportTelemetry(w, r, deviceID)
+	case resource == "commands":
+		HandleDeviceCommands(w, r, role, device.UserID, deviceID)
+	case resource == "usage":
+		HandleDeviceUsage(w, r, deviceID)
+	case resource == "machineClass":
+		HandleMachineClass(w, r, device.UserID, deviceID)
+	case parts[1] == "shares" && len(parts) <= 3:
+		HandleDeviceShares(w, r, userID, role, device, strings.Join(parts[2:], ""))
+	case resource == "transfer":
+		HandleTransferDevice(w, r, userID, device)
+	case resource == "asset":
+		HandleDeviceAsset(w, r, device)
+	case resource == "profile":
+		HandleDeviceProfile(w, r, device.UserID, device)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// deviceRouteRole is the lowest role allowed to call the method on a resource of the device. Viewers read,
+// operators also change the device and only the owner manages who has access to it.
+func deviceRouteRole(resource, method string) string {
+	switch resource {
+	case "commands", "machineClass", "profile":
+		if method != http.MethodGet {
+			return db.RoleOperator
+		}
+	case "transfer":
+		return db.RoleOwner
+	case "asset":
+		// The asset is in the hierarchy of the owner
+		if method != http.MethodGet {
+			return db.RoleOwner
+		}
+	case "shares":
+		// Users the device is shared with may leave it, HandleDeviceShares checks the share is theirs
+		if method != http.MethodDelete {
+			return db.RoleOwner
+		}
+	}
+	return db.RoleViewer
+}
@@ -0,0 +1,278 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/env"
+	"GOLANG_SERVER/components/protocal/mosquitto"
+	"GOLANG_SERVER/components/protocal/ws"
+	schema "GOLANG_SERVER/components/schema"
+	"GOLANG_SERVER/components/user"
+)
+
+// HandleDeviceShares manages who the device is shared with, the owner lists, invites, changes roles and revokes.
+// A user the device is shared with may only delete their own share to leave it.
+//
+//	GET    /device/{deviceID}/shares
+//	POST   /device/{deviceID}/shares {"email", "role": "operator"|"viewer"}
+//	PUT    /device/{deviceID}/shares/{shareID} {"role"}
+//	DELETE /device/{deviceID}/shares/{shareID}
+func HandleDeviceShares(w http.ResponseWriter, r *http.Request, userID, role string, device *schema.Device, shareID string) {
+	switch {
+	case shareID == "" && r.Method == http.MethodGet:
+		shares, err := db.DeviceShares(device.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"shares": shares})
+	case shareID == "" && r.Method == http.MethodPost:
+		body, ok := shareBody(w, r)
+		if !ok {
+			return
+		}
+		if !db.ValidShareRole(body["role"]) {
+			http.Error(w, db.ErrShareRole.Error(), http.StatusBadRequest)
+			return
+		}
+		inviteToDevice(w, r, userID, device, body["email"], body["role"])
+	case shareID != "" && r.Method == http.MethodPut:
+		body, ok := shareBody(w, r)
+		if !ok {
+			return
+		}
+		if err := db.UpdateShareRole(device.ID, shareID, body["role"]); err != nil {
+			writeShareError(w, err)
+			return
+		}
+		share, err := db.FindShare(shareID)
+		if err != nil {
+			writeShareError(w, err)
+			return
+		}
+		// Alert rules of a user depend on the access to the device
+		reloadAlertRules()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(share)
+	case shareID != "" && r.Method == http.MethodDelete:
+		share, err := db.FindShare(shareID)
+		if err == nil && share.DeviceID != device.ID {
+			err = db.ErrNotFound
+		}
+		if err != nil {
+			writeShareError(w, err)
+			return
+		}
+		if role != db.RoleOwner && share.UserID != userID {
+			writeAccessError(w, db.ErrDeviceRole)
+			return
+		}
+		if err := db.DeleteShare(shareID); err != nil {
+			writeShareError(w, err)
+			return
+		}
+		if share.UserID != "" {
+			revokeAccess(share.UserID, device.ID)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Share deleted"})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleTransferDevice invites a user to become the owner of the device, the device is theirs once they accept
+//
+//	POST /device/{deviceID}/transfer {"email"}
+func HandleTransferDevice(w http.ResponseWriter, r *http.Request, userID string, device *schema.Device) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, ok := shareBody(w, r)
+	if !ok {
+		return
+	}
+	inviteToDevice(w, r, userID, device, body["email"], db.RoleOwner)
+}
+
+// HandleInvitations lists the pending invitations of the email of the user and answers them
+//
+//	GET  /invitations
+//	POST /invitations/{shareID}/accept
+//	POST /invitations/{shareID}/decline
+func HandleInvitations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := tokenUser(w, r, "")
+	if !ok {
+		return
+	}
+	account, err := db.FindUserID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/invitations"), "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		invitations, err := db.Invitations(account.Email)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"invitations": invitations})
+	case len(parts) == 2 && parts[1] == "accept":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		acceptInvitation(w, userID, account.Email, parts[0])
+	case len(parts) == 2 && parts[1] == "decline":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		share, err := db.FindShare(parts[0])
+		if err == nil && (share.Status != db.SharePending || !strings.EqualFold(share.Email, account.Email)) {
+			err = db.ErrNotFound
+		}
+		if err == nil {
+			err = db.DeleteShare(share.ID)
+		}
+		if err != nil {
+			writeShareError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Invitation declined"})
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func acceptInvitation(w http.ResponseWriter, userID, email, shareID string) {
+	// The owner cannot accept a transfer of their own device
+	if share, err := db.FindShare(shareID); err == nil && share.OwnerID == userID {
+		http.Error(w, "Cannot accept an invitation to your own device", http.StatusBadRequest)
+		return
+	}
+
+	share, err := db.AcceptShare(shareID, email, userID)
+	if err != nil {
+		writeShareError(w, err)
+		return
+	}
+	if share.Role == db.RoleOwner {
+		// The previous owner keeps no access and the device is classified and authorised for its new owner
+		mosquitto.ForgetDevice(share.DeviceID)
+		revokeAccess(share.OwnerID, share.DeviceID)
+		log.Printf("Device %s transferred from user %s to user %s\n", share.DeviceID, share.OwnerID, userID)
+	} else {
+		reloadAlertRules()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Invitation accepted",
+		"deviceID": share.DeviceID,
+		"role":     share.Role,
+	})
+}
+
+// inviteToDevice stores the invitation of the email with the role and emails it
+func inviteToDevice(w http.ResponseWriter, r *http.Request, userID string, device *schema.Device, email, role string) {
+	owner, err := db.FindUserID(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if strings.EqualFold(strings.TrimSpace(email), owner.Email) {
+		http.Error(w, "Cannot invite yourself", http.StatusBadRequest)
+		return
+	}
+
+	share, err := db.InviteShare(userID, device.ID, device.DeviceName, email, role)
+	if err != nil {
+		writeShareError(w, err)
+		return
+	}
+	inviter := owner.Username
+	if inviter == "" {
+		inviter = owner.Email
+	}
+	if err := user.SendDeviceInviteEmail(share, inviter, invitationLink(share.ID), r.Header.Get("Accept-Language")); err != nil {
+		// The invitation is listed under /invitations even without the email
+		log.Println("Error sending invitation email:", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(share)
+}
+
+// invitationLink is SHARE_INVITE_URL with the shareID, empty when it is not set
+func invitationLink(shareID string) string {
+	page := env.GetEnv("SHARE_INVITE_URL")
+	if page == "" {
+		return ""
+	}
+	link, err := url.Parse(page)
+	if err != nil {
+		log.Println("Invalid SHARE_INVITE_URL:", err)
+		return ""
+	}
+	query := link.Query()
+	query.Set("shareID", shareID)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+// revokeAccess closes the sockets of a user who lost access to the device and stops their alert rules on it
+func revokeAccess(userID, deviceID string) {
+	ws.Disconnect(userID, deviceID)
+	reloadAlertRules()
+}
+
+// shareBody reads the email and role of a share request
+func shareBody(w http.ResponseWriter, r *http.Request) (map[string]string, bool) {
+	var raw map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+	// Handle both lowercase and uppercase keys
+	body := map[string]string{"email": raw["email"], "role": raw["role"]}
+	if body["email"] == "" {
+		body["email"] = raw["Email"]
+	}
+	if body["role"] == "" {
+		body["role"] = raw["Role"]
+	}
+	return body, true
+}
+
+func writeShareError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		http.Error(w, "Share not found", http.StatusNotFound)
+	case errors.Is(err, db.ErrShareExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, db.ErrShareRole), errors.Is(err, db.ErrShareEmail):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,56 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/usage"
+)
+
+// HandleDeviceUsage returns the messages, bytes, active hours and prediction calls of a device
+// by day or by month, from and to default to the current month
+//
+//	GET /device/{deviceID}/usage?from=2006-01-02&to=2006-01-02&breakdown=daily|monthly
+func HandleDeviceUsage(w http.ResponseWriter, r *http.Request, deviceID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	now := time.Now().In(usage.Location)
+	from, to := params.Get("from"), params.Get("to")
+	if from == "" {
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, usage.Location).Format(db.UsageDayLayout)
+	}
+	if to == "" {
+		to = now.Format(db.UsageDayLayout)
+	}
+
+	var monthly bool
+	switch params.Get("breakdown") {
+	case "", "daily":
+	case "monthly":
+		monthly = true
+	default:
+		http.Error(w, errInvalidParam("breakdown").Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Counters of the last minute are still in memory
+	usage.Flush()
+
+	report, err := db.DeviceUsageReport(deviceID, from, to, monthly)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,94 @@
+package rest
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/export"
+	"GOLANG_SERVER/components/schema"
+)
+
+// HandleExportTelemetry streams a device's telemetry in a time range as a file
+//
+//	GET /device/{deviceID}/telemetry/export?format=csv|jsonl|parquet&from=&to=
+func HandleExportTelemetry(w http.ResponseWriter, r *http.Request, deviceID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	formatName := params.Get("format")
+	if formatName == "" {
+		formatName = "csv"
+	}
+	format, ok := export.Formats[formatName]
+	if !ok {
+		http.Error(w, export.ErrUnknownFormat.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := db.TelemetryQuery{DeviceID: deviceID}
+	var err error
+	if query.From, err = parseTimeParam(params.Get("from")); err != nil {
+		http.Error(w, errInvalidParam("from").Error(), http.StatusBadRequest)
+		return
+	}
+	if query.To, err = parseTimeParam(params.Get("to")); err != nil {
+		http.Error(w, errInvalidParam("to").Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The file headers are only set once there is a file, so an error before the first row is a plain error
+	setHeaders := func() {
+		w.Header().Set("Content-Type", format.ContentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s_%d_%d.%s"`, deviceID, query.From, query.To, format.Extension))
+	}
+
+	// Stream the documents straight from the database cursor to the response
+	encoder := format.New(w)
+	rows := 0
+	err = db.ExportTelemetry(r.Context(), query, func(data schema.GyroData) error {
+		if rows == 0 {
+			setHeaders()
+		}
+		rows++
+		return encoder.Write(data)
+	})
+	if err != nil {
+		// The status line is already sent once a row is written, the client sees a truncated file
+		log.Printf("Error exporting telemetry of device %s after %d rows: %v\n", deviceID, rows, err)
+		if rows == 0 {
+			writeQueryError(w, err)
+		}
+		return
+	}
+	if rows == 0 {
+		setHeaders()
+	}
+	if err := encoder.Close(); err != nil {
+		log.Printf("Error finishing telemetry export of device %s: %v\n", deviceID, err)
+		return
+	}
+	log.Printf("Exported %d rows of device %s as %s\n", rows, deviceID, formatName)
+}
+
+// HandleDownloadData is the /downloaddata route, same as the device export with the deviceID as a query parameter
+func HandleDownloadData(w http.ResponseWriter, r *http.Request) {
+	deviceID := r.URL.Query().Get("deviceID")
+	if deviceID == "" {
+		http.Error(w, "Device ID is required", http.StatusBadRequest)
+		return
+	}
+	userID, ok := tokenUser(w, r, r.URL.Query().Get("userID"))
+	if !ok {
+		return
+	}
+	if err := checkDeviceAccess(userID, deviceID, db.RoleViewer); err != nil {
+		writeAccessError(w, err)
+		return
+	}
+	HandleExportTelemetry(w, r, deviceID)
+}
@@ -0,0 +1,31 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExportHeaders(t *testing.T) {
+	ts := newTenants(t)
+
+	rec := ts.do(t, ts.tokenA, http.MethodGet, "/device/deviceA/telemetry/export?format=csv", "")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Disposition"), "attachment;") {
+		t.Fatalf("export: %d %v", rec.Code, rec.Header())
+	}
+	if lines := strings.Count(rec.Body.String(), "\n"); lines != 4 {
+		t.Fatalf("export has %d lines, want a header and 3 rows", lines)
+	}
+
+	// An empty range is still a file
+	rec = ts.do(t, ts.tokenA, http.MethodGet, "/device/deviceA/telemetry/export?format=jsonl&from=1&to=2", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Disposition") == "" {
+		t.Fatalf("empty export: %d %v", rec.Code, rec.Header())
+	}
+
+	// An error before the first row is not offered as a download
+	rec = ts.do(t, ts.tokenA, http.MethodGet, "/device/deviceA/telemetry/export?from=2000&to=1000", "")
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Disposition") != "" {
+		t.Fatalf("failed export: %d %v", rec.Code, rec.Header())
+	}
+}
@@ -2,36 +2,28 @@ package rest
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 
 	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/presence"
+	"GOLANG_SERVER/components/profile"
 )
 
 func HandleGetDeviceAddress(w http.ResponseWriter, r *http.Request) {
-<<<<<<< HEAD
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json") // Set the content type to JSON
-
-	// * get device address from database
-	deviceAddresses, err := db.GetDeviceAddress()
-=======
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	// The body is optional, the devices are the ones of the token's user
 	var userDetail map[string]string
-	if err := json.NewDecoder(r.Body).Decode(&userDetail); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&userDetail); err != nil && err != io.EOF {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	userID := userDetail["userID"]
-	if userID == "" {
-		http.Error(w, "User ID is required", http.StatusBadRequest)
+	userID, ok := tokenUser(w, r, userDetail["userID"])
+	if !ok {
 		return
 	}
 
@@ -39,20 +31,28 @@ func HandleGetDeviceAddress(w http.ResponseWriter, r *http.Request) {
 
 	// ดึงข้อมูลอุปกรณ์จากฐานข้อมูล
 	devices, err := db.GetDeviceAddress(userID)
->>>>>>> Final_BN
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	// Only the devices under an organisation, site or asset of the user when set in the body or the query
+	filter := hierarchyFilter(func(key string) string {
+		if value := userDetail[key]; value != "" {
+			return value
+		}
+		return r.URL.Query().Get(key)
+	})
+	if devices, err = db.FilterDevices(userID, devices, filter); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Only the devices with every tag of the body or the query, tags=a,b or tag=a&tag=b
+	tags := profile.ParseTags(append(r.URL.Query()["tag"], userDetail["tags"], r.URL.Query().Get("tags"))...)
+	devices = profile.FilterTags(devices, tags)
+	presence.Apply(devices) // Status and LastSeen as of the last message
 
-<<<<<<< HEAD
-	// * send device addresses .json to client
-	response := map[string][]string{"deviceAddresses": deviceAddresses}
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-=======
 	// ส่งข้อมูลกลับในรูปแบบ JSON
 	if err := json.NewEncoder(w).Encode(devices); err != nil {
->>>>>>> Final_BN
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
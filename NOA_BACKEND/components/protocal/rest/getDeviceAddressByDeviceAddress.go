@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
 
 	"GOLANG_SERVER/components/db"
 
@@ -18,10 +19,25 @@ func HandleGetDeviceAddressByDeviceAddress(w http.ResponseWriter, r *http.Reques
 
 	w.Header().Set("Content-Type", "application/json") // Set the content type to JSON
 
+	userID, ok := tokenUser(w, r, "")
+	if !ok {
+		return
+	}
+
 	// Get the device address from the URL
-	deviceAddress := r.URL.Path[len("/checkdeviceaddresses/"):]
+	deviceAddress := strings.TrimPrefix(r.URL.Path, "/device/checkdeviceaddresses/")
 	log.Println("Received request for device address:", deviceAddress)
 
+	// Only devices the user has a role on are found, so other tenants' device IDs are not revealed
+	if err := checkDeviceAccess(userID, deviceAddress, db.RoleViewer); err != nil {
+		if err == db.ErrNotDeviceOwner {
+			http.Error(w, "No device addresses found", http.StatusNotFound)
+		} else {
+			writeAccessError(w, err)
+		}
+		return
+	}
+
 	// Get the data from the database
 	deviceAddresses, err := db.GetDeviceAddressByDeviceAddress(deviceAddress)
 	if err != nil {
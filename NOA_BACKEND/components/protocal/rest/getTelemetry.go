@@ -0,0 +1,109 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+)
+
+// HandleGetTelemetry returns a page of a device's telemetry in a time range
+//
+//	GET /device/{deviceID}/telemetry?from=&to=&fields=X.Acceleration,Temperature&sort=asc|desc&limit=&pageToken=
+func HandleGetTelemetry(w http.ResponseWriter, r *http.Request, deviceID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, err := parseTelemetryQuery(r, deviceID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get the page from the database
+	page, err := db.QueryTelemetry(query)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	// Encode the page into JSON
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// parseTelemetryQuery reads the telemetry query parameters of the request
+func parseTelemetryQuery(r *http.Request, deviceID string) (db.TelemetryQuery, error) {
+	params := r.URL.Query()
+	query := db.TelemetryQuery{DeviceID: deviceID}
+
+	var err error
+	if query.From, err = parseTimeParam(params.Get("from")); err != nil {
+		return query, errInvalidParam("from")
+	}
+	if query.To, err = parseTimeParam(params.Get("to")); err != nil {
+		return query, errInvalidParam("to")
+	}
+	if query.Fields, err = db.ParseTelemetryFields(params.Get("fields")); err != nil {
+		return query, err
+	}
+
+	switch params.Get("sort") {
+	case "", "asc":
+		query.Descending = false
+	case "desc":
+		query.Descending = true
+	default:
+		return query, errInvalidParam("sort")
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.ParseInt(limit, 10, 64); err != nil || query.Limit <= 0 {
+			return query, errInvalidParam("limit")
+		}
+	}
+
+	if query.Cursor, err = db.DecodePageToken(params.Get("pageToken")); err != nil {
+		return query, err
+	}
+	return query, nil
+}
+
+// parseTimeParam accepts unix milliseconds or an RFC3339 time, empty means no bound
+func parseTimeParam(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return ms, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, err
+	}
+	return t.UnixMilli(), nil
+}
+
+// writeQueryError answers 400 for a query the client got wrong and 500 for a database error
+func writeQueryError(w http.ResponseWriter, err error) {
+	var queryErr *db.QueryError
+	if errors.As(err, &queryErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// errInvalidParam reports a query parameter that can't be parsed
+func errInvalidParam(name string) error {
+	return errors.New("Invalid " + name + " parameter")
+}
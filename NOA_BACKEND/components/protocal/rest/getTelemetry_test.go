@@ -0,0 +1,30 @@
+package rest
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTelemetryQueryErrors(t *testing.T) {
+	ts := newTenants(t)
+
+	tests := []struct {
+		name, path string
+		want       int
+	}{
+		{"query", "/device/deviceA/telemetry?limit=2", http.StatusOK},
+		{"query range", "/device/deviceA/telemetry?from=2000&to=1000", http.StatusBadRequest},
+		{"query page token", "/device/deviceA/telemetry?pageToken=nope", http.StatusBadRequest},
+		{"query field", "/device/deviceA/telemetry?fields=X.Nope", http.StatusBadRequest},
+		{"aggregate", "/device/deviceA/telemetry/aggregate?interval=1m", http.StatusOK},
+		{"aggregate range", "/device/deviceA/telemetry/aggregate?interval=1m&from=2000&to=1000", http.StatusBadRequest},
+		{"aggregate interval", "/device/deviceA/telemetry/aggregate?interval=2m", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := ts.do(t, ts.tokenA, http.MethodGet, tt.path, ""); rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
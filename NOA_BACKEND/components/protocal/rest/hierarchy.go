@@ -0,0 +1,323 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"GOLANG_SERVER/components/db"
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// HandleHierarchyRoute dispatches the organisation, site and asset CRUD of the devices of the user, the tree
+// and the telemetry aggregated over every device under an organisation, site or asset
+//
+//	GET    /hierarchy
+//	GET    /orgs
+//	POST   /orgs {"name"}
+//	GET    /sites?orgID=
+//	POST   /sites {"orgID", "name", "location"}
+//	GET    /assets?siteID=&parentID=
+//	POST   /assets {"siteID", "parentID", "name", "kind"}
+//	GET    /{orgs|sites|assets}/{id}
+//	PUT    /{orgs|sites|assets}/{id}
+//	DELETE /{orgs|sites|assets}/{id}
+//	GET    /{orgs|sites|assets}/{id}/telemetry/aggregate?interval=1s|1m|1h|1d&from=&to=&kind=
+func HandleHierarchyRoute(w http.ResponseWriter, r *http.Request) {
+	userID, ok := tokenUser(w, r, r.URL.Query().Get("userID"))
+	if !ok {
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	level := parts[0]
+
+	switch {
+	case level == "hierarchy" && len(parts) == 1:
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		tree, err := db.HierarchyTree(userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"organisations": tree})
+	case level == "hierarchy":
+		http.Error(w, "Not found", http.StatusNotFound)
+	case len(parts) == 1:
+		switch r.Method {
+		case http.MethodGet:
+			handleListHierarchy(w, r, userID, level)
+		case http.MethodPost:
+			handleSaveHierarchy(w, r, userID, level, "")
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 2:
+		switch r.Method {
+		case http.MethodGet:
+			handleGetHierarchy(w, userID, level, parts[1])
+		case http.MethodPut:
+			handleSaveHierarchy(w, r, userID, level, parts[1])
+		case http.MethodDelete:
+			handleDeleteHierarchy(w, userID, level, parts[1])
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 4 && parts[2] == "telemetry" && parts[3] == "aggregate":
+		handleAggregateHierarchy(w, r, userID, level, parts[1])
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func handleListHierarchy(w http.ResponseWriter, r *http.Request, userID, level string) {
+	h, err := db.LoadHierarchy(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	params := r.URL.Query()
+
+	response := map[string]interface{}{}
+	switch level {
+	case "orgs":
+		orgs := h.Organisations
+		if orgs == nil {
+			orgs = []schema.Organisation{}
+		}
+		response["organisations"] = orgs
+	case "sites":
+		sites := []schema.Site{}
+		for _, site := range h.Sites {
+			if orgID := params.Get("orgID"); orgID == "" || site.OrgID == orgID {
+				sites = append(sites, site)
+			}
+		}
+		response["sites"] = sites
+	case "assets":
+		assets := []schema.Asset{}
+		for _, asset := range h.Assets {
+			if siteID := params.Get("siteID"); siteID != "" && asset.SiteID != siteID {
+				continue
+			}
+			if params.Has("parentID") && asset.ParentID != params.Get("parentID") {
+				continue
+			}
+			assets = append(assets, asset)
+		}
+		response["assets"] = assets
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func handleGetHierarchy(w http.ResponseWriter, userID, level, id string) {
+	h, err := db.LoadHierarchy(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	item, ok := hierarchyItem(h, level, id)
+	if !ok {
+		writeHierarchyError(w, db.ErrNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+// handleSaveHierarchy creates an organisation, site or asset when id is empty, or changes the fields of the body
+func handleSaveHierarchy(w http.ResponseWriter, r *http.Request, userID, level, id string) {
+	h, err := db.LoadHierarchy(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var item interface{}
+	switch level {
+	case "orgs":
+		org := &schema.Organisation{}
+		if id != "" {
+			current, ok := h.Organisation(id)
+			if !ok {
+				writeHierarchyError(w, db.ErrNotFound)
+				return
+			}
+			*org = current
+		}
+		item = org
+	case "sites":
+		site := &schema.Site{}
+		if id != "" {
+			current, ok := h.Site(id)
+			if !ok {
+				writeHierarchyError(w, db.ErrNotFound)
+				return
+			}
+			*site = current
+		}
+		item = site
+	case "assets":
+		asset := &schema.Asset{}
+		if id != "" {
+			current, ok := h.Asset(id)
+			if !ok {
+				writeHierarchyError(w, db.ErrNotFound)
+				return
+			}
+			*asset = current
+		}
+		item = asset
+	}
+	// Fields missing from the body keep their current value
+	if err := json.NewDecoder(r.Body).Decode(item); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var saved interface{}
+	switch item := item.(type) {
+	case *schema.Organisation:
+		item.ID, item.UserID = id, userID
+		if id == "" {
+			saved, err = db.CreateOrganisation(*item)
+		} else {
+			saved, err = db.UpdateOrganisation(*item)
+		}
+	case *schema.Site:
+		item.ID, item.UserID = id, userID
+		if id == "" {
+			saved, err = db.CreateSite(*item)
+		} else {
+			saved, err = db.UpdateSite(*item)
+		}
+	case *schema.Asset:
+		item.ID, item.UserID = id, userID
+		if id == "" {
+			saved, err = db.CreateAsset(*item)
+		} else {
+			saved, err = db.UpdateAsset(*item)
+		}
+	}
+	if err != nil {
+		writeHierarchyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if id == "" {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(saved)
+}
+
+func handleDeleteHierarchy(w http.ResponseWriter, userID, level, id string) {
+	var err error
+	switch level {
+	case "orgs":
+		err = db.DeleteOrganisation(userID, id)
+	case "sites":
+		err = db.DeleteSite(userID, id)
+	case "assets":
+		err = db.DeleteAsset(userID, id)
+	}
+	if err != nil {
+		writeHierarchyError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Deleted"})
+}
+
+// handleAggregateHierarchy aggregates the telemetry of every device under an organisation, site or asset
+// together, optionally only the devices under an asset of a kind
+func handleAggregateHierarchy(w http.ResponseWriter, r *http.Request, userID, level, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	query, ok := aggregateQuery(w, params)
+	if !ok {
+		return
+	}
+
+	h, err := db.LoadHierarchy(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, ok := hierarchyItem(h, level, id); !ok {
+		writeHierarchyError(w, db.ErrNotFound)
+		return
+	}
+	filter := db.HierarchyFilter{Kind: params.Get("kind")}
+	switch level {
+	case "orgs":
+		filter.OrgID = id
+	case "sites":
+		filter.SiteID = id
+	case "assets":
+		filter.AssetID = id
+	}
+	if query.DeviceIDs, err = db.HierarchyDevices(userID, filter); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	buckets := []db.TelemetryBucket{}
+	if len(query.DeviceIDs) > 0 {
+		if buckets, err = db.AggregateTelemetry(query); err != nil {
+			writeQueryError(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deviceIDs": query.DeviceIDs,
+		"interval":  params.Get("interval"),
+		"buckets":   buckets,
+	})
+}
+
+// hierarchyItem finds the organisation, site or asset of the level in the hierarchy
+func hierarchyItem(h *db.Hierarchy, level, id string) (interface{}, bool) {
+	switch level {
+	case "orgs":
+		return h.Organisation(id)
+	case "sites":
+		return h.Site(id)
+	case "assets":
+		return h.Asset(id)
+	}
+	return nil, false
+}
+
+// hierarchyFilter reads the orgID, siteID, assetID and kind a device list is filtered by
+func hierarchyFilter(get func(key string) string) db.HierarchyFilter {
+	return db.HierarchyFilter{
+		OrgID:   get("orgID"),
+		SiteID:  get("siteID"),
+		AssetID: get("assetID"),
+		Kind:    get("kind"),
+	}
+}
+
+func writeHierarchyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, db.ErrHierarchyInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, db.ErrHierarchyName), errors.Is(err, db.ErrHierarchyParent), errors.Is(err, db.ErrAssetCycle):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
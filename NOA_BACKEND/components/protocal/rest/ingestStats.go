@@ -0,0 +1,33 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"GOLANG_SERVER/components/ingest"
+)
+
+// HandleIngestStats returns the delivered, dropped and queued counters of the ingest bus subscribers.
+// The counters are for the whole server, so only the users in ADMIN_USER_IDS read them.
+//
+//	GET /ingest/stats
+func HandleIngestStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := tokenUser(w, r, "")
+	if !ok {
+		return
+	}
+	if !isAdmin(userID) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ingest.GetStats()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,82 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/protocal/mosquitto"
+	schema "GOLANG_SERVER/components/schema"
+	"GOLANG_SERVER/components/severity"
+)
+
+// MachineClassRequest is the body of PUT /device/{deviceID}/machineClass
+type MachineClassRequest struct {
+	Group      int    `json:"group"`      // 1 above 300 kW, 2 from 15 to 300 kW
+	Foundation string `json:"foundation"` // rigid or flexible
+}
+
+// HandleMachineClass reads or sets the ISO 10816-3 machine class of a device of ownerID
+//
+//	GET /device/{deviceID}/machineClass
+//	PUT /device/{deviceID}/machineClass
+func HandleMachineClass(w http.ResponseWriter, r *http.Request, ownerID, deviceID string) {
+	var class schema.MachineClass
+	switch r.Method {
+	case http.MethodGet:
+		device, err := db.FindDevice(deviceID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		class = device.MachineClass
+	case http.MethodPut:
+		var req MachineClassRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		class = schema.MachineClass{Group: req.Group, Foundation: req.Foundation}
+		if err := severity.Validate(class); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := db.UpdateMachineClass(ownerID, deviceID, class); err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeAccessError(w, db.ErrNotDeviceOwner)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Classify the next message with the new class
+		mosquitto.ForgetDevice(deviceID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Devices never configured are classified with the default class
+	configured := severity.Validate(class) == nil
+	if !configured {
+		class = severity.DefaultClass
+	}
+	limits := severity.Limits(class)
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"deviceID":     deviceID,
+		"machineClass": class,
+		"configured":   configured,
+		"limits": map[string]float64{
+			"AB": limits[0],
+			"BC": limits[1],
+			"CD": limits[2],
+		},
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
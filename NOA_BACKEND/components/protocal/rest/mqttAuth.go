@@ -0,0 +1,145 @@
+package rest
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"GOLANG_SERVER/components/env"
+	"GOLANG_SERVER/components/protocal/mosquitto"
+)
+
+// mqttAuthResponse is understood by both mosquitto-go-auth (ok, error) and EMQX (result, is_superuser).
+// mosquitto-go-auth reads the status, 200 when allowed and 403 when denied. EMQX reads the result and
+// ignores any answer but 200, so it always gets 200.
+type mqttAuthResponse struct {
+	Ok          bool   `json:"ok"`
+	Error       string `json:"error"`
+	Result      string `json:"result"`
+	IsSuperuser bool   `json:"is_superuser"`
+}
+
+// HandleMQTTAuth is the HTTP auth backend of the broker
+//
+//	POST /mqtt/auth/user      {"username", "password", "clientid"}
+//	POST /mqtt/auth/superuser {"username"}
+//	POST /mqtt/auth/acl       {"username", "clientid", "topic", "acc": 1|2|3|4} or {"action": "publish"|"subscribe"}
+//
+// EMQX adds ?broker=emqx to the URLs, an ACL check with an action is always EMQX.
+func HandleMQTTAuth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	secret := env.GetEnv("MQTT_AUTH_TOKEN")
+	if secret == "" {
+		// Anyone able to reach the server could otherwise ask it about any client
+		log.Println("[MQTT] MQTT_AUTH_TOKEN is not set, broker auth requests are refused")
+		http.Error(w, "Broker auth is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if !brokerRequest(r, secret) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	params, err := mqttAuthParams(r)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	username := params["username"]
+	emqx := r.URL.Query().Get("broker") == "emqx" || params["action"] != ""
+
+	var allowed bool
+	switch strings.Trim(strings.TrimPrefix(r.URL.Path, "/mqtt/auth"), "/") {
+	case "user":
+		allowed, err = mosquitto.AuthenticateClient(username, params["password"])
+	case "superuser":
+		allowed = mosquitto.IsSuperuser(username)
+	case "acl":
+		access, ok := mqttAccess(params)
+		if !ok {
+			http.Error(w, errInvalidParam("acc").Error(), http.StatusBadRequest)
+			return
+		}
+		allowed, err = mosquitto.CanAccess(username, params["topic"], access)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	response := mqttAuthResponse{Ok: allowed, Result: "allow", IsSuperuser: mosquitto.IsSuperuser(username)}
+	status := http.StatusOK
+	if err != nil {
+		log.Println("[MQTT] Auth check failed:", err)
+		response = mqttAuthResponse{Error: "internal error", Result: "deny"}
+		status = http.StatusInternalServerError
+	} else if !allowed {
+		response.Result = "deny"
+		status = http.StatusForbidden
+	}
+	// Another status would make EMQX ignore the answer and fall through to its next source
+	if emqx {
+		status = http.StatusOK
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// brokerRequest checks the MQTT_AUTH_TOKEN shared with the broker, as a bearer token or the token
+// query parameter
+func brokerRequest(r *http.Request, secret string) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+// mqttAuthParams reads the parameters of the broker as JSON or as a form
+func mqttAuthParams(r *http.Request) (map[string]string, error) {
+	params := make(map[string]string)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		// acc is a number
+		for key, value := range body {
+			if value != nil {
+				params[strings.ToLower(key)] = fmt.Sprint(value)
+			}
+		}
+		return params, nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	for key := range r.Form {
+		params[strings.ToLower(key)] = r.Form.Get(key)
+	}
+	return params, nil
+}
+
+// mqttAccess is the access of an ACL check, acc of mosquitto-go-auth or action of EMQX
+func mqttAccess(params map[string]string) (int, bool) {
+	switch params["action"] {
+	case "publish":
+		return mosquitto.AccessWrite, true
+	case "subscribe":
+		return mosquitto.AccessSubscribe, true
+	}
+	access, err := strconv.Atoi(params["acc"])
+	if err != nil || access < mosquitto.AccessRead || access > mosquitto.AccessSubscribe {
+		return 0, false
+	}
+	return access, true
+}
@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMQTTAuth(t *testing.T) {
+	t.Setenv("MQTT_USERNAME", "server")
+
+	tests := []struct {
+		name, token, path, body string
+		want                    int
+		result                  string
+	}{
+		{"no token configured", "", "/mqtt/auth/superuser", `{"username":"server"}`, http.StatusServiceUnavailable, ""},
+		{"wrong token", "nope", "/mqtt/auth/superuser?token=other", `{"username":"server"}`, http.StatusUnauthorized, ""},
+		{"mosquitto allowed", "secret", "/mqtt/auth/superuser?token=secret", `{"username":"server"}`, http.StatusOK, "allow"},
+		{"mosquitto denied", "secret", "/mqtt/auth/superuser?token=secret", `{"username":"deviceA"}`, http.StatusForbidden, "deny"},
+		{"emqx denied", "secret", "/mqtt/auth/superuser?broker=emqx&token=secret", `{"username":"deviceA"}`, http.StatusOK, "deny"},
+		{"emqx acl denied", "secret", "/mqtt/auth/acl?token=secret", `{"username":"deviceA","topic":"noa/userB/deviceB/telemetry","action":"publish"}`, http.StatusOK, "deny"},
+		{"mosquitto acl denied", "secret", "/mqtt/auth/acl?token=secret", `{"username":"deviceA","topic":"noa/userB/deviceB/telemetry","acc":2}`, http.StatusForbidden, "deny"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MQTT_AUTH_TOKEN", tt.token)
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			HandleMQTTAuth(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.result == "" {
+				return
+			}
+			var response mqttAuthResponse
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Result != tt.result {
+				t.Fatalf("result = %q, want %q", response.Result, tt.result)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"GOLANG_SERVER/components/db"
+)
+
+// ReadNotificationsRequest is the body of POST /notifications/read
+type ReadNotificationsRequest struct {
+	UserID          string   `json:"userID"`          // Optional, must be the user of the token
+	NotificationIDs []string `json:"notificationIDs"` // Every unread notification when empty
+}
+
+// HandleNotifications lists the notifications of a user, newest first
+//
+//	GET /notifications?unread=true&limit=&pageToken=
+func HandleNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	userID, ok := tokenUser(w, r, params.Get("userID"))
+	if !ok {
+		return
+	}
+
+	query := db.NotificationQuery{Unread: params.Get("unread") == "true"}
+	if value := params.Get("limit"); value != "" {
+		var err error
+		if query.Limit, err = strconv.ParseInt(value, 10, 64); err != nil || query.Limit <= 0 {
+			http.Error(w, errInvalidParam("limit").Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	cursor, err := db.DecodeNotificationToken(params.Get("pageToken"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.Cursor = cursor
+
+	w.Header().Set("Content-Type", "application/json")
+
+	page, err := db.Notifications(userID, query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleReadNotifications marks notifications of a user read
+//
+//	POST /notifications/read
+func HandleReadNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req ReadNotificationsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID, ok := tokenUser(w, r, req.UserID)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	updated, err := db.MarkNotificationsRead(userID, req.NotificationIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	unread, err := db.CountUnreadNotifications(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := map[string]interface{}{
+		"updated": updated,
+		"unread":  unread,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
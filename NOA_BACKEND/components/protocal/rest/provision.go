@@ -0,0 +1,200 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/env"
+	"GOLANG_SERVER/components/protocal/mosquitto"
+	"GOLANG_SERVER/components/provision"
+)
+
+// HandleProvisionRoute issues pairing codes for new devices of the user and follows them
+//
+//	POST /device/provision {"deviceName"}
+//	GET  /device/provision/{provisionID}
+func HandleProvisionRoute(w http.ResponseWriter, r *http.Request) {
+	userID, ok := tokenUser(w, r, "")
+	if !ok {
+		return
+	}
+
+	provisionID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/device/provision"), "/")
+	switch {
+	case strings.Contains(provisionID, "/"):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case provisionID == "" && r.Method == http.MethodPost:
+		handleStartProvision(w, r, userID)
+	case provisionID != "" && r.Method == http.MethodGet:
+		handleGetProvision(w, userID, provisionID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleStartProvision(w http.ResponseWriter, r *http.Request, userID string) {
+	var body map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	// Handle both lowercase and uppercase keys
+	deviceName := body["deviceName"]
+	if deviceName == "" {
+		deviceName = body["DeviceName"]
+	}
+
+	provisioning, code, err := provision.Start(userID, deviceName)
+	if errors.Is(err, provision.ErrDeviceName) {
+		http.Error(w, "Device name is required", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The QR code shown to the device carries the code and where to claim it
+	qr, _ := json.Marshal(map[string]string{
+		"code":   code,
+		"server": env.GetEnv("PROVISION_SERVER_URL"),
+	})
+	response := map[string]interface{}{
+		"provisionID": provisioning.ID,
+		"deviceName":  provisioning.DeviceName,
+		"pairingCode": code,
+		"qrPayload":   string(qr),
+		"expireAt":    provisioning.ExpireAt,
+		"expiresIn":   int(provision.TTL.Seconds()),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetProvision(w http.ResponseWriter, userID, provisionID string) {
+	provisioning, err := db.FindProvisioning(userID, provisionID)
+	if err == db.ErrNotFound {
+		http.Error(w, "Pairing not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"provisionID": provisioning.ID,
+		"deviceName":  provisioning.DeviceName,
+		"status":      provision.Status(provisioning),
+		"deviceID":    provisioning.DeviceID,
+		"expireAt":    provisioning.ExpireAt,
+		"claimedAt":   provisioning.ClaimedAt,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// Claims allowed per client IP in each window, far more than pairing needs and far too few to guess codes
+const (
+	claimLimit  = 10
+	claimWindow = time.Minute
+)
+
+// claimAttempts counts the claims of each client IP in the current window
+var claimAttempts = struct {
+	sync.Mutex
+	start  time.Time
+	counts map[string]int
+}{counts: map[string]int{}}
+
+// allowClaim counts a claim from the IP and returns how long to wait when over claimLimit
+func allowClaim(ip string, now time.Time) (time.Duration, bool) {
+	claimAttempts.Lock()
+	defer claimAttempts.Unlock()
+
+	if now.Sub(claimAttempts.start) >= claimWindow {
+		claimAttempts.start = now
+		claimAttempts.counts = map[string]int{}
+	}
+	if claimAttempts.counts[ip] >= claimLimit {
+		return claimAttempts.start.Add(claimWindow).Sub(now), false
+	}
+	claimAttempts.counts[ip]++
+	return 0, true
+}
+
+// HandleClaimDevice is called by a new device with the pairing code the user was given, it returns the
+// deviceID and password of the device once
+//
+//	POST /device/claim {"pairingCode"}
+func HandleClaimDevice(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if wait, ok := allowClaim(ip, startTime); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds()+0.999)))
+		http.Error(w, "Too many pairing attempts", http.StatusTooManyRequests)
+		return
+	}
+
+	var body map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	// Handle both lowercase and uppercase keys, code is the key of the QR payload
+	code := body["pairingCode"]
+	if code == "" {
+		code = body["PairingCode"]
+	}
+	if code == "" {
+		code = body["code"]
+	}
+
+	credentials, err := provision.Claim(code)
+	if err == db.ErrInvalidPairingCode {
+		http.Error(w, "Invalid or expired pairing code", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Device %s paired to user %s in %s\n", credentials.DeviceID, credentials.UserID, time.Since(startTime))
+
+	response := map[string]interface{}{
+		"deviceID":   credentials.DeviceID,
+		"userID":     credentials.UserID,
+		"deviceName": credentials.DeviceName,
+		"password":   credentials.Password,
+		"topics":     mosquitto.Topics(credentials.UserID, credentials.DeviceID),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,75 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/provision"
+)
+
+func TestClaimDevice(t *testing.T) {
+	newTenants(t)
+	claim := func(ip, code string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/device/claim", strings.NewReader(`{"pairingCode":"`+code+`"}`))
+		req.RemoteAddr = ip + ":5000"
+		rec := httptest.NewRecorder()
+		HandleClaimDevice(rec, req)
+		return rec
+	}
+	devices := func() int {
+		list, err := db.GetDeviceAddress("userA")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(list)
+	}
+	before := devices()
+
+	// A wrong code makes no device
+	if rec := claim("192.0.2.1", "AAAA-AAAA"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong code: %d %s", rec.Code, rec.Body.String())
+	}
+	if devices() != before {
+		t.Fatal("device created for a wrong code")
+	}
+
+	// A valid code pairs once and the pairing shows the device
+	provisioning, code, err := provision.Start("userA", "sensor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := claim("192.0.2.2", code)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("claim: %d %s", rec.Code, rec.Body.String())
+	}
+	var credentials provision.Credentials
+	if err := json.NewDecoder(rec.Body).Decode(&credentials); err != nil || credentials.DeviceID == "" || credentials.Password == "" {
+		t.Fatalf("credentials: %+v %v", credentials, err)
+	}
+	if rec := claim("192.0.2.2", code); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("code claimed twice: %d", rec.Code)
+	}
+	if devices() != before+1 {
+		t.Fatal("claimed device not registered")
+	}
+	provisioning, err = db.FindProvisioning("userA", provisioning.ID)
+	if err != nil || provisioning.DeviceID != credentials.DeviceID || provision.Status(provisioning) != "claimed" {
+		t.Fatalf("pairing after the claim: %+v %v", provisioning, err)
+	}
+
+	// Guessing is throttled per client IP
+	for i := 1; i < claimLimit; i++ {
+		claim("192.0.2.1", "AAAA-AAAA")
+	}
+	rec = claim("192.0.2.1", "AAAA-AAAA")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("claim over the limit: %d %v", rec.Code, rec.Header())
+	}
+	if rec := claim("192.0.2.3", "AAAA-AAAA"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("other IP throttled: %d", rec.Code)
+	}
+}
@@ -2,20 +2,13 @@ package rest
 
 import (
 	"encoding/json"
-<<<<<<< HEAD
-	"fmt"
-=======
->>>>>>> Final_BN
 	"log"
 	"net/http"
 	"time"
 
 	"GOLANG_SERVER/components/db"
-<<<<<<< HEAD
-=======
 
 	"golang.org/x/crypto/bcrypt"
->>>>>>> Final_BN
 )
 
 func HandleRegisterDevice(w http.ResponseWriter, r *http.Request) {
@@ -28,24 +21,6 @@ func HandleRegisterDevice(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json") // Set the content type to JSON
 
 	// Get the device address from the json request
-<<<<<<< HEAD
-	deviceAddress := r.URL.Query().Get("deviceAddress")
-	if deviceAddress == "" {
-		http.Error(w, "Device address not found", http.StatusBadRequest)
-		return
-	}
-
-	// display device address
-	log.Println("Device Address:", deviceAddress)
-
-	// store device address to database
-	if _, err := db.RegisterDevice(deviceAddress); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	// send device address .json to client
-	response := map[string]string{"deviceAddress": deviceAddress}
-=======
 	var userDetail map[string]string
 	if err := json.NewDecoder(r.Body).Decode(&userDetail); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -62,9 +37,9 @@ func HandleRegisterDevice(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Device ID is required", http.StatusBadRequest)
 		return
 	}
-	userID := userDetail["userID"]
-	if userID == "" {
-		http.Error(w, "User ID is required", http.StatusBadRequest)
+	// Devices are registered to the user of the token
+	userID, ok := tokenUser(w, r, userDetail["userID"])
+	if !ok {
 		return
 	}
 	devicePassword := userDetail["password"]
@@ -109,33 +84,25 @@ func HandleRegisterDevice(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("UserID: %s\n", user.ID)
 
+	// Save the deviceDetail to the database before answering, a failed save is then still a 500
+	if err := db.SaveDevice(deviceName, deviceID, userID, devicePassword); err != nil {
+		http.Error(w, "Error saving device details", http.StatusInternalServerError)
+		return
+	}
+	log.Println("Device details saved successfully")
+
 	response := map[string]string{
 		"message":  "Device created successfully",
 		"deviceID": deviceID,
 	}
 
 	w.WriteHeader(http.StatusOK)
->>>>>>> Final_BN
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-<<<<<<< HEAD
-	fmt.Fprintf(w, `{"message": "Device registered!"}`)
-	// Calculate the elapsed time
-	elapsedTime := time.Since(startTime)
-	log.Printf("User Loging time for  %s\n", elapsedTime)
-=======
-	// Save the deviceDetail to the database
-	if err := db.SaveDevice(deviceName, deviceID, userID, devicePassword); err != nil {
-		http.Error(w, "Error saving device details", http.StatusInternalServerError)
+		log.Println("Error writing the response:", err) // The status is already sent
 		return
 	}
-	log.Println("Device details saved successfully")
 
 	// Time out
 	elapsedTime := time.Since(startTime)
 	log.Printf("Device Authentication time for  %s\n", elapsedTime)
->>>>>>> Final_BN
 }
@@ -0,0 +1,90 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"GOLANG_SERVER/components/db"
+	schema "GOLANG_SERVER/components/schema"
+	"GOLANG_SERVER/components/sensitive"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestTransferredDeviceAuthenticates(t *testing.T) {
+	ts := newTenants(t)
+	ts.mux.Handle("/invitations", sensitive.AuthMiddleware(http.HandlerFunc(HandleInvitations)))
+	ts.mux.Handle("/invitations/", sensitive.AuthMiddleware(http.HandlerFunc(HandleInvitations)))
+	ts.mux.HandleFunc("/authendevice", sensitive.AuthenDevice)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveDevice("machine", "deviceT", "userA", string(hash)); err != nil {
+		t.Fatal(err)
+	}
+
+	// authenticate is what the firmware does, with the email of the owner it was set up with
+	authenticate := func(password string) (int, map[string]interface{}) {
+		rec := ts.do(t, "", http.MethodPost, "/authendevice", `{"deviceID":"deviceT","password":"`+password+`","email":"a@example.com"}`)
+		var body map[string]interface{}
+		json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body
+	}
+	if code, body := authenticate("secret"); code != http.StatusOK || body["userID"] != "userA" {
+		t.Fatalf("before the transfer: %d %v", code, body)
+	}
+
+	if rec := ts.do(t, ts.tokenA, http.MethodPost, "/device/deviceT/transfer", `{"email":"b@example.com"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("transfer: %d %s", rec.Code, rec.Body.String())
+	}
+	var listed struct{ Invitations []schema.DeviceShare }
+	rec := ts.do(t, ts.tokenB, http.MethodGet, "/invitations", "")
+	if err := json.NewDecoder(rec.Body).Decode(&listed); err != nil || len(listed.Invitations) != 1 {
+		t.Fatalf("invitations: %d %v %v", rec.Code, listed, err)
+	}
+	if rec := ts.do(t, ts.tokenB, http.MethodPost, "/invitations/"+listed.Invitations[0].ID+"/accept", ""); rec.Code != http.StatusOK {
+		t.Fatalf("accept: %d %s", rec.Code, rec.Body.String())
+	}
+
+	device, err := db.FindDevice("deviceT")
+	if err != nil || device.UserID != "userB" {
+		t.Fatalf("device after the transfer: %+v %v", device, err)
+	}
+	if shares, _ := db.DeviceShares("deviceT"); len(shares) != 0 {
+		t.Fatalf("accepted transfer kept as a share: %v", shares)
+	}
+
+	// The device still sends the email of user A and gets the topics of user B
+	code, body := authenticate("secret")
+	if code != http.StatusOK || body["userID"] != "userB" {
+		t.Fatalf("after the transfer: %d %v", code, body)
+	}
+	topics, _ := body["topics"].(map[string]interface{})
+	if topics["telemetry"] != "noa/userB/deviceT/telemetry" {
+		t.Fatalf("topics after the transfer: %v", topics)
+	}
+	if code, _ := authenticate("wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong password: %d", code)
+	}
+
+	// User A lost access, even with an accepted transfer left behind as a share
+	leftover := schema.DeviceShare{ID: "leftover", DeviceID: "deviceT", OwnerID: "userA", UserID: "userA", Role: db.RoleOwner, Status: db.ShareAccepted}
+	if err := db.GetStore().InsertShare(leftover); err != nil {
+		t.Fatal(err)
+	}
+	if rec := ts.do(t, ts.tokenA, http.MethodGet, "/device/deviceT/telemetry", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("previous owner: %d %s", rec.Code, rec.Body.String())
+	}
+	devices, err := db.GetDeviceAddress("userA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range devices {
+		if d.DeviceID == "deviceT" {
+			t.Fatal("transferred device listed for the previous owner")
+		}
+	}
+}
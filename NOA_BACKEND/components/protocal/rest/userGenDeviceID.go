@@ -2,12 +2,9 @@ package rest
 
 import (
 	"encoding/json"
-	"math/rand"
 	"net/http"
-	"strconv"
-	"time"
 
-	"GOLANG_SERVER/components/db" // Import the db package
+	"GOLANG_SERVER/components/provision"
 )
 
 func HandleGenerateDeviceID(w http.ResponseWriter, r *http.Request) {
@@ -17,22 +14,11 @@ func HandleGenerateDeviceID(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Header().Set("Content-Type", "application/json") // Set the content type to JSON
 
-	var deviceID string
-	for {
-		// Generate a new device ID
-		currentYear := time.Now().Year()                                                // Get the current year
-		randomNumber := rand.New(rand.NewSource(time.Now().UnixNano())).Intn(100000000) // Generate a random 8-digit number
-		deviceID = strconv.Itoa(currentYear) + strconv.Itoa(randomNumber)
-
-		// Check if the DeviceID already exists in the database
-		exists, err := db.HandlercheckDeviceID(deviceID)
-		if err != nil {
-			http.Error(w, "Error checking device ID in database", http.StatusInternalServerError)
-			return
-		}
-		if !exists {
-			break // If the DeviceID does not exist, exit the loop
-		}
+	// Crypto random and checked against the registered devices
+	deviceID, err := provision.NewDeviceID()
+	if err != nil {
+		http.Error(w, "Error checking device ID in database", http.StatusInternalServerError)
+		return
 	}
 
 	// Create a response
@@ -0,0 +1,252 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"GOLANG_SERVER/components/db"
+	schema "GOLANG_SERVER/components/schema"
+	"GOLANG_SERVER/components/webhook"
+)
+
+// HandleWebhookRoute dispatches the webhook CRUD, delivery log and test delivery
+//
+//	GET    /webhooks
+//	POST   /webhooks
+//	GET    /webhooks/{webhookID}
+//	PUT    /webhooks/{webhookID}
+//	DELETE /webhooks/{webhookID}
+//	GET    /webhooks/{webhookID}/deliveries?limit=
+//	POST   /webhooks/{webhookID}/test
+func HandleWebhookRoute(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/webhooks"), "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "":
+		switch r.Method {
+		case http.MethodGet:
+			handleListWebhooks(w, r)
+		case http.MethodPost:
+			handleSaveWebhook(w, r, "")
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 1:
+		switch r.Method {
+		case http.MethodGet:
+			handleGetWebhook(w, r, parts[0])
+		case http.MethodPut:
+			handleSaveWebhook(w, r, parts[0])
+		case http.MethodDelete:
+			handleDeleteWebhook(w, r, parts[0])
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 2 && parts[1] == "deliveries":
+		handleWebhookDeliveries(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "test":
+		handleTestWebhook(w, r, parts[0])
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := tokenUser(w, r, r.URL.Query().Get("userID"))
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	webhooks, err := db.Webhooks(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The secret is only shown when it is created
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"webhooks": webhooks}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleGetWebhook(w http.ResponseWriter, r *http.Request, webhookID string) {
+	hook, ok := findWebhook(w, r, r.URL.Query().Get("userID"), webhookID)
+	if !ok {
+		return
+	}
+	hook.Secret = ""
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(hook); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSaveWebhook creates a webhook when webhookID is empty, or replaces the webhook
+func handleSaveWebhook(w http.ResponseWriter, r *http.Request, webhookID string) {
+	var hook schema.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID, ok := tokenUser(w, r, hook.UserID)
+	if !ok {
+		return
+	}
+	hook.UserID = userID
+	if hook.DeviceID != "" {
+		if err := checkDeviceAccess(hook.UserID, hook.DeviceID, db.RoleViewer); err != nil {
+			writeAccessError(w, err)
+			return
+		}
+	}
+
+	if err := webhook.Validate(&hook); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	status := http.StatusCreated
+	if webhookID == "" {
+		// The secret is only shown in this response unless the client chose it
+		if hook.Secret == "" {
+			if hook.Secret, err = webhook.NewSecret(); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		hook, err = db.CreateWebhook(hook)
+	} else {
+		// Without a secret the update keeps the current one
+		secretGiven := hook.Secret != ""
+		hook.ID = webhookID
+		hook, err = db.UpdateWebhook(hook)
+		if !secretGiven {
+			hook.Secret = ""
+		}
+		status = http.StatusOK
+	}
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	// Devices of a new prediction webhook are predicted from the next message
+	webhook.Reload()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(hook); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleDeleteWebhook(w http.ResponseWriter, r *http.Request, webhookID string) {
+	userID, ok := tokenUser(w, r, r.URL.Query().Get("userID"))
+	if !ok {
+		return
+	}
+	if err := db.DeleteWebhook(userID, webhookID); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	webhook.Reload()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Webhook deleted"})
+}
+
+func handleWebhookDeliveries(w http.ResponseWriter, r *http.Request, webhookID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	if _, ok := findWebhook(w, r, params.Get("userID"), webhookID); !ok {
+		return
+	}
+
+	var limit int64
+	if value := params.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.ParseInt(value, 10, 64); err != nil || limit <= 0 {
+			http.Error(w, errInvalidParam("limit").Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	deliveries, err := db.WebhookDeliveries(webhookID, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"deliveries": deliveries}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func handleTestWebhook(w http.ResponseWriter, r *http.Request, webhookID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// The body is optional
+	var req struct {
+		UserID string `json:"userID"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	hook, ok := findWebhook(w, r, req.UserID, webhookID)
+	if !ok {
+		return
+	}
+
+	delivery, err := webhook.Test(hook)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(delivery); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// findWebhook reads a webhook of the token's user and writes the error response when there is none
+func findWebhook(w http.ResponseWriter, r *http.Request, claimed, webhookID string) (schema.Webhook, bool) {
+	userID, ok := tokenUser(w, r, claimed)
+	if !ok {
+		return schema.Webhook{}, false
+	}
+	hook, err := db.Webhook(userID, webhookID)
+	if err != nil {
+		writeWebhookError(w, err)
+		return schema.Webhook{}, false
+	}
+	return hook, true
+}
+
+func writeWebhookError(w http.ResponseWriter, err error) {
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
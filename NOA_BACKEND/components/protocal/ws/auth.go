@@ -0,0 +1,45 @@
+package ws
+
+import (
+	"net/http"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/sensitive"
+)
+
+// socketUser returns the user of the access token before the upgrade. A userID query parameter is
+// still accepted from older clients, only when it is the same user.
+func socketUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID := sensitive.UserID(r)
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", false
+	}
+	if claimed := r.URL.Query().Get("userID"); claimed != "" && claimed != userID {
+		http.Error(w, "userID does not match the token", http.StatusForbidden)
+		return "", false
+	}
+	return userID, true
+}
+
+// socketDevice returns the user and the deviceID query parameter, the user needs a role on the device
+func socketDevice(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	userID, ok := socketUser(w, r)
+	if !ok {
+		return "", "", false
+	}
+	deviceID := r.URL.Query().Get("deviceID")
+	if deviceID == "" {
+		http.Error(w, "Missing deviceID", http.StatusBadRequest)
+		return "", "", false
+	}
+	if err := db.CheckDeviceAccess(userID, deviceID, db.RoleViewer); err != nil {
+		if err == db.ErrNotDeviceOwner {
+			http.Error(w, "Device not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return "", "", false
+	}
+	return userID, deviceID, true
+}
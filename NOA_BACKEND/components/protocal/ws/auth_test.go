@@ -0,0 +1,67 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"GOLANG_SERVER/components/db"
+	schema "GOLANG_SERVER/components/schema"
+	"GOLANG_SERVER/components/sensitive"
+)
+
+func TestSocketDeviceCrossTenant(t *testing.T) {
+	os.Setenv("DB_STORE", "memory")
+	defer os.Unsetenv("DB_STORE")
+	if _, err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []schema.User{{ID: "userA", Username: "a", Email: "a@example.com"}, {ID: "userB", Username: "b", Email: "b@example.com"}} {
+		if err := db.GetStore().InsertUser(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.SaveDevice("machine", "deviceA", "userA", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := sensitive.StartSession(schema.User{ID: "userB", Username: "b"}, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handlers := map[string]http.HandlerFunc{
+		"/ws/boadcast":   HandleWebSocketBoadcast,
+		"/ws/prediction": HandleWebSocketPredict,
+	}
+	tests := []struct {
+		name, query string
+		want        int
+	}{
+		{"device of another user", "?deviceID=deviceA&token=" + tokens.AccessToken, http.StatusNotFound},
+		{"claimed userID of the owner", "?deviceID=deviceA&userID=userA&token=" + tokens.AccessToken, http.StatusForbidden},
+		{"unknown device", "?deviceID=nope&token=" + tokens.AccessToken, http.StatusNotFound},
+		{"no token", "?deviceID=deviceA", http.StatusUnauthorized},
+	}
+	for path, handler := range handlers {
+		for _, tt := range tests {
+			t.Run(path+" "+tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, path+tt.query, nil)
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "websocket")
+				rec := httptest.NewRecorder()
+				sensitive.AuthMiddleware(handler).ServeHTTP(rec, req)
+				if rec.Code != tt.want {
+					t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+				}
+			})
+		}
+	}
+
+	predictClients.Lock()
+	watching := len(predictClients.connections["deviceA"])
+	predictClients.Unlock()
+	if watching != 0 {
+		t.Fatalf("%d prediction sockets registered on the device of user A", watching)
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 
-	"GOLANG_SERVER/components/env"
-	"GOLANG_SERVER/components/schema"
+	"GOLANG_SERVER/components/alert"
+	"GOLANG_SERVER/components/ingest"
+	"GOLANG_SERVER/components/notification"
+	"GOLANG_SERVER/components/presence"
 
-	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/gorilla/websocket"
 )
 
@@ -37,16 +39,9 @@ var connectionRegistry = struct {
 
 // WebSocket handler for multiple userIDs and deviceIDs
 func HandleWebSocketBoadcast(w http.ResponseWriter, r *http.Request) {
-	// Extract userID and deviceID from query parameters
-	userID := r.URL.Query().Get("userID")
-	if userID == "" {
-		http.Error(w, "Missing userID", http.StatusBadRequest)
-		return
-	}
-
-	deviceID := r.URL.Query().Get("deviceID")
-	if deviceID == "" {
-		http.Error(w, "Missing deviceID", http.StatusBadRequest)
+	// The user comes from the token and needs a role on the deviceID of the query
+	userID, deviceID, ok := socketDevice(w, r)
+	if !ok {
 		return
 	}
 
@@ -121,73 +116,93 @@ func sendMessageToUser(clientKey string, data []byte) {
 	}
 }
 
-// SubscribeMQTTTopicForClient subscribes to MQTT for a specific client
-func SubscribeMQTTTopicForClient(clientKey string, dataChan chan<- []byte) {
-	// MQTT topic
-	opts := mqtt.NewClientOptions().AddBroker(env.GetEnv("MQTT_BROKER"))
-	opts.SetClientID(env.GetEnv("MQTT_CLIENT_ID") + "_" + clientKey) // Unique client ID for each subscription
-	opts.SetUsername(env.GetEnv("MQTT_USERNAME"))
-	opts.SetPassword(env.GetEnv("MQTT_PASSWORD"))
-	client := mqtt.NewClient(opts)
-
-	// Connect to the MQTT broker
-	if token := client.Connect(); token.Wait() && token.Error() != nil {
-		log.Printf("Error connecting to MQTT broker for clientKey=%s: %v\n", clientKey, token.Error())
-		return
-	}
+// sendMessageToDevice sends the message to the broadcast client of every user watching the device
+func sendMessageToDevice(deviceID string, data []byte) {
+	clients.Lock()
+	defer clients.Unlock()
 
-	// Subscribe to the topic
-	if token := client.Subscribe("vibration", 1, func(client mqtt.Client, msg mqtt.Message) {
-		// Parse the incoming message
-		var payload schema.Data
-		if err := json.Unmarshal(msg.Payload(), &payload); err != nil {
-			log.Printf("Error unmarshaling MQTT message for clientKey=%s: %v\n", clientKey, err)
-			return
+	for clientKey, conn := range clients.connections {
+		if !strings.HasSuffix(clientKey, ":"+deviceID) {
+			continue
 		}
-
-		// Check if the message is for this client
-		if clientKey == payload.UserID+":"+payload.DeviceID {
-			dataChan <- msg.Payload() // Send the message to the client's channel
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			log.Printf("Error writing message to WebSocket client (key=%s): %v\n", clientKey, err)
+			conn.Close()
+			delete(clients.connections, clientKey)
 		}
-	}); token.Wait() && token.Error() != nil {
-		log.Printf("Error subscribing to topic for clientKey=%s: %v\n", clientKey, token.Error())
-		return
 	}
-
-	log.Printf("MQTT client subscribed to topic for clientKey=%s\n", clientKey)
 }
 
-func StartGlobalMQTTSubscriber() {
-	opts := mqtt.NewClientOptions().AddBroker(env.GetEnv("MQTT_BROKER"))
-	opts.SetClientID(env.GetEnv("MQTT_CLIENT_ID") + "_global") // ใช้ client ID แบบ global
-	opts.SetUsername(env.GetEnv("MQTT_USERNAME"))
-	opts.SetPassword(env.GetEnv("MQTT_PASSWORD"))
-	client := mqtt.NewClient(opts)
-
-	// Connect to the MQTT broker
-	if token := client.Connect(); token.Wait() && token.Error() != nil {
-		log.Fatal("Error connecting to MQTT broker:", token.Error())
-	}
-
-	// Subscribe to the topic
-	topic := "vibration"
-	token := client.Subscribe(topic, 1, func(client mqtt.Client, msg mqtt.Message) {
-		// Parse the incoming message
-		var payload schema.Data
-		if err := json.Unmarshal(msg.Payload(), &payload); err != nil {
-			log.Println("Failed to unmarshal payload:", err)
-			return
+// Disconnect closes the broadcast and prediction sockets of the user on the device, after the user lost access to it
+func Disconnect(userID, deviceID string) {
+	clients.Lock()
+	if conn, exists := clients.connections[userID+":"+deviceID]; exists {
+		conn.Close() // The read loop of the handler removes it
+	}
+	clients.Unlock()
+
+	predictClients.Lock()
+	for conn, watcher := range predictClients.connections[deviceID] {
+		if watcher == userID {
+			conn.Close()
 		}
+	}
+	predictClients.Unlock()
+}
 
-		// Create clientKey from userID and deviceID
-		clientKey := payload.UserID + ":" + payload.DeviceID
-		// Send the message to the WebSocket client with the matching clientKey
-		sendMessageToUser(clientKey, msg.Payload())
+// StartIngestSubscribers subscribes the broadcast and prediction of WebSocket clients to the ingest bus.
+// Both drop messages when a client is too slow rather than holding back storage.
+// Presence changes and alerts are pushed on the broadcast socket of the device too,
+// and new notifications on the notification sockets of their user.
+func StartIngestSubscribers() {
+	ingest.Subscribe("broadcast", 256, ingest.Drop, broadcastTelemetry)
+	ingest.Subscribe("prediction", 256, ingest.Drop, predictTelemetry)
+	presence.OnChange(broadcastPresence)
+	alert.OnChange(broadcastAlert)
+	notification.OnCreate(pushNotification)
+}
 
-	})
-	if token.Wait() && token.Error() != nil {
-		log.Fatal("Error subscribing to MQTT topic:", token.Error())
+// broadcastAlert sends a firing or resolved alert to the WebSocket client of the user of its rule on the device
+func broadcastAlert(event alert.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	sendMessageToUser(event.UserID+":"+event.DeviceID, data)
+}
+
+// broadcastPresence sends an online/offline event to the WebSocket clients of the device
+func broadcastPresence(event presence.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
 	}
+	sendMessageToDevice(event.DeviceID, data)
+}
 
-	log.Println("Global MQTT subscriber started and subscribed to topic:", topic)
+// broadcastTelemetry sends the message to the WebSocket clients of its deviceID, the owner and the users
+// it is shared with, with the overall velocity and ISO 10816-3 zone added to the payload of the device
+func broadcastTelemetry(msg ingest.Message) {
+	sendMessageToDevice(msg.Data.DeviceID, withZone(msg))
+}
+
+// withZone adds velocity, zone and order to the JSON payload, the payload is sent as is when it cannot be decoded
+func withZone(msg ingest.Message) []byte {
+	if msg.Data.Zone == "" {
+		return msg.Payload
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return msg.Payload
+	}
+	payload["velocity"], _ = json.Marshal(msg.Data.Velocity)
+	payload["zone"], _ = json.Marshal(msg.Data.Zone)
+	if msg.Data.Order != 0 {
+		payload["order"], _ = json.Marshal(msg.Data.Order)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return msg.Payload
+	}
+	return data
 }
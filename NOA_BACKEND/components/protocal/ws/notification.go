@@ -1,145 +1,96 @@
 package ws
 
 import (
-	"bufio"
 	"encoding/json"
-	"errors"
 	"log"
 	"net/http"
-	"os"
-	"strings"
 	"sync"
-	"time"
 
-	"github.com/gorilla/websocket"
-)
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/notification"
 
-// Global Map สำหรับ userID → WebSocket connection
-var (
-	fileLock sync.Mutex // 🔥 Lock สำหรับการอ่านเขียน notification.json
+	"github.com/gorilla/websocket"
 )
 
-func HandleHistory(w http.ResponseWriter, r *http.Request) {
-	userID := r.URL.Query().Get("userID")
-	if userID == "" {
-		http.Error(w, "Missing userID", http.StatusBadRequest)
+// notificationClients are the notification sockets of every user, a user can have several tabs open
+var notificationClients = struct {
+	sync.Mutex
+	connections map[string]map[*websocket.Conn]bool // key: userID
+}{connections: make(map[string]map[*websocket.Conn]bool)}
+
+// HandleNotification sends the unread notifications of a user, then every new one as it is created
+//
+//	GET /ws/notification?token=
+func HandleNotification(w http.ResponseWriter, r *http.Request) {
+	userID, ok := socketUser(w, r)
+	if !ok {
 		return
 	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println("[HISTORY] Upgrade failed:", err)
+		log.Println("[NOTIFICATION] Upgrade failed:", err)
 		return
 	}
 	defer conn.Close()
 
-	log.Printf("[HISTORY] User %s connected", userID)
+	// Register before reading the backlog so nothing created in between is missed
+	notificationClients.Lock()
+	if notificationClients.connections[userID] == nil {
+		notificationClients.connections[userID] = make(map[*websocket.Conn]bool)
+	}
+	notificationClients.connections[userID][conn] = true
+	notificationClients.Unlock()
+
+	defer func() {
+		notificationClients.Lock()
+		delete(notificationClients.connections[userID], conn)
+		if len(notificationClients.connections[userID]) == 0 {
+			delete(notificationClients.connections, userID)
+		}
+		notificationClients.Unlock()
+	}()
 
-	// อ่าน notification.json ครั้งแรก
-	notifications, err := readNotifications("notification.json")
+	page, err := db.Notifications(userID, db.NotificationQuery{Unread: true})
 	if err != nil {
-		log.Printf("[ERROR] Failed to read notification.json: %v", err)
+		log.Println("[NOTIFICATION] Read failed:", err)
 		return
 	}
-
-	// ส่งข้อมูลเริ่มต้นไปยัง client
-	for _, line := range notifications {
-		var notification map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &notification); err != nil {
-			log.Printf("[ERROR] Failed to parse notification: %v", err)
-			continue
-		}
-
-		if notificationUserID, ok := notification["userID"].(string); ok && notificationUserID == userID {
-			err = conn.WriteMessage(websocket.TextMessage, []byte(line))
-			if err != nil {
-				log.Printf("[HISTORY] Write message failed: %v", err)
-				return
-			}
-			log.Printf("[HISTORY] Sent notification to user %s: %s", userID, line)
-		}
+	backlog, _ := json.Marshal(map[string]interface{}{
+		"event":         "notifications",
+		"unread":        page.Unread,
+		"notifications": page.Notifications,
+	})
+	notificationClients.Lock()
+	err = conn.WriteMessage(websocket.TextMessage, backlog)
+	notificationClients.Unlock()
+	if err != nil {
+		return
 	}
 
-	// ใช้ Ticker เพื่อตรวจสอบการเปลี่ยนแปลงของ notification.json
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-
+	// Block until the connection is closed
 	for {
-		select {
-		case <-ticker.C:
-			// อ่าน notification.json ใหม่
-			notifications, err := readNotifications("notification.json")
-			if err != nil {
-				log.Printf("[ERROR] Failed to read notification.json: %v", err)
-				continue
-			}
-
-			// เก็บข้อมูลที่ยังไม่ได้ส่ง
-			var remainingNotifications []string
-
-			// ส่งข้อมูลใหม่ไปยัง client
-			for _, line := range notifications {
-				var notification map[string]interface{}
-				if err := json.Unmarshal([]byte(line), &notification); err != nil {
-					log.Printf("[ERROR] Failed to parse notification: %v", err)
-					remainingNotifications = append(remainingNotifications, line) // เก็บข้อมูลที่ parse ไม่ได้
-					continue
-				}
-
-				if notificationUserID, ok := notification["userID"].(string); ok && notificationUserID == userID {
-					err = conn.WriteMessage(websocket.TextMessage, []byte(line))
-					if err != nil {
-						log.Printf("[HISTORY] Write message failed: %v", err)
-						return // client หลุด → ออกจากฟังก์ชัน
-					}
-					log.Printf("[HISTORY] Sent notification to user %s: %s", userID, line)
-				} else {
-					// เก็บข้อมูลที่ไม่ใช่ของ user นี้
-					remainingNotifications = append(remainingNotifications, line)
-				}
-			}
-
-			// เขียนข้อมูลที่เหลือกลับไปที่ notification.json
-			if err := writeNotifications("notification.json", remainingNotifications); err != nil {
-				log.Printf("[ERROR] Failed to update notification.json: %v", err)
-			}
-
-		case <-time.After(60 * time.Second):
-			log.Printf("[HISTORY] Connection timeout for user %s", userID)
-			return
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
 		}
 	}
 }
 
-func readNotifications(filename string) ([]string, error) {
-	fileLock.Lock()
-	defer fileLock.Unlock()
-
-	file, err := os.Open(filename)
+// pushNotification sends a new notification to every socket of its user
+func pushNotification(event notification.Event) {
+	data, err := json.Marshal(event)
 	if err != nil {
-		// ถ้าไฟล์ไม่เจอ = ไม่มี notification ก็ไม่ error
-		if errors.Is(err, os.ErrNotExist) {
-			return []string{}, nil
-		}
-		return nil, err
-	}
-	defer file.Close()
-
-	var notifications []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		notifications = append(notifications, scanner.Text())
-	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
+		return
 	}
-	return notifications, nil
-}
 
-func writeNotifications(filename string, notifications []string) error {
-	fileLock.Lock()
-	defer fileLock.Unlock()
+	notificationClients.Lock()
+	defer notificationClients.Unlock()
 
-	data := strings.Join(notifications, "\n")
-	return os.WriteFile(filename, []byte(data), 0644)
+	for conn := range notificationClients.connections[event.UserID] {
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			log.Printf("[NOTIFICATION] Write failed for user %s: %v\n", event.UserID, err)
+			conn.Close()
+			delete(notificationClients.connections[event.UserID], conn)
+		}
+	}
 }
@@ -1,16 +1,19 @@
 package ws
 
 import (
-	"encoding/json"
+	"context"
 	"log"
 	"net/http"
-	"os"
 	"sync"
 	"time"
 
+	"GOLANG_SERVER/components/ingest"
+	"GOLANG_SERVER/components/notification"
+	predict "GOLANG_SERVER/components/predict"
 	"GOLANG_SERVER/components/schema"
+	"GOLANG_SERVER/components/usage"
+	"GOLANG_SERVER/components/webhook"
 
-	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/gorilla/websocket"
 )
 
@@ -22,11 +25,8 @@ type SlidingWindow struct {
 	Z []float32
 }
 
-type PredictionResult struct {
-	Prediction     [][]float32 `json:"prediction"`
-	PredictedClass []int       `json:"predicted_class"`
-	Timestamp      int64       `json:"timestamp"`
-}
+// PredictionResult is the class probabilities sent to the client
+type PredictionResult = predict.Result
 
 var (
 	deviceFrames = struct {
@@ -34,9 +34,21 @@ var (
 		frames map[string]*SlidingWindow
 	}{frames: make(map[string]*SlidingWindow)}
 	cooldownMap sync.Map
+	lastClass   sync.Map // key: deviceID, value: label of the last prediction
+	// Every user the device is shared with can watch its predictions
+	predictClients = struct {
+		sync.Mutex
+		connections map[string]map[*websocket.Conn]string // key: deviceID, value: userID of each connection
+	}{connections: make(map[string]map[*websocket.Conn]string)}
 )
 
 func HandleWebSocketPredict(w http.ResponseWriter, r *http.Request) {
+	// Checked before the upgrade so the client gets the HTTP status
+	userID, deviceID, ok := socketDevice(w, r)
+	if !ok {
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("[ERROR] WebSocket upgrade:", err)
@@ -44,29 +56,14 @@ func HandleWebSocketPredict(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
-	deviceID := r.URL.Query().Get("deviceID")
-	userID := r.URL.Query().Get("userID")
-	if deviceID == "" || userID == "" {
-		log.Println("[ERROR] Missing deviceID or userID")
-		return
+	predictClients.Lock()
+	if predictClients.connections[deviceID] == nil {
+		predictClients.connections[deviceID] = make(map[*websocket.Conn]string)
 	}
+	predictClients.connections[deviceID][conn] = userID
+	predictClients.Unlock()
 
-	clients.Lock()
-	if _, exists := clients.connections[deviceID]; exists {
-		clients.Unlock()
-		http.Error(w, "Device already connected", http.StatusConflict)
-		return
-	}
-	clients.connections[deviceID] = conn
-	clients.Unlock()
-
-	defer func() {
-		clients.Lock()
-		delete(clients.connections, deviceID)
-		clients.Unlock()
-	}()
-
-	startMQTTSubscribe(userID, deviceID)
+	defer removePredictClient(deviceID, conn)
 
 	for {
 		if _, _, err := conn.NextReader(); err != nil {
@@ -76,38 +73,25 @@ func HandleWebSocketPredict(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func startMQTTSubscribe(userID, deviceID string) {
-	opts := mqtt.NewClientOptions().AddBroker("tcp://localhost:1883")
-	opts.SetClientID("mqtt_subscriber_" + deviceID)
-	client := mqtt.NewClient(opts)
-
-	if token := client.Connect(); token.Wait() && token.Error() != nil {
-		log.Println("[ERROR] MQTT connect:", token.Error())
+// predictTelemetry is the prediction subscriber of the ingest bus, it only keeps windows of devices
+// with a prediction client connected or a webhook the predictions are delivered to
+func predictTelemetry(msg ingest.Message) {
+	deviceID := msg.Data.DeviceID
+	predictClients.Lock()
+	watching := len(predictClients.connections[deviceID]) > 0
+	predictClients.Unlock()
+	if !watching && !webhook.WantsPrediction(msg.Data.UserID, deviceID) {
 		return
 	}
 
-	if token := client.Subscribe("vibration", 1, func(_ mqtt.Client, msg mqtt.Message) {
-		var data schema.GyroData
-		if err := json.Unmarshal(msg.Payload(), &data); err != nil {
-			log.Println("[ERROR] MQTT message unmarshal:", err)
-			return
-		}
-		if data.DeviceID != deviceID {
-			return // สนใจเฉพาะ device นี้
+	if ready, frame := updateSlidingWindow(deviceID, msg.Data.Data); ready {
+		if _, ok := cooldownMap.Load(deviceID); !ok {
+			go predictAndSend(msg.Data.UserID, deviceID, frame, msg.Profile)
+			cooldownMap.Store(deviceID, true)
+			time.AfterFunc(3*time.Second, func() {
+				cooldownMap.Delete(deviceID)
+			})
 		}
-
-		if ready, frame := updateSlidingWindow(deviceID, data.Data); ready {
-			if _, ok := cooldownMap.Load(deviceID); !ok {
-				go predictAndSend(userID, deviceID, frame)
-				cooldownMap.Store(deviceID, true)
-				time.AfterFunc(3*time.Second, func() {
-					cooldownMap.Delete(deviceID)
-				})
-			}
-		}
-	}); token.Wait() && token.Error() != nil {
-		log.Println("[ERROR] MQTT subscribe:", token.Error())
-		return
 	}
 }
 
@@ -136,78 +120,74 @@ func updateSlidingWindow(deviceID string, data schema.GyroDataDetail) (bool, *Sl
 	}
 }
 
-func predictAndSend(userID, deviceID string, frame *SlidingWindow) {
-	input := map[string]interface{}{
-		"inputs": []interface{}{
-			append([]interface{}{time.Now().UnixMilli()}, append(toInterface(frame.X), append(toInterface(frame.Y), toInterface(frame.Z)...)...)...),
-		},
-	}
-	payload, _ := json.Marshal(input)
-
-	conn, _, err := websocket.DefaultDialer.Dial("ws://localhost:8080/ws/predict", nil)
-	if err != nil {
-		log.Println("[ERROR] Connect to Python WebSocket:", err)
-		return
-	}
-	defer conn.Close()
-
-	if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
-		log.Println("[ERROR] Send to Python WebSocket:", err)
-		return
-	}
-
-	_, message, err := conn.ReadMessage()
+func predictAndSend(userID, deviceID string, frame *SlidingWindow, profile *schema.DeviceProfile) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	usage.RecordPrediction(userID, deviceID)
+	result, err := predict.Predict(ctx, predict.Input{
+		Timestamp: time.Now().UnixMilli(),
+		X:         frame.X,
+		Y:         frame.Y,
+		Z:         frame.Z,
+		Profile:   profile,
+	})
 	if err != nil {
-		log.Println("[ERROR] Read from Python WebSocket:", err)
+		log.Println("[ERROR] Prediction:", err)
 		return
 	}
 
-	var result PredictionResult
-	if err := json.Unmarshal(message, &result); err != nil {
-		log.Println("[ERROR] Unmarshal prediction result:", err)
-		return
-	}
-
-	sendToClient(deviceID, &result)
-	saveResult(userID, deviceID, &result)
+	sendToClient(deviceID, result)
+	saveResult(userID, deviceID, result)
 }
 
+// sendToClient sends the prediction to every client watching the device
 func sendToClient(deviceID string, result *PredictionResult) {
-	clients.Lock()
-	conn, exists := clients.connections[deviceID]
-	clients.Unlock()
-	if !exists {
-		log.Println("[WARN] No WebSocket client for deviceID:", deviceID)
-		return
+	predictClients.Lock()
+	defer predictClients.Unlock()
+
+	// A device predicted for its webhooks only has no client
+	for conn := range predictClients.connections[deviceID] {
+		if err := conn.WriteJSON(result); err != nil {
+			log.Println("[ERROR] Send prediction to client:", err)
+			conn.Close()
+			delete(predictClients.connections[deviceID], conn)
+		}
 	}
+}
 
-	if err := conn.WriteJSON(result); err != nil {
-		log.Println("[ERROR] Send prediction to client:", err)
-		conn.Close()
+func removePredictClient(deviceID string, conn *websocket.Conn) {
+	predictClients.Lock()
+	defer predictClients.Unlock()
 
-		clients.Lock()
-		delete(clients.connections, deviceID)
-		clients.Unlock()
+	delete(predictClients.connections[deviceID], conn)
+	if len(predictClients.connections[deviceID]) == 0 {
+		delete(predictClients.connections, deviceID)
 	}
 }
 
+// saveResult notifies the user when the predicted class of the device changes,
+// predictions run every few seconds and repeating the same class is not news
 func saveResult(userID, deviceID string, result *PredictionResult) {
-	record := map[string]interface{}{
-		"userID":         userID,
-		"deviceID":       deviceID,
-		"predictedClass": classLabel(result.PredictedClass),
-		"result":         toPercent(result.Prediction),
+	label := classLabel(result.PredictedClass)
+	if previous, ok := lastClass.Swap(deviceID, label); ok && previous == label {
+		return
 	}
 
-	file, err := os.OpenFile("notification.json", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	_, err := notification.Notify(schema.Notification{
+		UserID:   userID,
+		DeviceID: deviceID,
+		Type:     notification.TypePrediction,
+		Title:    "Prediction: " + label,
+		Message:  "Device " + deviceID + " is predicted " + label,
+		Data: map[string]interface{}{
+			"predictedClass": label,
+			"result":         toPercent(result.Prediction),
+		},
+	})
 	if err != nil {
-		log.Println("[ERROR] Open file:", err)
-		return
+		log.Println("[ERROR] Save notification:", err)
 	}
-	defer file.Close()
-
-	data, _ := json.Marshal(record)
-	file.WriteString(string(data) + "\n")
 }
 
 func classLabel(classes []int) string {
@@ -234,11 +214,3 @@ func toPercent(prediction [][]float32) [][]float32 {
 	}
 	return prediction
 }
-
-func toInterface(floats []float32) []interface{} {
-	interfaces := make([]interface{}, len(floats))
-	for i, v := range floats {
-		interfaces[i] = v
-	}
-	return interfaces
-}
@@ -0,0 +1,65 @@
+package ws
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"GOLANG_SERVER/components/db"
+	predict "GOLANG_SERVER/components/predict"
+	"GOLANG_SERVER/components/schema"
+)
+
+func TestPredictAndSend(t *testing.T) {
+	os.Setenv("DB_STORE", "memory")
+	defer os.Unsetenv("DB_STORE")
+	if _, err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	fake := &predict.Fake{Class: 2}
+	predict.Use(fake)
+	defer predict.Use(nil)
+
+	frame := &SlidingWindow{X: []float32{1, 2}, Y: []float32{3, 4}, Z: []float32{5, 6}}
+	profile := &schema.DeviceProfile{}
+	notifications := func() []schema.Notification {
+		page, err := db.Notifications("userP", db.NotificationQuery{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return page.Notifications
+	}
+
+	predictAndSend("userP", "deviceP", frame, profile)
+	calls := fake.Calls()
+	if len(calls) != 1 || calls[0].Z[1] != 6 || calls[0].Profile != profile {
+		t.Fatalf("predictor got %+v", calls)
+	}
+	list := notifications()
+	if len(list) != 1 || list[0].Title != "Prediction: Fault" || list[0].DeviceID != "deviceP" {
+		t.Fatalf("notifications after a prediction: %+v", list)
+	}
+	if result := list[0].Data["result"].([][]float32); result[0][2] != 100 {
+		t.Fatalf("result = %v, want percents", result)
+	}
+
+	// The same class again is not news
+	predictAndSend("userP", "deviceP", frame, profile)
+	if list := notifications(); len(list) != 1 {
+		t.Fatalf("%d notifications for a repeated class", len(list))
+	}
+
+	fake.Class = 1
+	predictAndSend("userP", "deviceP", frame, profile)
+	if list := notifications(); len(list) != 2 || list[0].Title != "Prediction: Normal" {
+		t.Fatalf("notifications after a new class: %+v", list)
+	}
+
+	// A failed prediction notifies nothing
+	fake.Err = errors.New("service down")
+	fake.Class = 0
+	predictAndSend("userP", "deviceP", frame, profile)
+	if list := notifications(); len(list) != 2 || len(fake.Calls()) != 4 {
+		t.Fatalf("after a failure: %d notifications, %d calls", len(list), len(fake.Calls()))
+	}
+}
@@ -0,0 +1,180 @@
+package provision
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/schema"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	TTL        = 10 * time.Minute // Time a pairing code can be claimed
+	CodeLength = 8                // Characters of a pairing code, shown as XXXX-XXXX
+)
+
+// codeAlphabet has no 0, 1, I or O, which are easily mistaken when typed from a screen
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+var ErrDeviceName = errors.New("device name is required")
+
+// Credentials are returned once to the device that claimed a pairing code
+type Credentials struct {
+	DeviceID   string `json:"deviceID"`
+	UserID     string `json:"userID"`
+	DeviceName string `json:"deviceName"`
+	Password   string `json:"password"` // Device password for /authendevice and MQTT, only its hash is stored
+}
+
+// randomString returns n characters picked from the alphabet with crypto/rand
+func randomString(alphabet string, n int) (string, error) {
+	max := big.NewInt(int64(len(alphabet)))
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		index, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(alphabet[index.Int64()])
+	}
+	return b.String(), nil
+}
+
+// normalize removes the separator and spaces of a typed code and uppercases it
+func normalize(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// hashCode is the hex SHA-256 of a normalized code, the code itself is never stored
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(normalize(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// FormatCode shows a code as XXXX-XXXX
+func FormatCode(code string) string {
+	code = normalize(code)
+	if len(code) != CodeLength {
+		return code
+	}
+	return code[:CodeLength/2] + "-" + code[CodeLength/2:]
+}
+
+// NewDeviceID returns an unused device ID of 16 random hex characters
+func NewDeviceID() (string, error) {
+	for {
+		raw := make([]byte, 8)
+		if _, err := rand.Read(raw); err != nil {
+			return "", err
+		}
+		deviceID := hex.EncodeToString(raw)
+		exists, err := db.HandlercheckDeviceID(deviceID)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return deviceID, nil
+		}
+	}
+}
+
+// Start issues a pairing code for a new device of the user and returns the pairing and the code
+func Start(userID, deviceName string) (schema.Provisioning, string, error) {
+	deviceName = strings.TrimSpace(deviceName)
+	if deviceName == "" {
+		return schema.Provisioning{}, "", ErrDeviceName
+	}
+
+	code, err := randomString(codeAlphabet, CodeLength)
+	if err != nil {
+		return schema.Provisioning{}, "", err
+	}
+	now := time.Now().Truncate(time.Millisecond)
+	provisioning := schema.Provisioning{
+		ID:         uuid.New().String(),
+		CodeHash:   hashCode(code),
+		UserID:     userID,
+		DeviceName: deviceName,
+		CreateAt:   now,
+		ExpireAt:   now.Add(TTL),
+	}
+	if err := db.CreateProvisioning(provisioning); err != nil {
+		return schema.Provisioning{}, "", err
+	}
+	return provisioning, FormatCode(code), nil
+}
+
+// Claim exchanges a pairing code for a new device registered to the user who issued it.
+// It returns db.ErrInvalidPairingCode when the code is unknown, expired or already claimed.
+func Claim(code string) (Credentials, error) {
+	if len(normalize(code)) != CodeLength {
+		return Credentials{}, db.ErrInvalidPairingCode
+	}
+
+	// The code is reserved before the costly ID lookup and bcrypt, so guessing codes is cheap to refuse
+	provisioning, err := db.ClaimProvisioning(hashCode(code))
+	if err != nil {
+		return Credentials{}, err
+	}
+	deviceID, password, err := pairDevice(provisioning)
+	if err != nil {
+		// The code can be claimed again once the failure is fixed
+		if releaseErr := db.ReleaseProvisioning(provisioning.ID); releaseErr != nil {
+			log.Println("Error releasing pairing code", provisioning.ID+":", releaseErr)
+		}
+		return Credentials{}, err
+	}
+	if err := db.SetProvisioningDevice(provisioning.ID, deviceID); err != nil {
+		// The device is paired, only GET /device/provision/{provisionID} misses its ID
+		log.Println("Error recording paired device", deviceID+":", err)
+	}
+	return Credentials{
+		DeviceID:   deviceID,
+		UserID:     provisioning.UserID,
+		DeviceName: provisioning.DeviceName,
+		Password:   password,
+	}, nil
+}
+
+// pairDevice registers a new device with a random ID and password for the claimed pairing
+func pairDevice(provisioning schema.Provisioning) (string, string, error) {
+	deviceID, err := NewDeviceID()
+	if err != nil {
+		return "", "", err
+	}
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	password := hex.EncodeToString(raw)
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+	if err := db.SaveDevice(provisioning.DeviceName, deviceID, provisioning.UserID, string(hashed)); err != nil {
+		return "", "", err
+	}
+	return deviceID, password, nil
+}
+
+// Status is pending, claimed or expired
+func Status(provisioning schema.Provisioning) string {
+	switch {
+	case provisioning.ClaimedAt != nil:
+		return "claimed"
+	case time.Now().After(provisioning.ExpireAt):
+		return "expired"
+	default:
+		return "pending"
+	}
+}
@@ -0,0 +1,154 @@
+package report
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	env "GOLANG_SERVER/components/env"
+	"GOLANG_SERVER/components/mail"
+	"GOLANG_SERVER/components/usage"
+)
+
+// The weekly report of the past Monday to Sunday is sent every Monday at 08:00 in usage.Location
+const (
+	sendWeekday = time.Monday
+	sendHour    = 8
+	sendTimeout = 30 * time.Second
+)
+
+// Device is one line of the weekly report
+type Device struct {
+	DeviceID    string
+	DeviceName  string
+	Messages    int64  // Telemetry messages ingested during the week
+	ActiveHours int64  // Hours with at least one message
+	Alerts      int    // Alerts fired during the week, at most db.DefaultAlertLimit
+	Zone        string // ISO 10816-3 zone of the last message of the week
+}
+
+// Start sends the weekly reports in the background, unless WEEKLY_REPORT is "off"
+func Start() {
+	if env.GetEnv("WEEKLY_REPORT") == "off" {
+		log.Println("Weekly reports are off")
+		return
+	}
+	go func() {
+		for {
+			now := time.Now()
+			next := NextRun(now)
+			time.Sleep(next.Sub(now))
+			SendWeekly(WeekBefore(next))
+		}
+	}()
+}
+
+// NextRun returns the first send time after now
+func NextRun(now time.Time) time.Time {
+	now = now.In(usage.Location)
+	next := time.Date(now.Year(), now.Month(), now.Day(), sendHour, 0, 0, 0, usage.Location)
+	next = next.AddDate(0, 0, int(sendWeekday-next.Weekday()+7)%7)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 7)
+	}
+	return next
+}
+
+// WeekBefore returns the Monday 00:00 of the week before at
+func WeekBefore(at time.Time) time.Time {
+	at = at.In(usage.Location)
+	monday := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, usage.Location)
+	monday = monday.AddDate(0, 0, -int(monday.Weekday()-time.Monday+7)%7)
+	return monday.AddDate(0, 0, -7)
+}
+
+// SendWeekly emails every user with devices the report of the week starting at start, and returns how many were sent.
+// Emails are sent one by one rather than queued so a large number of users cannot fill the mail queue.
+func SendWeekly(start time.Time) int {
+	users, err := db.Users()
+	if err != nil {
+		log.Println("Error listing users for the weekly report:", err)
+		return 0
+	}
+	end := start.AddDate(0, 0, 7)
+	from, to := start.Format(db.UsageDayLayout), end.AddDate(0, 0, -1).Format(db.UsageDayLayout)
+
+	sent := 0
+	for _, user := range users {
+		devices, err := weekDevices(user.ID, start, end)
+		if err != nil {
+			log.Printf("Error building the weekly report of user %s: %v\n", user.ID, err)
+			continue
+		}
+		if len(devices) == 0 || user.Email == "" {
+			continue
+		}
+		msg, err := mail.Render(user.Email, mail.TemplateWeeklyReport, mail.Language(""), map[string]interface{}{
+			"Name":    user.Username,
+			"From":    from,
+			"To":      to,
+			"Devices": devices,
+		})
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			err = mail.SendNow(ctx, msg)
+			cancel()
+		}
+		if err != nil {
+			log.Printf("Error sending the weekly report of user %s: %v\n", user.ID, err)
+			continue
+		}
+		sent++
+	}
+	log.Printf("Sent %d weekly reports for %s to %s\n", sent, from, to)
+	return sent
+}
+
+// weekDevices summarises the week of every device the user owns
+func weekDevices(userID string, start, end time.Time) ([]Device, error) {
+	owned, err := db.GetDeviceAddress(userID)
+	if err != nil {
+		return nil, err
+	}
+	var devices []Device
+	for _, device := range owned {
+		if device.Role != db.RoleOwner {
+			continue
+		}
+		line := Device{DeviceID: device.DeviceID, DeviceName: device.DeviceName}
+
+		week, err := db.DeviceUsageReport(device.DeviceID, start.Format(db.UsageDayLayout), end.AddDate(0, 0, -1).Format(db.UsageDayLayout), false)
+		if err != nil {
+			return nil, err
+		}
+		line.Messages, line.ActiveHours = week.Total.Messages, week.Total.ActiveHours
+
+		alerts, err := db.Alerts(userID, db.AlertQuery{DeviceID: device.DeviceID})
+		if err != nil {
+			return nil, err
+		}
+		for _, alert := range alerts {
+			if !alert.FiredAt.Before(start) && alert.FiredAt.Before(end) {
+				line.Alerts++
+			}
+		}
+
+		last, err := db.QueryTelemetry(db.TelemetryQuery{
+			DeviceID:   device.DeviceID,
+			From:       start.UnixMilli(),
+			To:         end.UnixMilli() - 1,
+			Fields:     []string{"zone"},
+			Descending: true,
+			Limit:      1,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(last.Data) == 1 {
+			line.Zone, _ = last.Data[0]["zone"].(string)
+		}
+		devices = append(devices, line)
+	}
+	return devices, nil
+}
@@ -0,0 +1,100 @@
+package report
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/mail"
+	schema "GOLANG_SERVER/components/schema"
+	"GOLANG_SERVER/components/usage"
+)
+
+func TestSchedule(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2026, 3, day, hour, 0, 0, 0, usage.Location)
+	}
+	// 2026-03-02 is a Monday
+	tests := []struct {
+		now, next time.Time
+	}{
+		{at(1, 12), at(2, 8)},
+		{at(2, 7), at(2, 8)},
+		{at(2, 8), at(9, 8)},
+		{at(4, 0), at(9, 8)},
+	}
+	for _, tt := range tests {
+		if got := NextRun(tt.now); !got.Equal(tt.next) {
+			t.Errorf("NextRun(%v) = %v, want %v", tt.now, got, tt.next)
+		}
+	}
+	if got := WeekBefore(at(9, 8)); !got.Equal(at(2, 0)) {
+		t.Errorf("WeekBefore = %v, want the Monday before", got)
+	}
+	if got := WeekBefore(at(8, 23)); !got.Equal(at(23, 0).AddDate(0, -1, 0)) {
+		t.Errorf("WeekBefore a Sunday = %v", got)
+	}
+}
+
+func TestSendWeekly(t *testing.T) {
+	os.Setenv("DB_STORE", "memory")
+	defer os.Unsetenv("DB_STORE")
+	if _, err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	outbox := &mail.Memory{}
+	mail.Use(outbox, "noa@example.com")
+
+	store := db.GetStore()
+	for _, u := range []schema.User{
+		{ID: "userA", Username: "alice", Email: "a@example.com"},
+		{ID: "userB", Username: "bob", Email: "b@example.com"},
+	} {
+		if err := store.InsertUser(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.SaveDevice("press", "deviceA", "userA", "hash"); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, usage.Location)
+	for _, day := range []schema.DeviceUsage{
+		{DeviceID: "deviceA", UserID: "userA", Day: "2026-03-01", Messages: 100, Hours: 1},
+		{DeviceID: "deviceA", UserID: "userA", Day: "2026-03-02", Messages: 7, Hours: 1<<3 | 1<<4},
+		{DeviceID: "deviceA", UserID: "userA", Day: "2026-03-08", Messages: 3, Hours: 1},
+	} {
+		if err := db.AddUsage(day.Day, day); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, firedAt := range []time.Time{start.Add(-time.Hour), start.Add(time.Hour), start.AddDate(0, 0, 6)} {
+		if err := store.InsertAlert(schema.Alert{ID: string(rune('a' + i)), UserID: "userA", DeviceID: "deviceA", FiredAt: firedAt}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The zone is the one of the last message of the week
+	for zone, at := range map[string]time.Time{"A": start.Add(-time.Hour), "C": start.Add(72 * time.Hour), "D": start.AddDate(0, 0, 7)} {
+		data := schema.GyroData{DeviceID: "deviceA", UserID: "userA", TimeStamp: at.UnixMilli(), Zone: zone}
+		if err := store.InsertGyroData(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// User B has no device and gets no report
+	if sent := SendWeekly(start); sent != 1 {
+		t.Fatalf("%d reports sent, want 1", sent)
+	}
+	messages := outbox.Messages()
+	if len(messages) != 1 || messages[0].To[0] != "a@example.com" {
+		t.Fatalf("reports = %+v", messages)
+	}
+	if want := "2026-03-02 to 2026-03-08"; !strings.Contains(messages[0].Subject, want) {
+		t.Fatalf("subject %q does not cover %s", messages[0].Subject, want)
+	}
+	if want := "press (deviceA): 10 messages, 3 active hours, 2 alerts, zone C"; !strings.Contains(messages[0].Text, want) {
+		t.Fatalf("report text does not contain %q:\n%s", want, messages[0].Text)
+	}
+}
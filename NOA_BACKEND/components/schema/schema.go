@@ -1,64 +1,33 @@
 package schema
 
-<<<<<<< HEAD
-type GyroStruct struct {
-	Acceleration                   float32 `json:"Acceleration"`
-	VelocityAngular                float32 `json:"VelocityAngular"`
-	VibrationSpeed                 float32 `json:"VibrationSpeed"`
-	VibrationAngle                 float32 `json:"VibrationAngle"`
-	VibrationDisplacement          float32 `json:"VibrationDisplacement"`
-	VibrationDisplacementHighSpeed float32 `json:"VibrationDisplacementHighSpeed"`
-	Frequency                      float32 `json:"Frequency"`
-}
-
-type GyroData struct {
-	DeviceAddress   string     `json:"DeviceAddress"`
-	DateTime        string     `json:"DateTime"`
-	TimeStamp       int64      `json:"TimeStamp"`
-	X               GyroStruct `json:"X"`
-	Y               GyroStruct `json:"Y"`
-	Z               GyroStruct `json:"Z"`
-	Temperature     float32    `json:"Temperature"`
-	ModbusHighSpeed bool       `json:"ModbusHighSpeed"`
-}
-
-type MQTTData struct {
-	Timestamp int64 `json:"Timestamp"`
-	X         struct {
-		Acceleration float64 `json:"acceleration"`
-	} `json:"x"`
-	Y struct {
-		Acceleration float64 `json:"acceleration"`
-	} `json:"y"`
-	Z struct {
-		Acceleration float64 `json:"acceleration"`
-	} `json:"z"`
-=======
 import "time"
 
 type GyroData struct {
-	DeviceID  string         `json:"deviceID"`
-	UserID    string         `json:"userID"`
-	DateTime  string         `json:"Datetime"`
-	TimeStamp int64          `json:"TimeStamp"`
-	Data      GyroDataDetail `json:"data"`
+	DeviceID  string         `json:"deviceID" bson:"deviceid"`
+	UserID    string         `json:"userID" bson:"userid"`
+	DateTime  string         `json:"Datetime" bson:"datetime"`
+	TimeStamp int64          `json:"TimeStamp" bson:"timestamp"`
+	Data      GyroDataDetail `json:"data" bson:"data"`
+	Velocity  float64        `json:"velocity,omitempty" bson:"velocity,omitempty"` // Overall RMS velocity in mm/s
+	Zone      string         `json:"zone,omitempty" bson:"zone,omitempty"`         // ISO 10816-3 zone A, B, C or D
+	Order     float64        `json:"order,omitempty" bson:"order,omitempty"`       // Dominant frequency in multiples of the rated running speed
 }
 
 type GyroDataDetail struct {
-	DeviceAddress string   `json:"DeviceAddress"`
-	X             AxisData `json:"X"`
-	Y             AxisData `json:"Y"`
-	Z             AxisData `json:"Z"`
-	Temperature   float64  `json:"Temperature"`
+	DeviceAddress string   `json:"DeviceAddress" bson:"deviceaddress"`
+	X             AxisData `json:"X" bson:"x"`
+	Y             AxisData `json:"Y" bson:"y"`
+	Z             AxisData `json:"Z" bson:"z"`
+	Temperature   float64  `json:"Temperature" bson:"temperature"`
 }
 
 type AxisData struct {
-	Acceleration          float64 `json:"Acceleration"`
-	VelocityAngular       float64 `json:"VelocityAngular"`
-	VibrationSpeed        float64 `json:"VibrationSpeed"`
-	VibrationAngle        float64 `json:"VibrationAngle"`
-	VibrationDisplacement float64 `json:"VibrationDisplacement"`
-	Frequency             float64 `json:"Frequency"`
+	Acceleration          float64 `json:"Acceleration" bson:"acceleration"`
+	VelocityAngular       float64 `json:"VelocityAngular" bson:"velocityangular"`
+	VibrationSpeed        float64 `json:"VibrationSpeed" bson:"vibrationspeed"`
+	VibrationAngle        float64 `json:"VibrationAngle" bson:"vibrationangle"`
+	VibrationDisplacement float64 `json:"VibrationDisplacement" bson:"vibrationdisplacement"`
+	Frequency             float64 `json:"Frequency" bson:"frequency"`
 }
 
 type GyroStruct struct {
@@ -83,7 +52,6 @@ type MQTTDataWithData struct {
 	DeviceID string   `json:"deviceID"`
 	UserID   string   `json:"userID"`
 	Data     GyroData `json:"data"`
->>>>>>> Final_BN
 }
 
 type PasswordRequest struct {
@@ -92,40 +60,296 @@ type PasswordRequest struct {
 }
 
 type User struct {
-<<<<<<< HEAD
-	ID       string `bson:"id,omitempty"` // User ID
-	Email    string `bson:"email"`        // User email
-	Password string `bson:"password"`     // User password
-=======
 	ID       string `bson:"userID"`   // User ID
 	Username string `bson:"username"` // User name
 	Email    string `bson:"email"`    // User email
 	Password string `bson:"password"` // User password
->>>>>>> Final_BN
+}
+
+// Session is a login of a user on one client, refreshed with a rotating refresh token
+type Session struct {
+	ID           string     `bson:"sessionID" json:"sessionID"`                     // Session ID, the sid claim of its access tokens
+	UserID       string     `bson:"userID" json:"userID"`                           // User logged in
+	RefreshHash  string     `bson:"refreshHash" json:"-"`                           // Hex SHA-256 of the current refresh token
+	PreviousHash string     `bson:"previousHash,omitempty" json:"-"`                // Hex SHA-256 of the refresh token it replaced, to detect reuse
+	UserAgent    string     `bson:"userAgent,omitempty" json:"userAgent,omitempty"` // User-Agent of the login
+	IP           string     `bson:"ip,omitempty" json:"ip,omitempty"`               // Remote address of the login
+	CreateAt     time.Time  `bson:"createAt" json:"createAt"`                       // Login date
+	RefreshAt    time.Time  `bson:"refreshAt" json:"refreshAt"`                     // Last refresh
+	ExpireAt     time.Time  `bson:"expireAt" json:"expireAt"`                       // Expiry of the refresh token, also removes the document
+	RevokedAt    *time.Time `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"` // Logout date, nil while active
+}
+
+// DeviceShare gives a user a role on a device of another user. It is an invitation until the invited email accepts it,
+// an invitation with the owner role transfers the device.
+type DeviceShare struct {
+	ID         string     `bson:"shareID" json:"shareID"`                       // Share ID
+	DeviceID   string     `bson:"deviceID" json:"deviceID"`                     // Shared device
+	DeviceName string     `bson:"deviceName" json:"deviceName"`                 // Name of the device when invited
+	OwnerID    string     `bson:"ownerID" json:"ownerID"`                       // Owner who invited
+	Email      string     `bson:"email" json:"email"`                           // Invited email, lowercase
+	UserID     string     `bson:"userID,omitempty" json:"userID,omitempty"`     // User who accepted, empty while pending
+	Role       string     `bson:"role" json:"role"`                             // operator or viewer, owner for a transfer
+	Status     string     `bson:"status" json:"status"`                         // pending or accepted
+	Bookmark   bool       `bson:"bookmark" json:"bookmark"`                     // Bookmarked by the user it is shared with
+	CreateAt   time.Time  `bson:"createAt" json:"createAt"`                     // Invitation date
+	AcceptAt   *time.Time `bson:"acceptAt,omitempty" json:"acceptAt,omitempty"` // Date the invitation was accepted
+	ExpireAt   *time.Time `bson:"expireAt,omitempty" json:"expireAt,omitempty"` // Expiry of a pending invitation, removed on accept so MongoDB keeps the share
+}
+
+// Provisioning is a pairing code a user issued for a new device, only the hash of the code is stored
+type Provisioning struct {
+	ID         string     `bson:"provisionID" json:"provisionID"`                 // Provisioning ID, shown to the user to follow the pairing
+	CodeHash   string     `bson:"codeHash" json:"-"`                              // Hex SHA-256 of the pairing code
+	UserID     string     `bson:"userID" json:"userID"`                           // User the device is registered to
+	DeviceName string     `bson:"deviceName" json:"deviceName"`                   // Name of the new device
+	DeviceID   string     `bson:"deviceID,omitempty" json:"deviceID,omitempty"`   // Device created by the claim, empty while pending
+	CreateAt   time.Time  `bson:"createAt" json:"createAt"`                       // Issue date
+	ExpireAt   time.Time  `bson:"expireAt" json:"expireAt"`                       // Expiry of the code, also removes the document
+	ClaimedAt  *time.Time `bson:"claimedAt,omitempty" json:"claimedAt,omitempty"` // Date a device claimed the code, nil while pending
+}
+
+// PendingUser is a registration waiting for its email to be verified
+type PendingUser struct {
+	Email    string    `bson:"email" json:"email"`       // Email the OTP is sent to
+	Username string    `bson:"username" json:"username"` // User name
+	Password string    `bson:"password" json:"-"`        // bcrypt hash of the password
+	CreateAt time.Time `bson:"createAt" json:"createAt"` // Registration date
+	ExpireAt time.Time `bson:"expireAt" json:"expireAt"` // Expiry, also removes the document
+}
+
+// OTP is a one-time code sent to verify an email or pair a device, only its hash is stored
+type OTP struct {
+	Subject  string    `bson:"subject"`  // Email the code was sent to
+	Purpose  string    `bson:"purpose"`  // register or reset
+	CodeHash string    `bson:"codeHash"` // Hex HMAC-SHA256 of the code
+	Attempts int       `bson:"attempts"` // Verifications tried
+	CreateAt time.Time `bson:"createAt"` // Issue date
+	ExpireAt time.Time `bson:"expireAt"` // Expiry, also removes the document
+}
+
+// OTPSends is the log of the codes sent to a subject for every purpose. It outlives the codes, so deleting
+// a code after too many attempts does not allow sending another one sooner.
+type OTPSends struct {
+	Subject  string      `bson:"subject"`  // Email the codes were sent to
+	Sends    []time.Time `bson:"sends"`    // Dates of the sends within the window, oldest first
+	ExpireAt time.Time   `bson:"expireAt"` // End of the window of the last send, also removes the document
+}
+
+// PasswordReset is a single use token allowing to set a new password, only its hash is stored
+type PasswordReset struct {
+	TokenHash string     `bson:"tokenHash" json:"-"`             // Hex SHA-256 of the token
+	UserID    string     `bson:"userID" json:"userID"`           // User the token resets
+	CreateAt  time.Time  `bson:"createAt" json:"createAt"`       // Issue date
+	ExpireAt  time.Time  `bson:"expireAt" json:"expireAt"`       // Expiry, also removes the document
+	UsedAt    *time.Time `bson:"usedAt,omitempty" json:"usedAt"` // Date the token was used, nil while unused
 }
 
 type Account struct {
 	ID  string `bson:"id,omitempty"` // User ID
 	OTP string `bson:"otp"`          // OTP
 }
-<<<<<<< HEAD
-=======
 
 type Device struct {
-	ID       string `bson:"deviceID"` // Device ID
-	Email    string `bson:"email"`    // User email
-	Password string `bson:"password"` // User password
+	ID           string        `bson:"deviceID"`          // Device ID
+	UserID       string        `bson:"userID"`            // Owner user ID
+	Email        string        `bson:"email,omitempty"`   // User email
+	Password     string        `bson:"password"`          // Device password (bcrypt hash)
+	DeviceName   string        `bson:"deviceName"`        // Device name
+	CreateDate   time.Time     `bson:"createDate"`        // Date the device was created
+	CurrentDate  time.Time     `bson:"currentDate"`       // Date the device was last updated
+	Bookmark     bool          `bson:"bookmark"`          // Bookmarked by the user
+	Usage        int           `bson:"usage"`             // Usage counter
+	Status       bool          `bson:"status"`            // Online when true
+	LastSeen     time.Time     `bson:"lastSeen"`          // Date of the last message from the device
+	MachineClass MachineClass  `bson:"machineClass"`      // ISO 10816-3 class of the monitored machine
+	AssetID      string        `bson:"assetID,omitempty"` // Asset of the owner the device is attached to
+	Profile      DeviceProfile `bson:"profile"`           // Machine the device is mounted on
+}
+
+// DeviceProfile describes the machine a device is mounted on and how the sensor is mounted
+type DeviceProfile struct {
+	MachineType  string      `json:"machineType" bson:"machineType"`   // motor, pump, fan, compressor, gearbox...
+	RatedRPM     float64     `json:"ratedRPM" bson:"ratedRPM"`         // Rated running speed in revolutions per minute
+	BearingModel string      `json:"bearingModel" bson:"bearingModel"` // Bearing at the sensor, such as SKF 6205
+	Mounting     string      `json:"mounting" bson:"mounting"`         // horizontal, vertical or inclined
+	AxisMapping  AxisMapping `json:"axisMapping" bson:"axisMapping"`   // Machine direction of each sensor axis
+	SampleRate   float64     `json:"sampleRate" bson:"sampleRate"`     // Samples per second of the sensor
+	Location     string      `json:"location" bson:"location"`         // Where the machine is, free text
+	Tags         []string    `json:"tags" bson:"tags"`                 // Lowercase tags to search the devices by
+}
+
+// AxisMapping is the machine direction of each sensor axis: axial, horizontal or vertical
+type AxisMapping struct {
+	X string `json:"X" bson:"x"`
+	Y string `json:"Y" bson:"y"`
+	Z string `json:"Z" bson:"z"`
+}
+
+// MachineClass is the ISO 10816-3 group and foundation of a machine
+type MachineClass struct {
+	Group      int    `json:"group" bson:"group"`           // 1 above 300 kW, 2 from 15 to 300 kW
+	Foundation string `json:"foundation" bson:"foundation"` // rigid or flexible
 }
 
 type GetDevice struct {
-	UserID      string    `bson:"userID"`
-	DeviceName  string    `bson:"deviceName"`
-	DeviceID    string    `bson:"deviceID"`
-	CreateDate  time.Time `bson:"createDate"`
-	CurrentDate time.Time `bson:"currentDate"`
-	Bookmark    bool      `bson:"bookmark"`
-	Usage       int       `bson:"usage"`
-	Status      bool      `bson:"status"`
+	UserID       string        `bson:"userID"`
+	Role         string        `bson:"-"` // Role of the user listing the device, owner, operator or viewer
+	DeviceName   string        `bson:"deviceName"`
+	DeviceID     string        `bson:"deviceID"`
+	CreateDate   time.Time     `bson:"createDate"`
+	CurrentDate  time.Time     `bson:"currentDate"`
+	Bookmark     bool          `bson:"bookmark"`
+	Usage        int           `bson:"usage"`
+	Status       bool          `bson:"status"`
+	LastSeen     time.Time     `bson:"lastSeen"`
+	MachineClass MachineClass  `bson:"machineClass"`
+	AssetID      string        `bson:"assetID,omitempty"`
+	Profile      DeviceProfile `bson:"profile"`
+}
+
+// Organisation is the top of the hierarchy devices are grouped by: organisation, site, then nested assets
+type Organisation struct {
+	ID       string    `json:"orgID" bson:"orgID"`       // Organisation ID
+	UserID   string    `json:"userID" bson:"userID"`     // Owner of the hierarchy
+	Name     string    `json:"name" bson:"name"`         // Display name
+	CreateAt time.Time `json:"createAt" bson:"createAt"` // Date the organisation was created
+	UpdateAt time.Time `json:"updateAt" bson:"updateAt"` // Date the organisation was last changed
+}
+
+// Site is a plant or building of an organisation
+type Site struct {
+	ID       string    `json:"siteID" bson:"siteID"`                         // Site ID
+	UserID   string    `json:"userID" bson:"userID"`                         // Owner of the hierarchy
+	OrgID    string    `json:"orgID" bson:"orgID"`                           // Organisation of the site
+	Name     string    `json:"name" bson:"name"`                             // Display name
+	Location string    `json:"location,omitempty" bson:"location,omitempty"` // Address or coordinates
+	CreateAt time.Time `json:"createAt" bson:"createAt"`                     // Date the site was created
+	UpdateAt time.Time `json:"updateAt" bson:"updateAt"`                     // Date the site was last changed
+}
+
+// Asset is a line, machine or any part of a site, assets nest under a parent asset and devices attach to them
+type Asset struct {
+	ID       string    `json:"assetID" bson:"assetID"`                       // Asset ID
+	UserID   string    `json:"userID" bson:"userID"`                         // Owner of the hierarchy
+	SiteID   string    `json:"siteID" bson:"siteID"`                         // Site of the asset
+	ParentID string    `json:"parentID,omitempty" bson:"parentID,omitempty"` // Parent asset, empty at the top of the site
+	Name     string    `json:"name" bson:"name"`                             // Display name
+	Kind     string    `json:"kind,omitempty" bson:"kind,omitempty"`         // Free-form type such as line, machine or motor
+	CreateAt time.Time `json:"createAt" bson:"createAt"`                     // Date the asset was created
+	UpdateAt time.Time `json:"updateAt" bson:"updateAt"`                     // Date the asset was last changed
+}
+
+// Command is a message sent to a device on its cmd topic, acknowledged by the device on its ack topic
+type Command struct {
+	ID       string                 `json:"commandID" bson:"commandID"`               // Command ID, echoed by the device in its ack
+	DeviceID string                 `json:"deviceID" bson:"deviceID"`                 // Target device
+	UserID   string                 `json:"userID" bson:"userID"`                     // Owner of the device
+	Type     string                 `json:"type" bson:"type"`                         // sampleRate, modbusHighSpeed, reboot or config
+	Params   map[string]interface{} `json:"params,omitempty" bson:"params,omitempty"` // Parameters of the command type
+	Status   string                 `json:"status" bson:"status"`                     // pending, acked, failed or expired
+	Error    string                 `json:"error,omitempty" bson:"error,omitempty"`   // Reason reported by the device when failed
+	CreateAt time.Time              `json:"createAt" bson:"createAt"`                 // Date the command was sent
+	ExpireAt time.Time              `json:"expireAt" bson:"expireAt"`                 // Date the command expires if not acknowledged
+	AckAt    *time.Time             `json:"ackAt,omitempty" bson:"ackAt,omitempty"`   // Date the device acknowledged the command
+}
+
+// DeviceUsage is the metered usage of a device over one day
+type DeviceUsage struct {
+	DeviceID    string `json:"deviceID" bson:"deviceID"`       // Device metered
+	UserID      string `json:"userID" bson:"userID"`           // Owner of the device
+	Day         string `json:"day" bson:"day"`                 // Day in Asia/Bangkok as 2006-01-02
+	Messages    int64  `json:"messages" bson:"messages"`       // Telemetry messages ingested
+	Bytes       int64  `json:"bytes" bson:"bytes"`             // Payload bytes ingested
+	Hours       int64  `json:"hours" bson:"hours"`             // Bit h is set when data arrived during hour h
+	Predictions int64  `json:"predictions" bson:"predictions"` // Prediction calls
+}
+
+// AlertRule is a condition on the telemetry of a device, or of every device of its user
+type AlertRule struct {
+	ID         string    `json:"ruleID" bson:"ruleID"`                         // Rule ID
+	UserID     string    `json:"userID" bson:"userID"`                         // Owner of the rule
+	DeviceID   string    `json:"deviceID,omitempty" bson:"deviceID,omitempty"` // Device watched, every device of the user when empty
+	Name       string    `json:"name" bson:"name"`                             // Name shown in alerts
+	Field      string    `json:"field" bson:"field"`                           // Measurement such as Z.VibrationSpeed or Temperature
+	Kind       string    `json:"kind" bson:"kind"`                             // threshold, rate or band
+	Operator   string    `json:"operator,omitempty" bson:"operator,omitempty"` // > or < for threshold and rate
+	Threshold  float64   `json:"threshold,omitempty" bson:"threshold"`         // Limit for threshold, per minute for rate
+	Low        float64   `json:"low,omitempty" bson:"low"`                     // Lower bound of the band
+	High       float64   `json:"high,omitempty" bson:"high"`                   // Upper bound of the band
+	Hysteresis float64   `json:"hysteresis" bson:"hysteresis"`                 // Margin back inside the limit needed to resolve
+	For        int64     `json:"for" bson:"for"`                               // Seconds the condition must hold to fire, and be clear to resolve
+	Severity   string    `json:"severity" bson:"severity"`                     // info, warning or critical
+	Enabled    bool      `json:"enabled" bson:"enabled"`                       // Evaluated when true
+	CreateAt   time.Time `json:"createAt" bson:"createAt"`                     // Date the rule was created
+	UpdateAt   time.Time `json:"updateAt" bson:"updateAt"`                     // Date the rule was last changed
+}
+
+// Alert is a firing or resolved occurrence of an AlertRule on a device
+type Alert struct {
+	ID         string     `json:"alertID" bson:"alertID"`                           // Alert ID
+	RuleID     string     `json:"ruleID" bson:"ruleID"`                             // Rule that fired
+	RuleName   string     `json:"ruleName" bson:"ruleName"`                         // Name of the rule when it fired
+	UserID     string     `json:"userID" bson:"userID"`                             // Owner of the device
+	DeviceID   string     `json:"deviceID" bson:"deviceID"`                         // Device the rule fired on
+	Field      string     `json:"field" bson:"field"`                               // Measurement of the rule
+	Severity   string     `json:"severity" bson:"severity"`                         // info, warning or critical
+	Zone       string     `json:"zone,omitempty" bson:"zone,omitempty"`             // ISO 10816-3 zone when the rule fired
+	Status     string     `json:"status" bson:"status"`                             // firing or resolved
+	Value      float64    `json:"value" bson:"value"`                               // Value when the rule fired
+	Message    string     `json:"message" bson:"message"`                           // Human readable condition
+	FiredAt    time.Time  `json:"firedAt" bson:"firedAt"`                           // Date the rule fired
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty" bson:"resolvedAt,omitempty"` // Date the condition cleared
+}
+
+// Notification is a message kept for a user, such as a prediction or an alert of one of their devices
+type Notification struct {
+	ID       string                 `json:"notificationID" bson:"notificationID"`         // Notification ID
+	UserID   string                 `json:"userID" bson:"userID"`                         // Recipient
+	DeviceID string                 `json:"deviceID,omitempty" bson:"deviceID,omitempty"` // Device the notification is about
+	Type     string                 `json:"type" bson:"type"`                             // prediction or alert
+	Title    string                 `json:"title" bson:"title"`                           // Short text
+	Message  string                 `json:"message" bson:"message"`                       // Details
+	Data     map[string]interface{} `json:"data,omitempty" bson:"data,omitempty"`         // Payload of the type, such as the prediction result
+	Read     bool                   `json:"read" bson:"read"`                             // Marked read by the user
+	CreateAt time.Time              `json:"createAt" bson:"createAt"`                     // Date the notification was created
+	ReadAt   *time.Time             `json:"readAt,omitempty" bson:"readAt,omitempty"`     // Date the notification was marked read
+	ExpireAt time.Time              `json:"expireAt" bson:"expireAt"`                     // Date the notification is deleted
+}
+
+// Webhook is a URL of a user called on device events
+type Webhook struct {
+	ID       string    `json:"webhookID" bson:"webhookID"`                   // Webhook ID
+	UserID   string    `json:"userID" bson:"userID"`                         // Owner of the webhook
+	URL      string    `json:"url" bson:"url"`                               // Receiver, called with POST
+	Secret   string    `json:"secret,omitempty" bson:"secret"`               // Key of the HMAC signature, only returned when set
+	Events   []string  `json:"events" bson:"events"`                         // prediction, alert and presence
+	DeviceID string    `json:"deviceID,omitempty" bson:"deviceID,omitempty"` // Only events of this device when set
+	Enabled  bool      `json:"enabled" bson:"enabled"`                       // Called when true
+	CreateAt time.Time `json:"createAt" bson:"createAt"`                     // Date the webhook was created
+	UpdateAt time.Time `json:"updateAt" bson:"updateAt"`                     // Date the webhook was last changed
+}
+
+// WebhookDelivery is one event queued for a webhook with the log of its attempts
+type WebhookDelivery struct {
+	ID          string           `json:"deliveryID" bson:"deliveryID"`                       // Delivery ID, sent in X-NOA-Delivery
+	WebhookID   string           `json:"webhookID" bson:"webhookID"`                         // Webhook called
+	UserID      string           `json:"userID" bson:"userID"`                               // Owner of the webhook
+	Event       string           `json:"event" bson:"event"`                                 // prediction, alert, presence or ping
+	Payload     string           `json:"payload" bson:"payload"`                             // JSON body sent
+	Status      string           `json:"status" bson:"status"`                               // pending, delivered or failed
+	Attempts    []WebhookAttempt `json:"attempts" bson:"attempts"`                           // Every attempt, oldest first
+	NextAttempt time.Time        `json:"nextAttempt" bson:"nextAttempt"`                     // Date of the next attempt while pending
+	CreateAt    time.Time        `json:"createAt" bson:"createAt"`                           // Date the event was queued
+	DeliveredAt *time.Time       `json:"deliveredAt,omitempty" bson:"deliveredAt,omitempty"` // Date of the successful attempt
+}
+
+// WebhookAttempt is one call of a webhook
+type WebhookAttempt struct {
+	At         time.Time `json:"at" bson:"at"`                           // Date of the call
+	StatusCode int       `json:"statusCode,omitempty" bson:"statusCode"` // HTTP status of the response, 0 when there was none
+	Error      string    `json:"error,omitempty" bson:"error,omitempty"` // Reason of a failure
+	Duration   int64     `json:"duration" bson:"duration"`               // Milliseconds the call took
 }
 
 type DataPayload struct {
@@ -167,4 +391,3 @@ type Data struct {
 		Temperature float64 `json:"Temperature"`
 	} `json:"data"`
 }
->>>>>>> Final_BN
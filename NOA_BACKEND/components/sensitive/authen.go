@@ -2,32 +2,24 @@ package sensitive
 
 import (
 	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/protocal/mosquitto"
 	"encoding/json"
 	"log"
 	"net/http"
 	"time"
-<<<<<<< HEAD
-
-	"go.mongodb.org/mongo-driver/bson"
-	"golang.org/x/crypto/bcrypt"
-=======
->>>>>>> Final_BN
 )
 
 // AuthenRequest represents the structure of the request body
 type AuthenRequest struct {
-<<<<<<< HEAD
-	Email  string `json:"email"`
-	UserID string `json:"userID"`
-	Pass   string `json:"pass"`
-=======
 	Email    string `json:"email"`
 	DeviceID string `json:"DeviceID"`
 	Pass     string `json:"pass"`
->>>>>>> Final_BN
 }
 
-// AuthenDevice function to authenticate device
+// AuthenDevice authenticates a device with its deviceID and password and returns the userID of its
+// current owner with its MQTT topics
+//
+//	POST /authendevice {"deviceID", "password"}
 func AuthenDevice(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 	if r.Method != http.MethodPost { // Allow only POST requests
@@ -45,19 +37,6 @@ func AuthenDevice(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Handle both lowercase and uppercase keys
-	email := deviceDetails["email"]
-	if email == "" {
-		email = deviceDetails["Email"]
-	}
-<<<<<<< HEAD
-	pass := deviceDetails["pass"]
-	if pass == "" {
-		pass = deviceDetails["Pass"]
-	}
-	userID := deviceDetails["userID"]
-	if userID == "" {
-		userID = deviceDetails["UserID"]
-=======
 	pass := deviceDetails["password"]
 	if pass == "" {
 		pass = deviceDetails["Password"]
@@ -65,76 +44,28 @@ func AuthenDevice(w http.ResponseWriter, r *http.Request) {
 	deviceID := deviceDetails["deviceID"]
 	if deviceID == "" {
 		deviceID = deviceDetails["DeviceID"]
->>>>>>> Final_BN
 	}
 
-	// Check if user exists
 	log.Println("Device authentication started")
-<<<<<<< HEAD
-	log.Println("Email: ", email)
-	log.Println("Pass: ", pass)
-	log.Println("UserID: ", userID)
-
-	// TODO use Email find in the database
-	_, err := db.FindUser(email)
-	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
-	} else if err == nil {
-		log.Println("User found")
-	}
-
-	// Hash device details
-	hashedPass, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
-	if err != nil {
-		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
-		return
-	}
-
-	deviceHash := bson.M{
-		"email":  email,
-		"pass":   string(hashedPass),
-		"userID": userID,
-	}
-
-	log.Println("Device hash: ", deviceHash)
-	// Calculate the elapsed time
-=======
-	// log.Println("Email: ", email)
-	// log.Println("Password: ", pass)
-	// log.Println("DeviceID: ", deviceID)
 
-	if email == "" || pass == "" || deviceID == "" {
+	if pass == "" || deviceID == "" {
 		http.Error(w, "Missing required fields", http.StatusBadRequest)
 		return
 	}
 
-	user, err := db.FindUser(email)
+	// The device credentials are enough, the email firmware still sends is ignored. A transferred device
+	// keeps the email of its previous owner and gets the userID of its current one.
+	device, err := db.CheckDeviceCredentials(deviceID, pass)
 	if err != nil {
-		log.Println("Error finding user:", err)
-		http.Error(w, "User not found", http.StatusUnauthorized)
-		return
-	}
-
-	// Authenticate the device using the provided email, password, and deviceID
-	if _, err := db.AuthenDevice(email, pass, deviceID); err != nil {
 		log.Println("Error authenticating device:", err)
 		http.Error(w, "Authentication failed", http.StatusUnauthorized)
 		return
 	}
 
-	// deviceHash := bson.M{
-	// 	"email":  email,
-	// 	"pass":   pass,
-	// 	"userID": userID,
-	// }
-
-	// log.Println("Device hash: ", deviceHash)
-	// Calculate the elapsed time
-
-	response := map[string]string{
+	response := map[string]interface{}{
 		"message": "Device authenticated successfully",
-		"userID":  user.ID,
+		"userID":  device.UserID,
+		"topics":  mosquitto.Topics(device.UserID, device.ID),
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -144,7 +75,6 @@ func AuthenDevice(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Log the elapsed time
->>>>>>> Final_BN
 	elapsedTime := time.Since(startTime)
 	log.Printf("Device Authentication time for  %s\n", elapsedTime)
 }
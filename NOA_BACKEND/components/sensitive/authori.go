@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"strings"
 
+	"GOLANG_SERVER/components/db"
+
 	"github.com/golang-jwt/jwt/v4"
 )
 
@@ -15,17 +17,23 @@ type contextKey string
 
 const userContextKey contextKey = "user"
 
-// VerifyJWT verifies the JWT token
-func VerifyJWT(tokenString string) (jwt.MapClaims, error) {
-	secretKey := []byte("User Login") // ใช้คีย์เดียวกับที่ใช้สร้าง JWT
+// VerifyJWT verifies the signature and expiry of an access token
+func VerifyJWT(tokenString string) (*Claims, error) {
+	claims := &Claims{}
 
 	// Parse the token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		// ตรวจสอบว่าใช้ SigningMethod ที่ถูกต้อง
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		if token.Method != jwt.SigningMethodHS256 {
 			return nil, errors.New("unexpected signing method")
 		}
-		return secretKey, nil
+		// The kid names the key that signed it, rotated keys stay valid while in JWT_KEYS
+		kid, _ := token.Header["kid"].(string)
+		key, ok := findKey(kid)
+		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+		return key.Secret, nil
 	})
 
 	if err != nil {
@@ -33,25 +41,21 @@ func VerifyJWT(tokenString string) (jwt.MapClaims, error) {
 	}
 
 	// ตรวจสอบว่า Token ถูกต้องและไม่หมดอายุ
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return claims, nil
+	if !token.Valid || claims.SessionID == "" || claims.UserID == "" {
+		return nil, errors.New("invalid token")
 	}
-
-	return nil, errors.New("invalid token")
+	return claims, nil
 }
 
-// AuthMiddleware is a middleware for validating JWT
+// AuthMiddleware is a middleware for validating JWT, the session of the token must not be logged out
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
+		tokenString := requestToken(r)
+		if tokenString == "" {
 			http.Error(w, "Authorization header is required", http.StatusUnauthorized)
 			return
 		}
 
-		// แยกคำว่า "Bearer" ออกจาก Token
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
 		// ตรวจสอบ JWT Token
 		claims, err := VerifyJWT(tokenString)
 		if err != nil {
@@ -59,27 +63,68 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Revoked sessions are kept until their refresh token expires, which outlives every access token
+		if revoked(claims) {
+			http.Error(w, "Token revoked", http.StatusUnauthorized)
+			return
+		}
+
 		// เพิ่มข้อมูล Claims ลงใน Context
 		ctx := context.WithValue(r.Context(), userContextKey, claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// requestToken is the bearer token of the Authorization header. Browsers cannot set headers on a
+// WebSocket handshake, so those may pass it as the token query parameter instead.
+func requestToken(r *http.Request) string {
+	if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+		// แยกคำว่า "Bearer" ออกจาก Token
+		return strings.TrimPrefix(authHeader, "Bearer ")
+	}
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return r.URL.Query().Get("token")
+	}
+	return ""
+}
+
+// UserID is the user of the access token AuthMiddleware verified, empty without one
+func UserID(r *http.Request) string {
+	if claims, ok := ClaimsFrom(r); ok {
+		return claims.UserID
+	}
+	return ""
+}
+
+// revoked reports whether the session of the token was logged out or no longer exists
+func revoked(claims *Claims) bool {
+	session, err := db.FindSession(claims.SessionID)
+	if err != nil {
+		return true
+	}
+	return session.RevokedAt != nil || session.UserID != claims.UserID
+}
+
+// ClaimsFrom returns the claims AuthMiddleware added to the request
+func ClaimsFrom(r *http.Request) (*Claims, bool) {
+	claims, ok := r.Context().Value(userContextKey).(*Claims)
+	return claims, ok
+}
+
 func ProtectedResource(w http.ResponseWriter, r *http.Request) {
 	// ดึง Claims จาก Context
-	claims, ok := r.Context().Value(userContextKey).(jwt.MapClaims)
+	claims, ok := ClaimsFrom(r)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	// ใช้ข้อมูลจาก Claims
-	username := claims["username"].(string)
-	log.Println("Accessing protected resource for user:", username)
+	log.Println("Accessing protected resource for user:", claims.Username)
 
 	response := map[string]string{
-		"message": "Welcome " + username,
-		"userID":  claims["userID"].(string),
+		"message": "Welcome " + claims.Username,
+		"userID":  claims.UserID,
 	}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
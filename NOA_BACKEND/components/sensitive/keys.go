@@ -0,0 +1,74 @@
+package sensitive
+
+import (
+	"crypto/rand"
+	"log"
+	"strings"
+	"sync"
+
+	"GOLANG_SERVER/components/env"
+)
+
+// signingKey is one HMAC key of the key set, named by the kid header of the tokens it signs
+type signingKey struct {
+	ID     string
+	Secret []byte
+}
+
+var (
+	keysOnce  sync.Once
+	activeKey signingKey            // Signs new tokens
+	keySet    map[string]signingKey // Verifies tokens, key: kid
+)
+
+// loadKeys reads JWT_KEYS, a comma separated list of kid:secret where the first key signs and every key verifies.
+// To rotate, put the new key first and drop the old one once its tokens expired.
+// JWT_SECRET is a single key with kid "default". Without either, a random key is used.
+func loadKeys() {
+	keySet = make(map[string]signingKey)
+	for _, entry := range strings.Split(env.GetEnv("JWT_KEYS"), ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || id == "" || secret == "" {
+			if entry != "" {
+				log.Println("[JWT] Ignoring malformed key in JWT_KEYS, expected kid:secret")
+			}
+			continue
+		}
+		addKey(signingKey{ID: id, Secret: []byte(secret)})
+	}
+
+	if len(keySet) == 0 {
+		if secret := env.GetEnv("JWT_SECRET"); secret != "" {
+			addKey(signingKey{ID: "default", Secret: []byte(secret)})
+		}
+	}
+
+	if len(keySet) == 0 {
+		log.Println("[JWT] JWT_KEYS and JWT_SECRET are not set, tokens are lost on restart and not shared between servers")
+		secret := make([]byte, 32)
+		rand.Read(secret)
+		addKey(signingKey{ID: "random", Secret: secret})
+	}
+	log.Printf("[JWT] Signing with key %q, %d keys accepted\n", activeKey.ID, len(keySet))
+}
+
+// addKey adds a key to the set, the first key added becomes the active one
+func addKey(key signingKey) {
+	if len(keySet) == 0 {
+		activeKey = key
+	}
+	keySet[key.ID] = key
+}
+
+// currentKey is the key signing new tokens
+func currentKey() signingKey {
+	keysOnce.Do(loadKeys)
+	return activeKey
+}
+
+// findKey is the key with the kid, false when it is not in the set
+func findKey(id string) (signingKey, bool) {
+	keysOnce.Do(loadKeys)
+	key, ok := keySet[id]
+	return key, ok
+}
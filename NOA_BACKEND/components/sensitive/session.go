@@ -0,0 +1,201 @@
+package sensitive
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/env"
+	"GOLANG_SERVER/components/schema"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultAccessTTL  = 15 * time.Minute    // Lifetime of an access token when JWT_ACCESS_TTL is not set
+	DefaultRefreshTTL = 30 * 24 * time.Hour // Lifetime of a refresh token when JWT_REFRESH_TTL is not set
+)
+
+var (
+	ErrInvalidRefresh = errors.New("invalid refresh token")
+	ErrRefreshReused  = errors.New("refresh token already used, the session is revoked")
+	ErrSessionRevoked = errors.New("session revoked")
+)
+
+// Claims are the claims of an access token
+type Claims struct {
+	UserID    string `json:"userID"`
+	Username  string `json:"username"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// Tokens are returned on login and refresh
+type Tokens struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType"`
+	ExpiresIn    int    `json:"expiresIn"` // Seconds until the access token expires
+	SessionID    string `json:"sessionID"`
+}
+
+// ttlEnv reads a Go duration such as "15m" from the environment variable
+func ttlEnv(key string, fallback time.Duration) time.Duration {
+	value := env.GetEnv(key)
+	if value == "" {
+		return fallback
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		log.Println("Invalid", key+", using", fallback)
+		return fallback
+	}
+	return ttl
+}
+
+// newRefreshSecret returns a random refresh secret and its hash
+func newRefreshSecret() (string, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	secret := hex.EncodeToString(raw)
+	return secret, hashRefresh(secret), nil
+}
+
+// hashRefresh is the hex SHA-256 of a refresh secret, the secret itself is never stored
+func hashRefresh(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// sameHash compares two hashes in constant time
+func sameHash(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// StartSession creates a session for the user and returns its first tokens
+func StartSession(user schema.User, userAgent, ip string) (Tokens, error) {
+	secret, hash, err := newRefreshSecret()
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	now := time.Now().Truncate(time.Millisecond)
+	session := schema.Session{
+		ID:          uuid.New().String(),
+		UserID:      user.ID,
+		RefreshHash: hash,
+		UserAgent:   userAgent,
+		IP:          ip,
+		CreateAt:    now,
+		RefreshAt:   now,
+		ExpireAt:    now.Add(ttlEnv("JWT_REFRESH_TTL", DefaultRefreshTTL)),
+	}
+	if err := db.CreateSession(session); err != nil {
+		return Tokens{}, err
+	}
+	return issueTokens(user, session.ID, secret)
+}
+
+// RefreshSession exchanges a refresh token for new tokens. The refresh token is rotated, presenting an old one
+// again revokes the whole session since it was likely stolen.
+func RefreshSession(refreshToken string) (Tokens, error) {
+	// A refresh token is sessionID.secret
+	sessionID, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok || sessionID == "" || secret == "" {
+		return Tokens{}, ErrInvalidRefresh
+	}
+
+	session, err := db.FindSession(sessionID)
+	if err == db.ErrNotFound {
+		return Tokens{}, ErrInvalidRefresh
+	}
+	if err != nil {
+		return Tokens{}, err
+	}
+	if session.RevokedAt != nil {
+		return Tokens{}, ErrSessionRevoked
+	}
+
+	hash := hashRefresh(secret)
+	if session.PreviousHash != "" && sameHash(hash, session.PreviousHash) {
+		log.Println("[JWT] Refresh token reused, revoking session:", session.ID)
+		if err := db.RevokeSession(session.UserID, session.ID); err != nil {
+			return Tokens{}, err
+		}
+		return Tokens{}, ErrRefreshReused
+	}
+	if !sameHash(hash, session.RefreshHash) || time.Now().After(session.ExpireAt) {
+		return Tokens{}, ErrInvalidRefresh
+	}
+
+	user, err := db.FindUserID(session.UserID)
+	if err != nil {
+		return Tokens{}, ErrInvalidRefresh
+	}
+
+	newSecret, newHash, err := newRefreshSecret()
+	if err != nil {
+		return Tokens{}, err
+	}
+	expireAt := time.Now().Truncate(time.Millisecond).Add(ttlEnv("JWT_REFRESH_TTL", DefaultRefreshTTL))
+	if err := db.RotateSession(session.ID, session.RefreshHash, newHash, expireAt); err != nil {
+		// Another request rotated the same token first
+		if err == db.ErrNotFound {
+			return Tokens{}, ErrInvalidRefresh
+		}
+		return Tokens{}, err
+	}
+	return issueTokens(*user, session.ID, newSecret)
+}
+
+// EndSession logs out the session of the access token
+func EndSession(claims *Claims) error {
+	return db.RevokeSession(claims.UserID, claims.SessionID)
+}
+
+// EndAllSessions logs out every session of the user and returns how many were active
+func EndAllSessions(userID string) (int64, error) {
+	return db.RevokeSessions(userID)
+}
+
+// issueTokens signs an access token for the session and pairs it with the refresh secret
+func issueTokens(user schema.User, sessionID, refreshSecret string) (Tokens, error) {
+	ttl := ttlEnv("JWT_ACCESS_TTL", DefaultAccessTTL)
+	now := time.Now()
+	claims := Claims{
+		UserID:    user.ID,
+		Username:  user.Username,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   user.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	key := currentKey()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = key.ID
+	accessToken, err := token.SignedString(key.Secret)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: sessionID + "." + refreshSecret,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(ttl.Seconds()),
+		SessionID:    sessionID,
+	}, nil
+}
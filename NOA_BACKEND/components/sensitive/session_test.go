@@ -0,0 +1,180 @@
+package sensitive
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/schema"
+)
+
+// useKeys reloads the key set from JWT_KEYS
+func useKeys(t *testing.T, keys string) {
+	t.Helper()
+	t.Setenv("JWT_KEYS", keys)
+	keysOnce = sync.Once{}
+}
+
+func newSession(t *testing.T) Tokens {
+	t.Helper()
+	db.UseStore(db.NewMemoryStore())
+	if err := db.GetStore().InsertUser(schema.User{ID: "userA", Username: "a", Email: "a@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := StartSession(schema.User{ID: "userA", Username: "a"}, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+// authorize calls a handler behind AuthMiddleware with the access token
+func authorize(accessToken string) int {
+	handler := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/api/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestRefreshRotates(t *testing.T) {
+	useKeys(t, "k1:secret1")
+	first := newSession(t)
+
+	second, err := RefreshSession(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.SessionID != first.SessionID || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh did not rotate: %+v %+v", first, second)
+	}
+	third, err := RefreshSession(second.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := authorize(third.AccessToken); code != http.StatusOK {
+		t.Fatalf("rotated access token: %d", code)
+	}
+
+	// Only the last two secrets are remembered, an older one is just invalid
+	if _, err := RefreshSession(first.RefreshToken); err != ErrInvalidRefresh {
+		t.Fatalf("refresh with the first token: %v", err)
+	}
+	for _, token := range []string{"", "no-dot", first.SessionID + ".", "unknown.secret", first.SessionID + ".wrong"} {
+		if _, err := RefreshSession(token); err != ErrInvalidRefresh {
+			t.Fatalf("refresh with %q: %v", token, err)
+		}
+	}
+	if code := authorize(third.AccessToken); code != http.StatusOK {
+		t.Fatalf("invalid refresh tokens revoked the session: %d", code)
+	}
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	useKeys(t, "k1:secret1")
+	first := newSession(t)
+	second, err := RefreshSession(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaying the rotated token means it leaked, the whole session goes
+	if _, err := RefreshSession(first.RefreshToken); err != ErrRefreshReused {
+		t.Fatalf("replayed refresh token: %v", err)
+	}
+	if _, err := RefreshSession(second.RefreshToken); err != ErrSessionRevoked {
+		t.Fatalf("refresh after reuse: %v", err)
+	}
+	for _, accessToken := range []string{first.AccessToken, second.AccessToken} {
+		if code := authorize(accessToken); code != http.StatusUnauthorized {
+			t.Fatalf("access token of the revoked session: %d", code)
+		}
+	}
+}
+
+func TestRevokedTokenRejected(t *testing.T) {
+	useKeys(t, "k1:secret1")
+	tokens := newSession(t)
+	if code := authorize(tokens.AccessToken); code != http.StatusOK {
+		t.Fatalf("new session: %d", code)
+	}
+	claims, err := VerifyJWT(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := EndSession(claims); err != nil {
+		t.Fatal(err)
+	}
+	// The token is still signed and unexpired, only the session says no
+	if _, err := VerifyJWT(tokens.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+	if code := authorize(tokens.AccessToken); code != http.StatusUnauthorized {
+		t.Fatalf("logged out session: %d", code)
+	}
+	if _, err := RefreshSession(tokens.RefreshToken); err != ErrSessionRevoked {
+		t.Fatalf("refresh of a logged out session: %v", err)
+	}
+
+	// Nor does a request without a token
+	if code := authorize(""); code != http.StatusUnauthorized {
+		t.Fatalf("no token: %d", code)
+	}
+}
+
+// kid reads the key ID from the header of a token
+func kid(t *testing.T, token string) string {
+	t.Helper()
+	header, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(header, &fields); err != nil {
+		t.Fatal(err)
+	}
+	id, _ := fields["kid"].(string)
+	return id
+}
+
+func TestKeyRotation(t *testing.T) {
+	useKeys(t, "old:secret1")
+	old := newSession(t)
+	if kid(t, old.AccessToken) != "old" {
+		t.Fatalf("signed with %q", kid(t, old.AccessToken))
+	}
+
+	// The new key signs, the old one still verifies
+	useKeys(t, "new:secret2, old:secret1")
+	if code := authorize(old.AccessToken); code != http.StatusOK {
+		t.Fatalf("token of the previous key: %d", code)
+	}
+	rotated, err := RefreshSession(old.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid(t, rotated.AccessToken) != "new" {
+		t.Fatalf("refreshed token signed with %q", kid(t, rotated.AccessToken))
+	}
+
+	// Once dropped, the old key verifies nothing, and a kid cannot pick another key's secret
+	useKeys(t, "new:secret2")
+	if code := authorize(old.AccessToken); code != http.StatusUnauthorized {
+		t.Fatalf("token of a dropped key: %d", code)
+	}
+	if code := authorize(rotated.AccessToken); code != http.StatusOK {
+		t.Fatalf("token of the new key: %d", code)
+	}
+	useKeys(t, "old:secret2")
+	if code := authorize(rotated.AccessToken); code != http.StatusUnauthorized {
+		t.Fatalf("token verified under another kid: %d", code)
+	}
+}
@@ -0,0 +1,96 @@
+package severity
+
+import (
+	"errors"
+	"math"
+
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// Zones of ISO 10816-3
+const (
+	ZoneA = "A" // Newly commissioned machines
+	ZoneB = "B" // Unrestricted long term operation
+	ZoneC = "C" // Restricted operation, plan maintenance
+	ZoneD = "D" // Severe enough to cause damage
+)
+
+// Foundations of a machine
+const (
+	Rigid    = "rigid"
+	Flexible = "flexible"
+)
+
+// DefaultClass is used for devices without a machine class: group 2 on a rigid foundation,
+// the lowest limits of the standard
+var DefaultClass = schema.MachineClass{Group: 2, Foundation: Rigid}
+
+// limits are the A/B, B/C and C/D boundaries in mm/s RMS of ISO 10816-3 table A.1 and A.2
+var limits = map[schema.MachineClass][3]float64{
+	{Group: 1, Foundation: Rigid}:    {2.3, 4.5, 7.1},
+	{Group: 1, Foundation: Flexible}: {3.5, 7.1, 11.0},
+	{Group: 2, Foundation: Rigid}:    {1.4, 2.8, 4.5},
+	{Group: 2, Foundation: Flexible}: {2.3, 4.5, 7.1},
+}
+
+// Validate checks a machine class: group 1 (above 300 kW) or 2 (15 to 300 kW), rigid or flexible foundation
+func Validate(class schema.MachineClass) error {
+	if class.Group != 1 && class.Group != 2 {
+		return errors.New("group must be 1 or 2")
+	}
+	if class.Foundation != Rigid && class.Foundation != Flexible {
+		return errors.New("foundation must be rigid or flexible")
+	}
+	return nil
+}
+
+// Limits returns the zone boundaries of a class, DefaultClass when the class is not set
+func Limits(class schema.MachineClass) [3]float64 {
+	if l, ok := limits[class]; ok {
+		return l
+	}
+	return limits[DefaultClass]
+}
+
+// Velocity is the overall RMS velocity in mm/s. ISO 10816-3 evaluates the highest of the
+// directions measured, so it is the largest VibrationSpeed of the three axes on purpose, not their combined RMS.
+func Velocity(data schema.GyroDataDetail) float64 {
+	return math.Max(math.Abs(data.X.VibrationSpeed), math.Max(math.Abs(data.Y.VibrationSpeed), math.Abs(data.Z.VibrationSpeed)))
+}
+
+// Zone maps an RMS velocity in mm/s to its zone for the class
+func Zone(velocity float64, class schema.MachineClass) string {
+	l := Limits(class)
+	switch {
+	case velocity < l[0]:
+		return ZoneA
+	case velocity < l[1]:
+		return ZoneB
+	case velocity < l[2]:
+		return ZoneC
+	default:
+		return ZoneD
+	}
+}
+
+// Order is the Frequency of the axis with the largest VibrationSpeed in multiples of the running speed
+// ratedRPM/60, 0 when the rated speed is unknown. Around 1 points to unbalance, around 2 to misalignment.
+func Order(data schema.GyroDataDetail, ratedRPM float64) float64 {
+	if ratedRPM <= 0 {
+		return 0
+	}
+	dominant := data.X
+	for _, axis := range []schema.AxisData{data.Y, data.Z} {
+		if math.Abs(axis.VibrationSpeed) > math.Abs(dominant.VibrationSpeed) {
+			dominant = axis
+		}
+	}
+	return dominant.Frequency / (ratedRPM / 60)
+}
+
+// Classify sets the overall velocity and zone of the telemetry, and its order from the profile of the device
+func Classify(data *schema.GyroData, class schema.MachineClass, profile schema.DeviceProfile) {
+	data.Velocity = Velocity(data.Data)
+	data.Zone = Zone(data.Velocity, class)
+	data.Order = Order(data.Data, profile.RatedRPM)
+}
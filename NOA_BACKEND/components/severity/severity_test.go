@@ -0,0 +1,124 @@
+package severity
+
+import (
+	"math"
+	"testing"
+
+	schema "GOLANG_SERVER/components/schema"
+)
+
+func TestZoneBoundaries(t *testing.T) {
+	// A/B, B/C and C/D of ISO 10816-3, a value on a boundary is in the zone above it
+	tests := []struct {
+		class  schema.MachineClass
+		limits [3]float64
+	}{
+		{schema.MachineClass{Group: 1, Foundation: Rigid}, [3]float64{2.3, 4.5, 7.1}},
+		{schema.MachineClass{Group: 1, Foundation: Flexible}, [3]float64{3.5, 7.1, 11.0}},
+		{schema.MachineClass{Group: 2, Foundation: Rigid}, [3]float64{1.4, 2.8, 4.5}},
+		{schema.MachineClass{Group: 2, Foundation: Flexible}, [3]float64{2.3, 4.5, 7.1}},
+	}
+	zones := []string{ZoneA, ZoneB, ZoneC, ZoneD}
+	for _, tt := range tests {
+		if got := Limits(tt.class); got != tt.limits {
+			t.Fatalf("%+v: limits = %v, want %v", tt.class, got, tt.limits)
+		}
+		if got := Zone(0, tt.class); got != ZoneA {
+			t.Fatalf("%+v: zone at rest = %s", tt.class, got)
+		}
+		for i, limit := range tt.limits {
+			below := math.Nextafter(limit, 0)
+			if got := Zone(below, tt.class); got != zones[i] {
+				t.Errorf("%+v: zone just below %v = %s, want %s", tt.class, limit, got, zones[i])
+			}
+			if got := Zone(limit, tt.class); got != zones[i+1] {
+				t.Errorf("%+v: zone at %v = %s, want %s", tt.class, limit, got, zones[i+1])
+			}
+		}
+	}
+}
+
+func TestLimitsDefault(t *testing.T) {
+	for _, class := range []schema.MachineClass{{}, {Group: 3, Foundation: Rigid}, {Group: 1}} {
+		if got := Limits(class); got != Limits(DefaultClass) {
+			t.Fatalf("%+v: limits = %v, want those of the default class", class, got)
+		}
+	}
+	if Zone(1.4, schema.MachineClass{}) != ZoneB {
+		t.Fatal("unset class is not zoned as group 2 rigid")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tt := range []struct {
+		class schema.MachineClass
+		ok    bool
+	}{
+		{schema.MachineClass{Group: 1, Foundation: Rigid}, true},
+		{schema.MachineClass{Group: 2, Foundation: Flexible}, true},
+		{schema.MachineClass{Group: 0, Foundation: Rigid}, false},
+		{schema.MachineClass{Group: 2, Foundation: "soft"}, false},
+	} {
+		if err := Validate(tt.class); (err == nil) != tt.ok {
+			t.Fatalf("%+v: %v", tt.class, err)
+		}
+	}
+}
+
+func detail(x, y, z schema.AxisData) schema.GyroDataDetail {
+	var d schema.GyroDataDetail
+	d.X, d.Y, d.Z = x, y, z
+	return d
+}
+
+func TestVelocity(t *testing.T) {
+	tests := []struct {
+		name    string
+		x, y, z float64
+		want    float64
+	}{
+		{"at rest", 0, 0, 0, 0},
+		{"largest axis, not the combined RMS", 3, 4, 0, 4},
+		{"any axis", 1, 2, 5.5, 5.5},
+		{"sign ignored", -6, 2, 1, 6},
+	}
+	for _, tt := range tests {
+		d := detail(schema.AxisData{VibrationSpeed: tt.x}, schema.AxisData{VibrationSpeed: tt.y}, schema.AxisData{VibrationSpeed: tt.z})
+		if got := Velocity(d); got != tt.want {
+			t.Errorf("%s: velocity = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOrder(t *testing.T) {
+	// The frequency of the axis with the largest speed, in multiples of 1500 rpm = 25 Hz
+	d := detail(
+		schema.AxisData{VibrationSpeed: 1, Frequency: 100},
+		schema.AxisData{VibrationSpeed: -3, Frequency: 50},
+		schema.AxisData{VibrationSpeed: 2, Frequency: 25},
+	)
+	tests := []struct {
+		name     string
+		ratedRPM float64
+		want     float64
+	}{
+		{"unknown speed", 0, 0},
+		{"negative speed", -1500, 0},
+		{"misalignment", 1500, 2},
+		{"unbalance", 3000, 1},
+	}
+	for _, tt := range tests {
+		if got := Order(d, tt.ratedRPM); got != tt.want {
+			t.Errorf("%s: order = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	data := schema.GyroData{}
+	data.Data = detail(schema.AxisData{VibrationSpeed: 4.5, Frequency: 50}, schema.AxisData{}, schema.AxisData{})
+	Classify(&data, schema.MachineClass{Group: 1, Foundation: Rigid}, schema.DeviceProfile{RatedRPM: 3000})
+	if data.Velocity != 4.5 || data.Zone != ZoneC || data.Order != 1 {
+		t.Fatalf("classified %v %s %v", data.Velocity, data.Zone, data.Order)
+	}
+}
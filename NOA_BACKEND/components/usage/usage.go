@@ -0,0 +1,138 @@
+package usage
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/ingest"
+	schema "GOLANG_SERVER/components/schema"
+
+	"github.com/google/uuid"
+)
+
+const (
+	flushInterval = time.Minute // How often the counters kept in memory are added to the usage collection
+	stopRetries   = 3           // Flushes retried on shutdown while batches fail
+	stopDelay     = time.Second // Wait between the flushes on shutdown
+)
+
+// Location is the time zone days and hours are metered in
+var Location = loadLocation()
+
+func loadLocation() *time.Location {
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		return time.FixedZone("ICT", 7*60*60)
+	}
+	return loc
+}
+
+// batch is counters flushed together, retried with the same ID until saved
+type batch struct {
+	id    string
+	usage schema.DeviceUsage
+}
+
+// pending holds the counters not yet flushed, key: deviceID + "/" + day, and the batches that failed to save
+var pending = struct {
+	sync.Mutex
+	days   map[string]*schema.DeviceUsage
+	failed []batch
+}{days: make(map[string]*schema.DeviceUsage)}
+
+// Start meters every ingested message and flushes the counters every minute
+func Start() {
+	// Billing needs every message, the handler only increments counters in memory
+	ingest.Subscribe("usage", 1024, ingest.Block, func(msg ingest.Message) {
+		hour := msg.Received.In(Location).Hour()
+		record(msg.Data.UserID, msg.Data.DeviceID, msg.Received, func(day *schema.DeviceUsage) {
+			day.Messages++
+			day.Bytes += int64(len(msg.Payload))
+			day.Hours |= 1 << hour
+		})
+	})
+	go flushLoop()
+}
+
+// RecordPrediction counts a prediction call for a device
+func RecordPrediction(userID, deviceID string) {
+	record(userID, deviceID, time.Now(), func(day *schema.DeviceUsage) {
+		day.Predictions++
+	})
+}
+
+// record updates the counters of the device for the day of at
+func record(userID, deviceID string, at time.Time, update func(*schema.DeviceUsage)) {
+	at = at.In(Location)
+	dayKey := at.Format(db.UsageDayLayout)
+
+	pending.Lock()
+	defer pending.Unlock()
+
+	day, ok := pending.days[deviceID+"/"+dayKey]
+	if !ok {
+		day = &schema.DeviceUsage{DeviceID: deviceID, UserID: userID, Day: dayKey}
+		pending.days[deviceID+"/"+dayKey] = day
+	}
+	update(day)
+}
+
+func flushLoop() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		Flush()
+	}
+}
+
+// Flush adds the counters kept in memory to the usage collection
+func Flush() {
+	pending.Lock()
+	batches := pending.failed
+	for _, day := range pending.days {
+		batches = append(batches, batch{id: uuid.New().String(), usage: *day})
+	}
+	pending.days = make(map[string]*schema.DeviceUsage)
+	pending.failed = nil
+	pending.Unlock()
+
+	var failed []batch
+	for _, b := range batches {
+		if err := db.AddUsage(b.id, b.usage); err != nil {
+			log.Println("Error saving usage:", err)
+
+			// Keep the batch as is, part of it may be saved already and the ID lets the store skip that part
+			failed = append(failed, b)
+		}
+	}
+	if len(failed) > 0 {
+		pending.Lock()
+		pending.failed = append(pending.failed, failed...)
+		pending.Unlock()
+	}
+}
+
+// Stop flushes the counters before the server exits, retrying the batches that fail a few times.
+// Batches still failing are logged, since the counters are lost with the process.
+func Stop() {
+	for retry := 0; ; retry++ {
+		Flush()
+
+		pending.Lock()
+		failed := pending.failed
+		pending.Unlock()
+		if len(failed) == 0 {
+			return
+		}
+		if retry == stopRetries {
+			for _, b := range failed {
+				log.Printf("Usage lost on shutdown: batch %s %+v", b.id, b.usage)
+			}
+			return
+		}
+		time.Sleep(stopDelay)
+	}
+}
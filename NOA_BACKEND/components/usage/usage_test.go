@@ -0,0 +1,79 @@
+package usage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// lostAck saves usage but reports the first failures, like a timeout after the write
+type lostAck struct {
+	*db.MemoryStore
+	failures int
+}
+
+func (s *lostAck) AddUsage(batchID string, usage schema.DeviceUsage) error {
+	if err := s.MemoryStore.AddUsage(batchID, usage); err != nil {
+		return err
+	}
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("timeout")
+	}
+	return nil
+}
+
+func TestFlushRetryCountsOnce(t *testing.T) {
+	store := &lostAck{MemoryStore: db.NewMemoryStore(), failures: 2}
+	db.UseStore(store)
+	if err := db.SaveDevice("machine", "deviceA", "userA", "hash"); err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2026, 3, 2, 9, 0, 0, 0, Location)
+	for i := 0; i < 3; i++ {
+		record("userA", "deviceA", at, func(day *schema.DeviceUsage) { day.Messages++ })
+	}
+	Flush()
+	Flush()
+	// New counters for the same day after a failure are a batch of their own
+	record("userA", "deviceA", at, func(day *schema.DeviceUsage) { day.Messages++ })
+	Flush()
+	Flush()
+
+	days, err := db.GetStore().UsageByDevice("deviceA", "2026-03-02", "2026-03-02")
+	if err != nil || len(days) != 1 || days[0].Messages != 4 {
+		t.Fatalf("usage after retries: %+v %v", days, err)
+	}
+	device, err := db.FindDevice("deviceA")
+	if err != nil || device.Usage != 4 {
+		t.Fatalf("device usage after retries: %d %v", device.Usage, err)
+	}
+	if len(pending.failed) != 0 || len(pending.days) != 0 {
+		t.Fatalf("left to flush: %v %v", pending.failed, pending.days)
+	}
+}
+
+func TestStopFlushesAndRetries(t *testing.T) {
+	store := &lostAck{MemoryStore: db.NewMemoryStore(), failures: 1}
+	db.UseStore(store)
+	if err := db.SaveDevice("machine", "deviceA", "userA", "hash"); err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2026, 3, 2, 9, 0, 0, 0, Location)
+	record("userA", "deviceA", at, func(day *schema.DeviceUsage) { day.Messages += 5 })
+	record("userA", "deviceA", at.AddDate(0, 0, 1), func(day *schema.DeviceUsage) { day.Messages += 2 })
+	Stop()
+
+	days, err := db.GetStore().UsageByDevice("deviceA", "2026-03-02", "2026-03-03")
+	if err != nil || len(days) != 2 || days[0].Messages+days[1].Messages != 7 {
+		t.Fatalf("usage after stop: %+v %v", days, err)
+	}
+	if len(pending.failed) != 0 || len(pending.days) != 0 {
+		t.Fatalf("left after stop: %v %v", pending.failed, pending.days)
+	}
+}
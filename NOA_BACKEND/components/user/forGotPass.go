@@ -2,12 +2,17 @@ package user
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"net/url"
 
 	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/env"
+	"GOLANG_SERVER/components/otp"
 )
 
-// ForgotPassword sends an OTP to the user's email
+// ForgotPassword sends an OTP to the user's email, or a link with it when RESET_PASSWORD_URL is set
 func ForgotPasswordReq(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost { // Allow only POST requests
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
@@ -29,26 +34,59 @@ func ForgotPasswordReq(w http.ResponseWriter, r *http.Request) {
 		email = userDetails["Email"]
 	}
 
+	// Unknown emails get the same answer, so the route does not tell which emails have an account
+	response := map[string]string{"message": "If the email has an account, a reset code was sent to it"}
+
 	// Check if user exists
-	_, err := db.ForgotpasswordCheck(email)
+	if _, err := db.ForgotpasswordCheck(email); err != nil {
+		log.Println("Password reset for unknown email:", email)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// A code sent less than a minute ago stays the only valid one
+	code, err := otp.Issue(email, otp.PurposeReset)
+	var cooldown *otp.CooldownError
+	if errors.As(err, &cooldown) {
+		log.Println("Password reset requested again during cooldown:", email)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Send OTP to the user's email
-	otp := GenerateOTP()
-	SendOTPEmail(email, otp)
-
-	// save OTP
-	SaveOTP(email, otp)
-
-	// Send a response
-	response := map[string]string{"message": "Login successful", "otp": otp}
+	if err := SendPasswordResetEmail(email, code, resetLink(email, code), requestLanguage(userDetails, r.Header.Get("Accept-Language"))); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
+	// Send a response, the OTP is only in the email
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
+
+// resetLink is the page of RESET_PASSWORD_URL with the email and OTP as query parameters, empty when not set
+func resetLink(email, code string) string {
+	page := env.GetEnv("RESET_PASSWORD_URL")
+	if page == "" {
+		return ""
+	}
+	link, err := url.Parse(page)
+	if err != nil {
+		log.Println("Invalid RESET_PASSWORD_URL:", err)
+		return ""
+	}
+	query := link.Query()
+	query.Set("email", email)
+	query.Set("otp", code)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
@@ -2,10 +2,12 @@ package user
 
 import (
 	"encoding/json"
+	"io"
 	"log"
 	"net/http"
 
 	"GOLANG_SERVER/components/db" // Import the db package
+	"GOLANG_SERVER/components/sensitive"
 )
 
 // UserResponse defines the structure of the user response without the password
@@ -25,7 +27,7 @@ func GetUserByUserID(w http.ResponseWriter, r *http.Request) {
 
 	// Parse the request body to get user ID
 	var userDetails map[string]string
-	if err := json.NewDecoder(r.Body).Decode(&userDetails); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&userDetails); err != nil && err != io.EOF {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -36,9 +38,17 @@ func GetUserByUserID(w http.ResponseWriter, r *http.Request) {
 		userID = userDetails["UserID"]
 	}
 
-	// Check if user ID is provided
+	// Only the user of the token can be read, the userID defaults to it
+	tokenUserID := sensitive.UserID(r)
+	if tokenUserID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 	if userID == "" {
-		http.Error(w, "User ID is required", http.StatusBadRequest)
+		userID = tokenUserID
+	}
+	if userID != tokenUserID {
+		http.Error(w, "userID does not match the token", http.StatusForbidden)
 		return
 	}
 
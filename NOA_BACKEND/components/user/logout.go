@@ -0,0 +1,102 @@
+package user
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"GOLANG_SERVER/components/sensitive"
+)
+
+// RefreshToken exchanges a refresh token for a new access token and refresh token
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost { // Allow only POST requests
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse the request body to get the refresh token
+	var body map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := sensitive.RefreshSession(body["refreshToken"])
+	switch err {
+	case nil:
+	case sensitive.ErrInvalidRefresh, sensitive.ErrRefreshReused, sensitive.ErrSessionRevoked:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(tokens); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// Logout logs out the session of the access token, behind sensitive.AuthMiddleware
+func Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost { // Allow only POST requests
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	claims, ok := sensitive.ClaimsFrom(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := sensitive.EndSession(claims); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Println("User logged out:", claims.UserID, claims.SessionID)
+
+	response := map[string]string{"message": "Logged out"}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// LogoutAll logs out every session of the user of the access token, behind sensitive.AuthMiddleware
+func LogoutAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost { // Allow only POST requests
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	claims, ok := sensitive.ClaimsFrom(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	count, err := sensitive.EndAllSessions(claims.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Println("User logged out everywhere:", claims.UserID, count)
+
+	response := map[string]interface{}{"message": "Logged out of every device", "sessions": count}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
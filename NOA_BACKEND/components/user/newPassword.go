@@ -1,6 +1,74 @@
 package user
 
-// NewPasswordReq sends a new password to the user's email
-func NewPasswordReq() {
+import (
+	"encoding/json"
+	"log"
+	"net/http"
 
+	"GOLANG_SERVER/components/db"
+)
+
+// NewPasswordReq sets a new password with a reset token and signs the user out everywhere
+func NewPasswordReq(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost { // Allow only POST requests
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse the request body to get user details
+	var userDetails map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&userDetails); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Handle both lowercase and uppercase keys
+	token := userDetails["resetToken"]
+	if token == "" {
+		token = userDetails["ResetToken"]
+	}
+	password := userDetails["password"]
+	if password == "" {
+		password = userDetails["Password"]
+	}
+
+	// Validate before using the token, so a weak password does not burn it
+	if !ValidatePassword(password) {
+		http.Error(w, "Password must be at least 8 characters long and contain at least one letter and one number", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := db.UsePasswordReset(token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	user, err := db.FindUserID(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := db.ForgotpasswordNewPassword(user.Email, password); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Every session logged in with the old password is logged out
+	if _, err := db.RevokeSessions(userID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Println("Password reset:", userID)
+
+	// Send a response
+	response := map[string]string{"message": "Password changed, please log in again"}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 }
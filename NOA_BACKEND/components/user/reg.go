@@ -4,19 +4,15 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
-<<<<<<< HEAD
-=======
 	"regexp"
->>>>>>> Final_BN
 
 	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/otp"
 	"GOLANG_SERVER/components/schema"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
-<<<<<<< HEAD
-=======
 // ValidateEmail checks if the email format is valid
 func ValidateEmail(email string) bool {
 	// Regular expression for validating email format
@@ -39,7 +35,6 @@ func ValidatePassword(password string) bool {
 	return hasLetter && hasDigit
 }
 
->>>>>>> Final_BN
 // Register handles user registration
 func Register(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost { // Allow only POST requests
@@ -57,13 +52,10 @@ func Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Handle both lowercase and uppercase keys
-<<<<<<< HEAD
-=======
 	username := userDetails["username"]
 	if username == "" {
 		username = userDetails["Username"]
 	}
->>>>>>> Final_BN
 	email := userDetails["email"]
 	if email == "" {
 		email = userDetails["Email"]
@@ -73,10 +65,6 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		password = userDetails["Password"]
 	}
 
-<<<<<<< HEAD
-	// Declare otp variable outside the if block
-	var otp string
-=======
 	if !ValidateEmail(email) {
 		http.Error(w, "Invalid email format", http.StatusBadRequest)
 		return
@@ -86,100 +74,74 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Password must be at least 8 characters long and contain at least one letter and one number", http.StatusBadRequest)
 		return
 	}
->>>>>>> Final_BN
 
-	// Hash the password
+	if username == "" {
+		http.Error(w, "Username is required", http.StatusBadRequest)
+		return
+	}
+
+	// Only users that finished the registration own their email
+	registered, err := db.Registered(email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if registered {
+		http.Error(w, "Email already exists", http.StatusBadRequest)
+		return
+	}
+
+	// Hash the password, it never leaves the server
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Convert the user details to a User struct
-<<<<<<< HEAD
-	user := schema.User{
+	// A registration waiting for the email keeps its credentials, the code sent below activates it
+	pending, created, err := db.CreatePendingUser(schema.PendingUser{
 		Email:    email,
+		Username: username,
 		Password: string(hashedPassword),
-	}
-
-	// Save user details to database
-	if _, err := db.StoreUser(user); err != nil {
+	})
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
-	} else {
-		log.Println("User registered successfully.")
-		// Generate OTP and assign it to the otp variable
-		otp = GenerateOTP()
-		SendOTPEmail(email, otp)
-		// Save the OTP in the database
-		SaveOTP(email, otp)
-=======
-	// user := schema.User{
-	//     Username: username,
-	//     Email:    email,
-	//     Password: string(hashedPassword),
-	// }
-
-	// Use = instead of := since err is already declared
-	_, err = db.StoreEmail(email) // Check if email already exists
+	}
+
+	code, err := otp.Issue(email, otp.PurposeRegister)
 	if err != nil {
-		if err.Error() == "email already exists" {
-			http.Error(w, "Email already exists", http.StatusBadRequest)
-		} else {
+		writeOTPError(w, err)
+		return
+	}
+	if !created {
+		// Resending keeps the registration for another PendingUserTTL, like /sendotp
+		if pending, err = db.SavePendingUser(pending); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
+	}
+
+	if err := SendOTPEmail(email, code, requestLanguage(userDetails, r.Header.Get("Accept-Language"))); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
-	} else {
-		log.Println("Email registered successfully.")
->>>>>>> Final_BN
 	}
 
-	// Send a response
-	response := map[string]string{
-<<<<<<< HEAD
-		"message": "User registered successfully. Please check your email for the OTP.",
-		"otp":     otp,
-=======
+	// Send a response, without the username of a registration made by someone else
+	response := map[string]interface{}{
 		"message":  "User registered successfully. Please check your email for the OTP.",
 		"username": username,
 		"email":    email,
-		"password": string(hashedPassword),
->>>>>>> Final_BN
+		"expireAt": pending.ExpireAt,
+	}
+	if !created {
+		response["message"] = "A registration is already waiting for this email. A new OTP was sent, it activates the first registration."
+		delete(response, "username")
 	}
-	log.Println("User registered successfully.")
+	log.Println("User registered, waiting for email verification:", email)
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
-<<<<<<< HEAD
-=======
-
-// function StoreUser to database after verifying the OTP
-func StoreUser(username string, email string, password string) error {
-
-	log.Println("Storing user:", username, email, password)
-
-	// Check emtpy email and password
-	if email == "" || password == "" || username == "" {
-		log.Println("Email, password, or username is empty.")
-		return nil
-	}
-
-	// Convert the user details to a User struct
-	user := schema.User{
-		Username: username,
-		Email:    email,
-		Password: password,
-	}
-
-	// Store the user in the database
-	if _, err := db.StoreUser(user); err != nil {
-		log.Println("Error storing user:", err)
-	}
-	log.Println("User stored successfully.")
-	return nil
-
-}
->>>>>>> Final_BN
@@ -0,0 +1,53 @@
+package user
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/mail"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRegisterAgainKeepsPendingPassword(t *testing.T) {
+	os.Setenv("DB_STORE", "memory")
+	defer os.Unsetenv("DB_STORE")
+	if _, err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	mail.Use(&mail.Memory{}, "noa@example.com")
+
+	register := func(username, password string) *httptest.ResponseRecorder {
+		body := `{"username":"` + username + `","email":"victim@example.com","password":"` + password + `"}`
+		rec := httptest.NewRecorder()
+		Register(rec, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body)))
+		return rec
+	}
+	if rec := register("victim", "victimpass1"); rec.Code != http.StatusOK {
+		t.Fatalf("first registration: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Registering again cannot replace the password the code sent to the victim activates
+	register("attacker", "attackerpass1")
+	pending, err := db.FindPendingUser("victim@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Username != "victim" || bcrypt.CompareHashAndPassword([]byte(pending.Password), []byte("victimpass1")) != nil {
+		t.Fatalf("pending registration replaced: %+v", pending)
+	}
+
+	// Once expired the email can be registered again
+	pending.ExpireAt = pending.CreateAt
+	if err := db.GetStore().SavePendingUser(pending); err != nil {
+		t.Fatal(err)
+	}
+	created, isNew, err := db.CreatePendingUser(pending)
+	if err != nil || !isNew || !created.ExpireAt.After(created.CreateAt) {
+		t.Fatalf("registration after expiry: %+v %v %v", created, isNew, err)
+	}
+}
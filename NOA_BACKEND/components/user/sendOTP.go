@@ -2,17 +2,18 @@ package user
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/otp"
 )
 
-// SendOTP sends an OTP to the user's email and returns the OTP
+// SendOTP sends a new registration OTP to the email of a pending account and extends its expiry
 func SendOTP(w http.ResponseWriter, r *http.Request) {
 
-<<<<<<< HEAD
-	if r.Method != http.MethodGet { // Allow only POST requests
-=======
 	if r.Method != http.MethodPost { // Allow only POST requests
->>>>>>> Final_BN
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 		return
 	}
@@ -31,23 +32,58 @@ func SendOTP(w http.ResponseWriter, r *http.Request) {
 	if email == "" {
 		email = userDetails["Email"]
 	}
+	if !ValidateEmail(email) {
+		http.Error(w, "Invalid email format", http.StatusBadRequest)
+		return
+	}
+
+	// Only a pending registration can get a new code
+	pending, err := db.FindPendingUser(email)
+	if err != nil {
+		http.Error(w, "No pending registration for this email.", http.StatusNotFound)
+		return
+	}
+
+	// Generate and save the OTP
+	code, err := otp.Issue(email, otp.PurposeRegister)
+	if err != nil {
+		writeOTPError(w, err)
+		return
+	}
 
-	// Generate OTP
-	otp := GenerateOTP()
+	// Resending keeps the registration for another PendingUserTTL
+	if _, err := db.SavePendingUser(pending); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	// Send OTP to user's email
-	if err := SendOTPEmail(email, otp); err != nil {
+	if err := SendOTPEmail(email, code, requestLanguage(userDetails, r.Header.Get("Accept-Language"))); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
-	} else {
-		SaveOTP(email, otp)
 	}
 
 	// Send a response
-	response := map[string]string{"message": "OTP sent successfully. Please check your email for the OTP.", "OTP": otp}
+	response := map[string]string{"message": "OTP sent successfully. Please check your email for the OTP."}
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
+
+// writeOTPError answers an error of the otp package with its status
+func writeOTPError(w http.ResponseWriter, err error) {
+	var cooldown *otp.CooldownError
+	switch {
+	case errors.As(err, &cooldown):
+		w.Header().Set("Retry-After", strconv.Itoa(int(cooldown.RetryAfter.Seconds()+0.5)))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case err == otp.ErrTooMany:
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case err == otp.ErrInvalid:
+		http.Error(w, "Invalid OTP.", http.StatusUnauthorized)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -1,76 +1,60 @@
 package user
 
 import (
-	"bytes"
-	"html/template"
-	"log"
-	"net/smtp"
-)
-
-// SendOTPEmail sends an OTP to the user's email
-func SendOTPEmail(email, otp string) error {
-	log.Println("Sending OTP to email...")
-
-	from := "toonglar@gmail.com"      // Replace with your email address
-	password := "gbgs bzkr kdbg xvqe" // Replace with your app-specific password
-	smtpHost := "smtp.gmail.com"
-	smtpPort := "587"
+	"strings"
 
-	// Set up authentication information.
-	auth := smtp.PlainAuth("", from, password, smtpHost)
-
-	log.Println("Sending email to:", email)
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/mail"
+	"GOLANG_SERVER/components/otp"
+	"GOLANG_SERVER/components/schema"
+)
 
-	// Dynamic content for the email
-	emailData := struct {
-		Name    string
-		Message string
-		OTP     string
-	}{
-		Name:    "John Doe",
-		Message: "This is a dynamic message generated by Go!",
-		OTP:     otp,
-	}
+// SendOTPEmail queues the verification email with the OTP, lang is a language code or an Accept-Language value
+func SendOTPEmail(email, code, lang string) error {
+	return mail.SendTemplate(email, mail.TemplateOTP, mail.Language(lang), map[string]interface{}{
+		"Name":          displayName(email),
+		"OTP":           code,
+		"ExpireMinutes": int(otp.TTL.Minutes()),
+	})
+}
 
-	// Create dynamic HTML email content
-	emailTemplate := `
-	<html>
-		<head></head>
-		<body>
-			<h1>Hello, Welcome to verify your Email !</h1>
-			<p>{{.Message}}</p>
-			<p>Your OTP is: <strong>{{.OTP}}</strong></p>
-		</body>
-	</html>
-	`
+// SendPasswordResetEmail queues the password reset email with the code, or the link when not empty
+func SendPasswordResetEmail(email, code, link, lang string) error {
+	return mail.SendTemplate(email, mail.TemplatePasswordReset, mail.Language(lang), map[string]interface{}{
+		"Name":          displayName(email),
+		"Code":          code,
+		"Link":          link,
+		"ExpireMinutes": int(otp.TTL.Minutes()),
+	})
+}
 
-	// Parse the template and generate HTML
-	tmpl, err := template.New("email").Parse(emailTemplate)
-	if err != nil {
-		log.Println("Error parsing email template:", err)
-		return err
-	}
+// SendDeviceInviteEmail queues the invitation to a shared device, or to take over the device for the owner role
+func SendDeviceInviteEmail(share schema.DeviceShare, inviter, link, lang string) error {
+	return mail.SendTemplate(share.Email, mail.TemplateDeviceInvite, mail.Language(lang), map[string]interface{}{
+		"Name":       displayName(share.Email),
+		"Inviter":    inviter,
+		"DeviceID":   share.DeviceID,
+		"DeviceName": share.DeviceName,
+		"Role":       share.Role,
+		"Transfer":   share.Role == db.RoleOwner,
+		"Link":       link,
+		"ExpireDays": int(db.ShareInvitationTTL.Hours() / 24),
+	})
+}
 
-	var body bytes.Buffer
-	if err := tmpl.Execute(&body, emailData); err != nil {
-		log.Println("Error executing email template:", err)
-		return err
+// displayName is the username of the account with the email, or the part of the email before @
+func displayName(email string) string {
+	if user, err := db.FindUser(email); err == nil && user.Username != "" {
+		return user.Username
 	}
+	name, _, _ := strings.Cut(email, "@")
+	return name
+}
 
-	// Set up email subject and content
-	to := []string{email}
-	msg := []byte("Subject: OTP Verification\r\n" +
-		"MIME-version: 1.0;\r\n" +
-		"Content-Type: text/html; charset=\"UTF-8\";\r\n\r\n" +
-		body.String())
-
-	// Send the email
-	err = smtp.SendMail(smtpHost+":"+smtpPort, auth, from, to, msg)
-	if err != nil {
-		log.Println("Error sending email:", err)
-		return err
-	} else {
-		log.Println("Sent OTP to email successfully.")
-		return nil
+// requestLanguage is the lang field of the body, else the Accept-Language header
+func requestLanguage(body map[string]string, acceptLanguage string) string {
+	if lang := body["lang"]; lang != "" {
+		return lang
 	}
+	return acceptLanguage
 }
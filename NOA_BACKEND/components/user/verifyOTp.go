@@ -1,14 +1,15 @@
-// TODO: Description: This file includes the functions that are used to verify the OTP of the user.
 package user
 
 import (
-	"GOLANG_SERVER/components/db"
 	"encoding/json"
 	"log"
 	"net/http"
+
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/otp"
 )
 
-// VerifyOTP verifies the OTP of the user with token in 1 minute
+// VerifyOTP verifies the registration OTP sent to the email and activates the pending account
 func VerifyOTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost { // Allow only POST requests
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
@@ -25,74 +26,51 @@ func VerifyOTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Handle both lowercase and uppercase keys
-<<<<<<< HEAD
-=======
-	username := userDetails["username"]
-	if username == "" {
-		username = userDetails["Username"]
-	}
->>>>>>> Final_BN
 	email := userDetails["email"]
 	if email == "" {
 		email = userDetails["Email"]
 	}
-<<<<<<< HEAD
-=======
-	password := userDetails["password"]
-	if password == "" {
-		password = userDetails["Password"]
-	}
->>>>>>> Final_BN
-	otp := userDetails["otp"]
-	if otp == "" {
-		otp = userDetails["OTP"]
+	code := userDetails["otp"]
+	if code == "" {
+		code = userDetails["OTP"]
 	}
 
-	// Check if user exists
-	user, err := db.FindUser(email)
-	if err != nil {
-		http.Error(w, "Invalid email.", http.StatusUnauthorized)
+	// Check the registration is still pending before spending an attempt
+	if _, err := db.FindPendingUser(email); err != nil {
+		http.Error(w, "No pending registration for this email.", http.StatusNotFound)
 		return
 	}
 
-<<<<<<< HEAD
-	log.Println("User:", user.ID+" "+"Forget Password")
-=======
-	log.Println("User:", user.ID+" "+"Verifying OTP")
->>>>>>> Final_BN
-
-	// Declare checkOTP and verify the OTP
-	checkOTP := db.VerifyOTP(user.ID, otp)
-
-	if checkOTP == "" {
-		http.Error(w, "Invalid OTP.", http.StatusUnauthorized)
+	// Verify the OTP sent to the email, it works once
+	if err := otp.Verify(email, otp.PurposeRegister, code); err != nil {
+		writeOTPError(w, err)
 		return
-	} else if checkOTP != otp {
-		http.Error(w, "Invalid OTP.", http.StatusUnauthorized)
+	}
+	log.Println("OTP Verified:", email)
+
+	// The username and password come from the registration, not from this request
+	user, err := db.ActivatePendingUser(email)
+	if err == db.ErrEmailExists {
+		http.Error(w, "Email already exists", http.StatusBadRequest)
 		return
-	} else if checkOTP == otp {
-		log.Println("OTP Verified")
-<<<<<<< HEAD
-=======
-		// Update the user status to verified
-		StoreUser(username, email, password)
-	} else {
-		http.Error(w, "Invalid OTP.", http.StatusUnauthorized)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
->>>>>>> Final_BN
 	}
+	log.Println("User stored successfully:", user.ID)
 
 	// Send a response
-	response := map[string]string{"message": "OTP verified"}
+	response := map[string]string{
+		"message":  "OTP verified",
+		"userID":   user.ID,
+		"username": user.Username,
+		"email":    user.Email,
+	}
 
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-<<<<<<< HEAD
-	// Verify the OTP
-
-=======
->>>>>>> Final_BN
 }
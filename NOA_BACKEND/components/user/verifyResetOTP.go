@@ -2,13 +2,15 @@ package user
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 
 	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/otp"
 )
 
-// ForgotPassword sends an OTP to the user's email
-func ForgotPasswordReq(w http.ResponseWriter, r *http.Request) {
+// VerifyResetOTP exchanges the OTP of a password reset for a single use reset token
+func VerifyResetOTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost { // Allow only POST requests
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 		return
@@ -28,23 +30,37 @@ func ForgotPasswordReq(w http.ResponseWriter, r *http.Request) {
 	if email == "" {
 		email = userDetails["Email"]
 	}
+	code := userDetails["otp"]
+	if code == "" {
+		code = userDetails["OTP"]
+	}
 
 	// Check if user exists
-	_, err := db.ForgotpasswordCheck(email)
+	user, err := db.FindUser(email)
 	if err != nil {
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		http.Error(w, "Invalid OTP.", http.StatusUnauthorized)
 		return
 	}
 
-	// Send OTP to the user's email
-	otp := GenerateOTP()
-	SendOTPEmail(email, otp)
+	// The OTP is deleted once verified so it cannot issue a second token
+	if err := otp.Verify(email, otp.PurposeReset, code); err != nil {
+		writeOTPError(w, err)
+		return
+	}
 
-	// save OTP
-	SaveOTP(email, otp)
+	token, err := db.CreatePasswordReset(user.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Println("Password reset token issued:", user.ID)
 
 	// Send a response
-	response := map[string]string{"message": "Login successful", "otp": otp}
+	response := map[string]interface{}{
+		"message":    "OTP verified",
+		"resetToken": token,
+		"expiresIn":  int(db.PasswordResetTTL.Seconds()),
+	}
 
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -0,0 +1,426 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"GOLANG_SERVER/components/alert"
+	"GOLANG_SERVER/components/db"
+	"GOLANG_SERVER/components/env"
+	"GOLANG_SERVER/components/notification"
+	"GOLANG_SERVER/components/presence"
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// Events a webhook can subscribe to
+const (
+	EventPrediction = "prediction" // The predicted class of a device changed
+	EventAlert      = "alert"      // An alert rule fired or resolved
+	EventPresence   = "presence"   // A device went online or offline
+	EventPing       = "ping"       // Sent by the test endpoint only
+)
+
+const (
+	MaxAttempts  = 8                // Attempts before a delivery is failed
+	baseBackoff  = 30 * time.Second // Wait after the first failed attempt, doubled after each one
+	maxBackoff   = time.Hour        // Longest wait between two attempts
+	claimLease   = 2 * time.Minute  // Time a worker holds a delivery, it is retried after if the server stops
+	pollInterval = 10 * time.Second // How often the queue is read when nothing wakes the worker
+	batchSize    = 50               // Deliveries read from the queue at once
+	workers      = 4                // Deliveries attempted at the same time
+)
+
+// predictionTTL is how long the webhooks subscribed to predictions are cached by WantsPrediction
+const predictionTTL = time.Minute
+
+// Client sends the deliveries, receivers must answer within its timeout. It only dials public addresses,
+// see checkAddress, and never goes through a proxy that would dial for it.
+var Client = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: checkAddress}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+}
+
+// ErrPrivateAddress is returned for a receiver on a loopback, private, link-local or otherwise internal address
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// cgnat is the shared address space of RFC 6598, not covered by net.IP.IsPrivate
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// wake starts a pass of the worker as soon as a delivery is queued
+var wake = make(chan struct{}, 1)
+
+var events = map[string]bool{EventPrediction: true, EventAlert: true, EventPresence: true}
+
+// predictions are the users and devices with an enabled prediction webhook
+var predictions = struct {
+	sync.Mutex
+	users   map[string]bool // key: userID of a webhook on every device of the user
+	devices map[string]bool // key: deviceID of a webhook on one device
+	loaded  time.Time
+}{}
+
+// Validate checks the URL and events of a webhook
+func Validate(webhook *schema.Webhook) error {
+	target, err := url.Parse(webhook.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	// Names are checked once resolved, when the delivery dials
+	host := target.Hostname()
+	if strings.EqualFold(host, "localhost") {
+		host = "127.0.0.1"
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicAddress(ip) {
+		return ErrPrivateAddress
+	}
+	if len(webhook.Events) == 0 {
+		return errors.New("events must list at least one of prediction, alert or presence")
+	}
+	for _, event := range webhook.Events {
+		if !events[event] {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	return nil
+}
+
+// checkAddress is the Control of the dialer of Client, it refuses every address that is not public so a
+// webhook cannot reach the server itself, the cloud metadata service or the internal network, whatever its
+// name resolves to and wherever it redirects
+func checkAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicAddress(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	return nil
+}
+
+// publicAddress reports whether a receiver may be on the address. WEBHOOK_ALLOWED_NETS, a comma separated
+// list of CIDRs such as 127.0.0.1/32, allows internal addresses for receivers run next to the server.
+func publicAddress(ip net.IP) bool {
+	for _, cidr := range strings.Split(env.GetEnv("WEBHOOK_ALLOWED_NETS"), ",") {
+		if _, allowed, err := net.ParseCIDR(strings.TrimSpace(cidr)); err == nil && allowed.Contains(ip) {
+			return true
+		}
+	}
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || cgnat.Contains(ip))
+}
+
+// NewSecret returns a random signing key
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// Sign returns the X-NOA-Signature of a body sent at timestamp, in unix seconds:
+// "sha256=" and the hex HMAC-SHA256 of "{timestamp}.{body}" keyed by the secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Start queues prediction changes, alerts and presence changes for the webhooks of their user
+// and delivers the queue. Predictions and presence are also queued for the users the device is shared with,
+// alerts only for the user of their rule.
+func Start() {
+	notification.OnCreate(func(event notification.Event) {
+		if event.Type == notification.TypePrediction {
+			go PublishDevice(event.UserID, event.DeviceID, EventPrediction, event.Data)
+		}
+	})
+	alert.OnChange(func(event alert.Event) {
+		go Publish(event.UserID, event.DeviceID, EventAlert, event.Alert)
+	})
+	presence.OnChange(func(event presence.Event) {
+		go PublishDevice(event.UserID, event.DeviceID, EventPresence, event)
+	})
+	go worker()
+}
+
+// WantsPrediction reports whether predictions of a device of ownerID go to a webhook, so the device is
+// predicted even when no client watches it. Predictions of a shared device go to the webhooks on that device
+// of the users it is shared with.
+func WantsPrediction(ownerID, deviceID string) bool {
+	predictions.Lock()
+	defer predictions.Unlock()
+
+	if time.Since(predictions.loaded) > predictionTTL {
+		loadPredictions()
+	}
+	return predictions.users[ownerID] || predictions.devices[deviceID]
+}
+
+// Reload applies a webhook change to WantsPrediction right away
+func Reload() {
+	predictions.Lock()
+	predictions.loaded = time.Time{}
+	predictions.Unlock()
+}
+
+// loadPredictions reads the prediction webhooks, the caller must hold the lock
+func loadPredictions() {
+	// Not read again before predictionTTL, even when it fails
+	predictions.loaded = time.Now()
+	webhooks, err := db.EnabledWebhooks(EventPrediction)
+	if err != nil {
+		log.Println("Error reading prediction webhooks:", err)
+		return
+	}
+	predictions.users = make(map[string]bool)
+	predictions.devices = make(map[string]bool)
+	for _, webhook := range webhooks {
+		if webhook.DeviceID == "" {
+			predictions.users[webhook.UserID] = true
+		} else {
+			predictions.devices[webhook.DeviceID] = true
+		}
+	}
+}
+
+// Publish queues an event for every enabled webhook of the user subscribed to it
+func Publish(userID, deviceID, event string, data interface{}) {
+	if queue(userID, deviceID, event, data, false) {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// PublishDevice queues an event of a device for the webhooks of its owner, and for the webhooks on that
+// device of the users it is shared with
+func PublishDevice(ownerID, deviceID, event string, data interface{}) {
+	queued := queue(ownerID, deviceID, event, data, false)
+
+	shares, err := db.DeviceShares(deviceID)
+	if err != nil {
+		log.Println("Error reading device shares:", err)
+	}
+	for _, share := range shares {
+		if share.Status == db.ShareAccepted && share.Role != db.RoleOwner && queue(share.UserID, deviceID, event, data, true) {
+			queued = true
+		}
+	}
+	if queued {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// queue queues the event for the enabled webhooks of the user subscribed to it, only the webhooks on the
+// device when deviceOnly is set. It reports whether any was queued.
+func queue(userID, deviceID, event string, data interface{}, deviceOnly bool) bool {
+	webhooks, err := db.Webhooks(userID)
+	if err != nil {
+		log.Println("Error reading webhooks:", err)
+		return false
+	}
+	var body []byte
+	for _, webhook := range webhooks {
+		if !webhook.Enabled || !subscribed(webhook, event) || (webhook.DeviceID != "" && webhook.DeviceID != deviceID) {
+			continue
+		}
+		if deviceOnly && webhook.DeviceID == "" {
+			continue
+		}
+		if body == nil {
+			if body, err = payload(userID, deviceID, event, data); err != nil {
+				log.Println("Error encoding webhook payload:", err)
+				return false
+			}
+		}
+		if _, err := db.QueueDelivery(webhook, event, body); err != nil {
+			log.Println("Error queueing webhook delivery:", err)
+		}
+	}
+	return body != nil
+}
+
+func subscribed(webhook schema.Webhook, event string) bool {
+	for _, e := range webhook.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// payload is the JSON body of a delivery
+func payload(userID, deviceID, event string, data interface{}) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"event":    event,
+		"userID":   userID,
+		"deviceID": deviceID,
+		"createAt": time.Now(),
+		"data":     data,
+	})
+}
+
+// Test sends a ping to the webhook right away and returns the logged delivery, it is not retried
+func Test(webhook schema.Webhook) (schema.WebhookDelivery, error) {
+	body, err := payload(webhook.UserID, webhook.DeviceID, EventPing, map[string]string{"message": "Test delivery"})
+	if err != nil {
+		return schema.WebhookDelivery{}, err
+	}
+	delivery, err := db.QueueDelivery(webhook, EventPing, body)
+	if err != nil {
+		return schema.WebhookDelivery{}, err
+	}
+	// Keep the worker away from it while it is sent here
+	if _, err := db.ClaimDelivery(delivery, time.Now().Add(claimLease)); err != nil {
+		return schema.WebhookDelivery{}, err
+	}
+
+	attempt := send(webhook, delivery)
+	delivery.Status = db.DeliveryFailed
+	if attempt.Error == "" {
+		delivery.Status = db.DeliveryDelivered
+		delivery.DeliveredAt = &attempt.At
+	}
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	if err := db.RecordAttempt(delivery.ID, attempt, delivery.Status, delivery.NextAttempt); err != nil {
+		return schema.WebhookDelivery{}, err
+	}
+	return delivery, nil
+}
+
+// worker delivers the due deliveries of the queue, several servers can share the queue
+func worker() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	slots := make(chan struct{}, workers)
+
+	for {
+		deliveries, err := db.DueDeliveries(batchSize)
+		if err != nil {
+			log.Println("Error reading webhook queue:", err)
+		}
+		for _, delivery := range deliveries {
+			claimed, err := db.ClaimDelivery(delivery, time.Now().Add(claimLease))
+			if err != nil {
+				log.Println("Error claiming webhook delivery:", err)
+				continue
+			}
+			if !claimed {
+				continue
+			}
+			slots <- struct{}{}
+			go func(delivery schema.WebhookDelivery) {
+				defer func() { <-slots }()
+				deliver(delivery)
+			}(delivery)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-wake:
+		}
+	}
+}
+
+// deliver attempts a claimed delivery and schedules the next attempt when it fails
+func deliver(delivery schema.WebhookDelivery) {
+	var attempt schema.WebhookAttempt
+	webhook, err := db.Webhook(delivery.UserID, delivery.WebhookID)
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		attempt = schema.WebhookAttempt{At: time.Now(), Error: "webhook deleted"}
+	case err != nil:
+		log.Println("Error reading webhook:", err)
+		return // Retried once the claim expires
+	case !webhook.Enabled:
+		attempt = schema.WebhookAttempt{At: time.Now(), Error: "webhook disabled"}
+	default:
+		attempt = send(webhook, delivery)
+	}
+
+	status, next := db.DeliveryDelivered, attempt.At
+	if attempt.Error != "" {
+		attempts := len(delivery.Attempts) + 1
+		if err != nil || !webhook.Enabled || attempts >= MaxAttempts {
+			status = db.DeliveryFailed
+		} else {
+			status, next = db.DeliveryPending, attempt.At.Add(Backoff(attempts))
+		}
+	}
+	if err := db.RecordAttempt(delivery.ID, attempt, status, next); err != nil {
+		log.Println("Error logging webhook delivery:", err)
+	}
+}
+
+// Backoff is the wait after the given number of failed attempts
+func Backoff(attempts int) time.Duration {
+	wait := baseBackoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+// send posts the payload of the delivery, a 2xx answer is a success
+func send(webhook schema.Webhook, delivery schema.WebhookDelivery) schema.WebhookAttempt {
+	start := time.Now()
+	attempt := schema.WebhookAttempt{At: start}
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "NOA-Webhook/1")
+	req.Header.Set("X-NOA-Event", delivery.Event)
+	req.Header.Set("X-NOA-Delivery", delivery.ID)
+	req.Header.Set("X-NOA-Timestamp", strconv.FormatInt(start.Unix(), 10))
+	req.Header.Set("X-NOA-Signature", Sign(webhook.Secret, start.Unix(), body))
+
+	resp, err := Client.Do(req)
+	attempt.Duration = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = resp.Status
+	}
+	return attempt
+}
@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"GOLANG_SERVER/components/db"
+	schema "GOLANG_SERVER/components/schema"
+)
+
+// openMemory opens a memory store like DB_STORE=memory
+func openMemory(t *testing.T) {
+	t.Helper()
+	os.Setenv("DB_STORE", "memory")
+	t.Cleanup(func() { os.Unsetenv("DB_STORE") })
+	if _, err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// receiver answers the given statuses in turn and records whether each signature was valid
+type receiver struct {
+	sync.Mutex
+	statuses []int
+	calls    int
+	badSigs  int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	ts, _ := strconv.ParseInt(r.Header.Get("X-NOA-Timestamp"), 10, 64)
+
+	rc.Lock()
+	defer rc.Unlock()
+	if r.Header.Get("X-NOA-Signature") != Sign("secret", ts, body) {
+		rc.badSigs++
+	}
+	status := rc.statuses[min(rc.calls, len(rc.statuses)-1)]
+	rc.calls++
+	w.WriteHeader(status)
+}
+
+func TestDeliverRetriesAndLogs(t *testing.T) {
+	openMemory(t)
+	t.Setenv("WEBHOOK_ALLOWED_NETS", "127.0.0.0/8,::1/128")
+	rc := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusNoContent}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	hook := schema.Webhook{UserID: "userA", URL: server.URL, Secret: "secret", Events: []string{EventAlert}, Enabled: true}
+	if err := Validate(&hook); err != nil {
+		t.Fatal(err)
+	}
+	hook, err := db.CreateWebhook(hook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !queue("userA", "deviceA", EventAlert, map[string]string{"rule": "hot"}, false) {
+		t.Fatal("nothing queued")
+	}
+
+	// The receiver fails the first attempt
+	due, err := db.DueDeliveries(10)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("due deliveries = %v, %v", due, err)
+	}
+	deliver(due[0])
+	deliveries, _ := db.WebhookDeliveries(hook.ID, 10)
+	if len(deliveries) != 1 {
+		t.Fatalf("%d deliveries logged, want 1", len(deliveries))
+	}
+	first := deliveries[0]
+	if first.Status != db.DeliveryPending || len(first.Attempts) != 1 || first.Attempts[0].StatusCode != http.StatusInternalServerError {
+		t.Fatalf("after a failure: %+v", first)
+	}
+	if want := first.Attempts[0].At.Add(Backoff(1)); !first.NextAttempt.Equal(want) {
+		t.Fatalf("next attempt = %v, want %v", first.NextAttempt, want)
+	}
+	if due, _ := db.DueDeliveries(10); len(due) != 0 {
+		t.Fatalf("delivery retried before its backoff: %v", due)
+	}
+
+	// The retry succeeds
+	deliver(first)
+	deliveries, _ = db.WebhookDeliveries(hook.ID, 10)
+	second := deliveries[0]
+	if second.Status != db.DeliveryDelivered || len(second.Attempts) != 2 || second.DeliveredAt == nil {
+		t.Fatalf("after the retry: %+v", second)
+	}
+	if second.Attempts[1].StatusCode != http.StatusNoContent {
+		t.Fatalf("second attempt = %+v", second.Attempts[1])
+	}
+
+	rc.Lock()
+	defer rc.Unlock()
+	if rc.calls != 2 || rc.badSigs != 0 {
+		t.Fatalf("receiver got %d calls with %d bad signatures", rc.calls, rc.badSigs)
+	}
+}
+
+func TestDeliverGivesUp(t *testing.T) {
+	openMemory(t)
+	t.Setenv("WEBHOOK_ALLOWED_NETS", "127.0.0.0/8,::1/128")
+	server := httptest.NewServer(&receiver{statuses: []int{http.StatusBadGateway}})
+	defer server.Close()
+
+	hook, err := db.CreateWebhook(schema.Webhook{UserID: "userA", URL: server.URL, Secret: "secret", Events: []string{EventAlert}, Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue("userA", "", EventAlert, nil, false)
+	for i := 0; i < MaxAttempts; i++ {
+		deliveries, _ := db.WebhookDeliveries(hook.ID, 10)
+		deliver(deliveries[0])
+	}
+	deliveries, _ := db.WebhookDeliveries(hook.ID, 10)
+	if deliveries[0].Status != db.DeliveryFailed || len(deliveries[0].Attempts) != MaxAttempts {
+		t.Fatalf("after %d failures: status %s, %d attempts", MaxAttempts, deliveries[0].Status, len(deliveries[0].Attempts))
+	}
+}
+
+func TestPrivateAddressBlocked(t *testing.T) {
+	openMemory(t)
+	rc := &receiver{statuses: []int{http.StatusOK}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	for _, url := range []string{"http://127.0.0.1/", "http://localhost:8080/", "http://10.0.0.1/", "http://169.254.169.254/latest", "http://[::1]/", "http://100.64.0.1/"} {
+		if err := Validate(&schema.Webhook{URL: url, Events: []string{EventAlert}}); !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("Validate(%s) = %v, want ErrPrivateAddress", url, err)
+		}
+	}
+	if err := Validate(&schema.Webhook{URL: "https://hooks.example.com/noa", Events: []string{EventAlert}}); err != nil {
+		t.Errorf("public URL refused: %v", err)
+	}
+
+	// A name resolving to loopback is refused when dialing, without the allowlist
+	hook, err := db.CreateWebhook(schema.Webhook{UserID: "userA", URL: server.URL, Secret: "secret", Events: []string{EventAlert}, Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivery, err := Test(hook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Status != db.DeliveryFailed || len(delivery.Attempts) != 1 || delivery.Attempts[0].StatusCode != 0 {
+		t.Fatalf("delivery to loopback: %+v", delivery)
+	}
+	if rc.calls != 0 {
+		t.Fatal("receiver on loopback was called")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestWantsPrediction(t *testing.T) {
+	openMemory(t)
+	for _, hook := range []schema.Webhook{
+		{UserID: "userA", URL: "https://a.example.com", Events: []string{EventPrediction}, Enabled: true},
+		{UserID: "userB", URL: "https://b.example.com", Events: []string{EventPrediction}, DeviceID: "deviceC", Enabled: true},
+		{UserID: "userD", URL: "https://d.example.com", Events: []string{EventAlert}, Enabled: true},
+		{UserID: "userE", URL: "https://e.example.com", Events: []string{EventPrediction}, Enabled: false},
+	} {
+		if _, err := db.CreateWebhook(hook); err != nil {
+			t.Fatal(err)
+		}
+	}
+	Reload()
+
+	tests := []struct {
+		owner, device string
+		want          bool
+	}{
+		{"userA", "deviceA", true},  // Webhook on every device of the owner
+		{"userC", "deviceC", true},  // Webhook of a user the device is shared with
+		{"userD", "deviceD", false}, // Only alerts
+		{"userE", "deviceE", false}, // Disabled
+		{"userF", "deviceF", false},
+	}
+	for _, tt := range tests {
+		if got := WantsPrediction(tt.owner, tt.device); got != tt.want {
+			t.Errorf("WantsPrediction(%s, %s) = %v, want %v", tt.owner, tt.device, got, tt.want)
+		}
+	}
+}
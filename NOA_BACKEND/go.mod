@@ -12,16 +12,12 @@ require (
 )
 
 require (
-<<<<<<< HEAD
-=======
 	github.com/tensorflow/tensorflow v2.19.0+incompatible // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
 )
 
 require (
-	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/golang-jwt/jwt/v4 v4.5.2
->>>>>>> Final_BN
 	github.com/golang/snappy v0.0.4 // indirect
 	github.com/klauspost/compress v1.17.9 // indirect
 	github.com/montanaflynn/stats v0.7.1 // indirect
@@ -2,20 +2,28 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 
+	"GOLANG_SERVER/components/alert"
 	"GOLANG_SERVER/components/db"
 	"GOLANG_SERVER/components/env"
+	"GOLANG_SERVER/components/mail"
+	"GOLANG_SERVER/components/notification"
+	"GOLANG_SERVER/components/predict"
 	"GOLANG_SERVER/components/protocal/mosquitto"
 	"GOLANG_SERVER/components/protocal/rest"
 	"GOLANG_SERVER/components/protocal/ws"
-<<<<<<< HEAD
-=======
+	"GOLANG_SERVER/components/report"
 	"GOLANG_SERVER/components/sensitive"
->>>>>>> Final_BN
+	"GOLANG_SERVER/components/usage"
 	"GOLANG_SERVER/components/user"
+	"GOLANG_SERVER/components/webhook"
 )
 
 // Main function
@@ -33,95 +41,95 @@ func main() {
 		return
 	}
 
+	// Select the fault classifier
+	if err := predict.Open(); err != nil {
+		log.Fatal("Error loading predictor:", err)
+		return
+	}
+
+	// Select the mail driver, the server still runs without email
+	if err := mail.Open(); err != nil {
+		log.Println("Error loading mailer, emails are disabled:", err)
+	}
+
 	// Connect to the database
-	if _, err := db.Connect(); err == nil {
+	if _, err := db.Open(); err == nil {
 		// Welcome message
 		fmt.Println("Message:", env.GetEnv("MESSAGE"))
 
 		//TODO REST API route
-<<<<<<< HEAD
-		go http.HandleFunc("/", rest.HandleAPI)                                                  //*[DONE] API route
-		go http.HandleFunc("/data", rest.HandleGetAllData)                                       //*[DONE] Get all data
-		go http.HandleFunc("/store", rest.HandleStore)                                           //*[DONE] Store data
-		go http.HandleFunc("/latest", rest.HandleGetLatestData)                                  //*[DONE] Get latest data
-		go http.HandleFunc("/clean", rest.HandleCleanData)                                       //*[DONE] Clean data
-		go http.HandleFunc("/registerdevice", rest.HandleRegisterDevice)                         //*[DONE] Register device
-		go http.HandleFunc("/deviceaddresses", rest.HandleGetDeviceAddress)                      //*[DONE] Get device address
-		go http.HandleFunc("/checkdeviceaddresses/", rest.HandleGetDeviceAddressByDeviceAddress) //*[DONE] Get device address by device address
-		go http.HandleFunc("/data/", rest.HandleGetAllDataByDeviceAddress)                       //*[DONE] Get data use param
-		go http.HandleFunc("/register", user.Register)                                           //*[DONE] Register user by Enail and Password
-		go http.HandleFunc("/login", user.Login)                                                 //*[DONE] login user by Email and Password
-		go http.HandleFunc("/sendotp", user.SendOTP)                                             //*[DONE] Send OTP to Email
-		go http.HandleFunc("/forgotpassword", user.ForgotPasswordReq)                            //*[DONE] Forgot Password
-		go http.HandleFunc("/verifyotp", user.VerifyOTP)                                         //*[DONE] Verify OTP
-
-		//TODO--------------------------------------------------------------------------------------------------------------------------||
-		//go http.HandleFunc("/authendevice", sensitive.AuthenDevice) //!Sensitive Authenticate device
-		//go http.HandleFunc("/verifydevice", sensitive.VerifyDevice) //!Sensitive Verify device
-=======
 
 		//go http.HandleFunc("/", rest.HandleAPI)                 //*[DONE] API route
 		//go http.HandleFunc("/latest", rest.HandleGetLatestData) //*[DONE] Get latest data
 
 		//* Device route
-		go http.HandleFunc("/device/generateDeviceID", rest.HandleGenerateDeviceID)                     //*[DONE] Generate device ID
-		go http.HandleFunc("/device/createDevice", rest.HandleRegisterDevice)                           //*[DONE] Register device
-		go http.HandleFunc("/device/getDevices", rest.HandleGetDeviceAddress)                           //*[DONE] Get device address
-		go http.HandleFunc("/device/checkdeviceaddresses/", rest.HandleGetDeviceAddressByDeviceAddress) //*[DONE] Get device address by device address
-		go http.HandleFunc("/device/deleteDevice", rest.HandleDeleteDevice)                             //*[DONE] Delete device
-		go http.HandleFunc("/authendevice", sensitive.AuthenDevice)                                     //*[DONE] Authenticate device
-		go http.HandleFunc("/device/changeBookmark", rest.ChangeBookmark)                               //*[DONE] Change bookmark
+		go http.Handle("/device/generateDeviceID", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleGenerateDeviceID)))                     //*[DONE] Generate device ID
+		go http.Handle("/device/createDevice", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleRegisterDevice)))                           //*[DONE] Register device
+		go http.Handle("/device/getDevices", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleGetDeviceAddress)))                           //*[DONE] Get device address
+		go http.Handle("/device/checkdeviceaddresses/", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleGetDeviceAddressByDeviceAddress))) //*[DONE] Get device address by device address
+		go http.Handle("/device/deleteDevice", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleDeleteDevice)))                             //*[DONE] Delete device
+		go http.HandleFunc("/authendevice", sensitive.AuthenDevice)                                                                             //*[DONE] Authenticate device
+		go http.HandleFunc("/mqtt/auth/", rest.HandleMQTTAuth)                                                                                  //*[DONE] Broker auth backend /mqtt/auth/user, /superuser and /acl
+		go http.Handle("/device/changeBookmark", sensitive.AuthMiddleware(http.HandlerFunc(rest.ChangeBookmark)))                               //*[DONE] Change bookmark
+		go http.Handle("/device/", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleDeviceRoute)))                                          //*[DONE] Device resources /device/{deviceID}/...
+		go http.Handle("/device/provision", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleProvisionRoute)))                              //*[DONE] Issue a pairing code for a new device
+		go http.Handle("/device/provision/", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleProvisionRoute)))                             //*[DONE] Pairing status /device/provision/{provisionID}
+		go http.HandleFunc("/device/claim", rest.HandleClaimDevice)                                                                             //*[DONE] Device claims its ID and credentials with the pairing code
+		go http.Handle("/invitations", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleInvitations)))                                      //*[DONE] Pending device invitations of the user
+		go http.Handle("/invitations/", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleInvitations)))                                     //*[DONE] Accept or decline /invitations/{shareID}/accept|decline
+		go http.Handle("/downloaddata", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleDownloadData)))                                    //*[DONE] Download data as CSV, JSON Lines or Parquet file
+		go http.Handle("/ingest/stats", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleIngestStats)))                                     //*[DONE] Ingest bus subscriber counters, ADMIN_USER_IDS only
+		go http.Handle("/alerts", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleAlertRoute)))                                            //*[DONE] Alert history
+		go http.Handle("/alerts/", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleAlertRoute)))                                           //*[DONE] Alert rules /alerts/rules/{ruleID}
+		go http.Handle("/notifications", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleNotifications)))                                  //*[DONE] List notifications
+		go http.Handle("/notifications/read", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleReadNotifications)))                         //*[DONE] Mark notifications read
+		go http.Handle("/webhooks", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleWebhookRoute)))                                        //*[DONE] List and create webhooks
+		go http.Handle("/webhooks/", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleWebhookRoute)))                                       //*[DONE] Webhook /webhooks/{webhookID}/deliveries and /test
+		go http.Handle("/hierarchy", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleHierarchyRoute)))                                     //*[DONE] Organisations, sites and assets as a tree with their devices
+		go http.Handle("/orgs", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleHierarchyRoute)))                                          //*[DONE] List and create organisations
+		go http.Handle("/orgs/", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleHierarchyRoute)))                                         //*[DONE] Organisation /orgs/{orgID} and /telemetry/aggregate
+		go http.Handle("/sites", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleHierarchyRoute)))                                         //*[DONE] List and create sites
+		go http.Handle("/sites/", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleHierarchyRoute)))                                        //*[DONE] Site /sites/{siteID} and /telemetry/aggregate
+		go http.Handle("/assets", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleHierarchyRoute)))                                        //*[DONE] List and create assets
+		go http.Handle("/assets/", sensitive.AuthMiddleware(http.HandlerFunc(rest.HandleHierarchyRoute)))                                       //*[DONE] Asset /assets/{assetID} and /telemetry/aggregate
 
 		//* User route
 		go http.HandleFunc("/register", user.Register)                                                            //*[DONE] Register user by Enail and Password
 		go http.HandleFunc("/login", user.Login)                                                                  //*[DONE] login user by Email and Password
+		go http.HandleFunc("/refresh", user.RefreshToken)                                                         //*[DONE] Rotate the refresh token for a new access token
+		go http.Handle("/logout", sensitive.AuthMiddleware(http.HandlerFunc(user.Logout)))                        //*[DONE] Logout the current session
+		go http.Handle("/logout/all", sensitive.AuthMiddleware(http.HandlerFunc(user.LogoutAll)))                 //*[DONE] Logout every session of the user
 		go http.HandleFunc("/sendotp", user.SendOTP)                                                              //*[DONE] Send OTP to Email
 		go http.HandleFunc("/forgotpassword", user.ForgotPasswordReq)                                             //*[DONE] Forgot Password
+		go http.HandleFunc("/forgotpassword/verify", user.VerifyResetOTP)                                         //*[DONE] Exchange the reset OTP for a reset token
+		go http.HandleFunc("/newpassword", user.NewPasswordReq)                                                   //*[DONE] Set a new password with a reset token
 		go http.HandleFunc("/verifyotp", user.VerifyOTP)                                                          //*[DONE] Verify OTP
 		go http.Handle("/api/protected", sensitive.AuthMiddleware(http.HandlerFunc(sensitive.ProtectedResource))) //*[DONE] Protected resource
-		go http.HandleFunc("/userID", user.GetUserByUserID)                                                       //*[DONE] Get user by userID
+		go http.Handle("/userID", sensitive.AuthMiddleware(http.HandlerFunc(user.GetUserByUserID)))               //*[DONE] Get user by userID
 
 		//TODO--------------------------------------------------------------------------------------------------------------------------||
 
->>>>>>> Final_BN
-		//go http.HandleFunc("/newpassword", user.ChangePassword)						  		 //TODO Change Password
-		//go http.HandleFunc("/logout", user.Logout)							  				 //TODO Logout
-		//go http.HandleFunc("/downloaddata")												  	 //?[Design] Download data as CSV or JSON file
 		//go http.HandleFunc("/payment")												  		 //?[Design] Payment route
-<<<<<<< HEAD
-		//go http.HandleFunc("/notification")													 //?[Design] Notification route
-		//go http.HandleFunc("/userprofile")													 //?[Design] User profile route
-		//go http.HandleFunc("/userprofile/update")												 //?[Design] Update user profile route
-		//go http.HandleFunc("/getprediction")													 //?[Design] Get prediction route
-		//go http.HandleFunc("/prediction")														 //?[Design] Prediction route
-		//TODO--------------------------------------------------------------------------------------------------------------------------||
-
-		// TODO: WebSocket route
-		http.HandleFunc("/ws", ws.HandleWebSocket) //*DONE Handle WebSocket connection
-
-		go http.HandleFunc("/ws/test", ws.HandleWsTest)
-		// TODO: Start MQTT client
-		go mosquitto.HandleMQTT()
-=======
 		//go http.HandleFunc("/userprofile")													 //?[Design] User profile route
 
 		//TODO--------------------------------------------------------------------------------------------------------------------------||
 
 		// TODO: WebSocket route
-		go http.HandleFunc("/ws/boadcast", ws.HandleWebSocketBoadcast)  //*DONE Handle WebSocket connection
-		go http.HandleFunc("/ws/prediction", ws.HandleWebSocketPredict) //TODO Prediction route
-		go http.HandleFunc("/ws/history", ws.HandleHistory)             //TODO Notification
-		//go http.HandleFunc("/ws/notification", ws.HandleNotification)   //TODO Notification
-		//* go http.HandleFunc("/ws/getdeviceid", ws.HandleGetDeviceIDWebSocket)
+		go http.Handle("/ws/boadcast", sensitive.AuthMiddleware(http.HandlerFunc(ws.HandleWebSocketBoadcast)))  //*DONE Handle WebSocket connection
+		go http.Handle("/ws/prediction", sensitive.AuthMiddleware(http.HandlerFunc(ws.HandleWebSocketPredict))) //TODO Prediction route
+		go http.Handle("/ws/notification", sensitive.AuthMiddleware(http.HandlerFunc(ws.HandleNotification)))   //*[DONE] Notification
+		go http.Handle("/ws/history", sensitive.AuthMiddleware(http.HandlerFunc(ws.HandleNotification)))        //*[DONE] Former notification route, same socket
 
 		//TODO: Start MQTT client--------------------------------------------------------------------------------------------------------------------------||
 
-		go mosquitto.HandleMQTT()
-		go ws.StartGlobalMQTTSubscriber()
-		//go mosquitto.HandleWebSocketMerge()
+		ws.StartIngestSubscribers() // Broadcast and prediction read from the ingest bus
+		alert.Start()               // Alert rules evaluated on every ingested message
+		notification.Start()        // Alerts stored as notifications, severe ones emailed
+		report.Start()              // Weekly report emails every Monday
+		webhook.Start()             // Predictions, alerts and presence sent to the webhooks of their user
+		go mosquitto.HandleMQTT()   // Single MQTT connection feeding the ingest bus
 
 		//TODO--------------------------------------------------------------------------------------------------------------------------||
->>>>>>> Final_BN
 
 		// TODO: Start the server in a goroutine
 		go func() {
@@ -131,15 +139,24 @@ func main() {
 			}
 		}()
 
-		// Wait for 'q' or 'Q' to stop the server
-		var input string
-		for {
-			fmt.Scanln(&input)
-			if input == "q" || input == "Q" {
-				fmt.Println("Server stopping...")
-				break // Stop the server
+		// Wait for 'q' or 'Q', or SIGINT or SIGTERM from a deploy, to stop the server
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			var input string
+			for {
+				if _, err := fmt.Scanln(&input); err == io.EOF {
+					return // No terminal, only the signals stop the server
+				}
+				if input == "q" || input == "Q" {
+					stop <- os.Interrupt
+					return
+				}
 			}
-		}
+		}()
+		<-stop
+		fmt.Println("Server stopping...")
+		usage.Stop() // Metered usage not flushed yet feeds billing
 	} else {
 		fmt.Println("Error connecting to database something went wrong!!")
 		return
@@ -13,10 +13,7 @@
 #include <PubSubClient.h>
 #include <cstdint>
 #include "SD_CARD_M.hpp"
-<<<<<<< HEAD
 #include "Auth.hpp"
-=======
->>>>>>> Final_BN
 
 // Function template for parseRes
 template <typename T>
@@ -27,11 +24,8 @@ T parseRes(unsigned char lowByte, unsigned char highByte) {
 #define DADDR_DEF 0x50
 #define MQTT_MAX_SIZE 1024
 
-<<<<<<< HEAD
 const char* Rest_ip = "104.214.174.39:8000";
 
-=======
->>>>>>> Final_BN
 HardwareSerial RS485(2); // Use UART2
 
 // Send data to server
@@ -41,10 +35,20 @@ WiFiClient espClient;
 PubSubClient client(espClient);
 
 DataSchema D;
-<<<<<<< HEAD
 AuthSchema A;
-=======
->>>>>>> Final_BN
+
+// * mqtt topics noa/{userID}/{deviceID}/..., set after the device is authenticated
+String TELEMETRY_TOPIC;
+String STATUS_TOPIC;
+String CMD_TOPIC;
+String ACK_TOPIC;
+
+// * delay between readings, changed by the sampleRate command
+int SAMPLE_DELAY_MS = 0;
+
+// * authenticate again this often, a device transferred to another user gets the topics of its new owner
+#define REAUTH_INTERVAL_MS (5 * 60 * 1000UL)
+unsigned long lastAuthMs = 0;
 
 GYRO gyro(DADDR_DEF);
 
@@ -59,19 +63,153 @@ void GDisplay(DataSchema g){
     Serial.printf("Modbus High Speed: %s\n", g.ModbusHighSpeed ? "true" : "false");
 }
 
+// * read the raw value of "key" in a flat json message, "" if missing
+String jsonValue(const String &json, const String &key) {
+    int start = json.indexOf("\"" + key + "\"");
+    if (start < 0) {
+        return "";
+    }
+    start = json.indexOf(":", start) + 1;
+    while (json[start] == ' ') {
+        start++;
+    }
+    if (json[start] == '"') {
+        int end = json.indexOf("\"", start + 1);
+        return json.substring(start + 1, end);
+    }
+    int end = start;
+    while (end < json.length() && json[end] != ',' && json[end] != '}') {
+        end++;
+    }
+    return json.substring(start, end);
+}
+
+// * report the result of a command to the server
+void ack(const String &commandID, bool ok, const String &error) {
+    String message = "{\"commandID\":\"" + commandID + "\",\"status\":\"" + (ok ? "acked" : "failed") + "\"";
+    if (!error.isEmpty()) {
+        message += ",\"error\":\"" + error + "\"";
+    }
+    message += "}";
+    client.publish(ACK_TOPIC.c_str(), message.c_str());
+}
+
+// * append pushed keys to the config file, applied on the next boot
+bool pushConfig(const String &message) {
+    const char *keys[] = {"WIFI_SSID", "WIFI_PASSWORD", "MQTT_SERVER", "MQTT_USER", "MQTT_PASS", "MQTT_PORT"};
+    File file = SD.open("/config.txt", FILE_APPEND);
+    if (!file) {
+        return false;
+    }
+    for (const char *key : keys) {
+        String value = jsonValue(message, key);
+        if (!value.isEmpty()) {
+            file.println(String(key) + "=" + value);
+        }
+    }
+    file.close();
+    return true;
+}
+
+// * commands from noa/{userID}/{deviceID}/cmd
 void callback(char *topic, byte *payload, unsigned int length) {
-    Serial.print("Message arrived [");
-    Serial.print(topic);
-    Serial.print("] ");
+    String message;
     for (int i = 0; i < length; i++) {
-        Serial.print((char)payload[i]);
+        message += (char)payload[i];
+    }
+    Serial.println("Message arrived [" + String(topic) + "] " + message);
+
+    if (CMD_TOPIC != topic) {
+        return;
+    }
+
+    String commandID = jsonValue(message, "commandID");
+    String type = jsonValue(message, "type");
+    if (commandID.isEmpty()) {
+        return;
+    }
+
+    if (type == "sampleRate") {
+        int hz = jsonValue(message, "hz").toInt();
+        if (hz < 1 || hz > 1000) {
+            ack(commandID, false, "invalid hz");
+            return;
+        }
+        SAMPLE_DELAY_MS = 1000 / hz;
+        ack(commandID, true, "");
+    } else if (type == "modbusHighSpeed") {
+        D.ModbusHighSpeed = jsonValue(message, "enabled") == "true";
+        ack(commandID, true, "");
+    } else if (type == "reboot") {
+        ack(commandID, true, "");
+        client.loop();
+        delay(500);
+        ESP.restart();
+    } else if (type == "config") {
+        if (pushConfig(message)) {
+            ack(commandID, true, "");
+        } else {
+            ack(commandID, false, "cannot write config file");
+        }
+    } else {
+        ack(commandID, false, "unknown command");
+    }
+}
+
+// * get the userID of the current owner of the device from its credentials and set the topics,
+// * returns true when the topics changed
+bool authenticate() {
+    lastAuthMs = millis();
+    String auth = "{\"password\":\"" + A.password + "\",\"deviceID\":\"" + A.deviceID + "\"}";
+
+    http.begin("http://" + String(Rest_ip) + "/authendevice"); // Specify destination for HTTP request
+    http.addHeader("Content-Type", "application/json"); // Specify content-type header
+
+    int httpResponseCode = http.POST(auth); // Send the actual POST request
+    Serial.println("HTTP Response Code: " + String(httpResponseCode));
+    if (httpResponseCode != 200) {
+        Serial.print("Error code: ");
+        Serial.println(httpResponseCode);
+        http.end();
+        return false;
     }
-    Serial.println();
+    String response = http.getString(); // Get the response to the request
+    http.end(); // Free resources
+    Serial.println("Response: " + response); // Print return value
+
+    String userID = jsonValue(response, "userID");
+    if (userID.isEmpty() || userID == A.userID) {
+        return false;
+    }
+    Serial.println("UserID: " + userID);
+    A.userID = userID;
+
+    // * per device topics
+    TELEMETRY_TOPIC = "noa/" + A.userID + "/" + A.deviceID + "/telemetry";
+    STATUS_TOPIC = "noa/" + A.userID + "/" + A.deviceID + "/status";
+    CMD_TOPIC = "noa/" + A.userID + "/" + A.deviceID + "/cmd";
+    ACK_TOPIC = "noa/" + A.userID + "/" + A.deviceID + "/ack";
+    return true;
+}
+
+// * connect to mqtt with the device credentials and an offline last will, then announce online and listen for commands
+void mqttConnect() {
+    bool connected = client.connect(
+        A.deviceID.c_str(),
+        (MQTT_USER.isEmpty() ? A.deviceID.c_str() : MQTT_USER.c_str()),
+        (MQTT_PASS.isEmpty() ? A.password.c_str() : MQTT_PASS.c_str()),
+        STATUS_TOPIC.c_str(), 1, true, "offline"
+    );
+    if (!connected) {
+        Serial.println("MQTT connect failed, state: " + String(client.state()));
+        return;
+    }
+    client.publish(STATUS_TOPIC.c_str(), "online", true);
+    client.subscribe(CMD_TOPIC.c_str(), 1);
 }
 
 String toJson(DataSchema g){
     String jsonString = "{";
-<<<<<<< HEAD
     String data = "{";
     data += "\"DeviceAddress\":\"" + String(A.deviceID) + "\",";
     data += "\"X\":{";
@@ -106,35 +244,6 @@ String toJson(DataSchema g){
     jsonString += "\"data\":" + data;
     jsonString += "}";
     jsonString.replace(" ", ""); // Remove spaces
-=======
-    jsonString += "\"DeviceAddress\":\"" + String(DeviceADDR) + "\",";
-    jsonString += "\"X\":{";
-    jsonString += "\"Acceleration\":" + String(g.X.Acceleration) + ",";
-    jsonString += "\"VelocityAngular\":" + String(g.X.VelocityAngular) + ",";
-    jsonString += "\"VibrationSpeed\":" + String(g.X.VibrationSpeed) + ",";
-    jsonString += "\"VibrationAngle\":" + String(g.X.VibrationAngle) + ",";
-    jsonString += "\"VibrationDisplacement\":" + String(g.X.VibrationDisplacement) + ",";
-    jsonString += "\"Frequency\":" + String(g.X.Frequency);
-    jsonString += "},";
-    jsonString += "\"Y\":{";
-    jsonString += "\"Acceleration\":" + String(g.Y.Acceleration) + ",";
-    jsonString += "\"VelocityAngular\":" + String(g.Y.VelocityAngular) + ",";
-    jsonString += "\"VibrationSpeed\":" + String(g.Y.VibrationSpeed) + ",";
-    jsonString += "\"VibrationAngle\":" + String(g.Y.VibrationAngle) + ",";
-    jsonString += "\"VibrationDisplacement\":" + String(g.Y.VibrationDisplacement) + ",";\
-    jsonString += "\"Frequency\":" + String(g.Y.Frequency);
-    jsonString += "},";
-    jsonString += "\"Z\":{";
-    jsonString += "\"Acceleration\":" + String(g.Z.Acceleration) + ",";
-    jsonString += "\"VelocityAngular\":" + String(g.Z.VelocityAngular) + ",";
-    jsonString += "\"VibrationSpeed\":" + String(g.Z.VibrationSpeed) + ",";
-    jsonString += "\"VibrationAngle\":" + String(g.Z.VibrationAngle) + ",";
-    jsonString += "\"VibrationDisplacement\":" + String(g.Z.VibrationDisplacement) + ",";
-    jsonString += "\"Frequency\":" + String(g.Z.Frequency);
-    jsonString += "},";
-    jsonString += "\"Temperature\":" + String(g.Temperature);
-    jsonString += "}";
->>>>>>> Final_BN
 
     return jsonString;
 }
@@ -194,27 +303,24 @@ void task1(void *pvParameters) {
         if (WiFi.status() == WL_CONNECTED) {
             String jsonString = toJson(D);
 
-            // * mqtt pub
+            // * mqtt pub, with the topics of the new owner once the device is transferred
+            if (millis() - lastAuthMs > REAUTH_INTERVAL_MS && authenticate() && client.connected()) {
+                client.disconnect();
+            }
             if (!client.connected()) {
-<<<<<<< HEAD
-                client.connect(A.deviceID.c_str(), (MQTT_USER.isEmpty() ? mqtt_uname : MQTT_USER.c_str()), (MQTT_PASS.isEmpty() ? mqtt_pass : MQTT_PASS.c_str()));
-=======
-                client.connect(DeviceADDR.c_str(), (MQTT_USER.isEmpty() ? mqtt_uname : MQTT_USER.c_str()), (MQTT_PASS.isEmpty() ? mqtt_pass : MQTT_PASS.c_str()));
->>>>>>> Final_BN
+                mqttConnect();
             }
 
-            client.publish("vibration", jsonString.c_str());
+            client.publish(TELEMETRY_TOPIC.c_str(), jsonString.c_str());
+            client.loop(); // receive commands
             Serial.println("Data sent to MQTT");
+            delay(SAMPLE_DELAY_MS);
             
         } else {
             Serial.println("WiFi Disconnected!");
 
             //  reconnect
-<<<<<<< HEAD
             WiFi.begin(WIFI_SSID, WIFI_PASSWORD);
-=======
-            WiFi.begin(SSID, PASSWORD);
->>>>>>> Final_BN
             Serial.print("Reconnecting to WiFi");
             while (WiFi.waitForConnectResult() != WL_CONNECTED) {
                 Serial.print(".");
@@ -228,48 +334,6 @@ void task1(void *pvParameters) {
 
 void task2(void *pvParameters) {
     for (;;) {
-<<<<<<< HEAD
-=======
-        // if (WiFi.status() == WL_CONNECTED) {
-        //     String jsonString = toJson(D);
-
-        //     // http.begin("http://172.20.10.3:8000/store");
-        //     // http.addHeader("Content-Type", "application/json");
-
-        //     // int httpResponseCode = http.POST(jsonString);
-
-        //     // if (httpResponseCode > 0) {
-        //     //     String response = http.getString();
-        //     //     Serial.println(httpResponseCode);
-        //     //     Serial.println(response);
-        //     // } else {
-
-        //     //     Serial.println("Error on sending POST request");
-        //     // }
-
-
-        //     // * mqtt pub
-        //     if (!client.connected()) {
-        //         client.connect("ESP32Client", mqtt_uname, mqtt_pass);
-        //     }
-
-        //     client.publish("vibration", jsonString.c_str());
-        //     Serial.println("Data sent to MQTT");
-
-        //     delay(50);
-        // } else {
-        //     Serial.println("WiFi Disconnected!");
-
-        //     //  reconnect
-        //     WiFi.begin(SSID, PASSWORD);
-        //     if (WiFi.waitForConnectResult() != WL_CONNECTED) {
-        //         Serial.println("WiFi Failed!");
-        //         while (1) {
-        //             delay(100);
-        //         }
-        //     }
-        // }
->>>>>>> Final_BN
     }
 }
 
@@ -326,7 +390,6 @@ void setup() {
         line.trim();
 
         if (line.startsWith("DEVICE_ADDR=")) {
-<<<<<<< HEAD
             A.deviceID = stringGuard(line.substring(12));
         } else if (line.startsWith("EMAIL=")) {
             A.email = stringGuard(line.substring(6));
@@ -336,13 +399,6 @@ void setup() {
             WIFI_SSID = stringGuard(line.substring(10));
         } else if (line.startsWith("WIFI_PASSWORD=")) {
             WIFI_PASSWORD = stringGuard(line.substring(14));
-=======
-            DeviceADDR = stringGuard(line.substring(12));
-        } else if (line.startsWith("SSID=")) {
-            SSID = stringGuard(line.substring(5));
-        } else if (line.startsWith("PASSWORD=")) {
-            PASSWORD = stringGuard(line.substring(9));
->>>>>>> Final_BN
         } else if (line.startsWith("MQTT_SERVER=")) {
             MQTT_SERVER = stringGuard(line.substring(12));
         } else if (line.startsWith("MQTT_USER=")) {
@@ -360,28 +416,17 @@ void setup() {
     
     Serial.println("Loaded configuration");
 
-<<<<<<< HEAD
-    while (A.deviceID.isEmpty() || A.email.isEmpty() || A.password.isEmpty()) {
+    while (A.deviceID.isEmpty() || A.password.isEmpty()) {
         Serial.println("Missing configuration data in file");
         Serial.println("Please check the config file and restart the device.");
         delay(100);
     }
     Serial.println("Configuration data loaded successfully");
     Serial.println("Device ID: " + A.deviceID);
-    Serial.println("Email: " + A.email);
     Serial.println("Password: " + String(A.password.length(), '*'));
 
     // * setup WIFI
     WiFi.begin(WIFI_SSID, WIFI_PASSWORD);
-=======
-    while (DeviceADDR.isEmpty()) {
-        Serial.println("Device Address is empty, please set it first");
-        delay(100);
-    }
-
-    // * setup WIFI
-    WiFi.begin(SSID, PASSWORD);
->>>>>>> Final_BN
     Serial.print("Connecting to WiFi");
     while (WiFi.status() != WL_CONNECTED) {
         Serial.print(".");
@@ -392,34 +437,8 @@ void setup() {
     Serial.print("IP Address: ");
     Serial.println(WiFi.localIP());
 
-<<<<<<< HEAD
-    String auth = "{\"email\":\"" + A.email + "\",\"password\":\"" + A.password + "\",\"deviceID\":\"" + A.deviceID + "\"}";
-    
-    // send to server
-    http.begin("http://" + String(Rest_ip) + "/authendevice"); // Specify destination for HTTP request
-    http.addHeader("Content-Type", "application/json"); // Specify content-type header
-
-    int httpResponseCode = http.POST(auth); // Send the actual POST request
-    Serial.println("HTTP Response Code: " + String(httpResponseCode));
-    if (httpResponseCode > 0) {
-        String response = http.getString(); // Get the response to the request
-        Serial.println("Response: " + response); // Print return value
-
-        // * get userID
-        int startIndex = response.indexOf("userID") + 9; // Find the index of "userID" and add 9 to skip to the value
-        int endIndex = response.indexOf("\"", startIndex);
-        String userID = response.substring(startIndex, endIndex);
-        Serial.println("UserID: " + userID);
-
-        A.userID = userID;
-    } else {
-        Serial.print("Error code: ");
-        Serial.println(httpResponseCode);
-    }
-    http.end(); // Free resources
+    authenticate();
 
-=======
->>>>>>> Final_BN
     // * connect to mqtt
     client.setServer((MQTT_SERVER.isEmpty() ? mqtt_server : MQTT_SERVER.c_str()), MQTT_PORT);
     client.setCallback(callback);